@@ -0,0 +1,109 @@
+package generate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+	"github.com/openshift-kni/commatrix/pkg/consts"
+	firewallapply "github.com/openshift-kni/commatrix/pkg/firewall-apply"
+)
+
+var (
+	applyLong = templates.LongDesc(`
+              Apply the MachineConfigs generated with '--format mc' to the cluster.
+
+              The nftables node disruption policy is merged into the cluster MachineConfiguration
+              first, keeping any existing unit and file policies, so that rolling out the firewall
+              rules reloads nftables instead of draining and rebooting the nodes. Then the
+              98-nftables-commatrix-<pool> MachineConfigs are created, or updated if they exist.
+	`)
+	applyExample = templates.Examples(`
+			 # Generate the MachineConfigs and apply them:
+			 oc commatrix generate --format mc --destDir /path/to/dir
+			 oc commatrix apply --destDir /path/to/dir
+
+			 # Show the changes against the live objects without persisting them:
+			 oc commatrix apply --destDir /path/to/dir --dry-run=server
+	`)
+)
+
+type ApplyOptions struct {
+	destDir string
+	dryRun  string
+	debug   bool
+	cs      *client.ClientSet
+	genericiooptions.IOStreams
+}
+
+func NewCmdCommatrixApply(cs *client.ClientSet, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ApplyOptions{cs: cs, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:     "apply",
+		Short:   "Apply the generated firewall MachineConfigs to the cluster.",
+		Long:    applyLong,
+		Example: applyExample,
+		Args:    cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := ValidateApply(o); err != nil {
+				return err
+			}
+
+			return RunApply(c.Context(), o)
+		},
+	}
+	cmd.Flags().StringVar(&o.destDir, "destDir", consts.CommatrixDefaultDir, "Dir containing the MachineConfig files generated with --format mc")
+	cmd.Flags().StringVar(&o.dryRun, "dry-run", firewallapply.DryRunNone, "Must be \"none\", \"server\", or \"client\". With server or client, print a diff against the live objects without persisting changes")
+	cmd.Flags().Lookup("dry-run").NoOptDefVal = firewallapply.DryRunClient
+	cmd.Flags().BoolVar(&o.debug, "debug", false, "Debug logs")
+
+	return cmd
+}
+
+func ValidateApply(o *ApplyOptions) error {
+	if !slices.Contains(firewallapply.ValidDryRunModes, o.dryRun) {
+		return fmt.Errorf("invalid dry-run value '%s', valid options are: %s",
+			o.dryRun, strings.Join(firewallapply.ValidDryRunModes, ", "))
+	}
+
+	return nil
+}
+
+func RunApply(ctx context.Context, o *ApplyOptions) error {
+	if o.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	mcs, err := firewallapply.LoadMachineConfigs(o.destDir)
+	if err != nil {
+		return err
+	}
+
+	applier := firewallapply.New(o.cs.Client, o.dryRun, o.Out)
+
+	// The node disruption policy must be in place before the MachineConfigs roll out,
+	// otherwise the MCO drains and reboots the nodes.
+	log.Debug("Merging the node disruption policy")
+	if err := applier.ApplyNodeDisruptionPolicy(ctx); err != nil {
+		return err
+	}
+
+	for _, mc := range mcs {
+		log.Debugf("Applying MachineConfig %s", mc.Name)
+		if err := applier.ApplyMachineConfig(ctx, mc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
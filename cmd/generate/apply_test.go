@@ -0,0 +1,76 @@
+package generate
+
+import (
+	"context"
+	"testing"
+
+	machineconfigurationv1 "github.com/openshift/api/machineconfiguration/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+)
+
+func TestCommatrixApply(t *testing.T) {
+	input := writeDiffTestFile(t, t.TempDir(), "matrix.csv", diffTestPrimaryCSV)
+	destDir := t.TempDir()
+
+	streams, _, _, _ := genericiooptions.NewTestIOStreams()
+	convert := NewCmd(nil, streams)
+	convert.SetArgs([]string{"convert", input, "--format", "mc", "--ocp-version", "4.19", "--destDir", destDir})
+	require.NoError(t, convert.Execute())
+
+	newClientSet := func(t *testing.T) *client.ClientSet {
+		sch := runtime.NewScheme()
+		require.NoError(t, operatorv1.AddToScheme(sch))
+		require.NoError(t, machineconfigurationv1.AddToScheme(sch))
+		cluster := &operatorv1.MachineConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+		return &client.ClientSet{Client: fake.NewClientBuilder().WithScheme(sch).WithObjects(cluster).Build()}
+	}
+
+	t.Run("server dry run prints a diff and does not create objects", func(t *testing.T) {
+		cs := newClientSet(t)
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(cs, streams)
+		cmd.SetArgs([]string{"apply", "--destDir", destDir, "--dry-run=server"})
+		require.NoError(t, cmd.Execute())
+
+		assert.Contains(t, out.String(), "+++ desired/machineconfiguration/cluster")
+		assert.Contains(t, out.String(), "machineconfig/98-nftables-commatrix-master created (server dry run)")
+
+		mc := &machineconfigurationv1.MachineConfig{}
+		err := cs.Get(context.TODO(), k8stypes.NamespacedName{Name: "98-nftables-commatrix-master"}, mc)
+		require.Error(t, err)
+	})
+
+	t.Run("apply creates the MachineConfigs and merges the node disruption policy", func(t *testing.T) {
+		cs := newClientSet(t)
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(cs, streams)
+		cmd.SetArgs([]string{"apply", "--destDir", destDir})
+		require.NoError(t, cmd.Execute())
+		assert.Equal(t, "machineconfiguration/cluster configured\nmachineconfig/98-nftables-commatrix-master created\n", out.String())
+
+		mc := &machineconfigurationv1.MachineConfig{}
+		require.NoError(t, cs.Get(context.TODO(), k8stypes.NamespacedName{Name: "98-nftables-commatrix-master"}, mc))
+		assert.Equal(t, "master", mc.Labels["machineconfiguration.openshift.io/role"])
+
+		mcfg := &operatorv1.MachineConfiguration{}
+		require.NoError(t, cs.Get(context.TODO(), k8stypes.NamespacedName{Name: "cluster"}, mcfg))
+		assert.Len(t, mcfg.Spec.NodeDisruptionPolicy.Units, 1)
+		assert.Len(t, mcfg.Spec.NodeDisruptionPolicy.Files, 1)
+	})
+
+	t.Run("invalid dry-run value is rejected", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(newClientSet(t), streams)
+		cmd.SetArgs([]string{"apply", "--destDir", destDir, "--dry-run=always"})
+		require.Error(t, cmd.Execute())
+	})
+}
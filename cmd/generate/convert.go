@@ -0,0 +1,165 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift-kni/commatrix/pkg/consts"
+	"github.com/openshift-kni/commatrix/pkg/types"
+	"github.com/openshift-kni/commatrix/pkg/utils"
+)
+
+var (
+	convertLong = templates.LongDesc(`
+              Convert a saved communication matrix into another output format without cluster access.
+
+              The input matrix can be a csv, json or yaml file, e.g. a matrix produced by 'generate'
+              and edited by hand. Firewall formats (nft, nft-json, butane, mc, firewalld, iptables,
+              ingressnodefirewall) are written per node group.
+              Butane and MachineConfig output need the OpenShift version, which is taken from
+              --ocp-version instead of being discovered from the cluster.
+	`)
+	convertExample = templates.Examples(`
+			 # Convert an edited CSV matrix into nftables rules:
+			 oc commatrix convert communication-matrix.csv --format nft
+
+			 # Convert a matrix into MachineConfig CRs for OpenShift 4.19:
+			 oc commatrix convert communication-matrix.json --format mc --ocp-version 4.19 --destDir /path/to/dir
+
+			 # Convert a matrix into nftables rules with one commented rule per flow:
+			 oc commatrix convert communication-matrix.csv --format nft --nft-rule-per-flow
+
+			 # Convert a matrix into firewalld zones:
+			 oc commatrix convert communication-matrix.csv --format firewalld
+
+			 # Convert a file whose extension does not match its content:
+			 oc commatrix convert matrix.txt --input-format csv --format yaml
+	`)
+)
+
+type ConvertOptions struct {
+	inputPath          string
+	inputFormat        string
+	format             string
+	destDir            string
+	ocpVersion         string
+	nft                nftFlags
+	customNodeGroupRaw []string
+	customNodeGroups   map[string]labels.Selector
+	genericiooptions.IOStreams
+}
+
+func NewCmdCommatrixConvert(streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ConvertOptions{IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:         "convert INPUT",
+		Short:       "Convert a saved communication matrix into another output format.",
+		Long:        convertLong,
+		Example:     convertExample,
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{offlineAnnotation: "true"},
+		RunE: func(c *cobra.Command, args []string) error {
+			o.inputPath = args[0]
+
+			if err := ValidateConvert(o); err != nil {
+				return err
+			}
+
+			if err := CompleteConvert(o); err != nil {
+				return err
+			}
+
+			return RunConvert(o)
+		},
+	}
+	cmd.Flags().StringVar(&o.inputFormat, "input-format", "", "Format of the input matrix file (json,yaml,csv). Detected from the file extension when empty")
+	cmd.Flags().StringVar(&o.format, "format", types.FormatCSV, "Desired format (json,yaml,csv,nft,nft-json,butane,mc,firewalld,iptables,ingressnodefirewall)")
+	cmd.Flags().StringVar(&o.destDir, "destDir", "", "Output files dir (default communication-matrix)")
+	cmd.Flags().StringVar(&o.ocpVersion, "ocp-version", "", "OpenShift version (e.g. 4.19) used for butane and mc output")
+	o.nft.addFlags(cmd)
+	cmd.Flags().StringArrayVar(&o.customNodeGroupRaw, "custom-node-group", nil,
+		"Label selector of a custom node group of the matrix, used as the nodeSelector of its ingressnodefirewall CR "+
+			"(format: groupName=labelSelector, e.g. mc-ingress=node-role.kubernetes.io/ingress). Repeatable.")
+
+	return cmd
+}
+
+func ValidateConvert(o *ConvertOptions) error {
+	if !slices.Contains(validFormats, o.format) {
+		return fmt.Errorf("invalid format '%s', valid options are: %s",
+			o.format, strings.Join(validFormats, ", "))
+	}
+
+	if o.inputFormat != "" && !slices.Contains(validCustomEntriesFormats, o.inputFormat) {
+		return fmt.Errorf("invalid input format '%s', valid options are: %s",
+			o.inputFormat, strings.Join(validCustomEntriesFormats, ", "))
+	}
+
+	if err := o.nft.validate(o.format); err != nil {
+		return err
+	}
+
+	if o.format == types.FormatButane || o.format == types.FormatMC {
+		if o.ocpVersion == "" {
+			return fmt.Errorf("--ocp-version is required for format '%s'", o.format)
+		}
+		if _, err := utils.NormalizeClusterVersion(o.ocpVersion); err != nil {
+			return fmt.Errorf("invalid --ocp-version: %w", err)
+		}
+	}
+
+	parsed, err := parseCustomNodeGroups(o.customNodeGroupRaw)
+	if err != nil {
+		return err
+	}
+	o.customNodeGroups = parsed
+
+	return nil
+}
+
+func CompleteConvert(o *ConvertOptions) error {
+	if o.ocpVersion != "" {
+		version, err := utils.NormalizeClusterVersion(o.ocpVersion)
+		if err != nil {
+			return fmt.Errorf("invalid --ocp-version: %w", err)
+		}
+		o.ocpVersion = version
+	}
+
+	if o.destDir == "" {
+		o.destDir = consts.CommatrixDefaultDir
+	}
+	if err := os.MkdirAll(o.destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory '%s': %w", o.destDir, err)
+	}
+
+	return nil
+}
+
+func RunConvert(o *ConvertOptions) error {
+	matrix, err := loadMatrixFile(o.inputPath, o.inputFormat)
+	if err != nil {
+		return err
+	}
+
+	// Firewall formats are rendered from a single squashed range list, as in generate.
+	if formatRequiresMerge(&GenerateOptions{format: o.format}) {
+		matrix.DynamicRanges.Squash()
+	}
+
+	if err := matrix.WriteMatrixToFileByType(utils.NewOffline(o.ocpVersion),
+		fileNamePrefix(o.format, consts.CommatrixFileNamePrefix), o.format, o.destDir,
+		append(o.nft.options(), types.WithNodeGroupSelectors(o.customNodeGroups))...); err != nil {
+		return fmt.Errorf("failed to write matrix to file: %w", err)
+	}
+
+	fmt.Fprintf(o.Out, "Matrix written to %s\n", o.destDir)
+	return nil
+}
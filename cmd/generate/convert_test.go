@@ -0,0 +1,145 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func TestCommatrixConvert(t *testing.T) {
+	dir := t.TempDir()
+	input := writeDiffTestFile(t, dir, "matrix.csv", diffTestPrimaryCSV)
+
+	t.Run("converts a csv matrix to json", func(t *testing.T) {
+		destDir := t.TempDir()
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "json", "--destDir", destDir})
+		require.NoError(t, cmd.Execute())
+
+		content, err := os.ReadFile(filepath.Join(destDir, "communication-matrix.json"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `"service": "node-exporter"`)
+	})
+
+	t.Run("converts a csv matrix to nftables per node group", func(t *testing.T) {
+		destDir := t.TempDir()
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "nft", "--destDir", destDir})
+		require.NoError(t, cmd.Execute())
+
+		content, err := os.ReadFile(filepath.Join(destDir, "communication-matrix-master.nft"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "tcp dport { 22, 9100 } accept")
+	})
+
+	t.Run("converts a csv matrix to nftables JSON rules per node group", func(t *testing.T) {
+		destDir := t.TempDir()
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "nft-json", "--destDir", destDir})
+		require.NoError(t, cmd.Execute())
+
+		content, err := os.ReadFile(filepath.Join(destDir, "communication-matrix-master.json"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `"nftables": [`)
+		assert.Contains(t, string(content), `"name": "openshift_filter"`)
+	})
+
+	t.Run("converts a csv matrix to firewalld zones per node group", func(t *testing.T) {
+		destDir := t.TempDir()
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "firewalld", "--destDir", destDir})
+		require.NoError(t, cmd.Execute())
+
+		content, err := os.ReadFile(filepath.Join(destDir, "commatrix-master.xml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "<short>OpenShift master</short>")
+		assert.Contains(t, string(content), `<port protocol="tcp" port="9100"/>`)
+	})
+
+	t.Run("converts a csv matrix to iptables-restore files per node group", func(t *testing.T) {
+		destDir := t.TempDir()
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "iptables", "--destDir", destDir})
+		require.NoError(t, cmd.Execute())
+
+		for _, ext := range []string{"iptables", "ip6tables"} {
+			content, err := os.ReadFile(filepath.Join(destDir, "communication-matrix-master."+ext))
+			require.NoError(t, err)
+			assert.Contains(t, string(content), "-A OPENSHIFT -p tcp -m multiport --dports 22,9100 -j ACCEPT")
+		}
+	})
+
+	t.Run("converts a csv matrix to IngressNodeFirewall CRs per node group", func(t *testing.T) {
+		destDir := t.TempDir()
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "ingressnodefirewall", "--destDir", destDir,
+			"--custom-node-group", "master=node-role.kubernetes.io/control-plane"})
+		require.NoError(t, cmd.Execute())
+
+		content, err := os.ReadFile(filepath.Join(destDir, "ingressnodefirewall-master.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "kind: IngressNodeFirewall")
+		assert.Contains(t, string(content), "key: node-role.kubernetes.io/control-plane")
+		assert.Contains(t, string(content), "ports: \"9100\"")
+	})
+
+	t.Run("rejects invalid custom node groups", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "ingressnodefirewall", "--destDir", t.TempDir(),
+			"--custom-node-group", "master"})
+		require.Error(t, cmd.Execute())
+	})
+
+	t.Run("uses --ocp-version for butane output", func(t *testing.T) {
+		destDir := t.TempDir()
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "butane", "--ocp-version", "4.19.2", "--destDir", destDir})
+		require.NoError(t, cmd.Execute())
+
+		content, err := os.ReadFile(filepath.Join(destDir, "butane-master.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "version: 4.19.0")
+	})
+
+	t.Run("butane output requires --ocp-version", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "butane", "--destDir", t.TempDir()})
+		err := cmd.Execute()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--ocp-version is required")
+	})
+
+	t.Run("nft flags are rejected with formats they do not apply to", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "firewalld", "--nft-egress-drop", "--destDir", t.TempDir()})
+		err := cmd.Execute()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--nft-egress-drop only applies to")
+	})
+
+	t.Run("invalid output format is rejected", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"convert", input, "--format", "xml", "--destDir", t.TempDir()})
+		require.Error(t, cmd.Execute())
+	})
+
+	t.Run("command does not require a cluster", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		assert.False(t, RequiresCluster(NewCmdCommatrixConvert(streams)))
+	})
+}
@@ -0,0 +1,139 @@
+package generate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift-kni/commatrix/pkg/errhandler"
+	matrixdiff "github.com/openshift-kni/commatrix/pkg/matrix-diff"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+const diffFormatText = "text"
+
+var (
+	diffLong = templates.LongDesc(`
+              Compare two saved communication matrices without cluster access.
+
+              Entries present only in the primary matrix are marked with '+', entries present only
+              in the secondary matrix are marked with '-'. The command exits with code 2 when the
+              matrices differ, so it can be used to gate pipelines.
+	`)
+	diffExample = templates.Examples(`
+			 # Compare this release's matrix with last release's matrix:
+			 oc commatrix diff new/communication-matrix.json old/communication-matrix.csv
+
+			 # Print the diff as JSON:
+			 oc commatrix diff new.csv old.csv --format json
+
+			 # Compare files whose extension does not match their content:
+			 oc commatrix diff new.txt old.txt --primary-format csv --secondary-format csv
+	`)
+
+	validDiffFormats = []string{
+		diffFormatText,
+		types.FormatJSON,
+		types.FormatYAML,
+	}
+)
+
+type DiffOptions struct {
+	primaryPath     string
+	secondaryPath   string
+	primaryFormat   string
+	secondaryFormat string
+	format          string
+	genericiooptions.IOStreams
+}
+
+func NewCmdCommatrixDiff(streams genericiooptions.IOStreams) *cobra.Command {
+	o := &DiffOptions{IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:         "diff PRIMARY SECONDARY",
+		Short:       "Compare two saved communication matrices.",
+		Long:        diffLong,
+		Example:     diffExample,
+		Args:        cobra.ExactArgs(2),
+		Annotations: map[string]string{offlineAnnotation: "true"},
+		RunE: func(c *cobra.Command, args []string) error {
+			o.primaryPath = args[0]
+			o.secondaryPath = args[1]
+
+			if err := ValidateDiff(o); err != nil {
+				return err
+			}
+
+			return RunDiff(o)
+		},
+	}
+	cmd.Flags().StringVar(&o.primaryFormat, "primary-format", "", "Format of the primary matrix file (json,yaml,csv). Detected from the file extension when empty")
+	cmd.Flags().StringVar(&o.secondaryFormat, "secondary-format", "", "Format of the secondary matrix file (json,yaml,csv). Detected from the file extension when empty")
+	cmd.Flags().StringVar(&o.format, "format", diffFormatText, "Output format of the diff (text,json,yaml)")
+
+	return cmd
+}
+
+func ValidateDiff(o *DiffOptions) error {
+	if !slices.Contains(validDiffFormats, o.format) {
+		return fmt.Errorf("invalid format '%s', valid options are: %s",
+			o.format, strings.Join(validDiffFormats, ", "))
+	}
+
+	for _, format := range []string{o.primaryFormat, o.secondaryFormat} {
+		if format != "" && !slices.Contains(validCustomEntriesFormats, format) {
+			return fmt.Errorf("invalid matrix format '%s', valid options are: %s",
+				format, strings.Join(validCustomEntriesFormats, ", "))
+		}
+	}
+
+	return nil
+}
+
+func RunDiff(o *DiffOptions) error {
+	primary, err := loadMatrixFile(o.primaryPath, o.primaryFormat)
+	if err != nil {
+		return err
+	}
+
+	secondary, err := loadMatrixFile(o.secondaryPath, o.secondaryFormat)
+	if err != nil {
+		return err
+	}
+
+	diff := matrixdiff.Generate(primary, secondary, matrixdiff.WithAttributeChanges())
+
+	var out []byte
+	switch o.format {
+	case types.FormatJSON:
+		out, err = diff.ToJSON()
+	case types.FormatYAML:
+		out, err = diff.ToYAML()
+	default:
+		var diffStr string
+		diffStr, err = diff.String()
+		out = []byte(diffStr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to print matrix diff: %w", err)
+	}
+
+	if _, err := fmt.Fprintln(o.Out, strings.TrimSuffix(string(out), "\n")); err != nil {
+		return err
+	}
+
+	if diff.HasDifferences() {
+		return &errhandler.ExitError{
+			Code: errhandler.ExitCodeMatrixDiff,
+			Err: fmt.Errorf("matrices differ: %d entries only in %s, %d entries only in %s, %d entries changed",
+				len(diff.GetUniquePrimary().Ports), o.primaryPath, len(diff.GetUniqueSecondary().Ports), o.secondaryPath,
+				len(diff.GetChanged())),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package generate
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+
+	"github.com/openshift-kni/commatrix/pkg/errhandler"
+	matrixdiff "github.com/openshift-kni/commatrix/pkg/matrix-diff"
+)
+
+const (
+	diffTestPrimaryCSV = `Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional
+Ingress,TCP,22,Host system service,sshd,,,master,true
+Ingress,TCP,9100,openshift-monitoring,node-exporter,node-exporter,node-exporter,master,false
+`
+	diffTestSecondaryJSON = `{
+    "Ports": [
+        {"direction": "Ingress", "protocol": "TCP", "port": 22, "namespace": "Host system service", "service": "sshd", "nodeGroup": "master", "optional": true},
+        {"direction": "Ingress", "protocol": "UDP", "port": 111, "namespace": "Host system service", "service": "rpcbind", "nodeGroup": "master", "optional": true}
+    ]
+}`
+)
+
+func writeDiffTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestCommatrixDiff(t *testing.T) {
+	dir := t.TempDir()
+	primary := writeDiffTestFile(t, dir, "primary.csv", diffTestPrimaryCSV)
+	secondary := writeDiffTestFile(t, dir, "secondary.json", diffTestSecondaryJSON)
+	noExt := writeDiffTestFile(t, dir, "primary.txt", diffTestPrimaryCSV)
+
+	t.Run("identical matrices do not return an error", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"diff", primary, primary})
+		require.NoError(t, cmd.Execute())
+		assert.Contains(t, out.String(), "Ingress,TCP,22,Host system service,sshd,,,master,true")
+		assert.NotContains(t, out.String(), "+ ")
+		assert.NotContains(t, out.String(), "- ")
+	})
+
+	t.Run("different matrices print the diff and exit with the diff code", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"diff", primary, secondary})
+		err := cmd.Execute()
+		require.Error(t, err)
+
+		var exitErr *errhandler.ExitError
+		require.True(t, errors.As(err, &exitErr))
+		assert.Equal(t, errhandler.ExitCodeMatrixDiff, exitErr.Code)
+
+		assert.Contains(t, out.String(), "+ Ingress,TCP,9100,openshift-monitoring,node-exporter,node-exporter,node-exporter,master,false")
+		assert.Contains(t, out.String(), "- Ingress,UDP,111,Host system service,rpcbind,,,master,true")
+		assert.Contains(t, out.String(), "\nIngress,TCP,22,Host system service,sshd,,,master,true")
+	})
+
+	t.Run("json output is machine readable", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"diff", primary, secondary, "--format", "json"})
+		require.Error(t, cmd.Execute())
+
+		var report matrixdiff.Report
+		require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+		require.Len(t, report.UniquePrimary, 1)
+		assert.Equal(t, 9100, report.UniquePrimary[0].Port)
+		require.Len(t, report.UniqueSecondary, 1)
+		assert.Equal(t, 111, report.UniqueSecondary[0].Port)
+		require.Len(t, report.Shared, 1)
+		assert.Equal(t, 22, report.Shared[0].Port)
+	})
+
+	t.Run("entries with different source CIDRs are changed", func(t *testing.T) {
+		restricted := writeDiffTestFile(t, dir, "restricted.csv", `Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional,SourceCIDRs
+Ingress,TCP,2379,openshift-etcd,etcd,etcd,etcd,master,false,10.0.0.0/24
+`)
+		unrestricted := writeDiffTestFile(t, dir, "unrestricted.csv", `Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional,SourceCIDRs
+Ingress,TCP,2379,openshift-etcd,etcd,etcd,etcd,master,false,
+`)
+
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"diff", restricted, unrestricted})
+		err := cmd.Execute()
+		require.Error(t, err)
+
+		var exitErr *errhandler.ExitError
+		require.True(t, errors.As(err, &exitErr))
+		assert.Equal(t, errhandler.ExitCodeMatrixDiff, exitErr.Code)
+		assert.Contains(t, err.Error(), "1 entries changed")
+		assert.Contains(t, out.String(), "+ Ingress,TCP,2379,openshift-etcd,etcd,etcd,etcd,master,false,,10.0.0.0/24,,,,")
+		assert.Contains(t, out.String(), "- Ingress,TCP,2379,openshift-etcd,etcd,etcd,etcd,master,false,,,,,,")
+
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		cmd = NewCmd(nil, streams)
+		cmd.SetArgs([]string{"diff", restricted, unrestricted, "--format", "json"})
+		require.Error(t, cmd.Execute())
+
+		var report matrixdiff.Report
+		require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+		assert.Empty(t, report.Shared)
+		require.Len(t, report.Changed, 1)
+		assert.Equal(t, []string{"10.0.0.0/24"}, []string(report.Changed[0].Primary.SourceCIDRs))
+		assert.Empty(t, report.Changed[0].Secondary.SourceCIDRs)
+	})
+
+	t.Run("a flow found on different interfaces is changed to unscoped", func(t *testing.T) {
+		scoped := writeDiffTestFile(t, dir, "scoped.csv", `Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional,Interface
+Ingress,TCP,9100,openshift-monitoring,node-exporter,node-exporter,node-exporter,worker,false,net1
+`)
+		twoInterfaces := writeDiffTestFile(t, dir, "two-interfaces.csv", `Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional,Interface
+Ingress,TCP,9100,openshift-monitoring,node-exporter,node-exporter,node-exporter,worker,false,net1
+Ingress,TCP,9100,openshift-monitoring,node-exporter,node-exporter,node-exporter,worker,false,ens4
+`)
+
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"diff", scoped, twoInterfaces})
+		require.Error(t, cmd.Execute())
+		assert.Contains(t, out.String(), "+ Ingress,TCP,9100,openshift-monitoring,node-exporter,node-exporter,node-exporter,worker,false,,,,,,net1")
+		assert.Contains(t, out.String(), "- Ingress,TCP,9100,openshift-monitoring,node-exporter,node-exporter,node-exporter,worker,false,,,,,,\n")
+	})
+
+	t.Run("explicit format overrides the file extension", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"diff", noExt, primary, "--primary-format", "csv"})
+		require.NoError(t, cmd.Execute())
+	})
+
+	t.Run("unknown extension without explicit format fails", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"diff", noExt, primary})
+		err := cmd.Execute()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to detect the format")
+	})
+
+	t.Run("invalid output format fails validation", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"diff", primary, secondary, "--format", "nft"})
+		err := cmd.Execute()
+		require.Error(t, err)
+		assert.Equal(t, "invalid format 'nft', valid options are: text, json, yaml", err.Error())
+	})
+}
+
+func TestRequiresCluster(t *testing.T) {
+	streams, _, _, _ := genericiooptions.NewTestIOStreams()
+	root := NewCmd(nil, streams)
+
+	diffCmd, _, err := root.Find([]string{"diff"})
+	require.NoError(t, err)
+	assert.False(t, RequiresCluster(diffCmd))
+
+	generateCmd, _, err := root.Find([]string{"generate"})
+	require.NoError(t, err)
+	assert.True(t, RequiresCluster(generateCmd))
+}
@@ -0,0 +1,130 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift-kni/commatrix/pkg/baseline"
+	matrixdocs "github.com/openshift-kni/commatrix/pkg/matrix-docs"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// defaultDocsDir is the directory of the documented matrices in the repository.
+const defaultDocsDir = "docs/stable"
+
+var (
+	docsLong = templates.LongDesc(`
+              Regenerate the documented communication matrices from matrices generated on several deployments.
+
+              Every input is a matrix file, optionally prefixed with the name of its deployment
+              (NAME=FILE). Without a name, the file name without its extension is used. The full
+              matrix of every deployment is written to raw/NAME.csv. Per node group, the entries
+              shared by all the deployments that have the group are written to unique/common-GROUP.csv,
+              and the remaining entries of every deployment to unique/NAME.csv.
+	`)
+	docsExample = templates.Examples(`
+			 # Regenerate docs/stable from the matrices generated on each documented deployment:
+			 oc commatrix docs aws=aws/communication-matrix.csv aws-sno=aws-sno/communication-matrix.csv \
+			   bm=bm/communication-matrix.csv none-sno=none-sno/communication-matrix.csv
+
+			 # Write the documented matrices to another directory:
+			 oc commatrix docs aws.csv bm.csv --destDir /path/to/dir
+	`)
+)
+
+type DocsOptions struct {
+	inputs  []string
+	destDir string
+	debug   bool
+	genericiooptions.IOStreams
+}
+
+func NewCmdCommatrixDocs(streams genericiooptions.IOStreams) *cobra.Command {
+	o := &DocsOptions{IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:         "docs [NAME=]FILE...",
+		Short:       "Regenerate the documented raw and unique communication matrices.",
+		Long:        docsLong,
+		Example:     docsExample,
+		Args:        cobra.MinimumNArgs(2),
+		Annotations: map[string]string{offlineAnnotation: "true"},
+		RunE: func(c *cobra.Command, args []string) error {
+			o.inputs = args
+
+			return RunDocs(o)
+		},
+	}
+	cmd.Flags().StringVar(&o.destDir, "destDir", defaultDocsDir, "Directory the raw and unique matrices are written to")
+	cmd.Flags().BoolVar(&o.debug, "debug", false, "Debug logs")
+
+	return cmd
+}
+
+func RunDocs(o *DocsOptions) error {
+	if o.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	matrices := map[string]*types.ComMatrix{}
+	for _, input := range o.inputs {
+		name, path := docsInput(input)
+		if _, ok := matrices[name]; ok {
+			return fmt.Errorf("duplicate deployment name %q", name)
+		}
+
+		matrix, err := loadDocsMatrix(path)
+		if err != nil {
+			return err
+		}
+		log.Debugf("Loaded %d entries of %s from %s", len(matrix.Ports), name, path)
+		matrices[name] = matrix
+	}
+
+	docs := matrixdocs.Generate(matrices)
+	if err := docs.Write(o.destDir); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(o.Out, "Wrote %d raw matrices and %d common node group matrices to %s\n",
+		len(docs.Raw), len(docs.Common), o.destDir)
+	return err
+}
+
+// docsInput splits a NAME=FILE argument. The name defaults to the file name without its extension.
+func docsInput(arg string) (string, string) {
+	if name, path, ok := strings.Cut(arg, "="); ok {
+		return name, path
+	}
+
+	return strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg)), arg
+}
+
+// loadDocsMatrix reads a matrix file. CSV files may use the legacy "Node Role" header of
+// the documented matrices.
+func loadDocsMatrix(path string) (*types.ComMatrix, error) {
+	format, err := formatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if format != types.FormatCSV {
+		return loadMatrixFile(path, format)
+	}
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	matrix, err := baseline.ParseDocCSV(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return matrix, nil
+}
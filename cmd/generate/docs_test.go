@@ -0,0 +1,59 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func TestCommatrixDocs(t *testing.T) {
+	dir := t.TempDir()
+	// The documented matrices use the legacy "Node Role" header.
+	aws := writeDiffTestFile(t, dir, "aws.csv", `Direction,Protocol,Port,Namespace,Service,Pod,Container,Node Role,Optional
+Ingress,TCP,6443,openshift-kube-apiserver,apiserver,kube-apiserver,kube-apiserver,master,false
+Ingress,TCP,9001,openshift-machine-config-operator,,,,master,false
+`)
+	bm := writeDiffTestFile(t, dir, "bm.yaml", `Ports:
+  - direction: Ingress
+    protocol: TCP
+    port: 6443
+    namespace: openshift-kube-apiserver
+    service: apiserver
+    pod: kube-apiserver
+    container: kube-apiserver
+    nodeGroup: master
+`)
+
+	t.Run("writes the raw and unique matrices", func(t *testing.T) {
+		destDir := filepath.Join(dir, "out")
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"docs", aws, "baremetal=" + bm, "--destDir", destDir})
+		require.NoError(t, cmd.Execute())
+		assert.Equal(t, "Wrote 2 raw matrices and 1 common node group matrices to "+destDir+"\n", out.String())
+
+		for _, file := range []string{"raw/aws.csv", "raw/baremetal.csv", "unique/aws.csv", "unique/baremetal.csv", "unique/common-master.csv"} {
+			assert.FileExists(t, filepath.Join(destDir, file))
+		}
+		unique, err := os.ReadFile(filepath.Join(destDir, "unique", "aws.csv"))
+		require.NoError(t, err)
+		assert.Contains(t, string(unique), ",9001,")
+		assert.NotContains(t, string(unique), ",6443,")
+	})
+
+	t.Run("fails on duplicate deployment names", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"docs", aws, "aws=" + bm, "--destDir", filepath.Join(dir, "dup")})
+		assert.ErrorContains(t, cmd.Execute(), `duplicate deployment name "aws"`)
+	})
+
+	t.Run("does not require a cluster", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		assert.False(t, RequiresCluster(NewCmdCommatrixDocs(streams)))
+	})
+}
@@ -0,0 +1,255 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+	"github.com/openshift-kni/commatrix/pkg/endpointslices"
+	"github.com/openshift-kni/commatrix/pkg/explain"
+	"github.com/openshift-kni/commatrix/pkg/mcp"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+var (
+	explainLong = templates.LongDesc(`
+              Explain where the entries of the communication matrix come from.
+
+              For a port, every source that contributed an entry is reported: the static entry
+              list, the Service, EndpointSlice, Pods and owner, the custom entries file and line,
+              and, with --host-open-ports, the listening sockets with their process and PID.
+              For a node, the rule that assigned its node group is reported as well, followed by
+              the sources of all the entries of that group.
+	`)
+	explainExample = templates.Examples(`
+			 # Explain why TCP port 9107 is open:
+			 oc commatrix explain --port 9107 --protocol TCP
+
+			 # Restrict the explanation to the worker node group:
+			 oc commatrix explain --port 9107 --protocol TCP --group worker
+
+			 # Explain the node group and the entries of a node:
+			 oc commatrix explain --node worker-0
+	`)
+)
+
+type ExplainOptions struct {
+	port      int
+	protocol  string
+	group     string
+	node      string
+	outFormat string
+	*GenerateOptions
+}
+
+func NewCmdCommatrixExplain(cs *client.ClientSet, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ExplainOptions{GenerateOptions: NewCommatrixOptions(streams, cs)}
+	cmd := &cobra.Command{
+		Use:     "explain",
+		Short:   "Explain the sources of a port or the node group of a node.",
+		Long:    explainLong,
+		Example: explainExample,
+		Args:    cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := ValidateExplain(o); err != nil {
+				return err
+			}
+
+			return RunExplain(o)
+		},
+	}
+	cmd.Flags().IntVar(&o.port, "port", 0, "Port to explain")
+	cmd.Flags().StringVar(&o.protocol, "protocol", "", "Protocol of the port (TCP,UDP,SCTP). All protocols when empty")
+	cmd.Flags().StringVar(&o.group, "group", "", "Only explain the entries of this node group")
+	cmd.Flags().StringVar(&o.node, "node", "", "Node to explain")
+	cmd.Flags().StringVar(&o.outFormat, "format", diffFormatText, "Output format (text,json,yaml)")
+	cmd.Flags().BoolVar(&o.debug, "debug", false, "Debug logs")
+	cmd.Flags().StringVar(&o.customEntriesPath, "customEntriesPath", "", "Add custom entries from a file to the matrix")
+	cmd.Flags().StringVar(&o.customEntriesFormat, "customEntriesFormat", "", "Set the format of the custom entries file (json,yaml,csv)")
+	cmd.Flags().BoolVar(&o.openPorts, "host-open-ports", false, "Add the host open ports to the explained sources")
+	cmd.Flags().StringArrayVar(&o.customNodeGroupRaw, "custom-node-group", nil,
+		"Assign nodes matching a label selector to a custom group "+
+			"(format: groupName=labelSelector, e.g. mc-ingress=node-role.kubernetes.io/ingress). Repeatable.")
+
+	return cmd
+}
+
+func ValidateExplain(o *ExplainOptions) error {
+	if (o.port == 0) == (o.node == "") {
+		return fmt.Errorf("exactly one of --port or --node must be specified")
+	}
+
+	if o.port < 0 || o.port > 65535 {
+		return fmt.Errorf("invalid port %d, must be between 1 and 65535", o.port)
+	}
+
+	if o.node != "" && (o.protocol != "" || o.group != "") {
+		return fmt.Errorf("--protocol and --group can only be used with --port")
+	}
+
+	if !slices.Contains(validDiffFormats, o.outFormat) {
+		return fmt.Errorf("invalid format '%s', valid options are: %s",
+			o.outFormat, strings.Join(validDiffFormats, ", "))
+	}
+
+	if err := validateCustomEntries(o.customEntriesPath, o.customEntriesFormat, validCustomEntriesFormats); err != nil {
+		return err
+	}
+
+	parsed, err := parseCustomNodeGroups(o.customNodeGroupRaw)
+	if err != nil {
+		return err
+	}
+	o.customNodeGroups = parsed
+
+	return nil
+}
+
+func RunExplain(o *ExplainOptions) error {
+	if o.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	result := &explain.Result{}
+	query := explain.Query{Port: o.port, Protocol: o.protocol, NodeGroup: o.group}
+	if o.node != "" {
+		nodes, err := o.utilsHelpers.ListNodes()
+		if err != nil {
+			return fmt.Errorf("failed to list nodes: %w", err)
+		}
+
+		result.NodeGroupResolution, err = explain.ResolveNodeGroup(nodes, o.node, o.customNodeGroups)
+		if err != nil {
+			return err
+		}
+		query = explain.Query{NodeGroup: result.NodeGroupResolution.NodeGroup}
+	}
+
+	sources, err := collectSources(o)
+	if err != nil {
+		return err
+	}
+	result.Sources = explain.Filter(sources, query)
+
+	out, err := printExplainResult(result, o.outFormat)
+	if err != nil {
+		return fmt.Errorf("failed to print explanation: %w", err)
+	}
+	_, err = fmt.Fprint(o.Out, string(out))
+	return err
+}
+
+// collectSources gathers the sources of all the entries of the matrix generated for the cluster.
+func collectSources(o *ExplainOptions) ([]explain.Source, error) {
+	sources, _, err := clusterSources(o.GenerateOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.customEntriesPath != "" {
+		content, err := os.ReadFile(filepath.Clean(o.customEntriesPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", o.customEntriesPath, err)
+		}
+		located, err := types.LocateEntries(content, o.customEntriesFormat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", o.customEntriesPath, err)
+		}
+		sources = append(sources, explain.CustomEntrySources(o.customEntriesPath, located)...)
+	}
+
+	if o.openPorts {
+		ssResult, err := generateSS(o.GenerateOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate SS matrix: %w", err)
+		}
+		sources = append(sources, explain.ListenerSources(ssResult.Listeners)...)
+	}
+
+	return sources, nil
+}
+
+// clusterSources gathers the sources of the EndpointSlice and static entries of the cluster,
+// and returns the node to node group mapping they were resolved with.
+func clusterSources(o *GenerateOptions) ([]explain.Source, map[string]string, error) {
+	settings, err := detectClusterSettings(o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	epExporter, err := endpointslices.New(o.cs, o.customNodeGroups)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed creating the endpointslices exporter: %w", err)
+	}
+	if err := epExporter.LoadExposedEndpointSlicesInfo(); err != nil {
+		return nil, nil, fmt.Errorf("failed loading endpointslices: %w", err)
+	}
+	epSources, err := epExporter.EntrySources()
+	if err != nil {
+		return nil, nil, err
+	}
+	sources := explain.EndpointSliceSources(epSources)
+
+	lists, err := types.GetStaticEntryLists(settings.platformType, settings.controlPlaneTopology, settings.ipv6Enabled, settings.dhcpEnabled)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting static entries: %w", err)
+	}
+	nodes, err := o.utilsHelpers.ListNodes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	poolToRoles := mcp.GetPoolRolesForStaticEntriesExpansion(nodes, epExporter.NodeToGroup())
+	sources = append(sources, explain.StaticSources(lists, poolToRoles)...)
+
+	return sources, epExporter.NodeToGroup(), nil
+}
+
+func printExplainResult(result *explain.Result, format string) ([]byte, error) {
+	switch format {
+	case types.FormatJSON:
+		out, err := json.MarshalIndent(result, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	case types.FormatYAML:
+		return yaml.Marshal(result)
+	}
+
+	var sb strings.Builder
+	if r := result.NodeGroupResolution; r != nil {
+		fmt.Fprintf(&sb, "Node %s is in node group %s\n", r.Node, r.NodeGroup)
+		fmt.Fprintf(&sb, "    rule: %s (%s)\n", r.Rule, r.Detail)
+		if r.Overridden != nil {
+			fmt.Fprintf(&sb, "    overrides: group %s from %s (%s)\n", r.Overridden.NodeGroup, r.Overridden.Rule, r.Overridden.Detail)
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(result.Sources) == 0 {
+		sb.WriteString("No matching entries in the communication matrix\n")
+		return []byte(sb.String()), nil
+	}
+
+	last := ""
+	for _, s := range result.Sources {
+		header := fmt.Sprintf("%s %s/%d (%s)", s.Entry.NodeGroup, s.Entry.Protocol, s.Entry.Port, s.Entry.Direction)
+		if header != last {
+			fmt.Fprintln(&sb, header)
+			last = header
+		}
+		fmt.Fprintf(&sb, "    %s\n", s.Describe())
+	}
+
+	return []byte(sb.String()), nil
+}
@@ -0,0 +1,101 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+
+	"github.com/openshift-kni/commatrix/pkg/explain"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+func TestPrintExplainResult(t *testing.T) {
+	entry := types.ComDetails{Direction: "Ingress", Protocol: "TCP", Port: 9107, NodeGroup: "worker", Namespace: "openshift-ovn-kubernetes"}
+	result := &explain.Result{
+		NodeGroupResolution: &explain.NodeGroupResolution{
+			Node:      "worker-0",
+			NodeGroup: "worker",
+			Rule:      explain.RuleMCPAnnotation,
+			Detail:    "machineconfiguration.openshift.io/currentConfig=rendered-worker-abc",
+		},
+		Sources: []explain.Source{
+			{Kind: explain.KindStatic, Entry: entry, StaticEntryList: "GeneralStaticEntriesWorker"},
+			{
+				Kind:          explain.KindEndpointSlice,
+				Entry:         entry,
+				Service:       "ovn-kubernetes-node",
+				EndpointSlice: "ovn-kubernetes-node-abcde",
+				Pods:          []string{"ovnkube-node-1"},
+				Owner:         "DaemonSet/ovnkube-node",
+			},
+			{Kind: explain.KindListeningSocket, Entry: entry, Node: "worker-0", LocalAddress: "*", Process: "ovnkube", PID: "42"},
+		},
+	}
+
+	out, err := printExplainResult(result, diffFormatText)
+	require.NoError(t, err)
+	assert.Equal(t, `Node worker-0 is in node group worker
+    rule: mcp-annotation (machineconfiguration.openshift.io/currentConfig=rendered-worker-abc)
+
+worker TCP/9107 (Ingress)
+    static entry list GeneralStaticEntriesWorker
+    service openshift-ovn-kubernetes/ovn-kubernetes-node, endpointslice ovn-kubernetes-node-abcde, pods ovnkube-node-1, owner DaemonSet/ovnkube-node
+    listener on node worker-0 at *, process ovnkube (pid 42)
+`, string(out))
+
+	out, err = printExplainResult(&explain.Result{}, diffFormatText)
+	require.NoError(t, err)
+	assert.Equal(t, "No matching entries in the communication matrix\n", string(out))
+
+	out, err = printExplainResult(result, "json")
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"staticEntryList": "GeneralStaticEntriesWorker"`)
+	assert.Contains(t, string(out), `"rule": "mcp-annotation"`)
+}
+
+func TestExplainValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "neither port nor node",
+			args:    []string{"explain"},
+			wantErr: "exactly one of --port or --node must be specified",
+		},
+		{
+			name:    "both port and node",
+			args:    []string{"explain", "--port", "22", "--node", "worker-0"},
+			wantErr: "exactly one of --port or --node must be specified",
+		},
+		{
+			name:    "invalid port",
+			args:    []string{"explain", "--port", "70000"},
+			wantErr: "invalid port 70000",
+		},
+		{
+			name:    "group with node",
+			args:    []string{"explain", "--node", "worker-0", "--group", "worker"},
+			wantErr: "--protocol and --group can only be used with --port",
+		},
+		{
+			name:    "invalid output format",
+			args:    []string{"explain", "--port", "22", "--format", "csv"},
+			wantErr: "invalid format 'csv'",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			streams, _, _, _ := genericiooptions.NewTestIOStreams()
+			cmd := NewCmd(nil, streams)
+			cmd.SetArgs(tt.args)
+			err := cmd.Execute()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
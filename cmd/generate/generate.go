@@ -1,11 +1,14 @@
 package generate
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/openshift-kni/commatrix/pkg/client"
 	commatrixcreator "github.com/openshift-kni/commatrix/pkg/commatrix-creator"
@@ -13,6 +16,8 @@ import (
 	"github.com/openshift-kni/commatrix/pkg/endpointslices"
 	listeningsockets "github.com/openshift-kni/commatrix/pkg/listening-sockets"
 	matrixdiff "github.com/openshift-kni/commatrix/pkg/matrix-diff"
+	mustgather "github.com/openshift-kni/commatrix/pkg/must-gather"
+	trafficobserve "github.com/openshift-kni/commatrix/pkg/traffic-observe"
 	"github.com/openshift-kni/commatrix/pkg/utils"
 	configv1 "github.com/openshift/api/config/v1"
 	log "github.com/sirupsen/logrus"
@@ -21,6 +26,7 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 	"k8s.io/kubectl/pkg/util/templates"
+	rtclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/openshift-kni/commatrix/pkg/types"
 )
@@ -29,7 +35,9 @@ var (
 	commatrixLong = templates.LongDesc(`
               Generate an up-to-date communication flows matrix for all ingress flows of openshift (multi-node and single-node in OpenShift) and Operators.
 
-              Optionally, generate a host open ports matrix and the difference with the communication matrix.
+              Optionally, generate a host open ports matrix and the difference with the communication matrix,
+              add the egress flows of the nodes to the communication matrix, and classify its entries
+              by the traffic observed on the nodes.
 			  
               For additional details, please refer to the communication matrix documentation(https://github.com/openshift-kni/commatrix/blob/main/README.md).
 
@@ -56,11 +64,47 @@ var (
 			 # Generate MachineConfig CRs with nftables firewall rules (per node pool) and a NodeDisruptionPolicy patch:
 			 oc commatrix generate --format mc
 
+			 # Generate the nftables rules in the libnftables JSON schema, to be loaded with 'nft -j -f':
+			 oc commatrix generate --format nft-json --host-open-ports
+
+			 # Generate a firewalld zone per node group:
+			 oc commatrix generate --format firewalld --host-open-ports
+
+			 # Generate iptables-restore and ip6tables-restore files per node group:
+			 oc commatrix generate --format iptables --host-open-ports
+
+			 # Generate an IngressNodeFirewall CR of the Ingress Node Firewall Operator per node group:
+			 oc commatrix generate --format ingressnodefirewall --host-open-ports
+
 			 # Generate separate MachineConfig CRs for nodes matching a label selector:
 			 oc commatrix generate --format mc --custom-node-group mc-ingress=node-role.kubernetes.io/ingress
 
 			 # Generate a MachineConfig CR for a specific node by hostname:
 			 oc commatrix generate --format mc --custom-node-group mc-egress=kubernetes.io/hostname=worker01
+
+			 # Generate nftables rules that only allow the node-to-node flows from the cluster:
+			 oc commatrix generate --format nft --restrict-internal
+
+			 # Generate nftables rules with one commented rule and one named counter per flow:
+			 oc commatrix generate --format nft --nft-counters
+
+			 # Generate nftables rules only allowing the ports of the sockets bound to specific addresses on these addresses:
+			 oc commatrix generate --format nft --host-open-ports --nft-bind-address-rules
+
+			 # Generate nftables rules scoping the flows to their interfaces and dropping the traffic of the other interfaces:
+			 oc commatrix generate --format nft --host-open-ports --customEntriesPath /path/to/interfaces.yaml --customEntriesFormat yaml --nft-drop-other-interfaces
+
+			 # Generate MachineConfig CRs without the optional flows, except sshd:
+			 oc commatrix generate --format mc --exclude-optional --keep-optional /path/to/keep-optional.yaml
+
+			 # Generate the communication matrix with the egress flows of the nodes:
+			 oc commatrix generate --egress
+
+			 # Generate the communication matrix and classify its entries by the traffic observed for 10 minutes:
+			 oc commatrix generate --observe 10m
+
+			 # Generate the communication matrix offline from a must-gather directory:
+			 oc commatrix generate --must-gather /path/to/must-gather
 	`)
 )
 
@@ -70,8 +114,12 @@ var (
 		types.FormatJSON,
 		types.FormatYAML,
 		types.FormatNFT,
+		types.FormatNFTJSON,
 		types.FormatButane,
 		types.FormatMC,
+		types.FormatFirewalld,
+		types.FormatIPTables,
+		types.FormatIngressNodeFirewall,
 	}
 
 	validCustomEntriesFormats = []string{
@@ -88,8 +136,16 @@ type GenerateOptions struct {
 	customEntriesFormat string
 	debug               bool
 	openPorts           bool
+	egress              bool
+	restrictInternal    bool
+	nft                 nftFlags
+	excludeOptional     bool
+	keepOptionalPath    string
+	optionalSelection   *types.OptionalSelection
+	observe             time.Duration
 	customNodeGroupRaw  []string
 	customNodeGroups    map[string]labels.Selector
+	mustGatherDir       string
 	cs                  *client.ClientSet
 	utilsHelpers        utils.UtilsInterface
 	configFlags         *genericclioptions.ConfigFlags
@@ -104,6 +160,16 @@ func NewCmd(cs *client.ClientSet, streams genericiooptions.IOStreams) *cobra.Com
 		Long:  commatrixLong,
 	}
 	cmds.AddCommand(NewCmdCommatrixGenerate(cs, streams))
+	cmds.AddCommand(NewCmdCommatrixDiff(streams))
+	cmds.AddCommand(NewCmdCommatrixConvert(streams))
+	cmds.AddCommand(NewCmdCommatrixApply(cs, streams))
+	cmds.AddCommand(NewCmdCommatrixValidate(cs, streams))
+	cmds.AddCommand(NewCmdCommatrixVerifyFirewall(cs, streams))
+	cmds.AddCommand(NewCmdCommatrixWatch(cs, streams))
+	cmds.AddCommand(NewCmdCommatrixExplain(cs, streams))
+	cmds.AddCommand(NewCmdCommatrixPredict(streams))
+	cmds.AddCommand(NewCmdCommatrixLint(cs, streams))
+	cmds.AddCommand(NewCmdCommatrixDocs(streams))
 
 	return cmds
 }
@@ -141,14 +207,26 @@ func NewCmdCommatrixGenerate(cs *client.ClientSet, streams genericiooptions.IOSt
 		},
 	}
 	cmd.Flags().StringVar(&o.destDir, "destDir", "", "Output files dir (default communication-matrix)")
-	cmd.Flags().StringVar(&o.format, "format", consts.FilesDefaultFormat, "Desired format (json,yaml,csv,nft,butane,mc)")
+	cmd.Flags().StringVar(&o.format, "format", consts.FilesDefaultFormat, "Desired format (json,yaml,csv,nft,nft-json,butane,mc,firewalld,iptables,ingressnodefirewall)")
 	cmd.Flags().BoolVar(&o.debug, "debug", false, "Debug logs")
 	cmd.Flags().StringVar(&o.customEntriesPath, "customEntriesPath", "", "Add custom entries from a file to the matrix")
 	cmd.Flags().StringVar(&o.customEntriesFormat, "customEntriesFormat", "", "Set the format of the custom entries file (json,yaml,csv)")
 	cmd.Flags().BoolVar(&o.openPorts, "host-open-ports", false, "Generate communication matrix, host open ports matrix, and their difference")
+	cmd.Flags().BoolVar(&o.egress, "egress", false, "Add the egress flows of the nodes, discovered from their established connections, to the matrix")
+	cmd.Flags().BoolVar(&o.restrictInternal, "restrict-internal", false,
+		"Only allow the node-to-node flows (etcd, OVN databases, kubelet, Geneve, Machine Config Server) "+
+			"from the nodes and the machine and cluster networks")
+	cmd.Flags().DurationVar(&o.observe, "observe", 0,
+		"Observe the inbound traffic of the nodes for the given duration (e.g. 10m) and classify the matrix entries as used, unused or not in matrix")
+	o.nft.addFlags(cmd)
+	cmd.Flags().BoolVar(&o.excludeOptional, "exclude-optional", false,
+		"Leave the optional flows out of the nft, nft-json, butane, mc, firewalld, iptables and ingressnodefirewall formats and list them in "+consts.ExcludedOptionalFileName+".csv")
+	cmd.Flags().StringVar(&o.keepOptionalPath, "keep-optional", "",
+		"Keep the optional flows matching a selection file (json,yaml) when using --exclude-optional")
 	cmd.Flags().StringArrayVar(&o.customNodeGroupRaw, "custom-node-group", nil,
 		"Assign nodes matching a label selector to a custom group for separate firewall CRs "+
 			"(format: groupName=labelSelector, e.g. mc-ingress=node-role.kubernetes.io/ingress). Repeatable.")
+	cmd.Flags().StringVar(&o.mustGatherDir, mustGatherFlag, "", "Generate the matrix offline from the objects of a must-gather directory")
 
 	return cmd
 }
@@ -163,6 +241,22 @@ func Validate(o *GenerateOptions) error {
 		return err
 	}
 
+	if o.excludeOptional && !formatRequiresMerge(o) {
+		return fmt.Errorf("--exclude-optional only applies to the nft, nft-json, butane, mc, firewalld, iptables and ingressnodefirewall formats")
+	}
+
+	if o.keepOptionalPath != "" && !o.excludeOptional {
+		return fmt.Errorf("you must specify --exclude-optional when using --keep-optional")
+	}
+
+	if err := o.nft.validate(o.format); err != nil {
+		return err
+	}
+
+	if o.observe < 0 {
+		return fmt.Errorf("invalid --observe duration '%s', it must not be negative", o.observe)
+	}
+
 	parsed, err := parseCustomNodeGroups(o.customNodeGroupRaw)
 	if err != nil {
 		return err
@@ -207,6 +301,21 @@ func parseCustomNodeGroups(raw []string) (map[string]labels.Selector, error) {
 	return result, nil
 }
 
+// loadOptionalSelection reads the selection file given with --keep-optional, if any.
+func loadOptionalSelection(o *GenerateOptions) error {
+	if o.keepOptionalPath == "" {
+		return nil
+	}
+
+	log.Debugf("Loading the optional flows selection %s", o.keepOptionalPath)
+	content, err := os.ReadFile(filepath.Clean(o.keepOptionalPath))
+	if err != nil {
+		return fmt.Errorf("failed to read the optional flows selection: %w", err)
+	}
+	o.optionalSelection, err = types.ParseOptionalSelection(content)
+	return err
+}
+
 func validateCustomEntries(path, format string, validFormats []string) error {
 	if path == "" && format == "" { // dont need to validate
 		return nil
@@ -241,6 +350,20 @@ func Complete(o *GenerateOptions) error {
 		}
 	}
 
+	if err := loadOptionalSelection(o); err != nil {
+		return err
+	}
+
+	if o.mustGatherDir != "" {
+		log.Debugf("Loading must-gather %s", o.mustGatherDir)
+		cs, err := mustgather.Load(o.mustGatherDir)
+		if err != nil {
+			return err
+		}
+		o.cs = cs
+		o.utilsHelpers = utils.NewReadOnly(cs)
+	}
+
 	return nil
 }
 
@@ -249,64 +372,127 @@ func Run(o *GenerateOptions) (err error) {
 		log.SetLevel(log.DebugLevel)
 	}
 
+	// Generate the comm matrix, but do not write it, yet.
+	matrix, _, _, err := buildMatrix(o)
+	if err != nil {
+		return err
+	}
+
+	// Generate the flow comm matrix and other info, but do not write it, yet.
+	var ssResult *listeningsockets.SSResult
+	if o.mustGatherDir != "" {
+		if o.openPorts {
+			log.Warn("Host open ports are unavailable when generating from a must-gather, skipping --host-open-ports")
+		}
+		if o.egress {
+			log.Warn("Established connections are unavailable when generating from a must-gather, only the static egress flows are added")
+		}
+		if o.observe > 0 {
+			log.Warn("Traffic observation is unavailable when generating from a must-gather, skipping --observe")
+		}
+	} else if o.openPorts || o.egress {
+		if ssResult, err = generateSS(o); err != nil {
+			return fmt.Errorf("failed to generate SS matrix: %w", err)
+		}
+	}
+
+	if ssResult != nil && ssResult.EgressMatrix != nil {
+		log.Debugf("Adding %d egress flows to the matrix", len(ssResult.EgressMatrix.Ports))
+		matrix = matrix.Merge(ssResult.EgressMatrix)
+	}
+	// The SS results were only needed for the egress flows.
+	if !o.openPorts {
+		ssResult = nil
+	}
+
+	if o.mustGatherDir == "" && o.observe > 0 {
+		if err := observeTraffic(o, matrix); err != nil {
+			return fmt.Errorf("failed to observe the traffic of the nodes: %w", err)
+		}
+	}
+
+	// If format is all in one, merge the SS matrix and the normal matrix and write the result.
+	if formatRequiresMerge(o) {
+		return writeMergedMatrix(o, matrix, ssResult)
+	}
+
+	// Otherwise, write the matrix and ss result files individually.
+	return writeMatrix(o, matrix, ssResult)
+}
+
+// clusterSettings holds the cluster properties the communication matrix depends on.
+type clusterSettings struct {
+	platformType         configv1.PlatformType
+	controlPlaneTopology configv1.TopologyMode
+	ipv6Enabled          bool
+	dhcpEnabled          bool
+}
+
+// buildMatrix detects the cluster's platform, topology, IPv6 and DHCP settings
+// and generates the communication matrix accordingly.
+func buildMatrix(o *GenerateOptions) (*types.ComMatrix, configv1.PlatformType, configv1.TopologyMode, error) {
+	settings, err := detectClusterSettings(o)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	matrix, err := generateMatrix(o, settings.controlPlaneTopology, settings.platformType, settings.ipv6Enabled, settings.dhcpEnabled)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to generate endpoint slice matrix: %w", err)
+	}
+
+	return matrix, settings.platformType, settings.controlPlaneTopology, nil
+}
+
+// detectClusterSettings detects the cluster's platform, topology, IPv6 and DHCP settings.
+func detectClusterSettings(o *GenerateOptions) (*clusterSettings, error) {
 	log.Debug("Detecting deployment and infra types")
 	controlPlaneTopology, err := o.utilsHelpers.GetControlPlaneTopology()
 	if err != nil {
-		return fmt.Errorf("failed to get control plane topology: %w", err)
+		return nil, fmt.Errorf("failed to get control plane topology: %w", err)
 	}
 
 	platformType, err := o.utilsHelpers.GetPlatformType()
 	if err != nil {
-		return fmt.Errorf("failed to get platform type: %w", err)
+		return nil, fmt.Errorf("failed to get platform type: %w", err)
 	}
 
 	if !slices.Contains(types.SupportedPlatforms, platformType) {
-		return fmt.Errorf("unsupported platform type: %s. Supported platform types are: %v", platformType, types.SupportedPlatforms)
+		return nil, fmt.Errorf("unsupported platform type: %s. Supported platform types are: %v", platformType, types.SupportedPlatforms)
 	}
 
 	// Validate control plane topology (supports: HA, SNO, HyperShift External)
 	if !types.IsSupportedTopology(controlPlaneTopology) {
-		return fmt.Errorf("unsupported control plane topology: %s. Supported topologies are: %v", controlPlaneTopology, types.SupportedTopologiesList())
+		return nil, fmt.Errorf("unsupported control plane topology: %s. Supported topologies are: %v", controlPlaneTopology, types.SupportedTopologiesList())
 	}
 
 	ipv6Enabled, err := o.utilsHelpers.IsIPv6Enabled()
 	if err != nil {
-		return fmt.Errorf("failed to detect IPv6: %w", err)
+		return nil, fmt.Errorf("failed to detect IPv6: %w", err)
 	}
 
 	// DHCP is only supported on BareMetal and None platforms
 	var dhcpEnabled bool
 	if platformType == configv1.BareMetalPlatformType || platformType == configv1.NonePlatformType {
 		dhcpEnabled, err = o.utilsHelpers.IsDHCPEnabled()
+		if errors.Is(err, utils.ErrNoCluster) {
+			log.Warn("DHCP detection is unavailable without cluster access, DHCP static entries are not included")
+			err = nil
+		}
 		if err != nil {
-			return fmt.Errorf("failed to detect DHCP: %w", err)
+			return nil, fmt.Errorf("failed to detect DHCP: %w", err)
 		}
 		if dhcpEnabled {
 			log.Debug("DHCP enabled")
 		}
 	}
 
-	// Generate the comm matrix, but do not write it, yet.
-	matrix, err := generateMatrix(o, controlPlaneTopology, platformType, ipv6Enabled, dhcpEnabled)
-	if err != nil {
-		return fmt.Errorf("failed to generate endpoint slice matrix: %w", err)
-	}
-
-	// Generate the flow comm matrix and other info, but do not write it, yet.
-	var ssResult *listeningsockets.SSResult
-	if o.openPorts {
-		if ssResult, err = generateSS(o); err != nil {
-			return fmt.Errorf("failed to generate SS matrix: %w", err)
-		}
-	}
-
-	// If format is all in one, merge the SS matrix and the normal matrix and write the result.
-	if formatRequiresMerge(o) {
-		return writeMergedMatrix(o, matrix, ssResult)
-	}
-
-	// Otherwise, write the matrix and ss result files individually.
-	return writeMatrix(o, matrix, ssResult)
+	return &clusterSettings{
+		platformType:         platformType,
+		controlPlaneTopology: controlPlaneTopology,
+		ipv6Enabled:          ipv6Enabled,
+		dhcpEnabled:          dhcpEnabled,
+	}, nil
 }
 
 // writeMergedMatrix merges the communication matrix with the SS (listening sockets) matrix
@@ -325,12 +511,20 @@ func writeMergedMatrix(o *GenerateOptions, matrix *types.ComMatrix, ssResult *li
 		matrix = matrix.Merge(ssResult.SSCommMatrix)
 	}
 
+	if o.excludeOptional {
+		var excluded *types.ComMatrix
+		matrix, excluded = matrix.ExcludeOptional(o.optionalSelection)
+		if err := writeExcludedOptional(o, excluded); err != nil {
+			return err
+		}
+	}
+
 	// Squash ranges together for the merged matrix.
 	matrix.DynamicRanges.Squash()
 
 	log.Debug("Writing endpoint matrix to file")
 	if err := matrix.WriteMatrixToFileByType(o.utilsHelpers, fileNamePrefix(o.format, consts.CommatrixFileNamePrefix),
-		o.format, o.destDir); err != nil {
+		o.format, o.destDir, o.firewallOptions()...); err != nil {
 		return fmt.Errorf("failed to write endpoint matrix to file: %w", err)
 	}
 	return nil
@@ -349,7 +543,7 @@ func writeMatrix(o *GenerateOptions, matrix *types.ComMatrix, ssResult *listenin
 
 	log.Debug("Writing endpoint matrix to file")
 	if err := matrix.WriteMatrixToFileByType(o.utilsHelpers, fileNamePrefix(o.format, consts.CommatrixFileNamePrefix),
-		o.format, o.destDir); err != nil {
+		o.format, o.destDir, o.firewallOptions()...); err != nil {
 		return fmt.Errorf("failed to write endpoint matrix to file: %w", err)
 	}
 
@@ -364,12 +558,20 @@ func writeMatrix(o *GenerateOptions, matrix *types.ComMatrix, ssResult *listenin
 
 	log.Debug("Writing SS matrix to file")
 	if err := ssResult.SSCommMatrix.WriteMatrixToFileByType(
-		o.utilsHelpers, fileNamePrefix(o.format, consts.SSMatrixFileNamePrefix), o.format, o.destDir); err != nil {
+		o.utilsHelpers, fileNamePrefix(o.format, consts.SSMatrixFileNamePrefix), o.format, o.destDir,
+		types.WithNodeGroupSelectors(o.customNodeGroups)); err != nil {
 		return fmt.Errorf("error while writing SS matrix to file: %w", err)
 	}
 
 	log.Debug("Generating diff between the endpoint slice and SS matrix")
-	diff := matrixdiff.Generate(matrix, ssResult.SSCommMatrix)
+	// The SS matrix only holds listening ports, the egress flows are left out of the diff.
+	ingress := &types.ComMatrix{DynamicRanges: matrix.DynamicRanges}
+	for _, cd := range matrix.Ports {
+		if !cd.IsEgress() {
+			ingress.Ports = append(ingress.Ports, cd)
+		}
+	}
+	diff := matrixdiff.Generate(ingress, ssResult.SSCommMatrix)
 	diffStr, err := diff.String()
 	if err != nil {
 		return fmt.Errorf("error while generating matrix diff string: %w", err)
@@ -396,8 +598,23 @@ func generateMatrix(o *GenerateOptions, controlPlaneTopology configv1.TopologyMo
 	}
 
 	log.Debug("Creating communication matrix")
+	opts := append([]commatrixcreator.Option{commatrixcreator.WithExporter(epExporter)},
+		creatorOptions(o, ipv6Enabled, dhcpEnabled)...)
+	commMatrix := commatrixcreator.New(
+		platformType, controlPlaneTopology, opts...,
+	)
+
+	matrix, err := commMatrix.CreateEndpointMatrix()
+	if err != nil {
+		return nil, err
+	}
+
+	return matrix, nil
+}
+
+// creatorOptions returns the options of the matrix creator selected by the flags and the cluster settings.
+func creatorOptions(o *GenerateOptions, ipv6Enabled bool, dhcpEnabled bool) []commatrixcreator.Option {
 	opts := []commatrixcreator.Option{
-		commatrixcreator.WithExporter(epExporter),
 		commatrixcreator.WithUtilsHelpers(o.utilsHelpers),
 	}
 	if o.customEntriesPath != "" {
@@ -413,16 +630,13 @@ func generateMatrix(o *GenerateOptions, controlPlaneTopology configv1.TopologyMo
 	if dhcpEnabled {
 		opts = append(opts, commatrixcreator.WithDHCP())
 	}
-	commMatrix := commatrixcreator.New(
-		platformType, controlPlaneTopology, opts...,
-	)
-
-	matrix, err := commMatrix.CreateEndpointMatrix()
-	if err != nil {
-		return nil, err
+	if o.restrictInternal {
+		opts = append(opts, commatrixcreator.WithRestrictInternal())
 	}
-
-	return matrix, nil
+	if o.egress {
+		opts = append(opts, commatrixcreator.WithEgress())
+	}
+	return opts
 }
 
 func generateSS(o *GenerateOptions) (*listeningsockets.SSResult, error) {
@@ -431,7 +645,11 @@ func generateSS(o *GenerateOptions) (*listeningsockets.SSResult, error) {
 	}
 
 	log.Debug("Creating listening socket check")
-	listeningCheck, err := listeningsockets.NewCheck(o.cs, o.utilsHelpers, o.customNodeGroups)
+	var checkOpts []listeningsockets.Option
+	if o.egress {
+		checkOpts = append(checkOpts, listeningsockets.WithEgress())
+	}
+	listeningCheck, err := listeningsockets.NewCheck(o.cs, o.utilsHelpers, o.customNodeGroups, checkOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed creating listening socket check: %w", err)
 	}
@@ -456,21 +674,187 @@ func generateSS(o *GenerateOptions) (*listeningsockets.SSResult, error) {
 	return result, nil
 }
 
+// observeTraffic reads the conntrack tables of the nodes for the --observe duration and
+// writes the classification of the matrix entries by the observed flows.
+func observeTraffic(o *GenerateOptions, matrix *types.ComMatrix) error {
+	log.Debug("Creating traffic observer")
+	network := &configv1.Network{}
+	if err := o.cs.Get(context.TODO(), rtclient.ObjectKey{Name: "cluster"}, network); err != nil {
+		return fmt.Errorf("failed to get Network config: %w", err)
+	}
+	var clusterNetworks []string
+	for _, entry := range network.Spec.ClusterNetwork {
+		clusterNetworks = append(clusterNetworks, entry.CIDR)
+	}
+
+	observer, err := trafficobserve.NewObserver(o.utilsHelpers, o.customNodeGroups, trafficobserve.WithClusterNetworks(clusterNetworks))
+	if err != nil {
+		return fmt.Errorf("failed creating traffic observer: %w", err)
+	}
+
+	log.Debug("Creating namespace")
+	if err := o.utilsHelpers.CreateNamespace(consts.DefaultDebugNamespace); err != nil {
+		return fmt.Errorf("failed to create namespace: %w", err)
+	}
+	defer func() {
+		if delErr := o.utilsHelpers.DeleteNamespace(consts.DefaultDebugNamespace); delErr != nil {
+			log.Warnf("failed to delete namespace %s: %v", consts.DefaultDebugNamespace, delErr)
+		}
+	}()
+
+	log.Infof("Observing the traffic of the nodes for %s", o.observe)
+	flows, err := observer.Observe(consts.DefaultDebugNamespace, o.observe)
+	if err != nil {
+		return err
+	}
+
+	report := trafficobserve.Classify(matrix, flows)
+	log.Infof("Observed traffic: %s", report.Summary())
+
+	var out []byte
+	ext := o.format
+	switch o.format {
+	case types.FormatJSON:
+		out, err = report.ToJSON()
+	case types.FormatYAML:
+		out, err = report.ToYAML()
+	default:
+		ext = types.FormatCSV
+		out, err = report.ToCSV()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to print the observed flows: %w", err)
+	}
+
+	log.Debug("Writing the observed flows to file")
+	if err := o.utilsHelpers.WriteFile(filepath.Join(o.destDir, consts.ObservedFlowsFileName+"."+ext), out); err != nil {
+		return fmt.Errorf("error writing the observed flows file: %w", err)
+	}
+
+	return nil
+}
+
+// writeExcludedOptional writes the optional flows left out of the firewall rules.
+func writeExcludedOptional(o *GenerateOptions, excluded *types.ComMatrix) error {
+	log.Infof("Excluding %d optional flows and %d optional dynamic ranges from the firewall rules",
+		len(excluded.Ports), len(excluded.DynamicRanges))
+
+	out, err := excluded.ToCSV()
+	if err != nil {
+		return fmt.Errorf("failed to print the excluded optional flows: %w", err)
+	}
+
+	log.Debug("Writing the excluded optional flows to file")
+	if err := o.utilsHelpers.WriteFile(filepath.Join(o.destDir, consts.ExcludedOptionalFileName+"."+types.FormatCSV), out); err != nil {
+		return fmt.Errorf("error writing the excluded optional flows file: %w", err)
+	}
+
+	return nil
+}
+
+// nftFlags are the flags selecting the form of the nftables rules of the nft, nft-json,
+// butane and mc formats.
+type nftFlags struct {
+	rulePerFlow         bool
+	counters            bool
+	bindAddresses       bool
+	interfaceRules      bool
+	dropOtherInterfaces bool
+	egressDrop          bool
+}
+
+func (f *nftFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.rulePerFlow, "nft-rule-per-flow", false,
+		"Write one nftables rule per flow, commented with its namespace, service and pod, instead of one rule per protocol")
+	cmd.Flags().BoolVar(&f.counters, "nft-counters", false, "Add a named counter to every nftables rule (implies --nft-rule-per-flow)")
+	cmd.Flags().BoolVar(&f.bindAddresses, "nft-bind-address-rules", false,
+		"Only allow the flows whose listening sockets are bound to specific addresses on these addresses")
+	cmd.Flags().BoolVar(&f.interfaceRules, "nft-interface-rules", false,
+		"Only allow the flows scoped to a network interface on this interface")
+	cmd.Flags().BoolVar(&f.dropOtherInterfaces, "nft-drop-other-interfaces", false,
+		"Drop the inbound traffic of the network interfaces no flow is scoped to (implies --nft-interface-rules)")
+	cmd.Flags().BoolVar(&f.egressDrop, "nft-egress-drop", false,
+		"Drop the egress traffic no egress flow allows instead of only logging it")
+}
+
+// validate returns an error if a flag is set with a format it does not apply to. The
+// iptables format only supports the interface rules and the egress drop.
+func (f *nftFlags) validate(format string) error {
+	nftFormats := []string{types.FormatNFT, types.FormatNFTJSON, types.FormatButane, types.FormatMC}
+	iptablesFormats := append(slices.Clone(nftFormats), types.FormatIPTables)
+	for _, flag := range []struct {
+		name    string
+		set     bool
+		formats []string
+	}{
+		{"nft-rule-per-flow", f.rulePerFlow, nftFormats},
+		{"nft-counters", f.counters, nftFormats},
+		{"nft-bind-address-rules", f.bindAddresses, nftFormats},
+		{"nft-interface-rules", f.interfaceRules, iptablesFormats},
+		{"nft-drop-other-interfaces", f.dropOtherInterfaces, nftFormats},
+		{"nft-egress-drop", f.egressDrop, iptablesFormats},
+	} {
+		if flag.set && !slices.Contains(flag.formats, format) {
+			return fmt.Errorf("--%s only applies to the %s and %s formats", flag.name,
+				strings.Join(flag.formats[:len(flag.formats)-1], ", "), flag.formats[len(flag.formats)-1])
+		}
+	}
+	return nil
+}
+
+// options returns the options of the nftables rules selected by the flags.
+func (f *nftFlags) options() []types.NFTOption {
+	var opts []types.NFTOption
+	if f.rulePerFlow {
+		opts = append(opts, types.WithNFTRulePerFlow())
+	}
+	if f.counters {
+		opts = append(opts, types.WithNFTCounters())
+	}
+	if f.bindAddresses {
+		opts = append(opts, types.WithNFTBindAddressRules())
+	}
+	if f.interfaceRules {
+		opts = append(opts, types.WithNFTInterfaceRules())
+	}
+	if f.dropOtherInterfaces {
+		opts = append(opts, types.WithNFTDropOtherInterfaces())
+	}
+	if f.egressDrop {
+		opts = append(opts, types.WithNFTEgressDrop())
+	}
+	return opts
+}
+
+// firewallOptions returns the options of the firewall formats: the nftables options selected
+// by the flags and the selectors of the custom node groups.
+func (o *GenerateOptions) firewallOptions() []types.NFTOption {
+	return append(o.nft.options(), types.WithNodeGroupSelectors(o.customNodeGroups))
+}
+
 func fileNamePrefix(format, defaultPrefix string) string {
 	switch format {
 	case types.FormatButane:
 		return consts.ButaneFileNamePrefix
 	case types.FormatMC:
 		return consts.MCFileNamePrefix
+	case types.FormatFirewalld:
+		return consts.FirewalldFileNamePrefix
+	case types.FormatIngressNodeFirewall:
+		return consts.IngressNodeFirewallFileNamePrefix
 	default:
 		return defaultPrefix
 	}
 }
 
 // formatRequiresMerge returns true for formats that combine both static and open ports
-// into a single output (Butane and MachineConfig formats).
+// into a single output (the firewall formats).
 func formatRequiresMerge(o *GenerateOptions) bool {
 	return o.format == types.FormatButane ||
 		o.format == types.FormatMC ||
-		o.format == types.FormatNFT
+		o.format == types.FormatNFT ||
+		o.format == types.FormatNFTJSON ||
+		o.format == types.FormatFirewalld ||
+		o.format == types.FormatIPTables ||
+		o.format == types.FormatIngressNodeFirewall
 }
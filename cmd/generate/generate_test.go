@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/openshift-kni/commatrix/pkg/client"
 	"github.com/openshift-kni/commatrix/pkg/consts"
+	trafficobserve "github.com/openshift-kni/commatrix/pkg/traffic-observe"
 	"github.com/openshift-kni/commatrix/pkg/types"
 	mock_utils "github.com/openshift-kni/commatrix/pkg/utils/mock"
 	machineconfigurationv1 "github.com/openshift/api/machineconfiguration/v1"
@@ -29,6 +31,7 @@ import (
 	fakek "k8s.io/client-go/kubernetes/fake"
 
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
 )
 
 func mustParseLabels(s string) labels.Selector {
@@ -182,15 +185,16 @@ var (
 
 	testEpsComDetails = []types.ComDetails{
 		{
-			Direction: "Ingress",
-			Protocol:  "TCP",
-			Port:      80,
-			Namespace: "test-ns",
-			Service:   "test-service",
-			Pod:       "test-app-pod",
-			Container: "test-container",
-			NodeGroup: "master",
-			Optional:  false,
+			Direction:     "Ingress",
+			Protocol:      "TCP",
+			Port:          80,
+			Namespace:     "test-ns",
+			Service:       "test-service",
+			Pod:           "test-app-pod",
+			Container:     "test-container",
+			NodeGroup:     "master",
+			Optional:      false,
+			AddressFamily: types.AddressFamilyIPv4,
 		},
 	}
 )
@@ -213,7 +217,7 @@ func TestCommatrixGeneration(t *testing.T) {
 			name: "Should Return failure on format validation",
 			args: []string{"generate", "--format", "test"},
 			expectedFunc: func() (string, error) {
-				return "", fmt.Errorf("invalid format 'test', valid options are: csv, json, yaml, nft, butane, mc")
+				return "", fmt.Errorf("invalid format 'test', valid options are: csv, json, yaml, nft, nft-json, butane, mc, firewalld, iptables, ingressnodefirewall")
 			},
 			wantErr: true,
 		},
@@ -658,7 +662,7 @@ func TestButaneAndMCOutputFiles(t *testing.T) {
 	mockUtils.EXPECT().WaitForPodStatus(consts.DefaultDebugNamespace, mockPod, corev1.PodRunning).Return(nil).AnyTimes()
 	mockUtils.EXPECT().RunCommandOnPod(
 		mockPod,
-		[]string{"chroot", "/host", "/bin/sh", "-c", "ip -j addr show lo"},
+		[]string{"chroot", "/host", "/bin/sh", "-c", "ip -j addr show"},
 	).Return([]byte(""), nil).AnyTimes()
 	mockUtils.EXPECT().RunCommandOnPod(
 		mockPod,
@@ -670,6 +674,7 @@ func TestButaneAndMCOutputFiles(t *testing.T) {
 	mockUtils.EXPECT().RunCommandOnPod(mockPod, []string{"/bin/sh", "-c", "ss -anpluH"}).Return([]byte(
 		"LISTEN 0 4096 0.0.0.0:5356 0.0.0.0:* \n",
 	), nil).AnyTimes()
+	mockUtils.EXPECT().RunCommandOnPod(mockPod, []string{"/bin/sh", "-c", "ss -anplSH"}).Return([]byte(""), nil).AnyTimes()
 
 	// Capture all written files
 	writtenFiles := map[string][]byte{}
@@ -684,6 +689,8 @@ func TestButaneAndMCOutputFiles(t *testing.T) {
 		name           string
 		format         string
 		openPorts      bool
+		exclude        bool
+		selection      *types.OptionalSelection
 		expectedFiles  []string
 		expectedInFile map[string][]string
 	}{
@@ -701,7 +708,8 @@ func TestButaneAndMCOutputFiles(t *testing.T) {
 					"machineconfiguration.openshift.io/role: master",
 					"nftables.service",
 					"/etc/sysconfig/nftables.conf",
-					"tcp dport { 22, 80, 111, 9107, 10250, 10256, 30000-32767 } accept",
+					"tcp dport { 22, 111, 9107, 10250, 10256, 30000-32767 } accept",
+					"meta nfproto ipv4 tcp dport { 80 } accept",
 					"udp dport { 111, 6081, 30000-32767 } accept",
 				},
 				"node-disruption-policy.yaml": {
@@ -726,8 +734,11 @@ func TestButaneAndMCOutputFiles(t *testing.T) {
 					"machineconfiguration.openshift.io/role: master",
 					"nftables.service",
 					"/etc/sysconfig/nftables.conf",
-					"tcp dport { 22, 80, 111, 5355, 9107, 10250, 10256, 30000-32767 } accept",
-					"udp dport { 111, 5356, 6081, 30000-32767 } accept",
+					"tcp dport { 22, 111, 9107, 10250, 10256, 30000-32767 } accept",
+					"udp dport { 111, 6081, 30000-32767 } accept",
+					// The ss listeners are bound to 0.0.0.0.
+					"meta nfproto ipv4 tcp dport { 80, 5355 } accept",
+					"meta nfproto ipv4 udp dport { 5356 } accept",
 				},
 				"node-disruption-policy.yaml": {
 					"oc patch machineconfiguration cluster",
@@ -755,6 +766,28 @@ func TestButaneAndMCOutputFiles(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "nft format leaves out the optional flows not kept by the selection",
+			format:    "nft",
+			exclude:   true,
+			selection: &types.OptionalSelection{Keep: []types.OptionalSelector{{Service: "sshd"}}},
+			expectedFiles: []string{
+				"communication-matrix-master.nft",
+				"excluded-optional-flows.csv",
+			},
+			expectedInFile: map[string][]string{
+				"communication-matrix-master.nft": {
+					"tcp dport { 22, 9107, 10250, 10256 } accept",
+					"meta nfproto ipv4 tcp dport { 80 } accept",
+					"udp dport { 6081 } accept",
+				},
+				"excluded-optional-flows.csv": {
+					"Ingress,TCP,30000-32767,,Kubelet node ports,,,,true,,,,,,\n",
+					"Ingress,TCP,111,Host system service,rpcbind,,,master,true,,,,,,\n",
+					"Ingress,UDP,111,Host system service,rpcbind,,,master,true,,,,,,\n",
+				},
+			},
+		},
 	}
 
 	for _, tt := range testCases {
@@ -771,6 +804,9 @@ func TestButaneAndMCOutputFiles(t *testing.T) {
 				cs:           clientset,
 				utilsHelpers: mockUtils,
 				openPorts:    tt.openPorts,
+
+				excludeOptional:   tt.exclude,
+				optionalSelection: tt.selection,
 			}
 
 			err := Run(opts)
@@ -789,3 +825,177 @@ func TestButaneAndMCOutputFiles(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateFromMustGather(t *testing.T) {
+	mgDir := t.TempDir()
+	writeObject := func(rel string, obj any, apiVersion, kind string) {
+		out, err := yaml.Marshal(obj)
+		require.NoError(t, err)
+		out = append([]byte(fmt.Sprintf("apiVersion: %s\nkind: %s\n", apiVersion, kind)), out...)
+
+		path := filepath.Join(mgDir, "quay-io-must-gather", rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, out, 0644))
+	}
+
+	bareMetalInfra := infra.DeepCopy()
+	bareMetalInfra.Status.PlatformStatus.Type = configv1.BareMetalPlatformType
+	writeObject("cluster-scoped-resources/config.openshift.io/infrastructures/cluster.yaml", bareMetalInfra, "config.openshift.io/v1", "Infrastructure")
+	writeObject("cluster-scoped-resources/config.openshift.io/networks/cluster.yaml", network, "config.openshift.io/v1", "Network")
+	writeObject("cluster-scoped-resources/core/nodes/test-node.yaml", testNode, "v1", "Node")
+	writeObject("cluster-scoped-resources/core/nodes/test-node-worker.yaml", testNodeWorker, "v1", "Node")
+	writeObject("namespaces/test-ns/core/services.yaml",
+		map[string]any{"items": []any{testService}}, "v1", "ServiceList")
+	writeObject("namespaces/test-ns/discovery.k8s.io/endpointslices.yaml",
+		map[string]any{"items": []any{testEndpointSlice}}, "discovery.k8s.io/v1", "EndpointSliceList")
+	writeObject("namespaces/test-ns/pods/test-app-pod/test-app-pod.yaml", testPod, "v1", "Pod")
+
+	outDir := t.TempDir()
+	streams, _, _, _ := genericiooptions.NewTestIOStreams()
+	cmd := NewCmd(nil, streams)
+	cmd.SetArgs([]string{"generate", "--must-gather", mgDir, "--destDir", outDir, "--format", "csv", "--host-open-ports"})
+	require.NoError(t, cmd.Execute())
+
+	generateCmd, _, err := cmd.Find([]string{"generate"})
+	require.NoError(t, err)
+	assert.False(t, RequiresCluster(generateCmd))
+
+	// DHCP detection is unavailable offline, so the DHCP static entries are not included.
+	staticEntries, err := types.GetStaticEntries(configv1.BareMetalPlatformType, configv1.HighlyAvailableTopologyMode, false, false)
+	require.NoError(t, err)
+	expected := types.ComMatrix{Ports: slices.Concat(testEpsComDetails, staticEntries)}
+	expected.SortAndRemoveDuplicates()
+
+	content, err := os.ReadFile(filepath.Join(outDir, "communication-matrix.csv"))
+	require.NoError(t, err)
+	actual, err := types.ParseToComMatrix(content, types.FormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Ports, actual.Ports)
+
+	// --host-open-ports is skipped rather than failing.
+	_, err = os.Stat(filepath.Join(outDir, consts.SSRawTCP))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGenerateObserve(t *testing.T) {
+	sch := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(sch))
+	require.NoError(t, discoveryv1.AddToScheme(sch))
+	require.NoError(t, configv1.AddToScheme(sch))
+	require.NoError(t, machineconfigurationv1.AddToScheme(sch))
+
+	fakeClient := fake.NewClientBuilder().WithScheme(sch).WithObjects(
+		infra, network, testNode, testNodeWorker, testPod, testService, testEndpointSlice, mcpWorker, mcpMaster,
+	).Build()
+	fakeClientset := fakek.NewSimpleClientset(testNode, testNodeWorker)
+	clientset := &client.ClientSet{Client: fakeClient, CoreV1Interface: fakeClientset.CoreV1()}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtils := mock_utils.NewMockUtilsInterface(ctrl)
+	mockUtils.EXPECT().ListNodes().Return([]corev1.Node{*testNode, *testNodeWorker}, nil).AnyTimes()
+	mockUtils.EXPECT().GetControlPlaneTopology().Return(configv1.HighlyAvailableTopologyMode, nil).AnyTimes()
+	mockUtils.EXPECT().GetPlatformType().Return(configv1.AWSPlatformType, nil).AnyTimes()
+	mockUtils.EXPECT().IsIPv6Enabled().Return(false, nil).AnyTimes()
+
+	mockPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "debug-pod", Namespace: consts.DefaultDebugNamespace}}
+	mockUtils.EXPECT().CreateNamespace(consts.DefaultDebugNamespace).Return(nil)
+	mockUtils.EXPECT().DeleteNamespace(consts.DefaultDebugNamespace).Return(nil)
+	mockUtils.EXPECT().CreatePodOnNode(gomock.Any(), consts.DefaultDebugNamespace, gomock.Any(), gomock.Any()).Return(mockPod, nil).Times(2)
+	mockUtils.EXPECT().DeletePod(mockPod).Return(nil).Times(2)
+	mockUtils.EXPECT().WaitForPodStatus(consts.DefaultDebugNamespace, mockPod, corev1.PodRunning).Return(nil).Times(2)
+	mockUtils.EXPECT().RunCommandOnPod(mockPod, []string{"chroot", "/host", "/bin/sh", "-c", "ip -j addr show"}).
+		Return([]byte(`[{"addr_info":[{"local":"10.0.0.1"}]}]`), nil).Times(2)
+	mockUtils.EXPECT().RunCommandOnPod(mockPod, trafficobserve.ConntrackCommand).Return([]byte(
+		"tcp 6 300 ESTABLISHED src=192.0.2.10 dst=10.0.0.1 sport=40000 dport=22 src=10.0.0.1 dst=192.0.2.10 sport=22 dport=40000 [ASSURED]\n"+
+			"tcp 6 300 ESTABLISHED src=192.0.2.10 dst=10.0.0.1 sport=40001 dport=9999 src=10.0.0.1 dst=192.0.2.10 sport=9999 dport=40001 [ASSURED]\n",
+	), nil).Times(2)
+
+	writtenFiles := map[string][]byte{}
+	mockUtils.EXPECT().WriteFile(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(path string, data []byte) error {
+			writtenFiles[filepath.Base(path)] = data
+			return nil
+		},
+	).AnyTimes()
+
+	opts := &GenerateOptions{
+		destDir:      t.TempDir(),
+		format:       types.FormatCSV,
+		cs:           clientset,
+		utilsHelpers: mockUtils,
+		observe:      time.Nanosecond,
+	}
+	require.NoError(t, Run(opts))
+
+	content, ok := writtenFiles["observed-flows.csv"]
+	require.True(t, ok, "expected observed-flows.csv to be written")
+	assert.Contains(t, string(content), "Status,Protocol,Port,NodeGroup,Namespace,Service,Optional,Sources,Connections\n")
+	assert.Contains(t, string(content), "used,TCP,22,master,Host system service,sshd,true,external,1\n")
+	assert.Contains(t, string(content), "unused,TCP,10250,master,")
+	assert.Contains(t, string(content), "not-in-matrix,TCP,9999,master,,,false,external,1\n")
+}
+
+func TestValidateRejectsNegativeObserveDuration(t *testing.T) {
+	o := &GenerateOptions{format: types.FormatCSV, observe: -time.Minute}
+	err := Validate(o)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--observe")
+}
+
+func TestValidateExcludeOptional(t *testing.T) {
+	testCases := []struct {
+		name    string
+		o       *GenerateOptions
+		wantErr string
+	}{
+		{name: "firewall format", o: &GenerateOptions{format: types.FormatMC, excludeOptional: true, keepOptionalPath: "keep.yaml"}},
+		{name: "non firewall format", o: &GenerateOptions{format: types.FormatCSV, excludeOptional: true}, wantErr: "--exclude-optional"},
+		{name: "selection without exclusion", o: &GenerateOptions{format: types.FormatNFT, keepOptionalPath: "keep.yaml"}, wantErr: "--keep-optional"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.o)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateNFTFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		format  string
+		nft     nftFlags
+		wantErr string
+	}{
+		{name: "every flag with nft", format: types.FormatNFT,
+			nft: nftFlags{rulePerFlow: true, counters: true, bindAddresses: true, interfaceRules: true, dropOtherInterfaces: true, egressDrop: true}},
+		{name: "interface rules and egress drop with iptables", format: types.FormatIPTables, nft: nftFlags{interfaceRules: true, egressDrop: true}},
+		{name: "counters with iptables", format: types.FormatIPTables, nft: nftFlags{counters: true},
+			wantErr: "--nft-counters only applies to the nft, nft-json, butane and mc formats"},
+		{name: "egress drop with firewalld", format: types.FormatFirewalld, nft: nftFlags{egressDrop: true},
+			wantErr: "--nft-egress-drop only applies to the nft, nft-json, butane, mc and iptables formats"},
+		{name: "interface rules with ingressnodefirewall", format: types.FormatIngressNodeFirewall, nft: nftFlags{interfaceRules: true},
+			wantErr: "--nft-interface-rules"},
+		{name: "rule per flow with csv", format: types.FormatCSV, nft: nftFlags{rulePerFlow: true}, wantErr: "--nft-rule-per-flow"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&GenerateOptions{format: tt.format, nft: tt.nft})
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
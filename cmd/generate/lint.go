@@ -0,0 +1,233 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+	"github.com/openshift-kni/commatrix/pkg/errhandler"
+	"github.com/openshift-kni/commatrix/pkg/explain"
+	"github.com/openshift-kni/commatrix/pkg/lint"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+var (
+	lintLong = templates.LongDesc(`
+              Check a custom entries file before it is used with --customEntriesPath.
+
+              Every entry is checked for a valid direction, an upper case protocol (TCP,UDP,SCTP),
+              a port between 1 and 65535, a node group and well formed dynamic ranges. Issues are
+              reported with their line, column and field. Entries that duplicate a static entry, or
+              another entry of the file, are reported as warnings.
+
+              With --live, the node groups are checked against the groups resolved for the nodes of
+              the cluster, and duplicates are looked up in the cluster's communication matrix.
+              The command exits with code 5 when errors are found.
+	`)
+	lintExample = templates.Examples(`
+			 # Check a custom entries file:
+			 oc commatrix lint custom-entries.yaml
+
+			 # Check a custom entries file against the cluster:
+			 oc commatrix lint custom-entries.csv --live
+	`)
+)
+
+type LintOptions struct {
+	path       string
+	fileFormat string
+	outFormat  string
+	live       bool
+	*GenerateOptions
+}
+
+// lintReport is the output of the lint command in the json and yaml formats.
+type lintReport struct {
+	File   string       `json:"file" yaml:"file"`
+	Issues []lint.Issue `json:"issues" yaml:"issues"`
+}
+
+func NewCmdCommatrixLint(cs *client.ClientSet, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &LintOptions{GenerateOptions: NewCommatrixOptions(streams, cs)}
+	cmd := &cobra.Command{
+		Use:         "lint FILE",
+		Short:       "Check a custom entries file for errors.",
+		Long:        lintLong,
+		Example:     lintExample,
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{offlineAnnotation: "true"},
+		RunE: func(c *cobra.Command, args []string) error {
+			o.path = args[0]
+
+			if err := ValidateLint(o); err != nil {
+				return err
+			}
+
+			return RunLint(o)
+		},
+	}
+	cmd.Flags().StringVar(&o.fileFormat, "file-format", "", "Format of the custom entries file (json,yaml,csv). Detected from the file extension when empty")
+	cmd.Flags().StringVar(&o.outFormat, "format", diffFormatText, "Output format (text,json,yaml)")
+	cmd.Flags().BoolVar(&o.live, liveFlag, false, "Check the node groups and duplicates against the cluster")
+	cmd.Flags().BoolVar(&o.debug, "debug", false, "Debug logs")
+	cmd.Flags().StringArrayVar(&o.customNodeGroupRaw, "custom-node-group", nil,
+		"Assign nodes matching a label selector to a custom group, used with --live "+
+			"(format: groupName=labelSelector, e.g. mc-ingress=node-role.kubernetes.io/ingress). Repeatable.")
+
+	return cmd
+}
+
+func ValidateLint(o *LintOptions) error {
+	if o.fileFormat == "" {
+		format, err := formatFromPath(o.path)
+		if err != nil {
+			return err
+		}
+		o.fileFormat = format
+	}
+	if !slices.Contains(validCustomEntriesFormats, o.fileFormat) {
+		return fmt.Errorf("invalid file format '%s', valid options are: %s",
+			o.fileFormat, strings.Join(validCustomEntriesFormats, ", "))
+	}
+
+	if !slices.Contains(validDiffFormats, o.outFormat) {
+		return fmt.Errorf("invalid format '%s', valid options are: %s",
+			o.outFormat, strings.Join(validDiffFormats, ", "))
+	}
+
+	if len(o.customNodeGroupRaw) > 0 && !o.live {
+		return fmt.Errorf("--custom-node-group can only be used with --live")
+	}
+
+	parsed, err := parseCustomNodeGroups(o.customNodeGroupRaw)
+	if err != nil {
+		return err
+	}
+	o.customNodeGroups = parsed
+
+	return nil
+}
+
+func RunLint(o *LintOptions) error {
+	if o.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	content, err := os.ReadFile(filepath.Clean(o.path))
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", o.path, err)
+	}
+
+	opts, err := lintOptions(o)
+	if err != nil {
+		return err
+	}
+
+	issues, err := lint.Lint(content, o.fileFormat, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", o.path, err)
+	}
+	if issues == nil {
+		issues = []lint.Issue{}
+	}
+
+	out, err := printLintReport(&lintReport{File: o.path, Issues: issues}, o.outFormat)
+	if err != nil {
+		return fmt.Errorf("failed to print lint report: %w", err)
+	}
+	if _, err := fmt.Fprint(o.Out, string(out)); err != nil {
+		return err
+	}
+
+	if lint.HasErrors(issues) {
+		return &errhandler.ExitError{
+			Code: errhandler.ExitCodeLintFailed,
+			Err:  fmt.Errorf("%s has errors", o.path),
+		}
+	}
+
+	return nil
+}
+
+// lintOptions returns the options to lint the file with. Offline, duplicates are looked up
+// in the static entries of all the platforms. With --live, the node groups and the matrix
+// of the cluster are used.
+func lintOptions(o *LintOptions) ([]lint.Option, error) {
+	if !o.live {
+		var sources []explain.Source
+		for _, platform := range types.SupportedPlatforms {
+			lists, err := types.GetStaticEntryLists(platform, configv1.HighlyAvailableTopologyMode, true, true)
+			if err != nil {
+				return nil, fmt.Errorf("failed getting static entries: %w", err)
+			}
+			sources = append(sources, explain.StaticSources(lists, nil)...)
+		}
+		return []lint.Option{lint.WithReferences(lintReferences(sources)...)}, nil
+	}
+
+	sources, nodeToGroup, err := clusterSources(o.GenerateOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	for _, group := range nodeToGroup {
+		if !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	log.Debugf("Cluster node groups: %s", strings.Join(groups, ","))
+
+	return []lint.Option{lint.WithNodeGroups(groups), lint.WithReferences(lintReferences(sources)...)}, nil
+}
+
+func lintReferences(sources []explain.Source) []lint.Reference {
+	refs := make([]lint.Reference, 0, len(sources))
+	for _, s := range sources {
+		refs = append(refs, lint.Reference{Description: s.Describe(), Entry: s.Entry})
+	}
+
+	return refs
+}
+
+func printLintReport(report *lintReport, format string) ([]byte, error) {
+	switch format {
+	case types.FormatJSON:
+		out, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	case types.FormatYAML:
+		return yaml.Marshal(report)
+	}
+
+	if len(report.Issues) == 0 {
+		return []byte(fmt.Sprintf("%s: no issues found\n", report.File)), nil
+	}
+
+	var sb strings.Builder
+	errs := 0
+	for _, i := range report.Issues {
+		fmt.Fprintf(&sb, "%s:%s\n", report.File, i)
+		if i.Severity == lint.SeverityError {
+			errs++
+		}
+	}
+	fmt.Fprintf(&sb, "%d errors, %d warnings\n", errs, len(report.Issues)-errs)
+
+	return []byte(sb.String()), nil
+}
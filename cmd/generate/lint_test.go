@@ -0,0 +1,77 @@
+package generate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+
+	"github.com/openshift-kni/commatrix/pkg/errhandler"
+)
+
+func TestCommatrixLint(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeDiffTestFile(t, dir, "valid.yaml", `Ports:
+  - direction: Ingress
+    protocol: TCP
+    port: 9050
+    nodeGroup: worker
+`)
+	invalid := writeDiffTestFile(t, dir, "invalid.csv", `Direction,Protocol,Port,NodeGroup,Optional
+Ingress,tcp,9050,worker,false
+Ingress,TCP,22,master,false
+`)
+
+	t.Run("reports no issues for a valid file", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"lint", valid})
+		require.NoError(t, cmd.Execute())
+		assert.Equal(t, valid+": no issues found\n", out.String())
+	})
+
+	t.Run("reports errors and duplicates of static entries", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"lint", invalid})
+		err := cmd.Execute()
+		require.Error(t, err)
+
+		var exitErr *errhandler.ExitError
+		require.True(t, errors.As(err, &exitErr))
+		assert.Equal(t, errhandler.ExitCodeLintFailed, exitErr.Code)
+		assert.Equal(t, invalid+`:2:9: error: Protocol: "tcp" must be upper case, use "TCP"
+`+invalid+`:3:1: warning: duplicates the master-22-TCP entry of static entry list GeneralStaticEntriesMaster
+1 errors, 1 warnings
+`, out.String())
+	})
+
+	t.Run("prints the issues as json", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"lint", valid, "--format", "json"})
+		require.NoError(t, cmd.Execute())
+		assert.JSONEq(t, `{"file": "`+valid+`", "issues": []}`, out.String())
+	})
+
+	t.Run("fails on an unknown file extension", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"lint", dir + "/entries.txt"})
+		err := cmd.Execute()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to detect the format")
+	})
+
+	t.Run("requires a cluster only with --live", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		lintCmd, _, err := NewCmd(nil, streams).Find([]string{"lint"})
+		require.NoError(t, err)
+		assert.False(t, RequiresCluster(lintCmd))
+
+		require.NoError(t, lintCmd.Flags().Set(liveFlag, "true"))
+		assert.True(t, RequiresCluster(lintCmd))
+	})
+}
@@ -0,0 +1,75 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// offlineAnnotation marks subcommands that only work on local files and
+// therefore can run without access to a cluster.
+const offlineAnnotation = "commatrix.openshift.io/offline"
+
+// mustGatherFlag is the flag of commands that can read the cluster objects from a must-gather.
+const mustGatherFlag = "must-gather"
+
+// liveFlag is the flag of offline commands that can optionally check their input against a cluster.
+const liveFlag = "live"
+
+// RequiresCluster returns true if the given command needs a cluster client to run.
+func RequiresCluster(cmd *cobra.Command) bool {
+	if f := cmd.Flags().Lookup(liveFlag); f != nil && f.Value.String() == "true" {
+		return true
+	}
+	if _, offline := cmd.Annotations[offlineAnnotation]; offline {
+		return false
+	}
+	if f := cmd.Flags().Lookup(mustGatherFlag); f != nil && f.Value.String() != "" {
+		return false
+	}
+	return true
+}
+
+// loadMatrixFile reads a matrix file in one of the formats supported by types.ParseToComMatrix.
+// When format is empty it is derived from the file extension.
+func loadMatrixFile(path, format string) (*types.ComMatrix, error) {
+	if format == "" {
+		var err error
+		if format, err = formatFromPath(path); err != nil {
+			return nil, err
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	matrix, err := types.ParseToComMatrix(content, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return matrix, nil
+}
+
+// formatFromPath returns the matrix format matching the extension of path.
+func formatFromPath(path string) (string, error) {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if ext == "yml" {
+		ext = types.FormatYAML
+	}
+
+	if !slices.Contains(validCustomEntriesFormats, ext) {
+		return "", fmt.Errorf("unable to detect the format of %s, valid options are: %s",
+			path, strings.Join(validCustomEntriesFormats, ", "))
+	}
+
+	return ext, nil
+}
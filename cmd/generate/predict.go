@@ -0,0 +1,144 @@
+package generate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/openshift-kni/commatrix/pkg/endpointslices"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+var (
+	predictLong = templates.LongDesc(`
+              Predict the host ports a set of Kubernetes manifests will expose, without cluster access.
+
+              The Deployments, DaemonSets, StatefulSets, ReplicaSets, Pods and Services found in the
+              YAML and JSON files of the directory, e.g. an OLM bundle or rendered Helm or Kustomize
+              output, are matched like the EndpointSlice controller would match them, and the same
+              host network, host port and localhost rules as 'generate' decide which ports need
+              firewall entries. The entries are printed for the given node group.
+	`)
+	predictExample = templates.Examples(`
+			 # Predict the entries an operator bundle adds to the worker nodes:
+			 oc commatrix predict ./bundle/manifests --node-group worker
+
+			 # Merge the prediction into an existing matrix:
+			 oc commatrix predict ./rendered --node-group worker --merge communication-matrix.csv > predicted-matrix.csv
+
+			 # Compare the prediction with an existing matrix:
+			 oc commatrix predict ./rendered --node-group worker --merge communication-matrix.csv > predicted-matrix.csv
+			 oc commatrix diff communication-matrix.csv predicted-matrix.csv
+	`)
+)
+
+type PredictOptions struct {
+	manifestsDir string
+	nodeGroup    string
+	namespace    string
+	format       string
+	mergePath    string
+	mergeFormat  string
+	debug        bool
+	genericiooptions.IOStreams
+}
+
+func NewCmdCommatrixPredict(streams genericiooptions.IOStreams) *cobra.Command {
+	o := &PredictOptions{IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:         "predict DIR",
+		Short:       "Predict the host ports exposed by a directory of Kubernetes manifests.",
+		Long:        predictLong,
+		Example:     predictExample,
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{offlineAnnotation: "true"},
+		RunE: func(c *cobra.Command, args []string) error {
+			o.manifestsDir = args[0]
+
+			if err := ValidatePredict(o); err != nil {
+				return err
+			}
+
+			return RunPredict(o)
+		},
+	}
+	cmd.Flags().StringVar(&o.nodeGroup, "node-group", "", "Node group the workloads will run on (required)")
+	cmd.Flags().StringVar(&o.namespace, "namespace", "default", "Namespace of the manifests that do not set one")
+	cmd.Flags().StringVar(&o.format, "format", types.FormatCSV, "Output format (json,yaml,csv)")
+	cmd.Flags().StringVar(&o.mergePath, "merge", "", "Matrix file to merge the predicted entries into")
+	cmd.Flags().StringVar(&o.mergeFormat, "merge-format", "", "Format of the --merge file (json,yaml,csv). Detected from the file extension when empty")
+	cmd.Flags().BoolVar(&o.debug, "debug", false, "Debug logs")
+
+	return cmd
+}
+
+func ValidatePredict(o *PredictOptions) error {
+	if o.nodeGroup == "" {
+		return fmt.Errorf("--node-group is required")
+	}
+
+	if !slices.Contains(validCustomEntriesFormats, o.format) {
+		return fmt.Errorf("invalid format '%s', valid options are: %s",
+			o.format, strings.Join(validCustomEntriesFormats, ", "))
+	}
+
+	if o.mergeFormat != "" && !slices.Contains(validCustomEntriesFormats, o.mergeFormat) {
+		return fmt.Errorf("invalid merge format '%s', valid options are: %s",
+			o.mergeFormat, strings.Join(validCustomEntriesFormats, ", "))
+	}
+
+	return nil
+}
+
+func RunPredict(o *PredictOptions) error {
+	if o.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	manifests, err := endpointslices.LoadManifests(o.manifestsDir, o.namespace)
+	if err != nil {
+		return fmt.Errorf("failed to load manifests: %w", err)
+	}
+	log.Debugf("Loaded %d services and %d workloads", len(manifests.Services), len(manifests.Pods))
+
+	comDetails, err := manifests.Predict(o.nodeGroup)
+	if err != nil {
+		return fmt.Errorf("failed to predict the matrix: %w", err)
+	}
+
+	matrix := &types.ComMatrix{Ports: comDetails}
+	if o.mergePath != "" {
+		existing, err := loadMatrixFile(o.mergePath, o.mergeFormat)
+		if err != nil {
+			return err
+		}
+		matrix = existing.Merge(matrix)
+	}
+	matrix.SortAndRemoveDuplicates()
+
+	out, err := printMatrix(matrix, o.format)
+	if err != nil {
+		return err
+	}
+	_, err = o.Out.Write(out)
+	return err
+}
+
+// printMatrix renders the matrix in one of the file formats (json,yaml,csv).
+func printMatrix(matrix *types.ComMatrix, format string) ([]byte, error) {
+	switch format {
+	case types.FormatJSON:
+		return matrix.ToJSON()
+	case types.FormatYAML:
+		return matrix.ToYAML()
+	case types.FormatCSV:
+		return matrix.ToCSV()
+	}
+
+	return nil, fmt.Errorf("invalid format '%s'", format)
+}
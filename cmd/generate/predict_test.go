@@ -0,0 +1,84 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+const predictTestManifests = `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: exporter
+  namespace: partner
+spec:
+  selector:
+    matchLabels:
+      app: exporter
+  template:
+    metadata:
+      labels:
+        app: exporter
+    spec:
+      hostNetwork: true
+      containers:
+      - name: exporter
+        image: exporter
+        ports:
+        - containerPort: 9107
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: exporter
+  namespace: partner
+spec:
+  selector:
+    app: exporter
+  ports:
+  - port: 9107
+`
+
+func TestCommatrixPredict(t *testing.T) {
+	dir := t.TempDir()
+	manifestsDir := t.TempDir()
+	writeDiffTestFile(t, manifestsDir, "exporter.yaml", predictTestManifests)
+	existing := writeDiffTestFile(t, dir, "matrix.csv", diffTestPrimaryCSV)
+
+	t.Run("prints the predicted entries", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"predict", manifestsDir, "--node-group", "worker"})
+		require.NoError(t, cmd.Execute())
+		assert.Equal(t, `Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional,Peer,SourceCIDRs,AddressFamily,BindScope,BindAddresses,Interface
+Ingress,TCP,9107,partner,exporter,exporter,exporter,worker,false,,,,,,
+`, out.String())
+	})
+
+	t.Run("merges the predicted entries into a matrix", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"predict", manifestsDir, "--node-group", "worker", "--merge", existing})
+		require.NoError(t, cmd.Execute())
+		assert.Contains(t, out.String(), "Ingress,TCP,9100,openshift-monitoring,node-exporter,node-exporter,node-exporter,master,false,,,,,,\n")
+		assert.Contains(t, out.String(), "Ingress,TCP,9107,partner,exporter,exporter,exporter,worker,false,,,,,,\n")
+	})
+
+	t.Run("requires a node group", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		cmd := NewCmd(nil, streams)
+		cmd.SetArgs([]string{"predict", manifestsDir})
+		err := cmd.Execute()
+		require.Error(t, err)
+		assert.Equal(t, "--node-group is required", err.Error())
+	})
+
+	t.Run("runs without a cluster", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		predictCmd, _, err := NewCmd(nil, streams).Find([]string{"predict"})
+		require.NoError(t, err)
+		assert.False(t, RequiresCluster(predictCmd))
+	})
+}
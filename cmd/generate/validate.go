@@ -0,0 +1,167 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/baseline"
+	"github.com/openshift-kni/commatrix/pkg/client"
+	"github.com/openshift-kni/commatrix/pkg/errhandler"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+var (
+	validateLong = templates.LongDesc(`
+              Validate the cluster's communication matrix against the documented communication matrix
+              shipped with this binary (docs/stable/raw).
+
+              The documented matrix is selected from the cluster's platform type and control plane topology.
+              Flows used by the cluster but not documented fail the validation with exit code 3.
+              Documented flows that are not used by the cluster are reported only.
+	`)
+	validateExample = templates.Examples(`
+			 # Validate the cluster against the documented matrix:
+			 oc commatrix validate
+
+			 # Ignore known extra flows listed in an allowlist file:
+			 oc commatrix validate --allowlist /path/to/allowlist.csv
+
+			 # Print the validation report as JSON:
+			 oc commatrix validate --format json
+	`)
+)
+
+type ValidateOptions struct {
+	allowlistPath   string
+	allowlistFormat string
+	format          string
+	*GenerateOptions
+}
+
+func NewCmdCommatrixValidate(cs *client.ClientSet, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ValidateOptions{GenerateOptions: NewCommatrixOptions(streams, cs)}
+	cmd := &cobra.Command{
+		Use:     "validate",
+		Short:   "Validate the cluster's communication flows against the documented matrix.",
+		Long:    validateLong,
+		Example: validateExample,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := ValidateBaselineOptions(o); err != nil {
+				return err
+			}
+
+			return RunValidate(o)
+		},
+	}
+	cmd.Flags().StringVar(&o.allowlistPath, "allowlist", "", "Matrix file with flows to ignore during validation")
+	cmd.Flags().StringVar(&o.allowlistFormat, "allowlist-format", "", "Format of the allowlist file (json,yaml,csv). Detected from the file extension when empty")
+	cmd.Flags().StringVar(&o.format, "format", diffFormatText, "Output format of the validation report (text,json,yaml)")
+	cmd.Flags().BoolVar(&o.debug, "debug", false, "Debug logs")
+	cmd.Flags().StringVar(&o.customEntriesPath, "customEntriesPath", "", "Add custom entries from a file to the matrix")
+	cmd.Flags().StringVar(&o.customEntriesFormat, "customEntriesFormat", "", "Set the format of the custom entries file (json,yaml,csv)")
+
+	return cmd
+}
+
+func ValidateBaselineOptions(o *ValidateOptions) error {
+	if !slices.Contains(validDiffFormats, o.format) {
+		return fmt.Errorf("invalid format '%s', valid options are: %s",
+			o.format, strings.Join(validDiffFormats, ", "))
+	}
+
+	if o.allowlistFormat != "" && !slices.Contains(validCustomEntriesFormats, o.allowlistFormat) {
+		return fmt.Errorf("invalid allowlist format '%s', valid options are: %s",
+			o.allowlistFormat, strings.Join(validCustomEntriesFormats, ", "))
+	}
+
+	return validateCustomEntries(o.customEntriesPath, o.customEntriesFormat, validCustomEntriesFormats)
+}
+
+func RunValidate(o *ValidateOptions) error {
+	if o.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	allowlist := &types.ComMatrix{}
+	if o.allowlistPath != "" {
+		var err error
+		if allowlist, err = loadMatrixFile(o.allowlistPath, o.allowlistFormat); err != nil {
+			return fmt.Errorf("failed to load allowlist: %w", err)
+		}
+	}
+
+	matrix, platformType, controlPlaneTopology, err := buildMatrix(o.GenerateOptions)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("Loading the documented communication matrix")
+	docType, docMatrix, err := baseline.Load(platformType, controlPlaneTopology)
+	if err != nil {
+		return err
+	}
+
+	result := baseline.Validate(docType, matrix, docMatrix, allowlist)
+
+	out, err := printValidationResult(result, o.format)
+	if err != nil {
+		return fmt.Errorf("failed to print validation report: %w", err)
+	}
+	if _, err := fmt.Fprintln(o.Out, strings.TrimSuffix(string(out), "\n")); err != nil {
+		return err
+	}
+
+	if !result.Passed() {
+		return &errhandler.ExitError{
+			Code: errhandler.ExitCodeValidationFailed,
+			Err: fmt.Errorf("%d flows are not documented and %d flows changed in the %s communication matrix",
+				len(result.Undocumented), len(result.Changed), docType),
+		}
+	}
+
+	return nil
+}
+
+func printValidationResult(result *baseline.Result, format string) ([]byte, error) {
+	switch format {
+	case types.FormatJSON:
+		return json.MarshalIndent(result, "", "    ")
+	case types.FormatYAML:
+		return yaml.Marshal(result)
+	}
+
+	colNames, err := types.GetComMatrixHeadersByFormat(types.FormatCSV)
+	if err != nil {
+		return nil, fmt.Errorf("error getting commatrix CSV tags: %w", err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Documented matrix: %s\n", result.DocType)
+	fmt.Fprintf(&sb, "\nUndocumented flows (%d):\n", len(result.Undocumented))
+	if len(result.Undocumented) > 0 {
+		undocumented := types.ComMatrix{Ports: result.Undocumented}
+		sb.WriteString(colNames + "\n" + undocumented.String())
+	}
+	fmt.Fprintf(&sb, "\nChanged flows (%d):\n", len(result.Changed))
+	if len(result.Changed) > 0 {
+		sb.WriteString(colNames + "\n")
+		for _, change := range result.Changed {
+			fmt.Fprintf(&sb, "+ %s\n- %s\n", change.Primary, change.Secondary)
+		}
+	}
+	fmt.Fprintf(&sb, "\nMissing flows (%d):\n", len(result.Missing))
+	if len(result.Missing) > 0 {
+		missing := types.ComMatrix{Ports: result.Missing}
+		sb.WriteString(colNames + "\n" + missing.String())
+	}
+
+	return []byte(sb.String()), nil
+}
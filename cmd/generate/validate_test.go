@@ -0,0 +1,93 @@
+package generate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machineconfigurationv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	fakek "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+	"github.com/openshift-kni/commatrix/pkg/errhandler"
+	mock_utils "github.com/openshift-kni/commatrix/pkg/utils/mock"
+)
+
+func TestCommatrixValidate(t *testing.T) {
+	sch := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(sch))
+	require.NoError(t, discoveryv1.AddToScheme(sch))
+	require.NoError(t, configv1.AddToScheme(sch))
+	require.NoError(t, machineconfigurationv1.AddToScheme(sch))
+
+	fakeClient := fake.NewClientBuilder().WithScheme(sch).WithObjects(
+		infra, network, testNode, testNodeWorker, testPod, testService, testEndpointSlice, mcpWorker, mcpMaster,
+	).Build()
+	fakeClientset := fakek.NewSimpleClientset(testNode, testNodeWorker)
+	cs := &client.ClientSet{Client: fakeClient, CoreV1Interface: fakeClientset.CoreV1()}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtils := mock_utils.NewMockUtilsInterface(ctrl)
+	mockUtils.EXPECT().ListNodes().Return([]corev1.Node{*testNode, *testNodeWorker}, nil).AnyTimes()
+	mockUtils.EXPECT().GetControlPlaneTopology().Return(configv1.HighlyAvailableTopologyMode, nil).AnyTimes()
+	mockUtils.EXPECT().GetPlatformType().Return(configv1.AWSPlatformType, nil).AnyTimes()
+	mockUtils.EXPECT().IsIPv6Enabled().Return(false, nil).AnyTimes()
+
+	allowlist := filepath.Join(t.TempDir(), "allowlist.csv")
+	require.NoError(t, os.WriteFile(allowlist, []byte(
+		"Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional\n"+
+			"Ingress,TCP,80,test-ns,test-service,test-app-pod,test-container,master,false\n"), 0644))
+
+	newOptions := func(streams genericiooptions.IOStreams) *ValidateOptions {
+		o := &ValidateOptions{GenerateOptions: NewCommatrixOptions(streams, cs), format: diffFormatText}
+		o.utilsHelpers = mockUtils
+		return o
+	}
+
+	t.Run("undocumented flows fail with the validation exit code", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		o := newOptions(streams)
+
+		err := RunValidate(o)
+		require.Error(t, err)
+		var exitErr *errhandler.ExitError
+		require.True(t, errors.As(err, &exitErr))
+		assert.Equal(t, errhandler.ExitCodeValidationFailed, exitErr.Code)
+
+		assert.Contains(t, out.String(), "Documented matrix: aws")
+		assert.Contains(t, out.String(), "Undocumented flows (1):")
+		assert.Contains(t, out.String(), "Ingress,TCP,80,test-ns,test-service,test-app-pod,test-container,master,false")
+		assert.Contains(t, out.String(), "Missing flows")
+	})
+
+	t.Run("allowlisted flows pass the validation", func(t *testing.T) {
+		streams, _, out, _ := genericiooptions.NewTestIOStreams()
+		o := newOptions(streams)
+		o.allowlistPath = allowlist
+
+		require.NoError(t, RunValidate(o))
+		assert.Contains(t, out.String(), "Undocumented flows (0):")
+	})
+
+	t.Run("invalid report format is rejected", func(t *testing.T) {
+		streams, _, _, _ := genericiooptions.NewTestIOStreams()
+		o := newOptions(streams)
+		o.format = "nft"
+
+		err := ValidateBaselineOptions(o)
+		require.Error(t, err)
+		assert.Equal(t, "invalid format 'nft', valid options are: text, json, yaml", err.Error())
+	})
+}
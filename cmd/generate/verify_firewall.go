@@ -0,0 +1,243 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+	"github.com/openshift-kni/commatrix/pkg/consts"
+	"github.com/openshift-kni/commatrix/pkg/errhandler"
+	firewallverify "github.com/openshift-kni/commatrix/pkg/firewall-verify"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+var (
+	verifyFirewallLong = templates.LongDesc(`
+              Verify the nftables rules loaded on every node against the communication matrix.
+
+              A debug pod on each node lists the 'inet openshift_filter' table and the accepted
+              TCP, UDP and SCTP ports are compared with the ports the nft output format would allow for
+              the node's group. Nodes with missing or extra ports fail the verification with exit code 4.
+	`)
+	verifyFirewallExample = templates.Examples(`
+			 # Verify the nodes against the matrix of the cluster:
+			 oc commatrix verify-firewall
+
+			 # Verify the nodes against the matrix the firewall rules were generated from:
+			 oc commatrix verify-firewall --matrix /path/to/communication-matrix.csv
+
+			 # Include the host open ports, as generated with 'generate --format mc --host-open-ports':
+			 oc commatrix verify-firewall --host-open-ports
+
+			 # Use the options the firewall rules were generated with:
+			 oc commatrix verify-firewall --restrict-internal --exclude-optional --keep-optional /path/to/keep-optional.yaml --nft-rule-per-flow
+	`)
+)
+
+type VerifyFirewallOptions struct {
+	matrixPath   string
+	matrixFormat string
+	format       string
+	*GenerateOptions
+}
+
+func NewCmdCommatrixVerifyFirewall(cs *client.ClientSet, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &VerifyFirewallOptions{GenerateOptions: NewCommatrixOptions(streams, cs)}
+	cmd := &cobra.Command{
+		Use:     "verify-firewall",
+		Short:   "Verify the nftables rules loaded on the nodes against the communication matrix.",
+		Long:    verifyFirewallLong,
+		Example: verifyFirewallExample,
+		Args:    cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := ValidateVerifyFirewall(o); err != nil {
+				return err
+			}
+
+			return RunVerifyFirewall(o)
+		},
+	}
+	cmd.Flags().StringVar(&o.matrixPath, "matrix", "", "Matrix file to verify against. The matrix is generated from the cluster when empty")
+	cmd.Flags().StringVar(&o.matrixFormat, "matrix-format", "", "Format of the matrix file (json,yaml,csv). Detected from the file extension when empty")
+	cmd.Flags().StringVar(&o.format, "format", diffFormatText, "Output format of the verification report (text,json,yaml)")
+	cmd.Flags().BoolVar(&o.debug, "debug", false, "Debug logs")
+	cmd.Flags().StringVar(&o.customEntriesPath, "customEntriesPath", "", "Add custom entries from a file to the matrix")
+	cmd.Flags().StringVar(&o.customEntriesFormat, "customEntriesFormat", "", "Set the format of the custom entries file (json,yaml,csv)")
+	cmd.Flags().BoolVar(&o.openPorts, "host-open-ports", false, "Add the host open ports to the generated matrix")
+	cmd.Flags().BoolVar(&o.restrictInternal, "restrict-internal", false,
+		"Only allow the node-to-node flows (etcd, OVN databases, kubelet, Geneve, Machine Config Server) "+
+			"from the nodes and the machine and cluster networks")
+	o.nft.addFlags(cmd)
+	cmd.Flags().BoolVar(&o.excludeOptional, "exclude-optional", false, "Leave the optional flows out of the expected ports")
+	cmd.Flags().StringVar(&o.keepOptionalPath, "keep-optional", "",
+		"Keep the optional flows matching a selection file (json,yaml) when using --exclude-optional")
+	cmd.Flags().StringArrayVar(&o.customNodeGroupRaw, "custom-node-group", nil,
+		"Assign nodes matching a label selector to a custom group "+
+			"(format: groupName=labelSelector, e.g. mc-ingress=node-role.kubernetes.io/ingress). Repeatable.")
+
+	return cmd
+}
+
+func ValidateVerifyFirewall(o *VerifyFirewallOptions) error {
+	if !slices.Contains(validDiffFormats, o.format) {
+		return fmt.Errorf("invalid format '%s', valid options are: %s",
+			o.format, strings.Join(validDiffFormats, ", "))
+	}
+
+	if o.matrixFormat != "" && !slices.Contains(validCustomEntriesFormats, o.matrixFormat) {
+		return fmt.Errorf("invalid matrix format '%s', valid options are: %s",
+			o.matrixFormat, strings.Join(validCustomEntriesFormats, ", "))
+	}
+
+	if o.matrixPath != "" && (o.customEntriesPath != "" || o.openPorts || o.restrictInternal) {
+		return fmt.Errorf("--matrix cannot be used with --customEntriesPath, --host-open-ports or --restrict-internal")
+	}
+
+	if o.keepOptionalPath != "" && !o.excludeOptional {
+		return fmt.Errorf("you must specify --exclude-optional when using --keep-optional")
+	}
+
+	if err := validateCustomEntries(o.customEntriesPath, o.customEntriesFormat, validCustomEntriesFormats); err != nil {
+		return err
+	}
+
+	parsed, err := parseCustomNodeGroups(o.customNodeGroupRaw)
+	if err != nil {
+		return err
+	}
+	o.customNodeGroups = parsed
+
+	return nil
+}
+
+func RunVerifyFirewall(o *VerifyFirewallOptions) error {
+	if o.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	if err := loadOptionalSelection(o.GenerateOptions); err != nil {
+		return err
+	}
+
+	matrix, err := verifyFirewallMatrix(o)
+	if err != nil {
+		return err
+	}
+	if o.excludeOptional {
+		matrix, _ = matrix.ExcludeOptional(o.optionalSelection)
+	}
+	matrix.DynamicRanges.Squash()
+
+	log.Debug("Creating firewall verifier")
+	verifier, err := firewallverify.NewVerifier(o.utilsHelpers, o.customNodeGroups)
+	if err != nil {
+		return fmt.Errorf("failed creating firewall verifier: %w", err)
+	}
+
+	log.Debug("Creating namespace")
+	if err := o.utilsHelpers.CreateNamespace(consts.DefaultDebugNamespace); err != nil {
+		return fmt.Errorf("failed to create namespace: %w", err)
+	}
+	defer func() {
+		if delErr := o.utilsHelpers.DeleteNamespace(consts.DefaultDebugNamespace); delErr != nil {
+			log.Warnf("failed to delete namespace %s: %v", consts.DefaultDebugNamespace, delErr)
+		}
+	}()
+
+	log.Debug("Reading the nftables rules of the nodes")
+	report, err := verifier.Verify(consts.DefaultDebugNamespace, matrix, o.firewallOptions()...)
+	if err != nil {
+		return err
+	}
+
+	out, err := printFirewallReport(report, o.format)
+	if err != nil {
+		return fmt.Errorf("failed to print verification report: %w", err)
+	}
+	if _, err := fmt.Fprintln(o.Out, strings.TrimSuffix(string(out), "\n")); err != nil {
+		return err
+	}
+
+	if !report.Passed() {
+		failed := 0
+		for _, n := range report.Nodes {
+			if !n.Passed() {
+				failed++
+			}
+		}
+		return &errhandler.ExitError{
+			Code: errhandler.ExitCodeFirewallMismatch,
+			Err:  fmt.Errorf("firewall verification failed on %d of %d nodes", failed, len(report.Nodes)),
+		}
+	}
+
+	return nil
+}
+
+// verifyFirewallMatrix returns the matrix given with --matrix, or generates it from the cluster
+// the same way generate does.
+func verifyFirewallMatrix(o *VerifyFirewallOptions) (*types.ComMatrix, error) {
+	if o.matrixPath != "" {
+		return loadMatrixFile(o.matrixPath, o.matrixFormat)
+	}
+
+	matrix, _, _, err := buildMatrix(o.GenerateOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.openPorts {
+		ssResult, err := generateSS(o.GenerateOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate SS matrix: %w", err)
+		}
+		matrix = matrix.Merge(ssResult.SSCommMatrix)
+	}
+
+	return matrix, nil
+}
+
+func printFirewallReport(report *firewallverify.Result, format string) ([]byte, error) {
+	switch format {
+	case types.FormatJSON:
+		return json.MarshalIndent(report, "", "    ")
+	case types.FormatYAML:
+		return yaml.Marshal(report)
+	}
+
+	var sb strings.Builder
+	for _, n := range report.Nodes {
+		status := "PASS"
+		if !n.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&sb, "%s %s (%s)\n", status, n.Node, n.NodeGroup)
+		if n.Error != "" {
+			fmt.Fprintf(&sb, "    error: %s\n", n.Error)
+		}
+		if len(n.Missing) > 0 {
+			fmt.Fprintf(&sb, "    missing: %s\n", joinPortRanges(n.Missing))
+		}
+		if len(n.Extra) > 0 {
+			fmt.Fprintf(&sb, "    extra: %s\n", joinPortRanges(n.Extra))
+		}
+	}
+
+	return []byte(sb.String()), nil
+}
+
+func joinPortRanges(ranges []firewallverify.PortRange) string {
+	res := make([]string, 0, len(ranges))
+	for _, pr := range ranges {
+		res = append(res, pr.String())
+	}
+	return strings.Join(res, ", ")
+}
@@ -0,0 +1,80 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+
+	firewallverify "github.com/openshift-kni/commatrix/pkg/firewall-verify"
+)
+
+func TestPrintFirewallReport(t *testing.T) {
+	result := &firewallverify.Result{Nodes: []firewallverify.NodeResult{
+		{Node: "master-0", NodeGroup: "master"},
+		{
+			Node:      "worker-0",
+			NodeGroup: "worker",
+			Missing:   []firewallverify.PortRange{{Protocol: "TCP", Start: 30000, End: 32767}},
+			Extra:     []firewallverify.PortRange{{Protocol: "UDP", Start: 111, End: 111}},
+		},
+	}}
+
+	out, err := printFirewallReport(result, diffFormatText)
+	require.NoError(t, err)
+	assert.Equal(t, `PASS master-0 (master)
+FAIL worker-0 (worker)
+    missing: TCP/30000-32767
+    extra: UDP/111
+`, string(out))
+
+	out, err = printFirewallReport(result, "json")
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"nodeGroup": "worker"`)
+}
+
+func TestVerifyFirewallValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "invalid output format",
+			args:    []string{"verify-firewall", "--format", "csv"},
+			wantErr: "invalid format 'csv'",
+		},
+		{
+			name:    "matrix file with custom entries",
+			args:    []string{"verify-firewall", "--matrix", "m.csv", "--customEntriesPath", "c.csv", "--customEntriesFormat", "csv"},
+			wantErr: "--matrix cannot be used with --customEntriesPath, --host-open-ports or --restrict-internal",
+		},
+		{
+			name:    "matrix file with restrict internal",
+			args:    []string{"verify-firewall", "--matrix", "m.csv", "--restrict-internal"},
+			wantErr: "--matrix cannot be used with --customEntriesPath, --host-open-ports or --restrict-internal",
+		},
+		{
+			name:    "selection without exclusion",
+			args:    []string{"verify-firewall", "--keep-optional", "keep.yaml"},
+			wantErr: "you must specify --exclude-optional when using --keep-optional",
+		},
+		{
+			name:    "invalid custom node group",
+			args:    []string{"verify-firewall", "--custom-node-group", "invalid"},
+			wantErr: "invalid --custom-node-group value",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			streams, _, _, _ := genericiooptions.NewTestIOStreams()
+			cmd := NewCmd(nil, streams)
+			cmd.SetArgs(tt.args)
+			err := cmd.Execute()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+	commatrixcreator "github.com/openshift-kni/commatrix/pkg/commatrix-creator"
+	driftwatch "github.com/openshift-kni/commatrix/pkg/drift-watch"
+	"github.com/openshift-kni/commatrix/pkg/endpointslices"
+	"github.com/openshift-kni/commatrix/pkg/utils"
+)
+
+const metricsServerShutdownTimeout = 5 * time.Second
+
+var (
+	watchLong = templates.LongDesc(`
+              Watch the cluster and report drift of the communication matrix from a baseline matrix file.
+
+              Services, EndpointSlices, host-network Pods and Nodes are watched with informers. After a
+              change the entries of the changed EndpointSlices and Nodes are recomputed from the informer
+              cache and the matrix is compared with the baseline. When entries
+              appear or disappear relative to the baseline, a drift event is logged and, if configured,
+              POSTed as JSON to a webhook. Drift counts per node group are exposed on a Prometheus
+              /metrics endpoint.
+	`)
+	watchExample = templates.Examples(`
+			 # Watch for drift from a matrix saved with 'generate':
+			 oc commatrix watch --baseline communication-matrix/communication-matrix.csv
+
+			 # Send drift events to a webhook and serve metrics on port 9090:
+			 oc commatrix watch --baseline baseline.json --webhook-url https://hooks.example.com/commatrix --metrics-bind-address :9090
+	`)
+)
+
+type WatchOptions struct {
+	baselinePath       string
+	baselineFormat     string
+	webhookURL         string
+	metricsBindAddress string
+	debounce           time.Duration
+	*GenerateOptions
+}
+
+func NewCmdCommatrixWatch(cs *client.ClientSet, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &WatchOptions{GenerateOptions: NewCommatrixOptions(streams, cs)}
+	cmd := &cobra.Command{
+		Use:     "watch",
+		Short:   "Watch the cluster and report drift of the communication matrix from a baseline.",
+		Long:    watchLong,
+		Example: watchExample,
+		Args:    cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := ValidateWatch(o); err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return RunWatch(ctx, o)
+		},
+	}
+	cmd.Flags().StringVar(&o.baselinePath, "baseline", "", "Matrix file to compare the cluster with (required)")
+	cmd.Flags().StringVar(&o.baselineFormat, "baseline-format", "", "Format of the baseline file (json,yaml,csv). Detected from the file extension when empty")
+	cmd.Flags().StringVar(&o.webhookURL, "webhook-url", "", "URL to POST drift events to as JSON")
+	cmd.Flags().StringVar(&o.metricsBindAddress, "metrics-bind-address", ":8080", "Address the /metrics endpoint binds to. Set to 0 to disable it")
+	cmd.Flags().DurationVar(&o.debounce, "debounce", 10*time.Second, "Time to wait for more changes before recomputing the matrix")
+	cmd.Flags().BoolVar(&o.debug, "debug", false, "Debug logs")
+	cmd.Flags().StringVar(&o.customEntriesPath, "customEntriesPath", "", "Add custom entries from a file to the matrix")
+	cmd.Flags().StringVar(&o.customEntriesFormat, "customEntriesFormat", "", "Set the format of the custom entries file (json,yaml,csv)")
+	cmd.Flags().StringArrayVar(&o.customNodeGroupRaw, "custom-node-group", nil,
+		"Assign nodes matching a label selector to a custom group "+
+			"(format: groupName=labelSelector, e.g. mc-ingress=node-role.kubernetes.io/ingress). Repeatable.")
+
+	return cmd
+}
+
+func ValidateWatch(o *WatchOptions) error {
+	if o.baselinePath == "" {
+		return fmt.Errorf("--baseline is required")
+	}
+
+	if o.debounce < 0 {
+		return fmt.Errorf("--debounce must not be negative")
+	}
+
+	if err := validateCustomEntries(o.customEntriesPath, o.customEntriesFormat, validCustomEntriesFormats); err != nil {
+		return err
+	}
+
+	parsed, err := parseCustomNodeGroups(o.customNodeGroupRaw)
+	if err != nil {
+		return err
+	}
+	o.customNodeGroups = parsed
+
+	return nil
+}
+
+func RunWatch(ctx context.Context, o *WatchOptions) error {
+	if o.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	baseline, err := loadMatrixFile(o.baselinePath, o.baselineFormat)
+	if err != nil {
+		return fmt.Errorf("failed to load baseline: %w", err)
+	}
+
+	// Platform, topology, IPv6 and DHCP do not change at runtime, detect them once.
+	settings, err := detectClusterSettings(o.GenerateOptions)
+	if err != nil {
+		return err
+	}
+
+	informerCache, err := cache.New(o.cs.Config, cache.Options{Scheme: o.cs.Scheme()})
+	if err != nil {
+		return fmt.Errorf("failed to create informer cache: %w", err)
+	}
+	cachedClient, err := runtimeclient.New(o.cs.Config, runtimeclient.Options{
+		Scheme: o.cs.Scheme(),
+		Cache:  &runtimeclient.CacheOptions{Reader: informerCache},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create cached client: %w", err)
+	}
+
+	// Recompute the matrix from the informer cache instead of listing the objects again,
+	// and only for the objects that changed.
+	cachedCS := *o.cs
+	cachedCS.Client = cachedClient
+	cachedOpts := *o.GenerateOptions
+	cachedOpts.cs = &cachedCS
+	cachedOpts.utilsHelpers = utils.New(&cachedCS)
+	incremental := commatrixcreator.NewIncremental(
+		func() (*endpointslices.EndpointSlicesExporter, error) {
+			return endpointslices.New(&cachedCS, o.customNodeGroups)
+		},
+		settings.platformType, settings.controlPlaneTopology,
+		creatorOptions(&cachedOpts, settings.ipv6Enabled, settings.dhcpEnabled)...,
+	)
+
+	metrics := driftwatch.NewMetrics()
+	watcherOpts := []driftwatch.Option{
+		driftwatch.WithNotifier(driftwatch.LogNotifier{}),
+		driftwatch.WithMetrics(metrics),
+		driftwatch.WithDebounce(o.debounce),
+	}
+	if o.webhookURL != "" {
+		watcherOpts = append(watcherOpts, driftwatch.WithNotifier(driftwatch.NewWebhookNotifier(o.webhookURL)))
+	}
+	watcher := driftwatch.New(baseline, incremental.Matrix, watcherOpts...)
+
+	if err := driftwatch.AddEventHandlers(ctx, informerCache, watcher); err != nil {
+		return err
+	}
+
+	if o.metricsBindAddress != "0" {
+		stopMetrics := serveMetrics(o.metricsBindAddress, metrics)
+		defer stopMetrics()
+	}
+
+	go func() {
+		if err := informerCache.Start(ctx); err != nil {
+			log.Errorf("Informer cache stopped: %v", err)
+		}
+	}()
+	log.Debug("Waiting for the informer cache to sync")
+	if !informerCache.WaitForCacheSync(ctx) {
+		return fmt.Errorf("failed to sync the informer cache")
+	}
+
+	log.Infof("Watching for drift from baseline %s", o.baselinePath)
+	return watcher.Run(ctx)
+}
+
+// serveMetrics serves the metrics on addr in the background and returns a function that stops the server.
+func serveMetrics(addr string, metrics *driftwatch.Metrics) func() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		log.Infof("Serving metrics on %s/metrics", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Metrics server failed: %v", err)
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsServerShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Warnf("failed to shut down the metrics server: %v", err)
+		}
+	}
+}
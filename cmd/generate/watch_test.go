@@ -0,0 +1,44 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+)
+
+func TestWatchValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "missing baseline",
+			args:    []string{"watch"},
+			wantErr: "--baseline is required",
+		},
+		{
+			name:    "negative debounce",
+			args:    []string{"watch", "--baseline", "b.csv", "--debounce", "-1s"},
+			wantErr: "--debounce must not be negative",
+		},
+		{
+			name:    "custom entries without format",
+			args:    []string{"watch", "--baseline", "b.csv", "--customEntriesPath", "c.csv"},
+			wantErr: "you must specify the --customEntriesFormat",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			streams, _, _, _ := genericiooptions.NewTestIOStreams()
+			cmd := NewCmd(nil, streams)
+			cmd.SetArgs(tt.args)
+			err := cmd.Execute()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
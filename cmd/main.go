@@ -6,6 +6,7 @@ import (
 	"github.com/openshift-kni/commatrix/cmd/generate"
 	"github.com/openshift-kni/commatrix/pkg/client"
 	"github.com/openshift-kni/commatrix/pkg/errhandler"
+	"github.com/spf13/cobra"
 
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 )
@@ -14,11 +15,16 @@ func main() {
 	defer errhandler.RecoverAndExit()
 	ioStreams := genericiooptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
 
-	cs, err := client.New()
-	if err != nil {
-		errhandler.HandleAndExit(err)
-	}
+	// Offline subcommands work on local files only, so a missing cluster
+	// configuration is only reported for commands that need a cluster.
+	cs, clientErr := client.New()
 	root := generate.NewCmd(cs, ioStreams)
+	root.PersistentPreRunE = func(c *cobra.Command, args []string) error {
+		if clientErr != nil && generate.RequiresCluster(c) {
+			return clientErr
+		}
+		return nil
+	}
 	// Silence Cobra's automatic error/usage output to avoid leaking details.
 	root.SilenceErrors = true
 	root.SilenceUsage = true
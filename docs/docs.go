@@ -0,0 +1,10 @@
+// Package docs embeds the documented communication matrices, so they can be
+// used as a validation baseline by the commatrix CLI.
+package docs
+
+import "embed"
+
+// Stable holds the documented raw and unique matrices under docs/stable.
+//
+//go:embed stable/raw/*.csv stable/unique/*.csv
+var Stable embed.FS
@@ -0,0 +1,139 @@
+package baseline
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	configv1 "github.com/openshift/api/config/v1"
+
+	"github.com/openshift-kni/commatrix/docs"
+	matrixdiff "github.com/openshift-kni/commatrix/pkg/matrix-diff"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+const rawDocsDir = "stable/raw"
+
+// legacyNodeGroupHeader is the header used by the documented matrices for the NodeGroup column.
+const legacyNodeGroupHeader = "Node Role"
+
+// Result holds the outcome of validating a matrix against the documented baseline.
+type Result struct {
+	// DocType is the name of the documented matrix used as baseline (e.g. aws, bm, none-sno).
+	DocType string `json:"docType" yaml:"docType"`
+	// Undocumented holds the flows that are in the matrix but not in the baseline.
+	Undocumented []types.ComDetails `json:"undocumented" yaml:"undocumented"`
+	// Missing holds the documented flows that are not in the matrix.
+	Missing []types.ComDetails `json:"missing" yaml:"missing"`
+	// Changed holds the flows whose documented source CIDRs, address family, bind addresses
+	// or interface differ in the matrix (primary) from the baseline (secondary).
+	Changed []matrixdiff.Change `json:"changed" yaml:"changed"`
+}
+
+// Passed returns true if no undocumented or changed flows were found.
+// Missing flows are reported but do not fail the validation.
+func (r *Result) Passed() bool {
+	return len(r.Undocumented) == 0 && len(r.Changed) == 0
+}
+
+// DocType returns the name of the documented matrix matching the given platform and topology.
+func DocType(platformType configv1.PlatformType, topology configv1.TopologyMode) (string, error) {
+	var docType string
+	switch platformType {
+	case configv1.AWSPlatformType:
+		docType = "aws"
+	case configv1.BareMetalPlatformType:
+		docType = "bm"
+	case configv1.NonePlatformType:
+		docType = "none"
+	default:
+		return "", fmt.Errorf("no documented communication matrix for platform type %s", platformType)
+	}
+
+	if topology == configv1.SingleReplicaTopologyMode {
+		docType += "-sno"
+	}
+
+	return docType, nil
+}
+
+// Load returns the documented matrix matching the given platform and topology.
+// The default NodePort dynamic range is added to the documented matrix.
+func Load(platformType configv1.PlatformType, topology configv1.TopologyMode) (string, *types.ComMatrix, error) {
+	docType, err := DocType(platformType, topology)
+	if err != nil {
+		return "", nil, err
+	}
+
+	content, err := docs.Stable.ReadFile(path.Join(rawDocsDir, docType+".csv"))
+	if err != nil {
+		return "", nil, fmt.Errorf("no documented communication matrix for platform type %s and topology %s",
+			platformType, topology)
+	}
+
+	docMatrix, err := ParseDocCSV(content)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse documented matrix %s: %w", docType, err)
+	}
+	docMatrix.DynamicRanges = append(docMatrix.DynamicRanges, types.KubeletNodePortDefaultDynamicRange...)
+
+	return docType, docMatrix, nil
+}
+
+// ParseDocCSV parses a documented matrix, accepting the legacy "Node Role" header.
+func ParseDocCSV(content []byte) (*types.ComMatrix, error) {
+	content = bytes.Replace(content, []byte(legacyNodeGroupHeader), []byte("NodeGroup"), 1)
+	return types.ParseToComMatrix(content, types.FormatCSV)
+}
+
+// Validate compares matrix with the documented docMatrix.
+// Flows matching an entry of the allowlist are ignored, and so are
+// undocumented ports that fall in one of the documented dynamic ranges.
+// The attributes of a flow are only compared when the documented flow sets any of them.
+func Validate(docType string, matrix, docMatrix, allowlist *types.ComMatrix) *Result {
+	if allowlist == nil {
+		allowlist = &types.ComMatrix{}
+	}
+	diff := matrixdiff.Generate(matrix, docMatrix, matrixdiff.WithAttributeChanges())
+
+	result := &Result{DocType: docType, Undocumented: []types.ComDetails{}, Missing: []types.ComDetails{},
+		Changed: []matrixdiff.Change{}}
+	for _, cd := range diff.GetUniquePrimary().Ports {
+		if allowlist.Contains(cd) || inDynamicRanges(cd, docMatrix.DynamicRanges) {
+			continue
+		}
+		result.Undocumented = append(result.Undocumented, cd)
+	}
+
+	for _, cd := range diff.GetUniqueSecondary().Ports {
+		if allowlist.Contains(cd) {
+			continue
+		}
+		result.Missing = append(result.Missing, cd)
+	}
+
+	for _, change := range diff.GetChanged() {
+		if allowlist.Contains(change.Primary) || !hasAttributes(change.Secondary) {
+			continue
+		}
+		result.Changed = append(result.Changed, change)
+	}
+
+	return result
+}
+
+// hasAttributes returns true if the entry sets any of the attributes compared by
+// ComDetails.SameAttributes. The documented matrices usually set none of them.
+func hasAttributes(cd types.ComDetails) bool {
+	return !cd.SameAttributes(types.ComDetails{})
+}
+
+func inDynamicRanges(cd types.ComDetails, dynamicRanges types.DynamicRangeList) bool {
+	for _, dr := range dynamicRanges {
+		if cd.Protocol == dr.Protocol && cd.Port >= dr.MinPort && cd.Port <= dr.MaxPort {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package baseline
+
+import (
+	g "github.com/onsi/ginkgo/v2"
+	o "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+var _ = g.Describe("DocType", func() {
+	g.DescribeTable("maps platform and topology to the documented matrix",
+		func(platformType configv1.PlatformType, topology configv1.TopologyMode, expected string) {
+			docType, err := DocType(platformType, topology)
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(docType).To(o.Equal(expected))
+		},
+		g.Entry("AWS multi-node", configv1.AWSPlatformType, configv1.HighlyAvailableTopologyMode, "aws"),
+		g.Entry("AWS SNO", configv1.AWSPlatformType, configv1.SingleReplicaTopologyMode, "aws-sno"),
+		g.Entry("BareMetal multi-node", configv1.BareMetalPlatformType, configv1.HighlyAvailableTopologyMode, "bm"),
+		g.Entry("None SNO", configv1.NonePlatformType, configv1.SingleReplicaTopologyMode, "none-sno"),
+	)
+
+	g.It("errors on unsupported platforms", func() {
+		_, err := DocType(configv1.GCPPlatformType, configv1.HighlyAvailableTopologyMode)
+		o.Expect(err).To(o.HaveOccurred())
+	})
+})
+
+var _ = g.Describe("Load", func() {
+	g.It("loads the embedded documented matrix with the legacy header", func() {
+		docType, docMatrix, err := Load(configv1.AWSPlatformType, configv1.HighlyAvailableTopologyMode)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(docType).To(o.Equal("aws"))
+		o.Expect(docMatrix.Ports).ToNot(o.BeEmpty())
+		o.Expect(docMatrix.Contains(types.ComDetails{Protocol: "TCP", Port: 6443, NodeGroup: "master"})).To(o.BeTrue())
+		o.Expect(docMatrix.DynamicRanges).To(o.Equal(types.KubeletNodePortDefaultDynamicRange))
+	})
+
+	g.It("errors when no matrix is documented for the cluster type", func() {
+		_, _, err := Load(configv1.NonePlatformType, configv1.HighlyAvailableTopologyMode)
+		o.Expect(err).To(o.HaveOccurred())
+		o.Expect(err.Error()).To(o.ContainSubstring("no documented communication matrix"))
+	})
+})
+
+var _ = g.Describe("Validate", func() {
+	docMatrix := &types.ComMatrix{
+		Ports: []types.ComDetails{
+			{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "master", Service: "sshd"},
+			{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master", Service: "apiserver"},
+		},
+		DynamicRanges: types.KubeletNodePortDefaultDynamicRange,
+	}
+
+	g.It("reports undocumented and missing flows", func() {
+		matrix := &types.ComMatrix{Ports: []types.ComDetails{
+			{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master", Service: "apiserver"},
+			{Direction: "Ingress", Protocol: "TCP", Port: 9999, NodeGroup: "master", Service: "extra"},
+		}}
+
+		result := Validate("aws", matrix, docMatrix, nil)
+		o.Expect(result.Passed()).To(o.BeFalse())
+		o.Expect(result.DocType).To(o.Equal("aws"))
+		o.Expect(result.Undocumented).To(o.HaveLen(1))
+		o.Expect(result.Undocumented[0].Port).To(o.Equal(9999))
+		o.Expect(result.Missing).To(o.HaveLen(1))
+		o.Expect(result.Missing[0].Port).To(o.Equal(22))
+	})
+
+	g.It("ignores allowlisted flows", func() {
+		matrix := &types.ComMatrix{Ports: []types.ComDetails{
+			{Direction: "Ingress", Protocol: "TCP", Port: 9999, NodeGroup: "master", Service: "extra"},
+		}}
+		allowlist := &types.ComMatrix{Ports: []types.ComDetails{
+			{Protocol: "TCP", Port: 9999, NodeGroup: "master"},
+			{Protocol: "TCP", Port: 22, NodeGroup: "master"},
+			{Protocol: "TCP", Port: 6443, NodeGroup: "master"},
+		}}
+
+		result := Validate("aws", matrix, docMatrix, allowlist)
+		o.Expect(result.Passed()).To(o.BeTrue())
+		o.Expect(result.Undocumented).To(o.BeEmpty())
+		o.Expect(result.Missing).To(o.BeEmpty())
+	})
+
+	g.It("reports flows whose documented source CIDRs changed", func() {
+		restrictedDoc := &types.ComMatrix{Ports: []types.ComDetails{
+			{Direction: "Ingress", Protocol: "TCP", Port: 2379, NodeGroup: "master", Service: "etcd",
+				SourceCIDRs: types.CIDRList{"10.0.0.0/24"}},
+			{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master", Service: "apiserver"},
+		}}
+		etcd := types.ComDetails{Direction: "Ingress", Protocol: "TCP", Port: 2379, NodeGroup: "master", Service: "etcd"}
+		apiserver := types.ComDetails{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master",
+			Service: "apiserver", AddressFamily: types.AddressFamilyIPv4}
+		matrix := &types.ComMatrix{Ports: []types.ComDetails{etcd, apiserver}}
+
+		result := Validate("aws", matrix, restrictedDoc, nil)
+		o.Expect(result.Passed()).To(o.BeFalse())
+		o.Expect(result.Undocumented).To(o.BeEmpty())
+		o.Expect(result.Changed).To(o.HaveLen(1))
+		o.Expect(result.Changed[0].Primary).To(o.Equal(etcd))
+		o.Expect(result.Changed[0].Secondary.SourceCIDRs).To(o.Equal(types.CIDRList{"10.0.0.0/24"}))
+	})
+
+	g.It("ignores undocumented ports in the documented dynamic ranges", func() {
+		matrix := &types.ComMatrix{Ports: []types.ComDetails{
+			{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "master", Service: "sshd"},
+			{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master", Service: "apiserver"},
+			{Direction: "Ingress", Protocol: "UDP", Port: 31000, NodeGroup: "worker", Service: "nodeport"},
+		}}
+
+		result := Validate("aws", matrix, docMatrix, nil)
+		o.Expect(result.Passed()).To(o.BeTrue())
+	})
+})
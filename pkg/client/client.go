@@ -63,9 +63,24 @@ func New() (*ClientSet, error) {
 
 	clientSet.Config = restConfig
 
+	myScheme, err := NewScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	clientSet.Client, err = runtimeclient.New(restConfig, runtimeclient.Options{Scheme: myScheme})
+	if err != nil {
+		return nil, err
+	}
+
+	return clientSet, nil
+}
+
+// NewScheme returns a scheme with the API types used by the communication matrix.
+func NewScheme() (*runtime.Scheme, error) {
 	myScheme := runtime.NewScheme()
 
-	err = corev1.AddToScheme(myScheme)
+	err := corev1.AddToScheme(myScheme)
 	if err != nil {
 		return nil, err
 	}
@@ -90,10 +105,5 @@ func New() (*ClientSet, error) {
 		return nil, err
 	}
 
-	clientSet.Client, err = runtimeclient.New(restConfig, runtimeclient.Options{Scheme: myScheme})
-	if err != nil {
-		return nil, err
-	}
-
-	return clientSet, nil
+	return myScheme, nil
 }
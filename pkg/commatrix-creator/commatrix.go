@@ -1,6 +1,7 @@
 package commatrixcreator
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -8,13 +9,18 @@ import (
 	"slices"
 
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 
 	dynamicranges "github.com/openshift-kni/commatrix/pkg/dynamic-ranges"
 	"github.com/openshift-kni/commatrix/pkg/endpointslices"
+	internalflows "github.com/openshift-kni/commatrix/pkg/internal-flows"
+	"github.com/openshift-kni/commatrix/pkg/lint"
 	"github.com/openshift-kni/commatrix/pkg/mcp"
 	"github.com/openshift-kni/commatrix/pkg/types"
 	"github.com/openshift-kni/commatrix/pkg/utils"
 	configv1 "github.com/openshift/api/config/v1"
+	clientOptions "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type CommunicationMatrixCreator struct {
@@ -25,6 +31,8 @@ type CommunicationMatrixCreator struct {
 	controlPlaneTopology configv1.TopologyMode
 	ipv6Enabled          bool
 	dhcpEnabled          bool
+	restrictInternal     bool
+	egress               bool
 	utilsHelpers         utils.UtilsInterface
 }
 
@@ -57,6 +65,21 @@ func WithDHCP() Option {
 	}
 }
 
+// WithRestrictInternal restricts the node-to-node flows to the addresses of the nodes and
+// the machine and cluster networks.
+func WithRestrictInternal() Option {
+	return func(c *CommunicationMatrixCreator) {
+		c.restrictInternal = true
+	}
+}
+
+// WithEgress adds the egress static entries of the flows the nodes always need.
+func WithEgress() Option {
+	return func(c *CommunicationMatrixCreator) {
+		c.egress = true
+	}
+}
+
 func WithUtilsHelpers(u utils.UtilsInterface) Option {
 	return func(c *CommunicationMatrixCreator) {
 		c.utilsHelpers = u
@@ -124,6 +147,13 @@ func (cm *CommunicationMatrixCreator) CreateEndpointMatrix() (*types.ComMatrix,
 		return nil, fmt.Errorf("failed to get dynamic ranges: %w", err)
 	}
 
+	return cm.completeMatrix(epSliceComDetails, dynamicRanges, nodes)
+}
+
+// completeMatrix adds the custom entries to the entries found in the cluster, restricts the
+// internal flows and applies the address families of the node groups.
+func (cm *CommunicationMatrixCreator) completeMatrix(epSliceComDetails []types.ComDetails,
+	dynamicRanges types.DynamicRangeList, nodes []corev1.Node) (*types.ComMatrix, error) {
 	if cm.customEntriesPath != "" {
 		log.Debug("Loading custom entries from file")
 		customMatrix, err := cm.GetComMatrixFromFile()
@@ -131,16 +161,54 @@ func (cm *CommunicationMatrixCreator) CreateEndpointMatrix() (*types.ComMatrix,
 			log.Errorf("Failed adding custom entries: %s", err)
 			return nil, fmt.Errorf("failed adding custom entries: %w", err)
 		}
+		restrictSources(epSliceComDetails, customMatrix.Ports)
+		restrictInterfaces(epSliceComDetails, customMatrix.Ports)
 		epSliceComDetails = append(epSliceComDetails, customMatrix.Ports...)
 		dynamicRanges = append(dynamicRanges, customMatrix.DynamicRanges...)
 	}
 
+	if cm.restrictInternal {
+		log.Debug("Restricting the internal flows to the cluster sources")
+		sources, machineNetworkKnown, err := internalflows.GetSources(cm.exporter, nodes)
+		if err != nil {
+			log.Errorf("Failed to get the internal flows sources: %v", err)
+			return nil, fmt.Errorf("failed to get the internal flows sources: %w", err)
+		}
+		internalflows.Restrict(epSliceComDetails, sources, machineNetworkKnown)
+	}
+
 	commMatrix := &types.ComMatrix{Ports: epSliceComDetails, DynamicRanges: dynamicRanges}
+
+	log.Debug("Applying the address families of the node groups")
+	groupFamilies, err := cm.nodeGroupAddressFamilies(nodes)
+	if err != nil {
+		log.Errorf("Failed to get the address families of the node groups: %v", err)
+		return nil, fmt.Errorf("failed to get the address families of the node groups: %w", err)
+	}
+	commMatrix.ApplyNodeGroupAddressFamilies(groupFamilies)
+
 	log.Debug("Sorting ComMatrix and removing duplicates")
 	commMatrix.SortAndRemoveDuplicates()
 	return commMatrix, nil
 }
 
+// nodeGroupAddressFamilies returns the address family of every node group, from the
+// addresses of its nodes and the machine networks of the cluster.
+func (cm *CommunicationMatrixCreator) nodeGroupAddressFamilies(nodes []corev1.Node) (map[string]string, error) {
+	infra := &configv1.Infrastructure{}
+	err := cm.exporter.Get(context.TODO(), clientOptions.ObjectKey{Name: "cluster"}, infra)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get Infrastructure config: %w", err)
+	}
+
+	machineNetworks, err := internalflows.GetMachineNetworks(cm.exporter, infra.Status.PlatformStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.BuildNodeGroupAddressFamilies(nodes, cm.exporter.NodeToGroup(), machineNetworks), nil
+}
+
 func (cm *CommunicationMatrixCreator) GetComMatrixFromFile() (*types.ComMatrix, error) {
 	log.Debugf("Opening file %s", cm.customEntriesPath)
 	f, err := os.Open(filepath.Clean(cm.customEntriesPath))
@@ -157,6 +225,12 @@ func (cm *CommunicationMatrixCreator) GetComMatrixFromFile() (*types.ComMatrix,
 		return nil, fmt.Errorf("failed to read file %s: %w", cm.customEntriesPath, err)
 	}
 
+	log.Debugf("Validating file content with format %s", cm.customEntriesFormat)
+	if err := lint.Validate(raw, cm.customEntriesFormat); err != nil {
+		log.Errorf("Failed to validate %s file: %v", cm.customEntriesFormat, err)
+		return nil, fmt.Errorf("failed to validate custom entries file: %w", err)
+	}
+
 	log.Debugf("Unmarshalling file content with format %s", cm.customEntriesFormat)
 	res, err := types.ParseToComMatrix(raw, cm.customEntriesFormat)
 	if err != nil {
@@ -168,10 +242,54 @@ func (cm *CommunicationMatrixCreator) GetComMatrixFromFile() (*types.ComMatrix,
 	return res, nil
 }
 
+// restrictSources sets the source CIDRs of the custom entries on the entries of the same
+// flow, so a custom entry can restrict a flow found in the cluster to its sources.
+func restrictSources(entries []types.ComDetails, customEntries []types.ComDetails) {
+	sources := map[string]types.CIDRList{}
+	for _, cd := range customEntries {
+		if len(cd.SourceCIDRs) > 0 && !cd.IsEgress() {
+			sources[cd.Key()] = cd.SourceCIDRs
+		}
+	}
+	if len(sources) == 0 {
+		return
+	}
+
+	for i := range entries {
+		if s, ok := sources[entries[i].Key()]; ok {
+			entries[i].SourceCIDRs = s
+		}
+	}
+}
+
+// restrictInterfaces sets the interface of the custom entries on the entries of the same
+// flow, so a custom entry can scope a flow found in the cluster to a network interface.
+func restrictInterfaces(entries []types.ComDetails, customEntries []types.ComDetails) {
+	interfaces := map[string]string{}
+	for _, cd := range customEntries {
+		if cd.Interface != "" && !cd.IsEgress() {
+			interfaces[cd.Key()] = cd.Interface
+		}
+	}
+	if len(interfaces) == 0 {
+		return
+	}
+
+	for i := range entries {
+		if iface, ok := interfaces[entries[i].Key()]; ok {
+			entries[i].Interface = iface
+		}
+	}
+}
+
 // getStaticEntries is a convenience wrapper around types.GetStaticEntries
 // that forwards the creator's platform configuration.
 func (cm *CommunicationMatrixCreator) getStaticEntries() ([]types.ComDetails, error) {
-	return types.GetStaticEntries(cm.platformType, cm.controlPlaneTopology, cm.ipv6Enabled, cm.dhcpEnabled)
+	entries, err := types.GetStaticEntries(cm.platformType, cm.controlPlaneTopology, cm.ipv6Enabled, cm.dhcpEnabled)
+	if err != nil || !cm.egress {
+		return entries, err
+	}
+	return append(entries, types.GetEgressStaticEntries(cm.controlPlaneTopology)...), nil
 }
 
 // expandStaticEntriesByPool uses MCP-derived role per pool.
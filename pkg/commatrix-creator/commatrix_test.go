@@ -436,6 +436,20 @@ var (
 			ServiceNodePortRange: "1024-65535",
 		},
 	}
+
+	testInfrastructure = &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: configv1.InfrastructureStatus{
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.BareMetalPlatformType,
+				BareMetal: &configv1.BareMetalPlatformStatus{
+					MachineNetworks: []configv1.CIDR{"192.168.1.0/24", "fd00:1::/64"},
+				},
+			},
+		},
+	}
 )
 
 var _ = g.Describe("Commatrix creator pkg tests", func() {
@@ -637,7 +651,7 @@ var _ = g.Describe("Commatrix creator pkg tests", func() {
 			err = configv1.AddToScheme(sch)
 			o.Expect(err).NotTo(o.HaveOccurred())
 
-			fakeClient := fake.NewClientBuilder().WithScheme(sch).WithObjects(testNode, testNodeWorker, testPod, testService, testEndpointSlice, mcpWorker, mcpMaster, testNetwork).Build()
+			fakeClient := fake.NewClientBuilder().WithScheme(sch).WithObjects(testNode, testNodeWorker, testPod, testService, testEndpointSlice, mcpWorker, mcpMaster, testNetwork, testInfrastructure).Build()
 			fakeClientset := fakek.NewSimpleClientset(testNode, testNodeWorker)
 
 			clientset := &client.ClientSet{
@@ -745,6 +759,81 @@ var _ = g.Describe("Commatrix creator pkg tests", func() {
 			o.Expect(diff.GetUniqueSecondary().Ports).To(o.BeEmpty())
 		})
 
+		g.It("Should limit the entries of the single-stack node groups to their address family", func() {
+			ipv4Nodes := []corev1.Node{*testNode.DeepCopy(), *testNodeWorker.DeepCopy()}
+			for i := range ipv4Nodes {
+				ipv4Nodes[i].Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: fmt.Sprintf("192.168.1.%d", i+10)}}
+			}
+			ipv4Utils := mock_utils.NewMockUtilsInterface(ctrl)
+			ipv4Utils.EXPECT().ListNodes().Return(ipv4Nodes, nil).AnyTimes()
+
+			commatrixCreator := New(
+				configv1.AWSPlatformType,
+				configv1.HighlyAvailableTopologyMode,
+				WithExporter(endpointSlices),
+				WithUtilsHelpers(ipv4Utils),
+				WithIPv6(),
+			)
+			commatrix, err := commatrixCreator.CreateEndpointMatrix()
+			o.Expect(err).ToNot(o.HaveOccurred())
+
+			o.Expect(commatrix.Ports).ToNot(o.BeEmpty())
+			for _, cd := range commatrix.Ports {
+				o.Expect(cd.AddressFamily).To(o.Equal(types.AddressFamilyIPv4), cd.String())
+				o.Expect(cd.Port).ToNot(o.Equal(546), cd.String())
+			}
+		})
+
+		g.It("Should restrict the internal flows to the cluster sources", func() {
+			commatrixCreator := New(
+				configv1.BareMetalPlatformType,
+				configv1.HighlyAvailableTopologyMode,
+				WithExporter(endpointSlices),
+				WithUtilsHelpers(mockUtils),
+				WithRestrictInternal(),
+			)
+			commatrix, err := commatrixCreator.CreateEndpointMatrix()
+			o.Expect(err).ToNot(o.HaveOccurred())
+
+			var internal, external int
+			for _, cd := range commatrix.Ports {
+				switch cd.Port {
+				case 10250, 2379, 2380:
+					internal++
+					o.Expect(cd.SourceCIDRs).To(o.Equal(types.CIDRList{"192.168.1.0/24", "fd00:1::/64"}), cd.String())
+				case 22:
+					external++
+					o.Expect(cd.SourceCIDRs).To(o.BeEmpty())
+				}
+			}
+			o.Expect(internal).ToNot(o.BeZero())
+			o.Expect(external).ToNot(o.BeZero())
+		})
+
+		g.It("Should add the egress static entries with WithEgress", func() {
+			commatrixCreator := New(
+				configv1.AWSPlatformType,
+				configv1.HighlyAvailableTopologyMode,
+				WithExporter(endpointSlices),
+				WithUtilsHelpers(mockUtils),
+				WithEgress(),
+			)
+			commatrix, err := commatrixCreator.CreateEndpointMatrix()
+			o.Expect(err).ToNot(o.HaveOccurred())
+
+			egress := map[string]bool{}
+			for _, cd := range commatrix.Ports {
+				if cd.IsEgress() {
+					egress[fmt.Sprintf("%s-%s/%d-%s", cd.NodeGroup, cd.Protocol, cd.Port, cd.Peer)] = true
+				}
+			}
+			o.Expect(egress).To(o.HaveKey("worker-UDP/6081-master"))
+			o.Expect(egress).To(o.HaveKey("worker-UDP/53-external"))
+			o.Expect(egress).To(o.HaveKey("worker-UDP/123-external"))
+			o.Expect(egress).To(o.HaveKey("worker-TCP/6443-master"))
+			o.Expect(egress).To(o.HaveKey("master-TCP/2380-master"))
+		})
+
 		g.It("Should filter out localhost-bound ports from endpoint matrix", func() {
 			g.By("Setting up fake client with localhost test resources")
 			sch := runtime.NewScheme()
@@ -901,6 +990,44 @@ var _ = g.Describe("Commatrix creator pkg tests", func() {
 		})
 	})
 
+	g.Context("restrictSources", func() {
+		g.It("sets the sources of the custom entries on the entries of the same flow", func() {
+			entries := []types.ComDetails{
+				{Direction: "Ingress", Protocol: "TCP", Port: 2379, NodeGroup: "master", Service: "etcd"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 2379, NodeGroup: "worker", Service: "etcd"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master", Service: "apiserver"},
+			}
+			custom := []types.ComDetails{
+				{Direction: "Ingress", Protocol: "TCP", Port: 2379, NodeGroup: "master", SourceCIDRs: types.CIDRList{"10.0.0.0/16"}},
+				{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master"},
+			}
+
+			restrictSources(entries, custom)
+			o.Expect(entries[0].SourceCIDRs).To(o.Equal(types.CIDRList{"10.0.0.0/16"}))
+			o.Expect(entries[1].SourceCIDRs).To(o.BeEmpty())
+			o.Expect(entries[2].SourceCIDRs).To(o.BeEmpty())
+		})
+	})
+
+	g.Context("restrictInterfaces", func() {
+		g.It("sets the interface of the custom entries on the entries of the same flow", func() {
+			entries := []types.ComDetails{
+				{Direction: "Ingress", Protocol: "SCTP", Port: 38412, NodeGroup: "worker", Service: "amf"},
+				{Direction: "Ingress", Protocol: "SCTP", Port: 38412, NodeGroup: "master", Service: "amf"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master", Service: "apiserver"},
+			}
+			custom := []types.ComDetails{
+				{Direction: "Ingress", Protocol: "SCTP", Port: 38412, NodeGroup: "worker", Interface: "net1"},
+				{Direction: "Egress", Protocol: "TCP", Port: 6443, NodeGroup: "master", Interface: "br-ex"},
+			}
+
+			restrictInterfaces(entries, custom)
+			o.Expect(entries[0].Interface).To(o.Equal("net1"))
+			o.Expect(entries[1].Interface).To(o.BeEmpty())
+			o.Expect(entries[2].Interface).To(o.BeEmpty())
+		})
+	})
+
 	g.Context("expandEntriesForPools", func() {
 		g.It("should fan-out role-scoped entries to all matching pools", func() {
 			// Given two static entries: one for master and one for worker
@@ -0,0 +1,274 @@
+package commatrixcreator
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	rtclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	dynamicranges "github.com/openshift-kni/commatrix/pkg/dynamic-ranges"
+	"github.com/openshift-kni/commatrix/pkg/endpointslices"
+	"github.com/openshift-kni/commatrix/pkg/mcp"
+	"github.com/openshift-kni/commatrix/pkg/types"
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+// Incremental creates the same matrix as CreateEndpointMatrix, but keeps the entries
+// contributed by every EndpointSlice and Node so that only the contributions of the
+// objects that changed are recomputed.
+type Incremental struct {
+	cm          *CommunicationMatrixCreator
+	newExporter func() (*endpointslices.EndpointSlicesExporter, error)
+	loaded      bool
+
+	staticEntries []types.ComDetails
+	nodes         []corev1.Node
+	// nodeEntries are the static entries of every node, expanded to its group.
+	nodeEntries map[string][]types.ComDetails
+	// endpointSlices are the entries of the EndpointSlices the services are exposed with.
+	endpointSlices map[k8stypes.NamespacedName]endpointSliceEntries
+	// serviceSlices maps every exposed service to the EndpointSlice its entries come from.
+	serviceSlices map[k8stypes.NamespacedName]k8stypes.NamespacedName
+}
+
+type endpointSliceEntries struct {
+	service k8stypes.NamespacedName
+	// nodes are the nodes of the endpoints, their groups are the groups of the entries.
+	nodes   sets.Set[string]
+	entries []types.ComDetails
+}
+
+// NewIncremental returns an Incremental creator. newExporter is called again every time
+// nodes change, to get the new groups of the nodes.
+func NewIncremental(
+	newExporter func() (*endpointslices.EndpointSlicesExporter, error),
+	platformType configv1.PlatformType,
+	topology configv1.TopologyMode,
+	opts ...Option,
+) *Incremental {
+	return &Incremental{
+		cm:          New(platformType, topology, opts...),
+		newExporter: newExporter,
+	}
+}
+
+// Matrix returns the communication matrix of the cluster. The first call computes the
+// contributions of all the objects. The next ones only recompute the contributions of the
+// objects related to the changed Services, EndpointSlices, Pods and Nodes, given in their
+// last known state.
+func (ic *Incremental) Matrix(changed []rtclient.Object) (*types.ComMatrix, error) {
+	if !ic.loaded {
+		if err := ic.load(); err != nil {
+			return nil, err
+		}
+	} else if err := ic.update(changed); err != nil {
+		return nil, err
+	}
+
+	entries := []types.ComDetails{}
+	for _, eps := range ic.endpointSlices {
+		entries = append(entries, eps.entries...)
+	}
+	// Like ExpandStaticEntriesByPool, keep the static entries as-is when no node has a role.
+	if len(ic.nodeEntries) == 0 {
+		entries = append(entries, ic.staticEntries...)
+	}
+	for _, nodeEntries := range ic.nodeEntries {
+		entries = append(entries, nodeEntries...)
+	}
+
+	dynamicRanges, err := dynamicranges.GetDynamicRanges(ic.cm.exporter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dynamic ranges: %w", err)
+	}
+
+	return ic.cm.completeMatrix(entries, dynamicRanges, ic.nodes)
+}
+
+func (ic *Incremental) load() error {
+	log.Debug("Loading the contributions of all the EndpointSlices and Nodes")
+	staticEntries, err := ic.cm.getStaticEntries()
+	if err != nil {
+		return fmt.Errorf("failed adding static entries: %w", err)
+	}
+	ic.staticEntries = staticEntries
+	ic.nodeEntries = map[string][]types.ComDetails{}
+	ic.endpointSlices = map[k8stypes.NamespacedName]endpointSliceEntries{}
+	ic.serviceSlices = map[k8stypes.NamespacedName]k8stypes.NamespacedName{}
+
+	if _, err := ic.reloadNodes(); err != nil {
+		return err
+	}
+	for _, node := range ic.nodes {
+		ic.updateNode(node.Name)
+	}
+
+	servicesList := &corev1.ServiceList{}
+	if err := ic.cm.exporter.List(context.TODO(), servicesList); err != nil {
+		return fmt.Errorf("failed to list services: %w", err)
+	}
+	for _, service := range servicesList.Items {
+		if err := ic.updateService(rtclient.ObjectKeyFromObject(&service)); err != nil {
+			return err
+		}
+	}
+
+	ic.loaded = true
+	return nil
+}
+
+func (ic *Incremental) update(changed []rtclient.Object) error {
+	services := sets.New[k8stypes.NamespacedName]()
+	nodes := sets.New[string]()
+	for _, obj := range changed {
+		switch obj := obj.(type) {
+		case *corev1.Node:
+			nodes.Insert(obj.Name)
+		case *corev1.Service:
+			services.Insert(rtclient.ObjectKeyFromObject(obj))
+		case *discoveryv1.EndpointSlice:
+			if name := obj.Labels[discoveryv1.LabelServiceName]; name != "" {
+				services.Insert(k8stypes.NamespacedName{Namespace: obj.Namespace, Name: name})
+			}
+		case *corev1.Pod:
+			selecting, err := ic.servicesSelecting(obj)
+			if err != nil {
+				return err
+			}
+			services.Insert(selecting...)
+		}
+	}
+
+	if nodes.Len() > 0 {
+		regrouped, err := ic.reloadNodes()
+		if err != nil {
+			return err
+		}
+		for name := range nodes.Union(regrouped) {
+			ic.updateNode(name)
+		}
+		// The groups of the entries of an EndpointSlice are the groups of its nodes.
+		for _, eps := range ic.endpointSlices {
+			if eps.nodes.HasAny(regrouped.UnsortedList()...) {
+				services.Insert(eps.service)
+			}
+		}
+	}
+
+	for key := range services {
+		if err := ic.updateService(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reloadNodes lists the nodes and resolves their groups again. It returns the nodes
+// whose group changed, including the added and deleted ones.
+func (ic *Incremental) reloadNodes() (sets.Set[string], error) {
+	exporter, err := ic.newExporter()
+	if err != nil {
+		return nil, fmt.Errorf("failed creating the endpointslices exporter: %w", err)
+	}
+	nodes, err := ic.cm.utilsHelpers.ListNodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	regrouped := sets.New[string]()
+	if ic.cm.exporter != nil {
+		oldGroups, newGroups := ic.cm.exporter.NodeToGroup(), exporter.NodeToGroup()
+		for name, group := range oldGroups {
+			if newGroups[name] != group {
+				regrouped.Insert(name)
+			}
+		}
+		for name, group := range newGroups {
+			if oldGroups[name] != group {
+				regrouped.Insert(name)
+			}
+		}
+	}
+
+	ic.cm.exporter = exporter
+	ic.nodes = nodes
+	return regrouped, nil
+}
+
+// updateNode recomputes the static entries of a node.
+func (ic *Incremental) updateNode(name string) {
+	delete(ic.nodeEntries, name)
+	for _, node := range ic.nodes {
+		if node.Name != name {
+			continue
+		}
+		poolRoles := mcp.GetPoolRolesForStaticEntriesExpansion([]corev1.Node{node}, ic.cm.exporter.NodeToGroup())
+		if len(poolRoles) > 0 {
+			ic.nodeEntries[name] = ExpandStaticEntriesByPool(ic.staticEntries, poolRoles)
+		}
+	}
+}
+
+// updateService recomputes the entries of the EndpointSlice a service is exposed with.
+func (ic *Incremental) updateService(key k8stypes.NamespacedName) error {
+	if slice, ok := ic.serviceSlices[key]; ok {
+		delete(ic.endpointSlices, slice)
+		delete(ic.serviceSlices, key)
+	}
+
+	service := &corev1.Service{}
+	if err := ic.cm.exporter.Get(context.TODO(), key, service); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get service %s: %w", key, err)
+	}
+
+	info, err := ic.cm.exporter.LoadServiceInfo(*service)
+	if err != nil || info == nil {
+		return err
+	}
+	entries, err := ic.cm.exporter.ComDetailsOf(*info)
+	if err != nil {
+		return err
+	}
+
+	nodes := sets.New[string]()
+	for _, endpoint := range info.EndpointSlice.Endpoints {
+		if endpoint.NodeName != nil {
+			nodes.Insert(*endpoint.NodeName)
+		}
+	}
+	slice := rtclient.ObjectKeyFromObject(&info.EndpointSlice)
+	ic.serviceSlices[key] = slice
+	ic.endpointSlices[slice] = endpointSliceEntries{service: key, nodes: nodes, entries: entries}
+	return nil
+}
+
+// servicesSelecting returns the services of the pod's namespace whose selector matches the pod.
+func (ic *Incremental) servicesSelecting(pod *corev1.Pod) ([]k8stypes.NamespacedName, error) {
+	servicesList := &corev1.ServiceList{}
+	if err := ic.cm.exporter.List(context.TODO(), servicesList, rtclient.InNamespace(pod.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	res := []k8stypes.NamespacedName{}
+	for _, service := range servicesList.Items {
+		if len(service.Spec.Selector) == 0 {
+			continue
+		}
+		if labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			res = append(res, rtclient.ObjectKeyFromObject(&service))
+		}
+	}
+
+	return res, nil
+}
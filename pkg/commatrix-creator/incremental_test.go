@@ -0,0 +1,89 @@
+package commatrixcreator
+
+import (
+	"context"
+
+	g "github.com/onsi/ginkgo/v2"
+	o "github.com/onsi/gomega"
+	gomock "go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	rtclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+	"github.com/openshift-kni/commatrix/pkg/endpointslices"
+	"github.com/openshift-kni/commatrix/pkg/types"
+	mock_utils "github.com/openshift-kni/commatrix/pkg/utils/mock"
+	configv1 "github.com/openshift/api/config/v1"
+	machineconfigurationv1 "github.com/openshift/api/machineconfiguration/v1"
+)
+
+var _ = g.Describe("Incremental", func() {
+	var (
+		fakeClient  rtclient.Client
+		incremental *Incremental
+		full        func() *types.ComMatrix
+	)
+
+	g.BeforeEach(func() {
+		sch := runtime.NewScheme()
+		o.Expect(corev1.AddToScheme(sch)).To(o.Succeed())
+		o.Expect(discoveryv1.AddToScheme(sch)).To(o.Succeed())
+		o.Expect(machineconfigurationv1.AddToScheme(sch)).To(o.Succeed())
+		o.Expect(configv1.AddToScheme(sch)).To(o.Succeed())
+
+		fakeClient = fake.NewClientBuilder().WithScheme(sch).WithObjects(testNode.DeepCopy(), testNodeWorker.DeepCopy(),
+			testPod.DeepCopy(), testService.DeepCopy(), testEndpointSlice.DeepCopy(), testNetwork, testInfrastructure).Build()
+		clientset := &client.ClientSet{Client: fakeClient}
+
+		mockUtils := mock_utils.NewMockUtilsInterface(gomock.NewController(g.GinkgoT()))
+		mockUtils.EXPECT().ListNodes().DoAndReturn(func() ([]corev1.Node, error) {
+			nodes := &corev1.NodeList{}
+			err := fakeClient.List(context.TODO(), nodes)
+			return nodes.Items, err
+		}).AnyTimes()
+
+		newExporter := func() (*endpointslices.EndpointSlicesExporter, error) {
+			return endpointslices.New(clientset, nil)
+		}
+		incremental = NewIncremental(newExporter, configv1.AWSPlatformType, configv1.HighlyAvailableTopologyMode,
+			WithUtilsHelpers(mockUtils))
+		full = func() *types.ComMatrix {
+			exporter, err := newExporter()
+			o.Expect(err).ToNot(o.HaveOccurred())
+			m, err := New(configv1.AWSPlatformType, configv1.HighlyAvailableTopologyMode,
+				WithExporter(exporter), WithUtilsHelpers(mockUtils)).CreateEndpointMatrix()
+			o.Expect(err).ToNot(o.HaveOccurred())
+			return m
+		}
+	})
+
+	g.It("creates the same matrix as CreateEndpointMatrix as the objects change", func() {
+		m, err := incremental.Matrix(nil)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(m).To(o.Equal(full()))
+		o.Expect(m.Contains(testEpsComDetails[0])).To(o.BeTrue())
+
+		g.By("moving a node to another group")
+		node := &corev1.Node{}
+		o.Expect(fakeClient.Get(context.TODO(), rtclient.ObjectKeyFromObject(testNode), node)).To(o.Succeed())
+		node.Annotations["machineconfiguration.openshift.io/currentConfig"] = "rendered-infra-abc"
+		o.Expect(fakeClient.Update(context.TODO(), node)).To(o.Succeed())
+
+		m, err = incremental.Matrix([]rtclient.Object{node})
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(m).To(o.Equal(full()))
+		moved := testEpsComDetails[0]
+		moved.NodeGroup = "infra"
+		o.Expect(m.Contains(moved)).To(o.BeTrue())
+
+		g.By("deleting a service")
+		o.Expect(fakeClient.Delete(context.TODO(), testService.DeepCopy())).To(o.Succeed())
+		m, err = incremental.Matrix([]rtclient.Object{testService})
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(m).To(o.Equal(full()))
+		o.Expect(m.Contains(moved)).To(o.BeFalse())
+	})
+})
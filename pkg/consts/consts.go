@@ -3,6 +3,8 @@ package consts
 const (
 	DefaultAddressType      = "IPv4"
 	IngressLabel            = "Ingress"
+	EgressLabel             = "Egress"
+	ExternalPeer            = "external"
 	OptionalLabel           = "optional"
 	OptionalTrue            = "true"
 	RoleLabel               = "node-role.kubernetes.io/"
@@ -14,10 +16,23 @@ const (
 	CommatrixDefaultDir     = "communication-matrix"
 	SSRawTCP                = "raw-ss-tcp"
 	SSRawUDP                = "raw-ss-udp"
+	SSRawSCTP               = "raw-ss-sctp"
 	MatrixDiffSSfileName    = "matrix-diff-ss"
+	ObservedFlowsFileName   = "observed-flows"
+	// ExcludedOptionalFileName lists the optional flows left out with --exclude-optional.
+	ExcludedOptionalFileName = "excluded-optional-flows"
 
 	// Butane and MachineConfig output constants.
 	ButaneFileNamePrefix         = "butane"
 	MCFileNamePrefix             = "mc"
 	NodeDisruptionPolicyFileName = "node-disruption-policy.yaml"
+
+	// FirewalldFileNamePrefix names the firewalld zone files, the zone being named after
+	// its file.
+	FirewalldFileNamePrefix = "commatrix"
+	// IngressNodeFirewallFileNamePrefix names the IngressNodeFirewall CR files.
+	IngressNodeFirewallFileNamePrefix = "ingressnodefirewall"
+
+	// NFTEgressChain is the nftables chain holding the egress rules.
+	NFTEgressChain = "OPENSHIFT_EGRESS"
 )
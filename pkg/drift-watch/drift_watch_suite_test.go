@@ -0,0 +1,13 @@
+package driftwatch
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDriftWatch(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Drift Watch Suite")
+}
@@ -0,0 +1,83 @@
+package driftwatch
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "commatrix"
+
+// Metrics exposes the drift of the communication matrix in the Prometheus format.
+type Metrics struct {
+	registry      *prometheus.Registry
+	driftEntries  *prometheus.GaugeVec
+	driftEvents   prometheus.Counter
+	reconciles    prometheus.Counter
+	reconcileErrs prometheus.Counter
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		driftEntries: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "drift_entries",
+			Help:      "Number of matrix entries that differ from the baseline, by node group and drift type (appeared, disappeared, changed).",
+		}, []string{"node_group", "type"}),
+		driftEvents: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "drift_events_total",
+			Help:      "Number of drift events emitted.",
+		}),
+		reconciles: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconciles_total",
+			Help:      "Number of times the communication matrix was recomputed.",
+		}),
+		reconcileErrs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconcile_errors_total",
+			Help:      "Number of failed recomputations of the communication matrix.",
+		}),
+	}
+	m.registry.MustRegister(m.driftEntries, m.driftEvents, m.reconciles, m.reconcileErrs)
+
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) setDrift(drift map[string]driftEntry) {
+	if m == nil {
+		return
+	}
+
+	m.driftEntries.Reset()
+	for _, entry := range drift {
+		m.driftEntries.WithLabelValues(entry.details.NodeGroup, entry.driftType).Inc()
+	}
+}
+
+func (m *Metrics) observeReconcile(err error) {
+	if m == nil {
+		return
+	}
+
+	m.reconciles.Inc()
+	if err != nil {
+		m.reconcileErrs.Inc()
+	}
+}
+
+func (m *Metrics) observeEvent() {
+	if m == nil {
+		return
+	}
+
+	m.driftEvents.Inc()
+}
@@ -0,0 +1,75 @@
+package driftwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// Notifier delivers drift events.
+type Notifier interface {
+	Notify(ctx context.Context, event *Event) error
+}
+
+// LogNotifier logs drift events.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(_ context.Context, event *Event) error {
+	for _, cd := range event.Appeared {
+		log.Warnf("Drift: entry not in baseline appeared: %s", cd)
+	}
+	for _, cd := range event.Disappeared {
+		log.Warnf("Drift: baseline entry disappeared: %s", cd)
+	}
+	for _, change := range event.Changed {
+		log.Warnf("Drift: entry changed from baseline %s to %s", change.Secondary, change.Primary)
+	}
+	for _, cd := range event.Restored {
+		log.Infof("Drift resolved: entry matches the baseline again: %s", cd)
+	}
+	return nil
+}
+
+// WebhookNotifier POSTs drift events as JSON to a URL.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal drift event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post drift event to %s: %w", n.url, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned status %s", n.url, resp.Status)
+	}
+
+	return nil
+}
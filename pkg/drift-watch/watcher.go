@@ -0,0 +1,339 @@
+package driftwatch
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	matrixdiff "github.com/openshift-kni/commatrix/pkg/matrix-diff"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+const defaultDebounce = 10 * time.Second
+
+// GenerateFunc computes the current communication matrix of the cluster. changed holds the
+// watched objects that changed since the last successful call, in their last known state.
+type GenerateFunc func(changed []runtimeclient.Object) (*types.ComMatrix, error)
+
+// Event reports a change in the drift of the cluster's matrix from the baseline.
+type Event struct {
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+	// Appeared holds the entries that are now in the cluster but not in the baseline.
+	Appeared []types.ComDetails `json:"appeared" yaml:"appeared"`
+	// Disappeared holds the baseline entries that are no longer in the cluster.
+	Disappeared []types.ComDetails `json:"disappeared" yaml:"disappeared"`
+	// Changed holds the entries whose source CIDRs, address family, bind addresses or
+	// interface now differ between the cluster (primary) and the baseline (secondary).
+	Changed []matrixdiff.Change `json:"changed" yaml:"changed"`
+	// Restored holds previously reported entries that match the baseline again.
+	Restored []types.ComDetails `json:"restored" yaml:"restored"`
+}
+
+// Empty returns true if the event holds no changes.
+func (e *Event) Empty() bool {
+	return len(e.Appeared) == 0 && len(e.Disappeared) == 0 && len(e.Changed) == 0 && len(e.Restored) == 0
+}
+
+// Drift types of the entries that differ from the baseline.
+const (
+	driftAppeared    = "appeared"
+	driftDisappeared = "disappeared"
+	driftChanged     = "changed"
+)
+
+// driftEntry is an entry that differs from the baseline, as last reported.
+type driftEntry struct {
+	details   types.ComDetails
+	driftType string
+	// change holds the cluster and baseline entries of a changed entry.
+	change matrixdiff.Change
+}
+
+// Watcher recomputes the communication matrix when triggered and reports drift
+// from a baseline matrix to its notifiers and metrics.
+type Watcher struct {
+	baseline  *types.ComMatrix
+	generate  GenerateFunc
+	notifiers []Notifier
+	metrics   *Metrics
+	debounce  time.Duration
+	trigger   chan struct{}
+	drift     map[string]driftEntry
+
+	mu      sync.Mutex
+	changed map[string]runtimeclient.Object
+}
+
+type Option func(*Watcher)
+
+// WithNotifier adds a notifier that receives every drift event.
+func WithNotifier(n Notifier) Option {
+	return func(w *Watcher) {
+		w.notifiers = append(w.notifiers, n)
+	}
+}
+
+// WithMetrics records the drift counts in m.
+func WithMetrics(m *Metrics) Option {
+	return func(w *Watcher) {
+		w.metrics = m
+	}
+}
+
+// WithDebounce sets how long the watcher waits for more changes before recomputing the matrix.
+func WithDebounce(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.debounce = d
+	}
+}
+
+func New(baseline *types.ComMatrix, generate GenerateFunc, opts ...Option) *Watcher {
+	w := &Watcher{
+		baseline: baseline,
+		generate: generate,
+		debounce: defaultDebounce,
+		trigger:  make(chan struct{}, 1),
+		drift:    map[string]driftEntry{},
+		changed:  map[string]runtimeclient.Object{},
+	}
+	for _, o := range opts {
+		o(w)
+	}
+	return w
+}
+
+// Trigger schedules a recomputation of the matrix. It never blocks.
+func (w *Watcher) Trigger() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Changed records a change of a watched object, passed to the next generate call,
+// and triggers a recomputation of the matrix.
+func (w *Watcher) Changed(obj runtimeclient.Object) {
+	w.mu.Lock()
+	w.changed[changeKey(obj)] = obj
+	w.mu.Unlock()
+	w.Trigger()
+}
+
+// takeChanged returns the recorded changes and clears them.
+func (w *Watcher) takeChanged() []runtimeclient.Object {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	res := make([]runtimeclient.Object, 0, len(w.changed))
+	for _, obj := range w.changed {
+		res = append(res, obj)
+	}
+	w.changed = map[string]runtimeclient.Object{}
+	return res
+}
+
+// restoreChanged records again the changes of a failed generate call, unless the
+// objects changed again in the meantime.
+func (w *Watcher) restoreChanged(changed []runtimeclient.Object) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, obj := range changed {
+		if _, ok := w.changed[changeKey(obj)]; !ok {
+			w.changed[changeKey(obj)] = obj
+		}
+	}
+}
+
+func changeKey(obj runtimeclient.Object) string {
+	return fmt.Sprintf("%T/%s/%s", obj, obj.GetNamespace(), obj.GetName())
+}
+
+// Run reconciles once and then on every trigger, until ctx is done.
+// Triggers received during the debounce period are coalesced into a single reconcile.
+// Reconcile errors are logged and do not stop the watcher.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.reconcileAndLog(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.trigger:
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.debounce):
+		}
+		// Drop the triggers received while waiting, they are covered by this reconcile.
+		select {
+		case <-w.trigger:
+		default:
+		}
+
+		w.reconcileAndLog(ctx)
+	}
+}
+
+func (w *Watcher) reconcileAndLog(ctx context.Context) {
+	if err := w.Reconcile(ctx); err != nil {
+		log.Errorf("Failed to reconcile the communication matrix: %v", err)
+	}
+}
+
+// Reconcile recomputes the matrix from the changes recorded since the last reconcile,
+// compares it with the baseline and notifies about the entries whose drift status changed.
+func (w *Watcher) Reconcile(ctx context.Context) error {
+	changed := w.takeChanged()
+	matrix, err := w.generate(changed)
+	if err != nil {
+		w.restoreChanged(changed)
+		w.metrics.observeReconcile(err)
+		return fmt.Errorf("failed to generate the communication matrix: %w", err)
+	}
+	w.metrics.observeReconcile(nil)
+
+	diff := matrixdiff.Generate(matrix, w.baseline, matrixdiff.WithAttributeChanges())
+	report := diff.Report()
+	current := map[string]driftEntry{}
+	for _, cd := range report.UniquePrimary {
+		current[cd.Key()] = driftEntry{details: cd, driftType: driftAppeared}
+	}
+	for _, cd := range report.UniqueSecondary {
+		current[cd.Key()] = driftEntry{details: cd, driftType: driftDisappeared}
+	}
+	for _, change := range report.Changed {
+		current[change.Primary.Key()] = driftEntry{details: change.Primary, driftType: driftChanged, change: change}
+	}
+	w.metrics.setDrift(current)
+
+	event := &Event{Timestamp: time.Now().UTC()}
+	for key, entry := range current {
+		if prev, ok := w.drift[key]; ok && prev.driftType == entry.driftType && prev.details.SameAttributes(entry.details) {
+			continue
+		}
+		switch entry.driftType {
+		case driftAppeared:
+			event.Appeared = append(event.Appeared, entry.details)
+		case driftDisappeared:
+			event.Disappeared = append(event.Disappeared, entry.details)
+		case driftChanged:
+			event.Changed = append(event.Changed, entry.change)
+		}
+	}
+	for key, prev := range w.drift {
+		if _, ok := current[key]; !ok {
+			event.Restored = append(event.Restored, prev.details)
+		}
+	}
+	w.drift = current
+
+	if event.Empty() {
+		return nil
+	}
+	event.Appeared = sorted(event.Appeared)
+	event.Disappeared = sorted(event.Disappeared)
+	event.Changed = sortedChanges(event.Changed)
+	event.Restored = sorted(event.Restored)
+
+	for _, n := range w.notifiers {
+		if err := n.Notify(ctx, event); err != nil {
+			log.Errorf("Failed to send drift event: %v", err)
+		}
+	}
+	w.metrics.observeEvent()
+
+	return nil
+}
+
+// WatchedObjects are the objects whose changes can change the communication matrix.
+var WatchedObjects = []runtimeclient.Object{
+	&corev1.Service{},
+	&discoveryv1.EndpointSlice{},
+	&corev1.Pod{},
+	&corev1.Node{},
+}
+
+// AddEventHandlers records in w every change of the WatchedObjects in the informer cache c.
+// Periodic resyncs, which do not change the objects, are ignored. Only the changes of
+// host-network pods are recorded, the other pods only matter through their EndpointSlices.
+func AddEventHandlers(ctx context.Context, c cache.Cache, w *Watcher) error {
+	var handler toolscache.ResourceEventHandler = toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { w.changedObject(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldMeta, okOld := oldObj.(runtimeclient.Object)
+			newMeta, okNew := newObj.(runtimeclient.Object)
+			if okOld && okNew && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+				return
+			}
+			w.changedObject(newObj)
+		},
+		DeleteFunc: func(obj interface{}) { w.changedObject(obj) },
+	}
+
+	for _, obj := range WatchedObjects {
+		informer, err := c.GetInformer(ctx, obj)
+		if err != nil {
+			return fmt.Errorf("failed to get informer for %T: %w", obj, err)
+		}
+		objHandler := handler
+		if _, ok := obj.(*corev1.Pod); ok {
+			objHandler = toolscache.FilteringResourceEventHandler{FilterFunc: isHostNetworkPod, Handler: handler}
+		}
+		if _, err := informer.AddEventHandler(objHandler); err != nil {
+			return fmt.Errorf("failed to add event handler for %T: %w", obj, err)
+		}
+	}
+
+	return nil
+}
+
+// changedObject records an object received by an event handler, unwrapping the
+// last known state of the objects deleted while the watch was down.
+func (w *Watcher) changedObject(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if o, ok := obj.(runtimeclient.Object); ok {
+		w.Changed(o)
+	}
+}
+
+func isHostNetworkPod(obj interface{}) bool {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	return ok && pod.Spec.HostNetwork
+}
+
+func sortedChanges(changes []matrixdiff.Change) []matrixdiff.Change {
+	if len(changes) == 0 {
+		return []matrixdiff.Change{}
+	}
+	slices.SortFunc(changes, func(a, b matrixdiff.Change) int {
+		return cmp.Compare(a.Primary.Key(), b.Primary.Key())
+	})
+	return changes
+}
+
+func sorted(entries []types.ComDetails) []types.ComDetails {
+	if len(entries) == 0 {
+		return []types.ComDetails{}
+	}
+	m := types.ComMatrix{Ports: entries}
+	m.SortAndRemoveDuplicates()
+	return m.Ports
+}
@@ -0,0 +1,223 @@
+package driftwatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	matrixdiff "github.com/openshift-kni/commatrix/pkg/matrix-diff"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+type recordingNotifier struct {
+	events []*Event
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, event *Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+var (
+	sshMaster      = types.ComDetails{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "master", Service: "sshd"}
+	rpcbindWorker  = types.ComDetails{Direction: "Ingress", Protocol: "UDP", Port: 111, NodeGroup: "worker", Service: "rpcbind"}
+	exporterWorker = types.ComDetails{Direction: "Ingress", Protocol: "TCP", Port: 9107, NodeGroup: "worker", Service: "new-exporter"}
+)
+
+var _ = Describe("Watcher", func() {
+	var (
+		ctx      context.Context
+		current  *types.ComMatrix
+		genErr   error
+		changed  [][]runtimeclient.Object
+		notifier *recordingNotifier
+		metrics  *Metrics
+		watcher  *Watcher
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		baseline := &types.ComMatrix{Ports: []types.ComDetails{sshMaster, rpcbindWorker}}
+		current = &types.ComMatrix{Ports: []types.ComDetails{sshMaster, rpcbindWorker}}
+		genErr = nil
+		changed = nil
+		notifier = &recordingNotifier{}
+		metrics = NewMetrics()
+		watcher = New(baseline, func(c []runtimeclient.Object) (*types.ComMatrix, error) {
+			changed = append(changed, c)
+			return current, genErr
+		},
+			WithNotifier(notifier), WithMetrics(metrics))
+	})
+
+	It("does not emit events while the cluster matches the baseline", func() {
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(notifier.events).To(BeEmpty())
+	})
+
+	It("emits an event only when the drift changes", func() {
+		current.Ports = []types.ComDetails{sshMaster, exporterWorker}
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(notifier.events).To(HaveLen(1))
+		Expect(notifier.events[0].Appeared).To(Equal([]types.ComDetails{exporterWorker}))
+		Expect(notifier.events[0].Disappeared).To(Equal([]types.ComDetails{rpcbindWorker}))
+		Expect(notifier.events[0].Restored).To(BeEmpty())
+
+		By("not repeating the same drift")
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(notifier.events).To(HaveLen(1))
+
+		By("reporting entries that match the baseline again")
+		current.Ports = []types.ComDetails{sshMaster, rpcbindWorker}
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(notifier.events).To(HaveLen(2))
+		Expect(notifier.events[1].Restored).To(Equal([]types.ComDetails{exporterWorker, rpcbindWorker}))
+	})
+
+	It("ignores changes of pod names that do not change the port", func() {
+		renamed := sshMaster
+		renamed.Pod = "sshd-abcde"
+		current.Ports = []types.ComDetails{renamed, rpcbindWorker}
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(notifier.events).To(BeEmpty())
+	})
+
+	It("reports entries whose source CIDRs changed", func() {
+		restricted := sshMaster
+		restricted.SourceCIDRs = types.CIDRList{"10.0.0.0/24"}
+		current.Ports = []types.ComDetails{restricted, rpcbindWorker}
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(notifier.events).To(HaveLen(1))
+		Expect(notifier.events[0].Changed).To(Equal([]matrixdiff.Change{{Primary: restricted, Secondary: sshMaster}}))
+		Expect(notifier.events[0].Appeared).To(BeEmpty())
+
+		By("reporting the entry again when its source CIDRs change again")
+		restricted.SourceCIDRs = types.CIDRList{"10.0.1.0/24"}
+		current.Ports = []types.ComDetails{restricted, rpcbindWorker}
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(notifier.events).To(HaveLen(2))
+		Expect(notifier.events[1].Changed).To(Equal([]matrixdiff.Change{{Primary: restricted, Secondary: sshMaster}}))
+
+		By("restoring the entry once it matches the baseline again")
+		current.Ports = []types.ComDetails{sshMaster, rpcbindWorker}
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(notifier.events).To(HaveLen(3))
+		Expect(notifier.events[2].Restored).To(Equal([]types.ComDetails{restricted}))
+	})
+
+	It("exposes drift counts per node group", func() {
+		current.Ports = []types.ComDetails{sshMaster, exporterWorker}
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+
+		rec := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body := rec.Body.String()
+		Expect(body).To(ContainSubstring(`commatrix_drift_entries{node_group="worker",type="appeared"} 1`))
+		Expect(body).To(ContainSubstring(`commatrix_drift_entries{node_group="worker",type="disappeared"} 1`))
+		Expect(body).To(ContainSubstring("commatrix_drift_events_total 1"))
+	})
+
+	It("returns generation errors and counts them", func() {
+		genErr = errors.New("boom")
+		Expect(watcher.Reconcile(ctx)).To(MatchError(ContainSubstring("boom")))
+
+		rec := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		Expect(rec.Body.String()).To(ContainSubstring("commatrix_reconcile_errors_total 1"))
+	})
+
+	It("passes the objects changed since the last reconcile", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}}
+		updatedNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", ResourceVersion: "2"}}
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "sshd", Namespace: "openshift-example"}}
+
+		watcher.Changed(node)
+		watcher.Changed(pod)
+		watcher.Changed(updatedNode)
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(changed[0]).To(ConsistOf(updatedNode, pod))
+
+		By("passing the changes of a failed reconcile again")
+		watcher.Changed(pod)
+		genErr = errors.New("boom")
+		Expect(watcher.Reconcile(ctx)).ToNot(Succeed())
+		genErr = nil
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(changed[2]).To(ConsistOf(pod))
+
+		By("passing no changes when nothing changed")
+		Expect(watcher.Reconcile(ctx)).To(Succeed())
+		Expect(changed[3]).To(BeEmpty())
+	})
+
+	It("coalesces triggers and stops when the context is done", func() {
+		calls := 0
+		w := New(&types.ComMatrix{}, func([]runtimeclient.Object) (*types.ComMatrix, error) {
+			calls++
+			return &types.ComMatrix{}, nil
+		}, WithDebounce(10*time.Millisecond))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error)
+		go func() { done <- w.Run(runCtx) }()
+
+		w.Trigger()
+		w.Trigger()
+		w.Trigger()
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(calls).To(BeNumerically("<=", 3))
+		Expect(calls).To(BeNumerically(">=", 2))
+	})
+})
+
+var _ = Describe("isHostNetworkPod", func() {
+	It("only accepts host-network pods", func() {
+		hostNetwork := &corev1.Pod{Spec: corev1.PodSpec{HostNetwork: true}}
+		Expect(isHostNetworkPod(hostNetwork)).To(BeTrue())
+		Expect(isHostNetworkPod(toolscache.DeletedFinalStateUnknown{Obj: hostNetwork})).To(BeTrue())
+		Expect(isHostNetworkPod(&corev1.Pod{})).To(BeFalse())
+		Expect(isHostNetworkPod(&corev1.Node{})).To(BeFalse())
+	})
+})
+
+var _ = Describe("WebhookNotifier", func() {
+	It("posts the event as JSON", func() {
+		var received Event
+		var contentType string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentType = r.Header.Get("Content-Type")
+			body, _ := io.ReadAll(r.Body)
+			Expect(json.Unmarshal(body, &received)).To(Succeed())
+		}))
+		defer server.Close()
+
+		event := &Event{Appeared: []types.ComDetails{exporterWorker}}
+		Expect(NewWebhookNotifier(server.URL).Notify(context.Background(), event)).To(Succeed())
+		Expect(contentType).To(Equal("application/json"))
+		Expect(received.Appeared).To(Equal([]types.ComDetails{exporterWorker}))
+	})
+
+	It("fails on non-2xx responses", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		err := NewWebhookNotifier(server.URL).Notify(context.Background(), &Event{})
+		Expect(err).To(MatchError(ContainSubstring("500")))
+	})
+})
@@ -23,6 +23,8 @@ type EndpointSlicesInfo struct {
 	EndpointSlice discoveryv1.EndpointSlice
 	Service       corev1.Service
 	Pods          []corev1.Pod
+	// AddressFamily is the family of the entries, empty when the service has endpoints of both families.
+	AddressFamily string
 }
 
 type EndpointSlicesExporter struct {
@@ -55,15 +57,8 @@ func New(cs *client.ClientSet, customNodeGroups map[string]labels.Selector) (*En
 	}
 	nodes := nodeList.Items
 
-	nodeToPool, err := mcp.ResolveNodeToPool(nodes)
+	nodeToPool, err := mcp.ResolveNodeToGroup(nodes, customNodeGroups)
 	if err != nil {
-		// Fallback: build node->group map (HyperShift or clusters without MCP): prefer NodePool label, else role
-		if nodeToPool, err = types.BuildNodeToGroupMap(nodes); err != nil {
-			return nil, err
-		}
-	}
-
-	if err := types.ApplyCustomNodeGroupOverrides(nodeToPool, customNodeGroups, nodes); err != nil {
 		return nil, err
 	}
 
@@ -81,75 +76,131 @@ func (ep *EndpointSlicesExporter) LoadExposedEndpointSlicesInfo() error {
 	}
 	epsliceInfos := []EndpointSlicesInfo{}
 	for _, service := range servicesList.Items {
-		// get the endpoint slice for this object
-		epl := &discoveryv1.EndpointSliceList{}
-		label, err := labels.Parse(fmt.Sprintf("kubernetes.io/service-name=%s", service.Name))
+		epsliceInfo, err := ep.LoadServiceInfo(service)
 		if err != nil {
-			return fmt.Errorf("failed to create selector for endpoint slice: %w", err)
+			return err
 		}
-		err = ep.List(context.TODO(), epl, &rtclient.ListOptions{Namespace: service.Namespace, LabelSelector: label})
-		if err != nil {
-			return fmt.Errorf("failed to list endpoint slice: %w", err)
+		if epsliceInfo != nil {
+			epsliceInfos = append(epsliceInfos, *epsliceInfo)
 		}
+	}
 
-		if len(epl.Items) == 0 {
-			log.Debugf("no endpoint slice found for service name %q", service.Name)
-			continue
-		}
+	log.Debugf("length of the created epsliceInfos slice: %d", len(epsliceInfos))
+	ep.sliceInfo = epsliceInfos
+	return nil
+}
 
-		if len(service.Spec.Selector) == 0 {
-			log.Debugf("no selector defined for service %q, skipping", service.Name)
-			continue
-		}
+// LoadServiceInfo returns the exposed EndpointSlice information of a service, as loaded by
+// LoadExposedEndpointSlicesInfo. It returns nil when the service exposes no host ports.
+func (ep *EndpointSlicesExporter) LoadServiceInfo(service corev1.Service) (*EndpointSlicesInfo, error) {
+	// get the endpoint slice for this object
+	epl := &discoveryv1.EndpointSliceList{}
+	label, err := labels.Parse(fmt.Sprintf("kubernetes.io/service-name=%s", service.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create selector for endpoint slice: %w", err)
+	}
+	err = ep.List(context.TODO(), epl, &rtclient.ListOptions{Namespace: service.Namespace, LabelSelector: label})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list endpoint slice: %w", err)
+	}
 
-		pods := &corev1.PodList{}
-		label = labels.SelectorFromSet(service.Spec.Selector)
-		err = ep.List(context.TODO(), pods, &rtclient.ListOptions{Namespace: service.Namespace, LabelSelector: label})
-		if err != nil {
-			return fmt.Errorf("failed to list pods: %w", err)
-		}
+	if len(epl.Items) == 0 {
+		log.Debugf("no endpoint slice found for service name %q", service.Name)
+		return nil, nil
+	}
 
-		// If there are no pods found for the service, skip.
-		if len(pods.Items) == 0 {
-			log.Debugf("no pods found for service name %q", service.Name)
-			continue
-		}
+	if len(service.Spec.Selector) == 0 {
+		log.Debugf("no selector defined for service %q, skipping", service.Name)
+		return nil, nil
+	}
 
-		ports := epl.Items[0].Ports
-		// For non-hostNetwork pods, the targetPort (containerPort) is inside the pod's
-		// network namespace and is not reachable on the host. Only ports with an
-		// explicit hostPort are on the host and need firewall entries.
-		// NodePort/LoadBalancer nodePorts are covered by the dynamic NodePort range.
-		// hostNetwork pods listen directly on the host, so all their
-		// containerPorts need firewall entries and are kept as-is.
-		if !isHostNetworked(pods.Items[0]) {
-			epsPortsInfo := getEndpointSlicePortsFromPod(pods.Items[0], epl.Items[0].Ports)
-			ports = filterEndpointPortsByPodHostPort(epsPortsInfo)
-		}
-		if len(ports) == 0 {
-			continue
-		}
-		// Exclude ports explicitly bound to localhost (127.0.0.1 or ::1)
-		epsPortsInfo := getEndpointSlicePortsFromPod(pods.Items[0], ports)
-		portsNoLocalhost := filterOutLocalhostPorts(epsPortsInfo)
-		if len(portsNoLocalhost) == 0 {
-			continue
-		}
-		epl.Items[0].Ports = portsNoLocalhost
+	pods := &corev1.PodList{}
+	label = labels.SelectorFromSet(service.Spec.Selector)
+	err = ep.List(context.TODO(), pods, &rtclient.ListOptions{Namespace: service.Namespace, LabelSelector: label})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
 
-		epsliceInfo := createEPSliceInfo(service, epl.Items[0], pods.Items)
-		log.Debugf("epsliceInfo created %+v", epsliceInfo)
-		epsliceInfos = append(epsliceInfos, epsliceInfo)
+	// If there are no pods found for the service, skip.
+	if len(pods.Items) == 0 {
+		log.Debugf("no pods found for service name %q", service.Name)
+		return nil, nil
 	}
 
-	log.Debugf("length of the created epsliceInfos slice: %d", len(epsliceInfos))
-	ep.sliceInfo = epsliceInfos
-	return nil
+	ports := epl.Items[0].Ports
+	// For non-hostNetwork pods, the targetPort (containerPort) is inside the pod's
+	// network namespace and is not reachable on the host. Only ports with an
+	// explicit hostPort are on the host and need firewall entries.
+	// NodePort/LoadBalancer nodePorts are covered by the dynamic NodePort range.
+	// hostNetwork pods listen directly on the host, so all their
+	// containerPorts need firewall entries and are kept as-is.
+	if !isHostNetworked(pods.Items[0]) {
+		epsPortsInfo := getEndpointSlicePortsFromPod(pods.Items[0], epl.Items[0].Ports)
+		ports = filterEndpointPortsByPodHostPort(epsPortsInfo)
+	}
+	if len(ports) == 0 {
+		return nil, nil
+	}
+	// Exclude ports explicitly bound to localhost (127.0.0.1 or ::1)
+	epsPortsInfo := getEndpointSlicePortsFromPod(pods.Items[0], ports)
+	portsNoLocalhost := filterOutLocalhostPorts(epsPortsInfo)
+	if len(portsNoLocalhost) == 0 {
+		return nil, nil
+	}
+	epl.Items[0].Ports = portsNoLocalhost
+
+	epsliceInfo := createEPSliceInfo(service, epl.Items[0], pods.Items)
+	epsliceInfo.AddressFamily = endpointSlicesAddressFamily(epl.Items)
+	log.Debugf("epsliceInfo created %+v", epsliceInfo)
+	return &epsliceInfo, nil
 }
 
 func (ep *EndpointSlicesExporter) ToComDetails() ([]types.ComDetails, error) {
 	comDetails := make([]types.ComDetails, 0)
 
+	for _, epSliceInfo := range ep.sliceInfo {
+		cds, err := ep.ComDetailsOf(epSliceInfo)
+		if err != nil {
+			return nil, err
+		}
+		comDetails = append(comDetails, cds...)
+	}
+
+	cleanedComDetails := removeDups(comDetails)
+	return cleanedComDetails, nil
+}
+
+// ComDetailsOf returns the entries of a single EndpointSlice information, as added by ToComDetails.
+func (ep *EndpointSlicesExporter) ComDetailsOf(epSliceInfo EndpointSlicesInfo) ([]types.ComDetails, error) {
+	cds, err := epSliceInfo.toComDetailsWithGroups(ep.nodeToGroup)
+	if err != nil {
+		switch err.(type) {
+		case *NoOwnerRefErr:
+			log.Debug(err.Error())
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return cds, nil
+}
+
+// EntrySource is a matrix entry together with the objects it was generated from.
+type EntrySource struct {
+	Entry         types.ComDetails
+	Service       string
+	EndpointSlice string
+	Pods          []string
+	// Owner is the controller of the backing pods, as "<Kind>/<name>".
+	Owner string
+}
+
+// EntrySources returns the entries generated by ToComDetails together with the
+// Service, EndpointSlice and Pods that produced each of them.
+func (ep *EndpointSlicesExporter) EntrySources() ([]EntrySource, error) {
+	res := make([]EntrySource, 0)
+
 	for _, epSliceInfo := range ep.sliceInfo {
 		cds, err := epSliceInfo.toComDetailsWithGroups(ep.nodeToGroup)
 		if err != nil {
@@ -161,11 +212,30 @@ func (ep *EndpointSlicesExporter) ToComDetails() ([]types.ComDetails, error) {
 				return nil, err
 			}
 		}
-		comDetails = append(comDetails, cds...)
+
+		pods := make([]string, 0, len(epSliceInfo.Pods))
+		for _, pod := range epSliceInfo.Pods {
+			pods = append(pods, pod.Name)
+		}
+		slices.Sort(pods)
+
+		owner := ""
+		if refs := epSliceInfo.Pods[0].OwnerReferences; len(refs) > 0 {
+			owner = fmt.Sprintf("%s/%s", refs[0].Kind, refs[0].Name)
+		}
+
+		for _, cd := range cds {
+			res = append(res, EntrySource{
+				Entry:         cd,
+				Service:       epSliceInfo.Service.Name,
+				EndpointSlice: epSliceInfo.EndpointSlice.Name,
+				Pods:          pods,
+				Owner:         owner,
+			})
+		}
 	}
 
-	cleanedComDetails := removeDups(comDetails)
-	return cleanedComDetails, nil
+	return res, nil
 }
 
 func createEPSliceInfo(service corev1.Service, ep discoveryv1.EndpointSlice, pods []corev1.Pod) EndpointSlicesInfo {
@@ -176,6 +246,25 @@ func createEPSliceInfo(service corev1.Service, ep discoveryv1.EndpointSlice, pod
 	}
 }
 
+// endpointSlicesAddressFamily returns the address family of the EndpointSlices of a service,
+// empty when they hold the addresses of both families.
+func endpointSlicesAddressFamily(epSlices []discoveryv1.EndpointSlice) string {
+	families := sets.New[string]()
+	for _, eps := range epSlices {
+		switch eps.AddressType {
+		case discoveryv1.AddressTypeIPv4:
+			families.Insert(types.AddressFamilyIPv4)
+		case discoveryv1.AddressTypeIPv6:
+			families.Insert(types.AddressFamilyIPv6)
+		}
+	}
+
+	if families.Len() != 1 {
+		return ""
+	}
+	return families.UnsortedList()[0]
+}
+
 func (ei *EndpointSlicesInfo) getEndpointSliceGroups(nodeToGroup map[string]string) []string {
 	poolsMap := make(map[string]bool)
 	for _, endpoint := range ei.EndpointSlice.Endpoints {
@@ -227,15 +316,16 @@ func (ei *EndpointSlicesInfo) toComDetailsWithGroups(nodeToGroup map[string]stri
 
 		for _, pool := range pools {
 			res = append(res, types.ComDetails{
-				Direction: consts.IngressLabel,
-				Protocol:  string(*port.Protocol),
-				Port:      int(*port.Port),
-				Namespace: namespace,
-				Pod:       name,
-				Container: containerName,
-				NodeGroup: pool,
-				Service:   ei.Service.Name,
-				Optional:  optional,
+				Direction:     consts.IngressLabel,
+				Protocol:      string(*port.Protocol),
+				Port:          int(*port.Port),
+				Namespace:     namespace,
+				Pod:           name,
+				Container:     containerName,
+				NodeGroup:     pool,
+				Service:       ei.Service.Name,
+				Optional:      optional,
+				AddressFamily: ei.AddressFamily,
 			})
 		}
 	}
@@ -310,9 +400,18 @@ func isOptional(epSlice discoveryv1.EndpointSlice) bool {
 	return optional
 }
 
+// removeDups removes the entries equal in all their fields. ComDetails is not comparable,
+// so the entries are compared by their string form.
 func removeDups(comDetails []types.ComDetails) []types.ComDetails {
-	set := sets.New[types.ComDetails](comDetails...)
-	res := set.UnsortedList()
+	seen := sets.New[string]()
+	res := []types.ComDetails{}
+	for _, cd := range comDetails {
+		if seen.Has(cd.String()) {
+			continue
+		}
+		seen.Insert(cd.String())
+		res = append(res, cd)
+	}
 
 	return res
 }
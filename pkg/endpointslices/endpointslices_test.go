@@ -4,7 +4,10 @@ import (
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
 )
 
 type TestCase struct {
@@ -82,3 +85,41 @@ func defineTestPod(t *TestCase) *corev1.Pod {
 		Spec:       corev1.PodSpec{NodeName: t.nodeName},
 	}
 }
+
+func TestEndpointSlicesAddressFamily(t *testing.T) {
+	tests := []struct {
+		desc         string
+		addressTypes []discoveryv1.AddressType
+		expected     string
+	}{
+		{
+			desc:         "ipv4-slices",
+			addressTypes: []discoveryv1.AddressType{discoveryv1.AddressTypeIPv4, discoveryv1.AddressTypeIPv4},
+			expected:     types.AddressFamilyIPv4,
+		},
+		{
+			desc:         "ipv6-slice",
+			addressTypes: []discoveryv1.AddressType{discoveryv1.AddressTypeIPv6},
+			expected:     types.AddressFamilyIPv6,
+		},
+		{
+			desc:         "dual-stack-slices",
+			addressTypes: []discoveryv1.AddressType{discoveryv1.AddressTypeIPv4, discoveryv1.AddressTypeIPv6},
+			expected:     "",
+		},
+		{
+			desc:         "fqdn-slice",
+			addressTypes: []discoveryv1.AddressType{discoveryv1.AddressTypeFQDN},
+			expected:     "",
+		},
+	}
+	for _, test := range tests {
+		var epSlices []discoveryv1.EndpointSlice
+		for _, addressType := range test.addressTypes {
+			epSlices = append(epSlices, discoveryv1.EndpointSlice{AddressType: addressType})
+		}
+		if res := endpointSlicesAddressFamily(epSlices); res != test.expected {
+			t.Fatalf("test %s failed. expected %q got %q", test.desc, test.expected, res)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package endpointslices
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// Manifests holds the Services and workloads read from a directory of Kubernetes manifests.
+type Manifests struct {
+	Services []corev1.Service
+	// Pods holds one pod per workload, built from its pod template and named after the workload.
+	Pods []corev1.Pod
+}
+
+// LoadManifests reads the Deployment, DaemonSet, StatefulSet, ReplicaSet, Pod and Service
+// manifests of the YAML and JSON files under dir. Objects without a namespace are placed in
+// defaultNamespace. Other kinds are ignored.
+func LoadManifests(dir, defaultNamespace string) (*Manifests, error) {
+	m := &Manifests{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			return nil
+		}
+
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		if err := m.add(content, defaultNamespace); err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// add decodes every document of content and adds the supported objects to m.
+func (m *Manifests) add(content []byte, defaultNamespace string) error {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		if obj.IsList() {
+			if err := obj.EachListItem(func(item runtime.Object) error {
+				return m.addObject(item.(*unstructured.Unstructured), defaultNamespace)
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := m.addObject(obj, defaultNamespace); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *Manifests) addObject(obj *unstructured.Unstructured, defaultNamespace string) error {
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(defaultNamespace)
+	}
+
+	var template *corev1.PodTemplateSpec
+	switch obj.GroupVersionKind() {
+	case corev1.SchemeGroupVersion.WithKind("Service"):
+		svc := corev1.Service{}
+		if err := fromUnstructured(obj, &svc); err != nil {
+			return err
+		}
+		m.Services = append(m.Services, svc)
+		return nil
+	case corev1.SchemeGroupVersion.WithKind("Pod"):
+		pod := corev1.Pod{}
+		if err := fromUnstructured(obj, &pod); err != nil {
+			return err
+		}
+		// Pods are named as extractControllerName names pods without owners.
+		pod.OwnerReferences = nil
+		m.Pods = append(m.Pods, pod)
+		return nil
+	case appsv1.SchemeGroupVersion.WithKind("Deployment"):
+		d := appsv1.Deployment{}
+		if err := fromUnstructured(obj, &d); err != nil {
+			return err
+		}
+		template = &d.Spec.Template
+	case appsv1.SchemeGroupVersion.WithKind("DaemonSet"):
+		ds := appsv1.DaemonSet{}
+		if err := fromUnstructured(obj, &ds); err != nil {
+			return err
+		}
+		template = &ds.Spec.Template
+	case appsv1.SchemeGroupVersion.WithKind("StatefulSet"):
+		sts := appsv1.StatefulSet{}
+		if err := fromUnstructured(obj, &sts); err != nil {
+			return err
+		}
+		template = &sts.Spec.Template
+	case appsv1.SchemeGroupVersion.WithKind("ReplicaSet"):
+		rs := appsv1.ReplicaSet{}
+		if err := fromUnstructured(obj, &rs); err != nil {
+			return err
+		}
+		template = &rs.Spec.Template
+	default:
+		log.Debugf("Skipping manifest %s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		return nil
+	}
+
+	m.Pods = append(m.Pods, corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Labels:    template.Labels,
+		},
+		Spec: template.Spec,
+	})
+	return nil
+}
+
+func fromUnstructured(obj *unstructured.Unstructured, into any) error {
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, into); err != nil {
+		return fmt.Errorf("failed to decode %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+	return nil
+}
+
+// Predict returns the entries the Services and workloads of m would add to the matrix of
+// nodeGroup once deployed. EndpointSlices are derived from the Service ports and the
+// workloads they select, and are filtered with the same rules as the live ones.
+func (m *Manifests) Predict(nodeGroup string) ([]types.ComDetails, error) {
+	comDetails := make([]types.ComDetails, 0)
+	for _, service := range m.Services {
+		if len(service.Spec.Selector) == 0 {
+			log.Debugf("no selector defined for service %q, skipping", service.Name)
+			continue
+		}
+
+		selector := labels.SelectorFromSet(service.Spec.Selector)
+		pods := []corev1.Pod{}
+		for _, pod := range m.Pods {
+			if pod.Namespace == service.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				pods = append(pods, pod)
+			}
+		}
+		if len(pods) == 0 {
+			log.Debugf("no workloads found for service name %q", service.Name)
+			continue
+		}
+
+		ports := predictEndpointPorts(service, pods[0])
+		if !isHostNetworked(pods[0]) {
+			ports = filterEndpointPortsByPodHostPort(getEndpointSlicePortsFromPod(pods[0], ports))
+		}
+		if len(ports) == 0 {
+			continue
+		}
+		ports = filterOutLocalhostPorts(getEndpointSlicePortsFromPod(pods[0], ports))
+		if len(ports) == 0 {
+			continue
+		}
+
+		// EndpointSlices inherit the labels of their Service.
+		info := EndpointSlicesInfo{
+			EndpointSlice: discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            service.Name,
+					Namespace:       service.Namespace,
+					Labels:          service.Labels,
+					OwnerReferences: []metav1.OwnerReference{{Kind: "Service", Name: service.Name}},
+				},
+				Endpoints: []discoveryv1.Endpoint{{NodeName: &nodeGroup}},
+				Ports:     ports,
+			},
+			Service:       service,
+			Pods:          pods,
+			AddressFamily: serviceAddressFamily(service),
+		}
+
+		cds, err := info.toComDetailsWithGroups(map[string]string{nodeGroup: nodeGroup})
+		if err != nil {
+			return nil, err
+		}
+		comDetails = append(comDetails, cds...)
+	}
+
+	return removeDups(comDetails), nil
+}
+
+// predictEndpointPorts returns the EndpointSlice ports the EndpointSlice controller creates
+// for service and a pod it selects, resolving named target ports against the pod's containers.
+func predictEndpointPorts(service corev1.Service, pod corev1.Pod) []discoveryv1.EndpointPort {
+	ports := []discoveryv1.EndpointPort{}
+	for _, sp := range service.Spec.Ports {
+		protocol := sp.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+
+		port, ok := resolveTargetPort(sp, pod)
+		if !ok {
+			log.Debugf("failed to resolve target port %s of service %s/%s", sp.TargetPort.String(), service.Namespace, service.Name)
+			continue
+		}
+
+		ports = append(ports, discoveryv1.EndpointPort{
+			Name:     &sp.Name,
+			Port:     &port,
+			Protocol: &protocol,
+		})
+	}
+
+	return ports
+}
+
+func resolveTargetPort(sp corev1.ServicePort, pod corev1.Pod) (int32, bool) {
+	if sp.TargetPort.StrVal == "" {
+		if sp.TargetPort.IntVal != 0 {
+			return sp.TargetPort.IntVal, true
+		}
+		return sp.Port, true
+	}
+
+	for _, container := range pod.Spec.Containers {
+		for _, cp := range container.Ports {
+			if cp.Name == sp.TargetPort.StrVal {
+				return cp.ContainerPort, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// serviceAddressFamily returns the address family of a single-stack service, empty when
+// the service does not set a single IP family.
+func serviceAddressFamily(service corev1.Service) string {
+	if len(service.Spec.IPFamilies) != 1 {
+		return ""
+	}
+	if service.Spec.IPFamilies[0] == corev1.IPv6Protocol {
+		return types.AddressFamilyIPv6
+	}
+	return types.AddressFamilyIPv4
+}
@@ -0,0 +1,159 @@
+package endpointslices
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+const testManifests = `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: exporter
+spec:
+  selector:
+    matchLabels:
+      app: exporter
+  template:
+    metadata:
+      labels:
+        app: exporter
+    spec:
+      hostNetwork: true
+      containers:
+      - name: exporter
+        image: exporter
+        ports:
+        - name: metrics
+          containerPort: 9107
+        - name: local
+          containerPort: 9108
+          hostIP: 127.0.0.1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: exporter
+spec:
+  selector:
+    app: exporter
+  ports:
+  - name: metrics
+    port: 443
+    targetPort: metrics
+  - name: local
+    port: 9108
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: webhook
+  namespace: partner
+  labels:
+    app: webhook
+spec:
+  selector:
+    matchLabels:
+      app: webhook
+  template:
+    metadata:
+      labels:
+        app: webhook
+    spec:
+      containers:
+      - name: server
+        image: webhook
+        ports:
+        - containerPort: 8443
+        - containerPort: 30443
+          hostPort: 30443
+          protocol: UDP
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: webhook
+    namespace: partner
+    labels:
+      optional: "true"
+  spec:
+    selector:
+      app: webhook
+    ports:
+    - port: 443
+      targetPort: 8443
+    - port: 30443
+      protocol: UDP
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: no-workload
+    namespace: partner
+  spec:
+    selector:
+      app: missing
+    ports:
+    - port: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+`
+
+func TestPredict(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bundle.yaml"), []byte(testManifests), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := LoadManifests(dir, "partner-system")
+	if err != nil {
+		t.Fatalf("failed to load manifests: %s", err)
+	}
+	if len(m.Services) != 3 || len(m.Pods) != 2 {
+		t.Fatalf("expected 3 services and 2 pods, got %d and %d", len(m.Services), len(m.Pods))
+	}
+
+	res, err := m.Predict("worker")
+	if err != nil {
+		t.Fatalf("failed to predict: %s", err)
+	}
+	matrix := types.ComMatrix{Ports: res}
+	matrix.SortAndRemoveDuplicates()
+
+	expected := []types.ComDetails{
+		{
+			// Host networked, the named target port is resolved and the localhost port excluded.
+			Direction: "Ingress",
+			Protocol:  "TCP",
+			Port:      9107,
+			Namespace: "partner-system",
+			Service:   "exporter",
+			Pod:       "exporter",
+			Container: "exporter",
+			NodeGroup: "worker",
+		},
+		{
+			// Not host networked, only the port with a hostPort is exposed on the host.
+			Direction: "Ingress",
+			Protocol:  "UDP",
+			Port:      30443,
+			Namespace: "partner",
+			Service:   "webhook",
+			Pod:       "webhook",
+			Container: "server",
+			NodeGroup: "worker",
+			Optional:  true,
+		},
+	}
+	if !reflect.DeepEqual(matrix.Ports, expected) {
+		t.Fatalf("expected %v got %v", expected, matrix.Ports)
+	}
+}
@@ -1,18 +1,52 @@
 package errhandler
 
 import (
+	"errors"
 	"fmt"
 	"os"
 )
 
+// Exit codes returned by the commatrix CLI.
+const (
+	ExitCodeError = 1
+	// ExitCodeMatrixDiff is returned when two compared matrices differ.
+	ExitCodeMatrixDiff = 2
+	// ExitCodeValidationFailed is returned when the cluster does not match the documented matrix.
+	ExitCodeValidationFailed = 3
+	// ExitCodeFirewallMismatch is returned when the nftables rules loaded on a node differ from the matrix.
+	ExitCodeFirewallMismatch = 4
+	// ExitCodeLintFailed is returned when a custom entries file has errors.
+	ExitCodeLintFailed = 5
+)
+
+// ExitError wraps an error with the process exit code it should produce.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 // HandleAndExit prints the error to stderr and exits non-zero.
 // Upstream callers should wrap errors with %w for context.
+// If err wraps an ExitError, its Code is used as the exit code.
 func HandleAndExit(err error) {
 	if err == nil {
 		return
 	}
 	fmt.Fprintf(os.Stderr, "error: %v\n", err)
-	os.Exit(1)
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+	os.Exit(ExitCodeError)
 }
 
 // RecoverAndExit recovers from panics in main, logs them, and exits with a generic message.
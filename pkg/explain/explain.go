@@ -0,0 +1,195 @@
+package explain
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/openshift-kni/commatrix/pkg/endpointslices"
+	listeningsockets "github.com/openshift-kni/commatrix/pkg/listening-sockets"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// Source kinds.
+const (
+	KindStatic          = "static"
+	KindEndpointSlice   = "endpointslice"
+	KindCustomEntries   = "custom-entries"
+	KindListeningSocket = "listening-socket"
+)
+
+// Source describes where a matrix entry came from. Only the fields relevant to Kind are set.
+type Source struct {
+	Kind  string           `json:"kind" yaml:"kind"`
+	Entry types.ComDetails `json:"entry" yaml:"entry"`
+
+	// StaticEntryList is the name of the static entry list, for static entries.
+	StaticEntryList string `json:"staticEntryList,omitempty" yaml:"staticEntryList,omitempty"`
+
+	// Service, EndpointSlice, Pods and Owner are the objects an endpointslice entry was generated from.
+	Service       string   `json:"service,omitempty" yaml:"service,omitempty"`
+	EndpointSlice string   `json:"endpointSlice,omitempty" yaml:"endpointSlice,omitempty"`
+	Pods          []string `json:"pods,omitempty" yaml:"pods,omitempty"`
+	Owner         string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+
+	// File and Line locate a custom entry.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	Line int    `json:"line,omitempty" yaml:"line,omitempty"`
+
+	// Node, LocalAddress, Process and PID describe a listening socket found with ss.
+	Node         string `json:"node,omitempty" yaml:"node,omitempty"`
+	LocalAddress string `json:"localAddress,omitempty" yaml:"localAddress,omitempty"`
+	Process      string `json:"process,omitempty" yaml:"process,omitempty"`
+	PID          string `json:"pid,omitempty" yaml:"pid,omitempty"`
+}
+
+// Describe returns a one line, human readable description of the source.
+func (s Source) Describe() string {
+	switch s.Kind {
+	case KindStatic:
+		return fmt.Sprintf("static entry list %s", s.StaticEntryList)
+	case KindEndpointSlice:
+		desc := fmt.Sprintf("service %s/%s, endpointslice %s, pods %s",
+			s.Entry.Namespace, s.Service, s.EndpointSlice, strings.Join(s.Pods, ","))
+		if s.Owner != "" {
+			desc += ", owner " + s.Owner
+		}
+		return desc
+	case KindCustomEntries:
+		return fmt.Sprintf("custom entries %s:%d", s.File, s.Line)
+	case KindListeningSocket:
+		return fmt.Sprintf("listener on node %s at %s, process %s (pid %s)", s.Node, s.LocalAddress, s.Process, s.PID)
+	}
+
+	return s.Kind
+}
+
+// StaticSources returns the static entries of lists, expanded per pool the same way
+// the communication matrix is. When poolToRoles is empty the entries are kept per role.
+func StaticSources(lists []types.StaticEntryList, poolToRoles map[string][]string) []Source {
+	pools := make([]string, 0, len(poolToRoles))
+	for pool := range poolToRoles {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+
+	var res []Source
+	for _, l := range lists {
+		for _, cd := range l.Entries {
+			if len(pools) == 0 {
+				res = append(res, Source{Kind: KindStatic, Entry: cd, StaticEntryList: l.Name})
+				continue
+			}
+			for _, pool := range pools {
+				if !slices.Contains(poolToRoles[pool], cd.NodeGroup) {
+					continue
+				}
+				expanded := cd
+				expanded.NodeGroup = pool
+				res = append(res, Source{Kind: KindStatic, Entry: expanded, StaticEntryList: l.Name})
+			}
+		}
+	}
+
+	return res
+}
+
+// EndpointSliceSources returns the sources of the entries generated from EndpointSlices.
+func EndpointSliceSources(entries []endpointslices.EntrySource) []Source {
+	res := make([]Source, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, Source{
+			Kind:          KindEndpointSlice,
+			Entry:         e.Entry,
+			Service:       e.Service,
+			EndpointSlice: e.EndpointSlice,
+			Pods:          e.Pods,
+			Owner:         e.Owner,
+		})
+	}
+
+	return res
+}
+
+// CustomEntrySources returns the sources of the entries read from the custom entries file.
+func CustomEntrySources(file string, entries []types.LocatedEntry) []Source {
+	res := make([]Source, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, Source{Kind: KindCustomEntries, Entry: e.Entry, File: file, Line: e.Line})
+	}
+
+	return res
+}
+
+// ListenerSources returns the sources of the entries found with ss on the nodes.
+func ListenerSources(listeners []listeningsockets.Listener) []Source {
+	res := make([]Source, 0, len(listeners))
+	for _, l := range listeners {
+		res = append(res, Source{
+			Kind:         KindListeningSocket,
+			Entry:        l.Entry,
+			Node:         l.Node,
+			LocalAddress: l.LocalAddress,
+			Process:      l.Process,
+			PID:          l.PID,
+		})
+	}
+
+	return res
+}
+
+// Query selects matrix entries. Zero value fields match any entry.
+type Query struct {
+	Port      int
+	Protocol  string
+	NodeGroup string
+}
+
+// Matches returns true if cd is selected by the query. Protocols are compared case-insensitively.
+func (q Query) Matches(cd types.ComDetails) bool {
+	if q.Port != 0 && cd.Port != q.Port {
+		return false
+	}
+	if q.Protocol != "" && !strings.EqualFold(cd.Protocol, q.Protocol) {
+		return false
+	}
+	if q.NodeGroup != "" && cd.NodeGroup != q.NodeGroup {
+		return false
+	}
+
+	return true
+}
+
+// Filter returns the sources whose entry matches q, sorted by entry and kind.
+func Filter(sources []Source, q Query) []Source {
+	var res []Source
+	for _, s := range sources {
+		if q.Matches(s.Entry) {
+			res = append(res, s)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i].Entry, res[j].Entry
+		if a.NodeGroup != b.NodeGroup {
+			return a.NodeGroup < b.NodeGroup
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return res[i].Kind < res[j].Kind
+	})
+
+	return res
+}
+
+// Result is the explanation of a port or a node.
+type Result struct {
+	// NodeGroupResolution is set when a node is explained.
+	NodeGroupResolution *NodeGroupResolution `json:"nodeGroupResolution,omitempty" yaml:"nodeGroupResolution,omitempty"`
+	Sources             []Source             `json:"sources" yaml:"sources"`
+}
@@ -0,0 +1,127 @@
+package explain
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+var _ = Describe("Sources", func() {
+	lists := []types.StaticEntryList{
+		{Name: "GeneralStaticEntriesMaster", Entries: []types.ComDetails{
+			{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "master"},
+		}},
+		{Name: "GeneralStaticEntriesWorker", Entries: []types.ComDetails{
+			{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "worker"},
+		}},
+	}
+
+	It("expands static entries per pool", func() {
+		sources := StaticSources(lists, map[string][]string{
+			"master": {"master"},
+			"worker": {"worker"},
+			"infra":  {"worker"},
+		})
+
+		Expect(sources).To(HaveLen(3))
+		groups := map[string]string{}
+		for _, s := range sources {
+			Expect(s.Kind).To(Equal(KindStatic))
+			groups[s.Entry.NodeGroup] = s.StaticEntryList
+		}
+		Expect(groups).To(Equal(map[string]string{
+			"master": "GeneralStaticEntriesMaster",
+			"worker": "GeneralStaticEntriesWorker",
+			"infra":  "GeneralStaticEntriesWorker",
+		}))
+	})
+
+	It("keeps static entries per role without pools", func() {
+		sources := StaticSources(lists, nil)
+		Expect(sources).To(HaveLen(2))
+		Expect(sources[0].Entry.NodeGroup).To(Equal("master"))
+	})
+
+	It("filters sources by port, protocol and group", func() {
+		sources := append(StaticSources(lists, nil),
+			CustomEntrySources("custom.yaml", []types.LocatedEntry{
+				{Line: 4, Entry: types.ComDetails{Protocol: "TCP", Port: 22, NodeGroup: "worker"}},
+				{Line: 9, Entry: types.ComDetails{Protocol: "UDP", Port: 22, NodeGroup: "worker"}},
+			})...)
+
+		res := Filter(sources, Query{Port: 22, Protocol: "tcp", NodeGroup: "worker"})
+		Expect(res).To(HaveLen(2))
+		Expect(res[0].Kind).To(Equal(KindCustomEntries))
+		Expect(res[0].Describe()).To(Equal("custom entries custom.yaml:4"))
+		Expect(res[1].Describe()).To(Equal("static entry list GeneralStaticEntriesWorker"))
+
+		Expect(Filter(sources, Query{Port: 22})).To(HaveLen(4))
+		Expect(Filter(sources, Query{Port: 23})).To(BeEmpty())
+	})
+})
+
+var _ = Describe("ResolveNodeGroup", func() {
+	node := func(name string, lbls, annotations map[string]string) corev1.Node {
+		return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: lbls, Annotations: annotations}}
+	}
+
+	It("uses the MCP annotation when all nodes have one", func() {
+		nodes := []corev1.Node{
+			node("n1", map[string]string{"node-role.kubernetes.io/worker": ""},
+				map[string]string{currentConfigAnnotation: "rendered-infra-abc"}),
+		}
+
+		res, err := ResolveNodeGroup(nodes, "n1", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.NodeGroup).To(Equal("infra"))
+		Expect(res.Rule).To(Equal(RuleMCPAnnotation))
+		Expect(res.Detail).To(ContainSubstring("rendered-infra-abc"))
+	})
+
+	It("falls back to the HyperShift NodePool label and the role", func() {
+		nodes := []corev1.Node{
+			node("n1", map[string]string{hypershiftNodePoolLabel: "np1", "node-role.kubernetes.io/worker": ""}, nil),
+			node("n2", map[string]string{"node-role.kubernetes.io/worker": ""}, nil),
+		}
+
+		res, err := ResolveNodeGroup(nodes, "n1", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.NodeGroup).To(Equal("np1"))
+		Expect(res.Rule).To(Equal(RuleHyperShiftLabel))
+
+		res, err = ResolveNodeGroup(nodes, "n2", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.NodeGroup).To(Equal("worker"))
+		Expect(res.Rule).To(Equal(RuleRole))
+	})
+
+	It("reports custom selector overrides", func() {
+		nodes := []corev1.Node{
+			node("n1", map[string]string{"node-role.kubernetes.io/worker": "", "edge": "true"}, nil),
+			node("n2", map[string]string{"node-role.kubernetes.io/worker": ""}, nil),
+		}
+		selector, err := labels.Parse("edge=true")
+		Expect(err).ToNot(HaveOccurred())
+		custom := map[string]labels.Selector{"edge": selector}
+
+		res, err := ResolveNodeGroup(nodes, "n1", custom)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.NodeGroup).To(Equal("edge"))
+		Expect(res.Rule).To(Equal(RuleCustomSelector))
+		Expect(res.Detail).To(Equal("edge=true"))
+		Expect(res.Overridden.Rule).To(Equal(RuleRole))
+
+		res, err = ResolveNodeGroup(nodes, "n2", custom)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Rule).To(Equal(RuleRole))
+	})
+
+	It("fails for unknown nodes", func() {
+		_, err := ResolveNodeGroup(nil, "missing", nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
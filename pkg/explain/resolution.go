@@ -0,0 +1,86 @@
+package explain
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/openshift-kni/commatrix/pkg/mcp"
+)
+
+// Node group resolution rules, in the order they are tried.
+const (
+	RuleMCPAnnotation   = "mcp-annotation"
+	RuleHyperShiftLabel = "hypershift-label"
+	RuleRole            = "role"
+	RuleCustomSelector  = "custom-selector"
+)
+
+const (
+	currentConfigAnnotation = "machineconfiguration.openshift.io/currentConfig"
+	hypershiftNodePoolLabel = "hypershift.openshift.io/nodePool"
+)
+
+// NodeGroupResolution explains how a node was assigned to its node group.
+type NodeGroupResolution struct {
+	Node      string `json:"node" yaml:"node"`
+	NodeGroup string `json:"nodeGroup" yaml:"nodeGroup"`
+	Rule      string `json:"rule" yaml:"rule"`
+	// Detail is the annotation, label or selector the rule matched on.
+	Detail string `json:"detail" yaml:"detail"`
+	// Overridden is the resolution replaced by a custom node group selector, if any.
+	Overridden *NodeGroupResolution `json:"overridden,omitempty" yaml:"overridden,omitempty"`
+}
+
+// ResolveNodeGroup resolves the node group of nodeName with mcp.ResolveNodeToGroup, like the matrix:
+// MCP pools are used when every node has a rendered MachineConfig annotation, otherwise the
+// HyperShift NodePool label or the node role. Custom node group selectors override the result.
+func ResolveNodeGroup(nodes []corev1.Node, nodeName string, customNodeGroups map[string]labels.Selector) (*NodeGroupResolution, error) {
+	var node *corev1.Node
+	for i := range nodes {
+		if nodes[i].Name == nodeName {
+			node = &nodes[i]
+			break
+		}
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node %s not found", nodeName)
+	}
+
+	nodeToGroup, fromPools, err := mcp.ResolveBaseNodeToGroup(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &NodeGroupResolution{Node: nodeName, NodeGroup: nodeToGroup[nodeName]}
+	if fromPools {
+		res.Rule = RuleMCPAnnotation
+		res.Detail = fmt.Sprintf("%s=%s", currentConfigAnnotation, node.Annotations[currentConfigAnnotation])
+	} else {
+		if np, ok := node.Labels[hypershiftNodePoolLabel]; ok && np != "" {
+			res.Rule = RuleHyperShiftLabel
+			res.Detail = fmt.Sprintf("%s=%s", hypershiftNodePoolLabel, np)
+		} else {
+			res.Rule = RuleRole
+			res.Detail = fmt.Sprintf("role %s from the node-role.kubernetes.io/ labels", res.NodeGroup)
+		}
+	}
+
+	overridden, err := mcp.ResolveNodeToGroup(nodes, customNodeGroups)
+	if err != nil {
+		return nil, err
+	}
+	group := overridden[nodeName]
+	if selector, ok := customNodeGroups[group]; ok && selector.Matches(labels.Set(node.Labels)) {
+		return &NodeGroupResolution{
+			Node:       nodeName,
+			NodeGroup:  group,
+			Rule:       RuleCustomSelector,
+			Detail:     selector.String(),
+			Overridden: res,
+		}, nil
+	}
+
+	return res, nil
+}
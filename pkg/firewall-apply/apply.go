@@ -0,0 +1,299 @@
+package firewallapply
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	machineconfigurationv1 "github.com/openshift/api/machineconfiguration/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/pmezard/go-difflib/difflib"
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/consts"
+	"github.com/openshift-kni/commatrix/pkg/firewall"
+)
+
+// Dry run modes, following the semantics of kubectl's --dry-run flag.
+const (
+	DryRunNone   = "none"
+	DryRunClient = "client"
+	DryRunServer = "server"
+)
+
+// ValidDryRunModes lists the supported dry run modes.
+var ValidDryRunModes = []string{DryRunNone, DryRunClient, DryRunServer}
+
+// machineConfigurationName is the name of the cluster-wide MachineConfiguration object.
+const machineConfigurationName = "cluster"
+
+// Applier rolls out the generated firewall MachineConfigs and the node disruption policy.
+type Applier struct {
+	client runtimeclient.Client
+	dryRun string
+	out    io.Writer
+}
+
+// New returns an Applier that uses c to talk to the cluster and writes its progress to out.
+// In dry run modes the changes are not persisted and a diff against the live objects is printed.
+func New(c runtimeclient.Client, dryRun string, out io.Writer) *Applier {
+	return &Applier{client: c, dryRun: dryRun, out: out}
+}
+
+// LoadMachineConfigs reads the per-pool MachineConfig files (mc-<pool>.yaml) written to destDir.
+func LoadMachineConfigs(destDir string) ([]*machineconfigurationv1.MachineConfig, error) {
+	paths, err := filepath.Glob(filepath.Join(destDir, consts.MCFileNamePrefix+"-*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MachineConfig files in %s: %w", destDir, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no MachineConfig files (%s-<pool>.yaml) found in %s", consts.MCFileNamePrefix, destDir)
+	}
+	sort.Strings(paths)
+
+	mcs := make([]*machineconfigurationv1.MachineConfig, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		mc := &machineconfigurationv1.MachineConfig{}
+		if err := yaml.UnmarshalStrict(content, mc); err != nil {
+			return nil, fmt.Errorf("failed to parse MachineConfig %s: %w", path, err)
+		}
+		if mc.Name == "" {
+			return nil, fmt.Errorf("MachineConfig in %s has no name", path)
+		}
+		mcs = append(mcs, mc)
+	}
+
+	return mcs, nil
+}
+
+// DesiredNodeDisruptionPolicy returns the node disruption policy entries needed to reload
+// nftables rules without draining and rebooting nodes.
+func DesiredNodeDisruptionPolicy() (operatorv1.NodeDisruptionPolicyConfig, error) {
+	mc := &operatorv1.MachineConfiguration{}
+	if err := yaml.Unmarshal([]byte(firewall.NodeDisruptionPolicyPatch), mc); err != nil {
+		return operatorv1.NodeDisruptionPolicyConfig{}, fmt.Errorf("failed to parse the node disruption policy: %w", err)
+	}
+
+	return mc.Spec.NodeDisruptionPolicy, nil
+}
+
+// MergeNodeDisruptionPolicy adds the desired units and files to existing. Entries already
+// defined for the same unit name or file path are kept as they are.
+// Returns true if existing was modified.
+func MergeNodeDisruptionPolicy(existing *operatorv1.NodeDisruptionPolicyConfig, desired operatorv1.NodeDisruptionPolicyConfig) bool {
+	changed := false
+	for _, unit := range desired.Units {
+		if slices.ContainsFunc(existing.Units, func(u operatorv1.NodeDisruptionPolicySpecUnit) bool { return u.Name == unit.Name }) {
+			log.Debugf("Keeping the existing node disruption policy for unit %s", unit.Name)
+			continue
+		}
+		existing.Units = append(existing.Units, unit)
+		changed = true
+	}
+
+	for _, file := range desired.Files {
+		if slices.ContainsFunc(existing.Files, func(f operatorv1.NodeDisruptionPolicySpecFile) bool { return f.Path == file.Path }) {
+			log.Debugf("Keeping the existing node disruption policy for file %s", file.Path)
+			continue
+		}
+		existing.Files = append(existing.Files, file)
+		changed = true
+	}
+
+	return changed
+}
+
+// ApplyNodeDisruptionPolicy merges the nftables node disruption policy into the cluster
+// MachineConfiguration object.
+func (a *Applier) ApplyNodeDisruptionPolicy(ctx context.Context) error {
+	desired, err := DesiredNodeDisruptionPolicy()
+	if err != nil {
+		return err
+	}
+
+	mc := &operatorv1.MachineConfiguration{}
+	if err := a.client.Get(ctx, types.NamespacedName{Name: machineConfigurationName}, mc); err != nil {
+		return fmt.Errorf("failed to get machineconfiguration %s: %w", machineConfigurationName, err)
+	}
+
+	live := mc.Spec.NodeDisruptionPolicy.DeepCopy()
+	ref := "machineconfiguration/" + machineConfigurationName
+	if !MergeNodeDisruptionPolicy(&mc.Spec.NodeDisruptionPolicy, desired) {
+		a.printf("%s unchanged\n", ref)
+		return nil
+	}
+
+	if err := a.printDiff(ref, live, mc.Spec.NodeDisruptionPolicy); err != nil {
+		return err
+	}
+
+	if a.dryRun != DryRunClient {
+		if err := a.client.Update(ctx, mc, a.updateOptions()...); err != nil {
+			return fmt.Errorf("failed to update %s: %w", ref, err)
+		}
+	}
+
+	a.printf("%s configured%s\n", ref, a.suffix())
+	return nil
+}
+
+// ApplyMachineConfig creates the given MachineConfig or updates its labels and spec if it exists.
+func (a *Applier) ApplyMachineConfig(ctx context.Context, desired *machineconfigurationv1.MachineConfig) error {
+	ref := "machineconfig/" + desired.Name
+	desiredView := machineConfigView(desired)
+
+	live := &machineconfigurationv1.MachineConfig{}
+	err := a.client.Get(ctx, types.NamespacedName{Name: desired.Name}, live)
+	if k8serrors.IsNotFound(err) {
+		if err := a.printDiff(ref, nil, desiredView); err != nil {
+			return err
+		}
+		if a.dryRun != DryRunClient {
+			if err := a.client.Create(ctx, desired.DeepCopy(), a.createOptions()...); err != nil {
+				return fmt.Errorf("failed to create %s: %w", ref, err)
+			}
+		}
+		a.printf("%s created%s\n", ref, a.suffix())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", ref, err)
+	}
+
+	liveView := machineConfigView(live)
+	updated := live.DeepCopy()
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		updated.Labels[k] = v
+	}
+	updated.Spec = desired.Spec
+
+	changed, err := a.diffChanged(ref, liveView, machineConfigView(updated))
+	if err != nil {
+		return err
+	}
+	if !changed {
+		a.printf("%s unchanged\n", ref)
+		return nil
+	}
+
+	if a.dryRun != DryRunClient {
+		if err := a.client.Update(ctx, updated, a.updateOptions()...); err != nil {
+			return fmt.Errorf("failed to update %s: %w", ref, err)
+		}
+	}
+	a.printf("%s configured%s\n", ref, a.suffix())
+	return nil
+}
+
+// mcView holds the MachineConfig fields managed by apply.
+type mcView struct {
+	Labels map[string]string                        `json:"labels,omitempty"`
+	Spec   machineconfigurationv1.MachineConfigSpec `json:"spec"`
+}
+
+func machineConfigView(mc *machineconfigurationv1.MachineConfig) *mcView {
+	return &mcView{Labels: mc.Labels, Spec: mc.Spec}
+}
+
+// diffChanged prints the diff between live and desired and reports whether they differ.
+func (a *Applier) diffChanged(ref string, live, desired any) (bool, error) {
+	liveYAML, desiredYAML, err := marshalPair(live, desired)
+	if err != nil {
+		return false, fmt.Errorf("failed to render %s: %w", ref, err)
+	}
+	if liveYAML == desiredYAML {
+		return false, nil
+	}
+
+	return true, a.writeDiff(ref, liveYAML, desiredYAML)
+}
+
+func (a *Applier) printDiff(ref string, live, desired any) error {
+	liveYAML, desiredYAML, err := marshalPair(live, desired)
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %w", ref, err)
+	}
+
+	return a.writeDiff(ref, liveYAML, desiredYAML)
+}
+
+// writeDiff prints a unified diff between the live and desired objects. Diffs are only
+// shown in dry run modes, where they are the main output of the command.
+func (a *Applier) writeDiff(ref, liveYAML, desiredYAML string) error {
+	if a.dryRun == DryRunNone {
+		return nil
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(liveYAML),
+		B:        difflib.SplitLines(desiredYAML),
+		FromFile: "live/" + ref,
+		ToFile:   "desired/" + ref,
+		Context:  3,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to diff %s: %w", ref, err)
+	}
+
+	a.printf("%s", diff)
+	return nil
+}
+
+func marshalPair(live, desired any) (string, string, error) {
+	liveYAML := ""
+	if live != nil {
+		out, err := yaml.Marshal(live)
+		if err != nil {
+			return "", "", err
+		}
+		liveYAML = string(out)
+	}
+
+	out, err := yaml.Marshal(desired)
+	if err != nil {
+		return "", "", err
+	}
+
+	return liveYAML, string(out), nil
+}
+
+func (a *Applier) createOptions() []runtimeclient.CreateOption {
+	if a.dryRun == DryRunServer {
+		return []runtimeclient.CreateOption{runtimeclient.DryRunAll}
+	}
+	return nil
+}
+
+func (a *Applier) updateOptions() []runtimeclient.UpdateOption {
+	if a.dryRun == DryRunServer {
+		return []runtimeclient.UpdateOption{runtimeclient.DryRunAll}
+	}
+	return nil
+}
+
+func (a *Applier) suffix() string {
+	if a.dryRun == DryRunNone {
+		return ""
+	}
+	return fmt.Sprintf(" (%s dry run)", a.dryRun)
+}
+
+func (a *Applier) printf(format string, args ...any) {
+	fmt.Fprintf(a.out, format, args...)
+}
@@ -0,0 +1,170 @@
+package firewallapply
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machineconfigurationv1 "github.com/openshift/api/machineconfiguration/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testMachineConfig = `apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfig
+metadata:
+  labels:
+    machineconfiguration.openshift.io/role: master
+  name: 98-nftables-commatrix-master
+spec:
+  config:
+    ignition:
+      version: 3.4.0
+`
+
+func newFakeClient(objs ...runtimeclient.Object) runtimeclient.Client {
+	sch := runtime.NewScheme()
+	Expect(operatorv1.AddToScheme(sch)).To(Succeed())
+	Expect(machineconfigurationv1.AddToScheme(sch)).To(Succeed())
+	return fake.NewClientBuilder().WithScheme(sch).WithObjects(objs...).Build()
+}
+
+func clusterMachineConfiguration(policy operatorv1.NodeDisruptionPolicyConfig) *operatorv1.MachineConfiguration {
+	return &operatorv1.MachineConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: machineConfigurationName},
+		Spec:       operatorv1.MachineConfigurationSpec{NodeDisruptionPolicy: policy},
+	}
+}
+
+func loadTestMachineConfig() *machineconfigurationv1.MachineConfig {
+	dir := GinkgoT().TempDir()
+	Expect(os.WriteFile(filepath.Join(dir, "mc-master.yaml"), []byte(testMachineConfig), 0644)).To(Succeed())
+	mcs, err := LoadMachineConfigs(dir)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(mcs).To(HaveLen(1))
+	return mcs[0]
+}
+
+var _ = Describe("MergeNodeDisruptionPolicy", func() {
+	It("adds the nftables entries and keeps unrelated ones", func() {
+		desired, err := DesiredNodeDisruptionPolicy()
+		Expect(err).ToNot(HaveOccurred())
+
+		existing := operatorv1.NodeDisruptionPolicyConfig{
+			Files: []operatorv1.NodeDisruptionPolicySpecFile{
+				{Path: "/etc/chrony.conf", Actions: []operatorv1.NodeDisruptionPolicySpecAction{{Type: operatorv1.NoneSpecAction}}},
+			},
+		}
+		Expect(MergeNodeDisruptionPolicy(&existing, desired)).To(BeTrue())
+		Expect(existing.Files).To(HaveLen(2))
+		Expect(existing.Files[0].Path).To(Equal("/etc/chrony.conf"))
+		Expect(existing.Files[1].Path).To(Equal("/etc/sysconfig/nftables.conf"))
+		Expect(existing.Units).To(HaveLen(1))
+		Expect(string(existing.Units[0].Name)).To(Equal("nftables.service"))
+
+		By("being idempotent")
+		Expect(MergeNodeDisruptionPolicy(&existing, desired)).To(BeFalse())
+	})
+
+	It("does not overwrite an existing policy for the same unit", func() {
+		desired, err := DesiredNodeDisruptionPolicy()
+		Expect(err).ToNot(HaveOccurred())
+
+		existing := operatorv1.NodeDisruptionPolicyConfig{
+			Units: []operatorv1.NodeDisruptionPolicySpecUnit{
+				{Name: "nftables.service", Actions: []operatorv1.NodeDisruptionPolicySpecAction{{Type: operatorv1.NoneSpecAction}}},
+			},
+		}
+		MergeNodeDisruptionPolicy(&existing, desired)
+		Expect(existing.Units).To(HaveLen(1))
+		Expect(existing.Units[0].Actions[0].Type).To(Equal(operatorv1.NoneSpecAction))
+	})
+})
+
+var _ = Describe("LoadMachineConfigs", func() {
+	It("fails when the directory has no MachineConfig files", func() {
+		_, err := LoadMachineConfigs(GinkgoT().TempDir())
+		Expect(err).To(MatchError(ContainSubstring("no MachineConfig files")))
+	})
+})
+
+var _ = Describe("Applier", func() {
+	var (
+		ctx context.Context
+		out *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		out = &bytes.Buffer{}
+	})
+
+	It("creates a missing MachineConfig", func() {
+		c := newFakeClient()
+		Expect(New(c, DryRunNone, out).ApplyMachineConfig(ctx, loadTestMachineConfig())).To(Succeed())
+		Expect(out.String()).To(Equal("machineconfig/98-nftables-commatrix-master created\n"))
+
+		mc := &machineconfigurationv1.MachineConfig{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "98-nftables-commatrix-master"}, mc)).To(Succeed())
+		Expect(mc.Labels).To(HaveKeyWithValue("machineconfiguration.openshift.io/role", "master"))
+	})
+
+	It("reports an up to date MachineConfig as unchanged", func() {
+		desired := loadTestMachineConfig()
+		c := newFakeClient(desired.DeepCopy())
+		Expect(New(c, DryRunNone, out).ApplyMachineConfig(ctx, desired)).To(Succeed())
+		Expect(out.String()).To(Equal("machineconfig/98-nftables-commatrix-master unchanged\n"))
+	})
+
+	It("prints a diff and does not persist changes on server dry run", func() {
+		desired := loadTestMachineConfig()
+		live := desired.DeepCopy()
+		live.Labels["extra"] = "label"
+		live.Spec.KernelArguments = []string{"foo=bar"}
+		c := newFakeClient(live)
+
+		Expect(New(c, DryRunServer, out).ApplyMachineConfig(ctx, desired)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("--- live/machineconfig/98-nftables-commatrix-master"))
+		Expect(out.String()).To(ContainSubstring("-  kernelArguments:"))
+		Expect(out.String()).To(ContainSubstring("machineconfig/98-nftables-commatrix-master configured (server dry run)"))
+
+		mc := &machineconfigurationv1.MachineConfig{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: desired.Name}, mc)).To(Succeed())
+		Expect(mc.Spec.KernelArguments).To(Equal([]string{"foo=bar"}))
+	})
+
+	It("merges the node disruption policy into the cluster MachineConfiguration", func() {
+		existing := operatorv1.NodeDisruptionPolicyConfig{
+			Files: []operatorv1.NodeDisruptionPolicySpecFile{
+				{Path: "/etc/chrony.conf", Actions: []operatorv1.NodeDisruptionPolicySpecAction{{Type: operatorv1.NoneSpecAction}}},
+			},
+		}
+		c := newFakeClient(clusterMachineConfiguration(existing))
+		applier := New(c, DryRunNone, out)
+		Expect(applier.ApplyNodeDisruptionPolicy(ctx)).To(Succeed())
+		Expect(out.String()).To(Equal("machineconfiguration/cluster configured\n"))
+
+		mc := &operatorv1.MachineConfiguration{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: machineConfigurationName}, mc)).To(Succeed())
+		Expect(mc.Spec.NodeDisruptionPolicy.Files).To(HaveLen(2))
+		Expect(mc.Spec.NodeDisruptionPolicy.Units).To(HaveLen(1))
+
+		By("reporting a second run as unchanged")
+		out.Reset()
+		Expect(applier.ApplyNodeDisruptionPolicy(ctx)).To(Succeed())
+		Expect(out.String()).To(Equal("machineconfiguration/cluster unchanged\n"))
+	})
+
+	It("fails when the cluster MachineConfiguration does not exist", func() {
+		err := New(newFakeClient(), DryRunNone, out).ApplyNodeDisruptionPolicy(ctx)
+		Expect(err).To(MatchError(ContainSubstring("failed to get machineconfiguration cluster")))
+	})
+})
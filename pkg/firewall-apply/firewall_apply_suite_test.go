@@ -0,0 +1,13 @@
+package firewallapply
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFirewallApply(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Firewall Apply Suite")
+}
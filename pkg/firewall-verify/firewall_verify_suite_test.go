@@ -0,0 +1,13 @@
+package firewallverify
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFirewallVerify(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Firewall Verify Suite")
+}
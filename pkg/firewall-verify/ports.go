@@ -0,0 +1,192 @@
+package firewallverify
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/openshift-kni/commatrix/pkg/consts"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// PortRange is an inclusive range of ports allowed for a protocol.
+type PortRange struct {
+	Protocol string `json:"protocol" yaml:"protocol"`
+	Start    int    `json:"start" yaml:"start"`
+	End      int    `json:"end" yaml:"end"`
+}
+
+func (pr PortRange) String() string {
+	if pr.Start == pr.End {
+		return fmt.Sprintf("%s/%d", pr.Protocol, pr.Start)
+	}
+	return fmt.Sprintf("%s/%d-%d", pr.Protocol, pr.Start, pr.End)
+}
+
+// ExpectedPorts returns the TCP, UDP and SCTP ports allowed by the input chain that
+// ComMatrix.ToNFTables generates for m with the given options, read from the same rules in
+// the libnftables JSON schema. Egress entries are not part of it.
+func ExpectedPorts(m *types.ComMatrix, opts ...types.NFTOption) ([]PortRange, error) {
+	out, err := m.ToNFTablesJSON(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the nftables rules of the matrix: %w", err)
+	}
+
+	return ParseNFTablesJSON(out)
+}
+
+// nftJSON is the subset of the `nft -j` output needed to find accepted destination ports.
+type nftJSON struct {
+	Nftables []struct {
+		Rule *struct {
+			Chain string                       `json:"chain"`
+			Expr  []map[string]json.RawMessage `json:"expr"`
+		} `json:"rule,omitempty"`
+	} `json:"nftables"`
+}
+
+type nftMatch struct {
+	Op   string `json:"op"`
+	Left struct {
+		Payload *struct {
+			Protocol string `json:"protocol"`
+			Field    string `json:"field"`
+		} `json:"payload"`
+	} `json:"left"`
+	Right json.RawMessage `json:"right"`
+}
+
+// ParseNFTablesJSON returns the TCP, UDP and SCTP destination ports accepted by the rules in
+// the output of `nft -j list table ...`. Only rules matching on "tcp dport", "udp dport" or
+// "sctp dport" and ending with an accept verdict are considered. The rules of the egress chain are ignored.
+func ParseNFTablesJSON(data []byte) ([]PortRange, error) {
+	out := &nftJSON{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("failed to parse nft JSON output: %w", err)
+	}
+
+	var res []PortRange
+	for _, obj := range out.Nftables {
+		if obj.Rule == nil || len(obj.Rule.Expr) == 0 || obj.Rule.Chain == consts.NFTEgressChain {
+			continue
+		}
+		if _, accept := obj.Rule.Expr[len(obj.Rule.Expr)-1]["accept"]; !accept {
+			continue
+		}
+
+		for _, expr := range obj.Rule.Expr {
+			raw, ok := expr["match"]
+			if !ok {
+				continue
+			}
+			match := &nftMatch{}
+			if err := json.Unmarshal(raw, match); err != nil {
+				return nil, fmt.Errorf("failed to parse nft match expression: %w", err)
+			}
+			payload := match.Left.Payload
+			if payload == nil || payload.Field != "dport" || (match.Op != "==" && match.Op != "in") {
+				continue
+			}
+			protocol := strings.ToUpper(payload.Protocol)
+			if !slices.Contains(types.NFTProtocols, protocol) {
+				continue
+			}
+
+			ranges, err := parsePortValue(match.Right, protocol)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, ranges...)
+		}
+	}
+
+	return normalize(res), nil
+}
+
+// parsePortValue parses the right side of a dport match: a port, a range or a set of both.
+func parsePortValue(raw json.RawMessage, protocol string) ([]PortRange, error) {
+	var port int
+	if err := json.Unmarshal(raw, &port); err == nil {
+		return []PortRange{{Protocol: protocol, Start: port, End: port}}, nil
+	}
+
+	var obj struct {
+		Set   []json.RawMessage `json:"set"`
+		Range []int             `json:"range"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("unsupported %s dport value %s", protocol, string(raw))
+	}
+
+	if len(obj.Range) == 2 {
+		return []PortRange{{Protocol: protocol, Start: obj.Range[0], End: obj.Range[1]}}, nil
+	}
+
+	var res []PortRange
+	for _, elem := range obj.Set {
+		ranges, err := parsePortValue(elem, protocol)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ranges...)
+	}
+
+	return res, nil
+}
+
+// Compare returns the port ranges in expected but not in actual (missing) and
+// the port ranges in actual but not in expected (extra).
+func Compare(expected, actual []PortRange) (missing, extra []PortRange) {
+	expected, actual = normalize(expected), normalize(actual)
+	return subtract(expected, actual), subtract(actual, expected)
+}
+
+// normalize sorts the ranges and merges overlapping and adjacent ranges of the same protocol.
+func normalize(ranges []PortRange) []PortRange {
+	sorted := make([]PortRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Protocol != sorted[j].Protocol {
+			return sorted[i].Protocol < sorted[j].Protocol
+		}
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	var res []PortRange
+	for _, pr := range sorted {
+		if n := len(res); n > 0 && res[n-1].Protocol == pr.Protocol && pr.Start <= res[n-1].End+1 {
+			res[n-1].End = max(res[n-1].End, pr.End)
+			continue
+		}
+		res = append(res, pr)
+	}
+
+	return res
+}
+
+// subtract returns the parts of the normalized ranges a that are not covered by the normalized ranges b.
+func subtract(a, b []PortRange) []PortRange {
+	var res []PortRange
+	for _, pr := range a {
+		start := pr.Start
+		for _, other := range b {
+			if other.Protocol != pr.Protocol || other.End < start || other.Start > pr.End {
+				continue
+			}
+			if other.Start > start {
+				res = append(res, PortRange{Protocol: pr.Protocol, Start: start, End: other.Start - 1})
+			}
+			start = other.End + 1
+			if start > pr.End {
+				break
+			}
+		}
+		if start <= pr.End {
+			res = append(res, PortRange{Protocol: pr.Protocol, Start: start, End: pr.End})
+		}
+	}
+
+	return res
+}
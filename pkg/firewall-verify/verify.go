@@ -0,0 +1,143 @@
+package firewallverify
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/openshift-kni/commatrix/pkg/consts"
+	"github.com/openshift-kni/commatrix/pkg/mcp"
+	"github.com/openshift-kni/commatrix/pkg/types"
+	"github.com/openshift-kni/commatrix/pkg/utils"
+)
+
+// ListTableCommand prints the commatrix nftables table of the host in JSON format.
+var ListTableCommand = []string{"chroot", "/host", "nft", "-j", "list", "table", "inet", "openshift_filter"}
+
+// NodeResult is the verification result of a single node.
+type NodeResult struct {
+	Node      string      `json:"node" yaml:"node"`
+	NodeGroup string      `json:"nodeGroup" yaml:"nodeGroup"`
+	Missing   []PortRange `json:"missing" yaml:"missing"`
+	Extra     []PortRange `json:"extra" yaml:"extra"`
+	Error     string      `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Passed returns true if the loaded ruleset of the node matches the expected ports.
+func (r NodeResult) Passed() bool {
+	return r.Error == "" && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// Result holds the verification results of all nodes, sorted by node name.
+type Result struct {
+	Nodes []NodeResult `json:"nodes" yaml:"nodes"`
+}
+
+// Passed returns true if all nodes passed the verification.
+func (r *Result) Passed() bool {
+	for _, n := range r.Nodes {
+		if !n.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+type Verifier struct {
+	podUtils    utils.UtilsInterface
+	nodeToGroup map[string]string
+}
+
+func NewVerifier(podUtils utils.UtilsInterface, customNodeGroups map[string]labels.Selector) (*Verifier, error) {
+	nodes, err := podUtils.ListNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeToGroup, err := mcp.ResolveNodeToGroup(nodes, customNodeGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{
+		podUtils:    podUtils,
+		nodeToGroup: nodeToGroup,
+	}, nil
+}
+
+// Verify reads the loaded nftables ruleset of every node through a debug pod in namespace
+// and compares its allowed ports with the ports expected for the node's group in matrix,
+// the rules being generated with the given options. Failures to read the ruleset of a node
+// are reported in its NodeResult.
+func (v *Verifier) Verify(namespace string, matrix *types.ComMatrix, opts ...types.NFTOption) (*Result, error) {
+	expected := map[string][]PortRange{}
+	for group, groupMatrix := range matrix.SeparateMatrixByGroup() {
+		ports, err := ExpectedPorts(&groupMatrix, opts...)
+		if err != nil {
+			return nil, err
+		}
+		expected[group] = ports
+	}
+
+	result := &Result{}
+	nLock := &sync.Mutex{}
+	g := new(errgroup.Group)
+	for nodeName, group := range v.nodeToGroup {
+		name, group := nodeName, group
+		g.Go(func() error {
+			res := NodeResult{Node: name, NodeGroup: group}
+			actual, err := v.loadedPorts(name, namespace)
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Missing, res.Extra = Compare(expected[group], actual)
+			}
+
+			nLock.Lock()
+			defer nLock.Unlock()
+			result.Nodes = append(result.Nodes, res)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result.Nodes, func(i, j int) bool {
+		return result.Nodes[i].Node < result.Nodes[j].Node
+	})
+
+	return result, nil
+}
+
+func (v *Verifier) loadedPorts(nodeName, namespace string) ([]PortRange, error) {
+	debugPod, err := v.podUtils.CreatePodOnNode(nodeName, namespace, consts.DefaultDebugPodImage, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		err := v.podUtils.DeletePod(debugPod)
+		if err != nil {
+			log.Warningf("failed cleaning debug pod %s: %v", debugPod.Name, err)
+		}
+	}()
+
+	err = v.podUtils.WaitForPodStatus(namespace, debugPod, corev1.PodRunning)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := v.podUtils.RunCommandOnPod(debugPod, ListTableCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the nftables table: %w", err)
+	}
+
+	return ParseNFTablesJSON(out)
+}
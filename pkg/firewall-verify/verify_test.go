@@ -0,0 +1,184 @@
+package firewallverify
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+	mock_utils "github.com/openshift-kni/commatrix/pkg/utils/mock"
+)
+
+// nftListTable is the `nft -j list table inet openshift_filter` output for the rules
+// generated by ToNFTables with TCP ports 22, 6443 and 30000-32767 and UDP port 111.
+const nftListTable = `{"nftables": [{"metainfo": {"version": "1.0.9", "release_name": "Old Doc Yak #3", "json_schema_version": 1}},
+{"table": {"family": "inet", "name": "openshift_filter", "handle": 3}},
+{"chain": {"family": "inet", "table": "openshift_filter", "name": "OPENSHIFT", "handle": 1, "type": "filter", "hook": "input", "prio": 1, "policy": "accept"}},
+{"rule": {"family": "inet", "table": "openshift_filter", "chain": "OPENSHIFT", "handle": 4, "expr": [{"match": {"op": "==", "left": {"meta": {"key": "iif"}}, "right": "lo"}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "openshift_filter", "chain": "OPENSHIFT", "handle": 5, "expr": [{"match": {"op": "in", "left": {"ct": {"key": "state"}}, "right": ["established", "related"]}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "openshift_filter", "chain": "OPENSHIFT", "handle": 8, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": {"set": [22, 6443, {"range": [30000, 32767]}]}}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "openshift_filter", "chain": "OPENSHIFT", "handle": 9, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "udp", "field": "dport"}}, "right": 111}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "openshift_filter", "chain": "OPENSHIFT", "handle": 10, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": 23}}, {"drop": null}]}}
+]}`
+
+var _ = Describe("ParseNFTablesJSON", func() {
+	It("returns the accepted TCP and UDP destination ports", func() {
+		ports, err := ParseNFTablesJSON([]byte(nftListTable))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ports).To(Equal([]PortRange{
+			{Protocol: "TCP", Start: 22, End: 22},
+			{Protocol: "TCP", Start: 6443, End: 6443},
+			{Protocol: "TCP", Start: 30000, End: 32767},
+			{Protocol: "UDP", Start: 111, End: 111},
+		}))
+	})
+
+	It("returns the accepted SCTP destination ports", func() {
+		ports, err := ParseNFTablesJSON([]byte(`{"nftables": [
+{"rule": {"family": "inet", "table": "openshift_filter", "chain": "OPENSHIFT", "handle": 10, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "sctp", "field": "dport"}}, "right": {"set": [38412, {"range": [30000, 32767]}]}}}, {"accept": null}]}}
+]}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ports).To(Equal([]PortRange{
+			{Protocol: "SCTP", Start: 30000, End: 32767},
+			{Protocol: "SCTP", Start: 38412, End: 38412},
+		}))
+	})
+
+	It("ignores the rules of the egress chain", func() {
+		ports, err := ParseNFTablesJSON([]byte(`{"nftables": [
+{"rule": {"family": "inet", "table": "openshift_filter", "chain": "OPENSHIFT", "handle": 8, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": 22}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "openshift_filter", "chain": "OPENSHIFT_EGRESS", "handle": 20, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": 443}}, {"accept": null}]}}
+]}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ports).To(Equal([]PortRange{{Protocol: "TCP", Start: 22, End: 22}}))
+	})
+
+	It("fails on invalid JSON", func() {
+		_, err := ParseNFTablesJSON([]byte("Error: No such file or directory"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ExpectedPorts", func() {
+	It("returns the ports accepted by the generated input chain", func() {
+		m := &types.ComMatrix{
+			Ports: []types.ComDetails{
+				{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "master"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 2379, NodeGroup: "master", SourceCIDRs: types.CIDRList{"10.0.0.0/16"}},
+				{Direction: "Ingress", Protocol: "UDP", Port: 6081, NodeGroup: "master", AddressFamily: types.AddressFamilyIPv4},
+				{Direction: "Egress", Protocol: "TCP", Port: 443, NodeGroup: "master"},
+			},
+			DynamicRanges: types.DynamicRangeList{
+				{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767},
+			},
+		}
+
+		for _, opts := range [][]types.NFTOption{nil, {types.WithNFTCounters()}, {types.WithNFTRulePerFlow()}} {
+			ports, err := ExpectedPorts(m, opts...)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ports).To(Equal([]PortRange{
+				{Protocol: "TCP", Start: 22, End: 22},
+				{Protocol: "TCP", Start: 2379, End: 2379},
+				{Protocol: "TCP", Start: 30000, End: 32767},
+				{Protocol: "UDP", Start: 6081, End: 6081},
+			}))
+		}
+	})
+})
+
+var _ = Describe("Compare", func() {
+	It("reports missing and extra port ranges", func() {
+		expected := []PortRange{
+			{Protocol: "TCP", Start: 22, End: 22},
+			{Protocol: "TCP", Start: 30000, End: 32767},
+			{Protocol: "UDP", Start: 111, End: 111},
+		}
+		actual := []PortRange{
+			{Protocol: "TCP", Start: 22, End: 22},
+			{Protocol: "TCP", Start: 80, End: 80},
+			{Protocol: "TCP", Start: 30000, End: 31000},
+		}
+
+		missing, extra := Compare(expected, actual)
+		Expect(missing).To(Equal([]PortRange{
+			{Protocol: "TCP", Start: 31001, End: 32767},
+			{Protocol: "UDP", Start: 111, End: 111},
+		}))
+		Expect(extra).To(Equal([]PortRange{{Protocol: "TCP", Start: 80, End: 80}}))
+	})
+
+	It("merges adjacent ports before comparing", func() {
+		missing, extra := Compare(
+			[]PortRange{{Protocol: "TCP", Start: 9000, End: 9001}},
+			[]PortRange{{Protocol: "TCP", Start: 9001, End: 9001}, {Protocol: "TCP", Start: 9000, End: 9000}},
+		)
+		Expect(missing).To(BeEmpty())
+		Expect(extra).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Verifier", func() {
+	var (
+		mockUtils *mock_utils.MockUtilsInterface
+		matrix    *types.ComMatrix
+		pod       *corev1.Pod
+	)
+
+	BeforeEach(func() {
+		ctrl := gomock.NewController(GinkgoT())
+		mockUtils = mock_utils.NewMockUtilsInterface(ctrl)
+		nodes := []corev1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "master-0", Annotations: map[string]string{"machineconfiguration.openshift.io/currentConfig": "rendered-master-abc"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Annotations: map[string]string{"machineconfiguration.openshift.io/currentConfig": "rendered-worker-abc"}}},
+		}
+		mockUtils.EXPECT().ListNodes().Return(nodes, nil).AnyTimes()
+		pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "ns"}}
+		mockUtils.EXPECT().CreatePodOnNode(gomock.Any(), "ns", gomock.Any(), gomock.Any()).Return(pod, nil).AnyTimes()
+		mockUtils.EXPECT().WaitForPodStatus("ns", pod, corev1.PodRunning).Return(nil).AnyTimes()
+		mockUtils.EXPECT().DeletePod(pod).Return(nil).AnyTimes()
+
+		matrix = &types.ComMatrix{
+			Ports: []types.ComDetails{
+				{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "master"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master"},
+				{Direction: "Ingress", Protocol: "UDP", Port: 111, NodeGroup: "master"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "worker"},
+				{Direction: "Ingress", Protocol: "UDP", Port: 111, NodeGroup: "worker"},
+			},
+			DynamicRanges: types.DynamicRangeList{{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767}},
+		}
+	})
+
+	It("reports per node results", func() {
+		mockUtils.EXPECT().RunCommandOnPod(pod, ListTableCommand).Return([]byte(nftListTable), nil).Times(2)
+
+		verifier, err := NewVerifier(mockUtils, nil)
+		Expect(err).ToNot(HaveOccurred())
+		report, err := verifier.Verify("ns", matrix)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(report.Nodes).To(HaveLen(2))
+		Expect(report.Nodes[0].Node).To(Equal("master-0"))
+		Expect(report.Nodes[0].Passed()).To(BeTrue())
+		Expect(report.Nodes[1].Node).To(Equal("worker-0"))
+		Expect(report.Nodes[1].NodeGroup).To(Equal("worker"))
+		Expect(report.Nodes[1].Extra).To(Equal([]PortRange{{Protocol: "TCP", Start: 6443, End: 6443}}))
+		Expect(report.Passed()).To(BeFalse())
+	})
+
+	It("reports nodes whose table cannot be read as failed", func() {
+		mockUtils.EXPECT().RunCommandOnPod(pod, ListTableCommand).Return(nil, errors.New("no such table")).Times(2)
+
+		verifier, err := NewVerifier(mockUtils, nil)
+		Expect(err).ToNot(HaveOccurred())
+		report, err := verifier.Verify("ns", matrix)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Nodes[0].Error).To(ContainSubstring("no such table"))
+		Expect(report.Passed()).To(BeFalse())
+	})
+})
@@ -9,7 +9,8 @@ const NodeDisruptionPolicyPatch = `# By default, the Machine Config Operator (MC
 #     oc get -o yaml machineconfiguration cluster
 #   If you aren't currently defining any nodeDisruptionPolicies, apply this file directly:
 #     oc patch machineconfiguration cluster --type=merge --patch-file=node-disruption-policy.yaml
-#   Otherwise, manually add these entries to .spec.nodeDisruptionPolicy.units and .spec.nodeDisruptionPolicy.files.
+#   Otherwise, manually add these entries to .spec.nodeDisruptionPolicy.units and .spec.nodeDisruptionPolicy.files,
+#   or run 'oc commatrix apply', which merges them with the existing policies.
 apiVersion: operator.openshift.io/v1
 kind: MachineConfiguration
 metadata:
@@ -0,0 +1,179 @@
+package internalflows
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/endpointslices"
+	"github.com/openshift-kni/commatrix/pkg/types"
+	configv1 "github.com/openshift/api/config/v1"
+	clientOptions "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Flow is an ingress flow that only the nodes and the pods of the cluster use.
+type Flow struct {
+	Protocol    string
+	Port        int
+	Description string
+	// NewNodes is set on the flows of the nodes joining the cluster, which only have an
+	// address of the machine network. They are left open when the machine network is unknown.
+	NewNodes bool
+}
+
+// Flows are the node-to-node flows restricted by --restrict-internal. Externally facing
+// flows, like the API server and the ingress routers, are never restricted.
+var Flows = []Flow{
+	{Protocol: types.ProtocolTCP, Port: 2379, Description: "etcd clients"},
+	{Protocol: types.ProtocolTCP, Port: 2380, Description: "etcd peers"},
+	{Protocol: types.ProtocolTCP, Port: 9641, Description: "OVN northbound database"},
+	{Protocol: types.ProtocolTCP, Port: 9642, Description: "OVN southbound database"},
+	{Protocol: types.ProtocolTCP, Port: 9643, Description: "OVN northbound database raft"},
+	{Protocol: types.ProtocolTCP, Port: 9644, Description: "OVN southbound database raft"},
+	{Protocol: types.ProtocolTCP, Port: 10250, Description: "kubelet"},
+	{Protocol: types.ProtocolUDP, Port: 6081, Description: "Geneve tunnel"},
+	{Protocol: types.ProtocolTCP, Port: 22623, Description: "Machine Config Server", NewNodes: true},
+	{Protocol: types.ProtocolTCP, Port: 22624, Description: "Machine Config Server", NewNodes: true},
+}
+
+// installConfigMap holds the install-config of the cluster.
+const (
+	installConfigMapNamespace = "kube-system"
+	installConfigMapName      = "cluster-config-v1"
+	installConfigKey          = "install-config"
+)
+
+// installConfig is the part of the install-config holding the machine networks.
+type installConfig struct {
+	Networking struct {
+		MachineNetwork []struct {
+			CIDR string `json:"cidr"`
+		} `json:"machineNetwork"`
+	} `json:"networking"`
+}
+
+// GetSources returns the addresses allowed to reach the internal flows: the internal and
+// external addresses of the nodes of every node group, the machine networks and the cluster
+// networks of the Network config. machineNetworkKnown is false when the machine networks
+// could not be found.
+func GetSources(exporter *endpointslices.EndpointSlicesExporter, nodes []corev1.Node) (sources types.CIDRList, machineNetworkKnown bool, err error) {
+	log.Debug("Getting the internal flows sources")
+	infra := &configv1.Infrastructure{}
+	if err := exporter.Get(context.TODO(), clientOptions.ObjectKey{Name: "cluster"}, infra); err != nil {
+		return nil, false, fmt.Errorf("failed to get Infrastructure config: %w", err)
+	}
+
+	network := &configv1.Network{}
+	if err := exporter.Get(context.TODO(), clientOptions.ObjectKey{Name: "cluster"}, network); err != nil {
+		return nil, false, fmt.Errorf("failed to get Network config: %w", err)
+	}
+
+	machineNetworks, err := GetMachineNetworks(exporter, infra.Status.PlatformStatus)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, node := range nodes {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != corev1.NodeInternalIP && addr.Type != corev1.NodeExternalIP {
+				continue
+			}
+			if ip := net.ParseIP(addr.Address); ip != nil {
+				sources = append(sources, ip.String())
+			}
+		}
+	}
+
+	if len(machineNetworks) == 0 {
+		log.Warnf("The machine network is unknown, neither the Infrastructure status nor the %s/%s ConfigMap, "+
+			"which must-gathers seldom hold, list it: the Machine Config Server is left open to every source "+
+			"and the other internal flows only allow the nodes added later once the matrix is regenerated",
+			installConfigMapNamespace, installConfigMapName)
+	}
+	sources = append(sources, machineNetworks...)
+
+	for _, entry := range network.Spec.ClusterNetwork {
+		sources = append(sources, entry.CIDR)
+	}
+
+	slices.Sort(sources)
+	sources = slices.Compact(sources)
+	if len(sources) == 0 {
+		return nil, false, fmt.Errorf("no node addresses or cluster networks found")
+	}
+
+	// Reject anything the nftables output could not use as a source.
+	if _, _, err := sources.ByFamily(); err != nil {
+		return nil, false, err
+	}
+
+	return sources, len(machineNetworks) > 0, nil
+}
+
+// GetMachineNetworks returns the machine networks of the Infrastructure config platform
+// status, or else of the install-config stored in kube-system/cluster-config-v1, which
+// holds them on every platform. It returns none when neither has them.
+func GetMachineNetworks(c clientOptions.Reader, status *configv1.PlatformStatus) ([]string, error) {
+	if res := types.MachineNetworks(status); len(res) > 0 {
+		return res, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := c.Get(context.TODO(), clientOptions.ObjectKey{Namespace: installConfigMapNamespace, Name: installConfigMapName}, cm)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the install-config: %w", err)
+	}
+
+	var config installConfig
+	if err := yaml.Unmarshal([]byte(cm.Data[installConfigKey]), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the install-config: %w", err)
+	}
+
+	res := []string{}
+	for _, network := range config.Networking.MachineNetwork {
+		if network.CIDR != "" {
+			res = append(res, network.CIDR)
+		}
+	}
+	return res, nil
+}
+
+// Restrict sets the sources on the ingress entries of the internal flows. Entries that
+// already have sources, like the ones restricted by custom entries, are left unchanged.
+// The flows of the joining nodes are only restricted when the machine network is known.
+func Restrict(entries []types.ComDetails, sources types.CIDRList, machineNetworkKnown bool) {
+	for i := range entries {
+		cd := &entries[i]
+		if cd.IsEgress() || len(cd.SourceCIDRs) > 0 || !IsInternal(*cd) {
+			continue
+		}
+		if !machineNetworkKnown && isNewNodesFlow(*cd) {
+			log.Debugf("Leaving %s/%d of %s open, the machine network is unknown", cd.Protocol, cd.Port, cd.NodeGroup)
+			continue
+		}
+		log.Debugf("Restricting %s/%d of %s to the cluster sources", cd.Protocol, cd.Port, cd.NodeGroup)
+		cd.SourceCIDRs = slices.Clone(sources)
+	}
+}
+
+func isNewNodesFlow(cd types.ComDetails) bool {
+	return slices.ContainsFunc(Flows, func(f Flow) bool {
+		return f.NewNodes && f.Protocol == cd.Protocol && f.Port == cd.Port
+	})
+}
+
+// IsInternal returns true if the entry is one of the internal flows.
+func IsInternal(cd types.ComDetails) bool {
+	return slices.ContainsFunc(Flows, func(f Flow) bool {
+		return f.Protocol == cd.Protocol && f.Port == cd.Port
+	})
+}
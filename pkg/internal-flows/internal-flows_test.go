@@ -0,0 +1,159 @@
+package internalflows
+
+import (
+	g "github.com/onsi/ginkgo/v2"
+	o "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	rtclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+	"github.com/openshift-kni/commatrix/pkg/endpointslices"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+func newExporterWithObjects(objs ...rtclient.Object) *endpointslices.EndpointSlicesExporter {
+	sch := runtime.NewScheme()
+	o.Expect(configv1.AddToScheme(sch)).ToNot(o.HaveOccurred())
+	o.Expect(corev1.AddToScheme(sch)).ToNot(o.HaveOccurred())
+
+	fakeClient := fake.NewClientBuilder().WithScheme(sch).WithObjects(objs...).Build()
+	return &endpointslices.EndpointSlicesExporter{ClientSet: &client.ClientSet{Client: fakeClient}}
+}
+
+func nodeWithAddresses(name string, addrs ...corev1.NodeAddress) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NodeStatus{Addresses: addrs},
+	}
+}
+
+var _ = g.Describe("Internal flows", func() {
+	network := &configv1.Network{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: configv1.NetworkSpec{
+			ClusterNetwork: []configv1.ClusterNetworkEntry{{CIDR: "10.128.0.0/14"}, {CIDR: "fd01::/48"}},
+		},
+	}
+	nodes := []corev1.Node{
+		nodeWithAddresses("master-0",
+			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "192.168.1.10"},
+			corev1.NodeAddress{Type: corev1.NodeHostName, Address: "master-0"}),
+		nodeWithAddresses("worker-0",
+			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "192.168.1.20"},
+			corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "203.0.113.20"}),
+	}
+
+	awsInfra := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status: configv1.InfrastructureStatus{
+			PlatformStatus: &configv1.PlatformStatus{Type: configv1.AWSPlatformType},
+		},
+	}
+
+	g.Context("GetSources", func() {
+		g.It("returns the node addresses, the machine networks and the cluster networks", func() {
+			infra := &configv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Status: configv1.InfrastructureStatus{
+					PlatformStatus: &configv1.PlatformStatus{
+						Type: configv1.BareMetalPlatformType,
+						BareMetal: &configv1.BareMetalPlatformStatus{
+							MachineNetworks: []configv1.CIDR{"192.168.1.0/24"},
+						},
+					},
+				},
+			}
+
+			got, known, err := GetSources(newExporterWithObjects(infra, network), nodes)
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(known).To(o.BeTrue())
+			o.Expect(got).To(o.Equal(types.CIDRList{
+				"10.128.0.0/14", "192.168.1.0/24", "192.168.1.10", "192.168.1.20", "203.0.113.20", "fd01::/48",
+			}))
+		})
+
+		g.It("reads the machine networks from the install-config when the platform has none", func() {
+			installConfig := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "cluster-config-v1"},
+				Data: map[string]string{
+					"install-config": "apiVersion: v1\nnetworking:\n  machineNetwork:\n  - cidr: 10.0.0.0/16\n  networkType: OVNKubernetes\n",
+				},
+			}
+
+			got, known, err := GetSources(newExporterWithObjects(awsInfra, network, installConfig), nodes)
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(known).To(o.BeTrue())
+			o.Expect(got).To(o.Equal(types.CIDRList{
+				"10.0.0.0/16", "10.128.0.0/14", "192.168.1.10", "192.168.1.20", "203.0.113.20", "fd01::/48",
+			}))
+		})
+
+		g.It("only uses the node addresses when the machine networks are unknown", func() {
+			got, known, err := GetSources(newExporterWithObjects(awsInfra, network), nodes)
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(known).To(o.BeFalse())
+			o.Expect(got).To(o.Equal(types.CIDRList{
+				"10.128.0.0/14", "192.168.1.10", "192.168.1.20", "203.0.113.20", "fd01::/48",
+			}))
+		})
+
+		g.It("fails on an invalid install-config", func() {
+			installConfig := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "cluster-config-v1"},
+				Data:       map[string]string{"install-config": "networking: ["},
+			}
+
+			_, _, err := GetSources(newExporterWithObjects(awsInfra, network, installConfig), nodes)
+			o.Expect(err).To(o.HaveOccurred())
+		})
+
+		g.It("fails without the Infrastructure config", func() {
+			_, _, err := GetSources(newExporterWithObjects(network), nodes)
+			o.Expect(err).To(o.HaveOccurred())
+		})
+	})
+
+	g.Context("Restrict", func() {
+		g.It("only restricts the ingress entries of the internal flows without sources", func() {
+			sources := types.CIDRList{"192.168.1.0/24"}
+			entries := []types.ComDetails{
+				{Direction: "Ingress", Protocol: "TCP", Port: 10250, NodeGroup: "worker"},
+				{Direction: "Ingress", Protocol: "UDP", Port: 6081, NodeGroup: "master"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master"},
+				{Direction: "Ingress", Protocol: "UDP", Port: 10250, NodeGroup: "master"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 2379, NodeGroup: "master", SourceCIDRs: types.CIDRList{"10.0.0.1"}},
+				{Direction: "Egress", Protocol: "TCP", Port: 2380, NodeGroup: "master", Peer: "master"},
+			}
+
+			Restrict(entries, sources, true)
+			o.Expect(entries[0].SourceCIDRs).To(o.Equal(sources))
+			o.Expect(entries[1].SourceCIDRs).To(o.Equal(sources))
+			o.Expect(entries[2].SourceCIDRs).To(o.BeEmpty())
+			o.Expect(entries[3].SourceCIDRs).To(o.BeEmpty())
+			o.Expect(entries[4].SourceCIDRs).To(o.Equal(types.CIDRList{"10.0.0.1"}))
+			o.Expect(entries[5].SourceCIDRs).To(o.BeEmpty())
+		})
+
+		g.It("leaves the Machine Config Server open when the machine network is unknown", func() {
+			sources := types.CIDRList{"192.168.1.10"}
+			entries := []types.ComDetails{
+				{Direction: "Ingress", Protocol: "TCP", Port: 22623, NodeGroup: "master"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 22624, NodeGroup: "master"},
+				{Direction: "Ingress", Protocol: "TCP", Port: 10250, NodeGroup: "master"},
+			}
+
+			Restrict(entries, sources, false)
+			o.Expect(entries[0].SourceCIDRs).To(o.BeEmpty())
+			o.Expect(entries[1].SourceCIDRs).To(o.BeEmpty())
+			o.Expect(entries[2].SourceCIDRs).To(o.Equal(sources))
+
+			Restrict(entries, sources, true)
+			o.Expect(entries[0].SourceCIDRs).To(o.Equal(sources))
+			o.Expect(entries[1].SourceCIDRs).To(o.Equal(sources))
+		})
+	})
+})
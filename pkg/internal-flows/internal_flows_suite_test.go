@@ -0,0 +1,13 @@
+package internalflows
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestInternalFlows(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "InternalFlows Suite")
+}
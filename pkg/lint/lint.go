@@ -0,0 +1,395 @@
+package lint
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openshift-kni/commatrix/pkg/consts"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// Severity is the severity of an issue.
+type Severity string
+
+const (
+	// SeverityError marks entries that are rejected or produce a broken firewall.
+	SeverityError Severity = "error"
+	// SeverityWarning marks entries that are accepted but are likely a mistake.
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found in a custom entries file.
+type Issue struct {
+	Severity Severity `json:"severity" yaml:"severity"`
+	Line     int      `json:"line" yaml:"line"`
+	Column   int      `json:"column" yaml:"column"`
+	// Field is the name of the field the issue is about, as written in the file.
+	Field   string `json:"field,omitempty" yaml:"field,omitempty"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s: %s", i.Line, i.Column, i.Severity, i.Field, i.Message)
+}
+
+func errorf(line, column int, field, format string, args ...any) Issue {
+	return Issue{Severity: SeverityError, Line: line, Column: column, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+func warningf(line, column int, field, format string, args ...any) Issue {
+	return Issue{Severity: SeverityWarning, Line: line, Column: column, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// HasErrors returns true if any of issues is an error.
+func HasErrors(issues []Issue) bool {
+	return slices.ContainsFunc(issues, func(i Issue) bool { return i.Severity == SeverityError })
+}
+
+// Reference is an entry the custom entries are compared with to find duplicates.
+type Reference struct {
+	// Description tells where the entry comes from, e.g. "static entry list GeneralStaticEntriesMaster".
+	Description string
+	Entry       types.ComDetails
+}
+
+type linter struct {
+	nodeGroups []string
+	references []Reference
+}
+
+type Option func(*linter)
+
+// WithNodeGroups restricts the node groups the entries can use to groups.
+func WithNodeGroups(groups []string) Option {
+	return func(l *linter) {
+		l.nodeGroups = groups
+	}
+}
+
+// WithReferences warns about entries that duplicate one of refs.
+func WithReferences(refs ...Reference) Option {
+	return func(l *linter) {
+		l.references = append(l.references, refs...)
+	}
+}
+
+// validDirections are the directions of the entries, as used by the static entries.
+var validDirections = []string{consts.IngressLabel, consts.EgressLabel}
+
+// validProtocols are the protocols of the entries. The protocols are case sensitive,
+// lower case values are silently left out of the nftables rules.
+var validProtocols = []string{"TCP", "UDP", "SCTP"}
+
+// Lint checks the custom entries in content, in the given format (csv,json,yaml), and
+// returns the issues found, sorted by position. An error is returned if content
+// cannot be parsed at all.
+func Lint(content []byte, format string, opts ...Option) ([]Issue, error) {
+	l := &linter{}
+	for _, o := range opts {
+		o(l)
+	}
+
+	entries, issues, err := parse(content, format)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]int{}
+	for _, e := range entries {
+		if e.isRange {
+			issues = append(issues, l.checkRange(e, format)...)
+			continue
+		}
+
+		entryIssues, cd := l.checkPort(e)
+		issues = append(issues, entryIssues...)
+		if HasErrors(entryIssues) {
+			continue
+		}
+
+		key := cd.Key()
+		if line, ok := seen[key]; ok {
+			issues = append(issues, warningf(e.line, e.column, "", "duplicates the entry at line %d", line))
+			continue
+		}
+		seen[key] = e.line
+
+		// An entry with sources or an interface restricts the entry it duplicates.
+		for _, ref := range l.references {
+			if ref.Entry.Key() == key && len(cd.SourceCIDRs) == 0 && cd.Interface == "" {
+				issues = append(issues, warningf(e.line, e.column, "", "duplicates the %s entry of %s", key, ref.Description))
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+
+	return issues, nil
+}
+
+// Validate returns an error listing the errors found by Lint in content.
+// Warnings are ignored.
+func Validate(content []byte, format string, opts ...Option) error {
+	issues, err := Lint(content, format, opts...)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, i := range issues {
+		if i.Severity == SeverityError {
+			errs = append(errs, i.String())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid custom entries:\n%s", strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// checkPort checks a Ports entry and returns its issues and the parsed entry.
+func (l *linter) checkPort(e entry) ([]Issue, types.ComDetails) {
+	var issues []Issue
+	cd := types.ComDetails{}
+
+	cd.Direction, issues = checkDirection(e, issues)
+	cd.Protocol, issues = checkProtocol(e, issues)
+	cd.Port, issues = checkPortNumber(e, "port", issues)
+	issues = checkOptional(e, issues)
+
+	group, ok := e.fields["nodegroup"]
+	switch {
+	case !ok || group.raw == "":
+		issues = append(issues, missing(e, group, "nodeGroup"))
+	case len(l.nodeGroups) > 0 && !slices.Contains(l.nodeGroups, group.raw):
+		issues = append(issues, errorf(group.line, group.column, group.name,
+			"unknown node group %q, the cluster has %s", group.raw, strings.Join(l.nodeGroups, ",")))
+	}
+	cd.NodeGroup = group.raw
+
+	peer, ok := e.fields["peer"]
+	switch {
+	case ok && peer.raw != "" && !cd.IsEgress():
+		issues = append(issues, warningf(peer.line, peer.column, peer.name, "is only used by %s entries", consts.EgressLabel))
+	case ok && cd.IsEgress():
+		cd.Peer = peer.raw
+	}
+
+	cd.SourceCIDRs, issues = checkSourceCIDRs(e, cd.IsEgress(), issues)
+	cd.AddressFamily, issues = checkAddressFamily(e, issues)
+	cd.BindScope, cd.BindAddresses, issues = checkBindAddresses(e, cd.IsEgress(), issues)
+	cd.Interface, issues = checkInterface(e, cd.IsEgress(), issues)
+
+	return issues, cd
+}
+
+// checkRange checks a DynamicRanges entry. In the CSV format ranges are rows with
+// a "min-max" port.
+func (l *linter) checkRange(e entry, format string) []Issue {
+	var issues []Issue
+
+	direction, issues := checkDirection(e, issues)
+	_, issues = checkProtocol(e, issues)
+	issues = checkOptional(e, issues)
+	_, issues = checkSourceCIDRs(e, strings.EqualFold(direction, consts.EgressLabel), issues)
+
+	if format == types.FormatCSV {
+		port := e.fields["port"]
+		minRaw, maxRaw, _ := strings.Cut(port.raw, "-")
+		e.fields["minport"] = value{name: port.name, raw: strings.TrimSpace(minRaw), line: port.line, column: port.column}
+		e.fields["maxport"] = value{name: port.name, raw: strings.TrimSpace(maxRaw), line: port.line, column: port.column}
+	}
+
+	minPort, issues := checkPortNumber(e, "minPort", issues)
+	maxPort, issues := checkPortNumber(e, "maxPort", issues)
+	if minPort > 0 && maxPort > 0 && minPort > maxPort {
+		v := e.fields["minport"]
+		issues = append(issues, errorf(v.line, v.column, v.name, "range start %d is greater than its end %d", minPort, maxPort))
+	}
+
+	return issues
+}
+
+func checkDirection(e entry, issues []Issue) (string, []Issue) {
+	v, ok := e.fields["direction"]
+	switch {
+	case !ok || v.raw == "":
+		return "", append(issues, missing(e, v, "direction"))
+	case slices.Contains(validDirections, v.raw):
+	case containsFold(validDirections, v.raw):
+		issues = append(issues, warningf(v.line, v.column, v.name, "%q should be written %q", v.raw, canonical(validDirections, v.raw)))
+	default:
+		issues = append(issues, errorf(v.line, v.column, v.name, "unknown direction %q, must be one of %s", v.raw, strings.Join(validDirections, ",")))
+	}
+	return v.raw, issues
+}
+
+// checkSourceCIDRs checks that the sources are addresses or CIDRs and returns them.
+// The sources of egress entries are not part of the rules.
+func checkSourceCIDRs(e entry, egress bool, issues []Issue) (types.CIDRList, []Issue) {
+	v, ok := e.fields["sourcecidrs"]
+	if !ok || v.raw == "" {
+		return nil, issues
+	}
+	if egress {
+		return nil, append(issues, warningf(v.line, v.column, v.name, "is only used by %s entries", consts.IngressLabel))
+	}
+
+	sources := types.CIDRList(strings.Fields(v.raw))
+	for _, source := range sources {
+		if _, _, err := types.CIDRList([]string{source}).ByFamily(); err != nil {
+			issues = append(issues, errorf(v.line, v.column, v.name, "%q is not an address or a CIDR", source))
+		}
+	}
+
+	return sources, issues
+}
+
+// checkAddressFamily checks the address family of the entry, empty for both families.
+// The families are case sensitive like the protocols.
+func checkAddressFamily(e entry, issues []Issue) (string, []Issue) {
+	v, ok := e.fields["addressfamily"]
+	switch {
+	case !ok || v.raw == "":
+		return "", issues
+	case slices.Contains(types.AddressFamilies, v.raw):
+	case containsFold(types.AddressFamilies, v.raw):
+		issues = append(issues, errorf(v.line, v.column, v.name, "%q must be written %q", v.raw, canonical(types.AddressFamilies, v.raw)))
+	default:
+		issues = append(issues, errorf(v.line, v.column, v.name, "unknown address family %q, must be one of %s or empty for both", v.raw, strings.Join(types.AddressFamilies, ",")))
+	}
+	return v.raw, issues
+}
+
+// checkBindAddresses checks the scope and the addresses the listening sockets of the entry
+// are bound to and returns them. The bound addresses of egress entries and of the entries
+// bound to the wildcard are not part of the rules.
+func checkBindAddresses(e entry, egress bool, issues []Issue) (string, types.CIDRList, []Issue) {
+	scope, hasScope := e.fields["bindscope"]
+	switch {
+	case !hasScope || scope.raw == "":
+	case slices.Contains(types.BindScopes, scope.raw):
+	case containsFold(types.BindScopes, scope.raw):
+		issues = append(issues, errorf(scope.line, scope.column, scope.name, "%q must be written %q", scope.raw, canonical(types.BindScopes, scope.raw)))
+	default:
+		issues = append(issues, errorf(scope.line, scope.column, scope.name, "unknown bind scope %q, must be one of %s", scope.raw, strings.Join(types.BindScopes, ",")))
+	}
+
+	v, ok := e.fields["bindaddresses"]
+	if !ok || v.raw == "" {
+		return scope.raw, nil, issues
+	}
+	switch {
+	case egress:
+		return scope.raw, nil, append(issues, warningf(v.line, v.column, v.name, "is only used by %s entries", consts.IngressLabel))
+	case scope.raw == types.BindScopeWildcard:
+		issues = append(issues, warningf(v.line, v.column, v.name, "is ignored for the sockets bound to the %s", types.BindScopeWildcard))
+	}
+
+	addresses := types.CIDRList(strings.Fields(v.raw))
+	for _, addr := range addresses {
+		if net.ParseIP(addr) == nil {
+			issues = append(issues, errorf(v.line, v.column, v.name, "%q is not an address", addr))
+		}
+	}
+
+	return scope.raw, addresses, issues
+}
+
+// checkInterface checks the network interface of the entry, empty for every interface.
+// The interfaces of egress entries are not part of the rules.
+func checkInterface(e entry, egress bool, issues []Issue) (string, []Issue) {
+	v, ok := e.fields["interface"]
+	switch {
+	case !ok || v.raw == "":
+		return "", issues
+	case egress:
+		return "", append(issues, warningf(v.line, v.column, v.name, "is only used by %s entries", consts.IngressLabel))
+	}
+	if err := types.ValidateInterfaceName(v.raw); err != nil {
+		issues = append(issues, errorf(v.line, v.column, v.name, "%s", err))
+	}
+	return v.raw, issues
+}
+
+func checkProtocol(e entry, issues []Issue) (string, []Issue) {
+	v, ok := e.fields["protocol"]
+	switch {
+	case !ok || v.raw == "":
+		return "", append(issues, missing(e, v, "protocol"))
+	case slices.Contains(validProtocols, v.raw):
+	case containsFold(validProtocols, v.raw):
+		issues = append(issues, errorf(v.line, v.column, v.name, "%q must be upper case, use %q", v.raw, canonical(validProtocols, v.raw)))
+	default:
+		issues = append(issues, errorf(v.line, v.column, v.name, "unknown protocol %q, must be one of %s", v.raw, strings.Join(validProtocols, ",")))
+	}
+	return v.raw, issues
+}
+
+// checkPortNumber checks that the field is an integer in the valid port range and returns it.
+// Zero is returned for invalid ports.
+func checkPortNumber(e entry, field string, issues []Issue) (int, []Issue) {
+	v, ok := e.fields[strings.ToLower(field)]
+	if !ok || v.raw == "" {
+		return 0, append(issues, missing(e, v, field))
+	}
+	if v.tag != "" && v.tag != "!!int" {
+		return 0, append(issues, errorf(v.line, v.column, v.name, "%q is not an integer", v.raw))
+	}
+
+	port, err := strconv.Atoi(v.raw)
+	if err != nil {
+		return 0, append(issues, errorf(v.line, v.column, v.name, "%q is not an integer", v.raw))
+	}
+	if port < 1 || port > 65535 {
+		return 0, append(issues, errorf(v.line, v.column, v.name, "port %d is out of range 1-65535", port))
+	}
+
+	return port, issues
+}
+
+func checkOptional(e entry, issues []Issue) []Issue {
+	v, ok := e.fields["optional"]
+	if !ok || v.raw == "" || v.tag == "!!null" {
+		return issues
+	}
+	if v.tag != "" && v.tag != "!!bool" {
+		return append(issues, errorf(v.line, v.column, v.name, "%q is not a boolean", v.raw))
+	}
+	if _, err := strconv.ParseBool(v.raw); err != nil {
+		return append(issues, errorf(v.line, v.column, v.name, "%q is not a boolean", v.raw))
+	}
+	return issues
+}
+
+// missing reports a missing or empty field. The issue points at the field when it is
+// present and at the entry otherwise.
+func missing(e entry, v value, field string) Issue {
+	if v.name != "" {
+		return errorf(v.line, v.column, v.name, "must not be empty")
+	}
+	return errorf(e.line, e.column, field, "is required")
+}
+
+func canonical(list []string, s string) string {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return l
+		}
+	}
+	return s
+}
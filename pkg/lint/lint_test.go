@@ -0,0 +1,263 @@
+package lint
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// at returns the severity, position and field of issues, to compare them without the messages.
+func at(issues []Issue) []string {
+	res := []string{}
+	for _, i := range issues {
+		res = append(res, Issue{Severity: i.Severity, Line: i.Line, Column: i.Column, Field: i.Field}.String())
+	}
+	return res
+}
+
+var _ = Describe("Lint", func() {
+	DescribeTable("accepts the sample custom entries with warnings only",
+		func(path, format string) {
+			content, err := os.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+
+			issues, err := Lint(content, format)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(HasErrors(issues)).To(BeFalse(), "%v", issues)
+			Expect(Validate(content, format)).To(Succeed())
+		},
+		Entry("csv", "../../samples/custom-entries/example-custom-entries.csv", types.FormatCSV),
+		Entry("json", "../../samples/custom-entries/example-custom-entries.json", types.FormatJSON),
+		Entry("yaml", "../../samples/custom-entries/example-custom-entries.yaml", types.FormatYAML),
+	)
+
+	It("reports the position of invalid CSV fields", func() {
+		content := []byte(`Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional
+Ingress,tcp,9050,ns,svc,pod,ctr,master,false
+Ingress,TCP,70000,ns,svc,pod,ctr,master,false
+Egres,TCP,0,ns,svc,pod,ctr,,maybe
+Ingress,TCP,9999-9000,,range,,,,false
+Ingress,UDP,x-10,,range,,,,false
+`)
+		issues, err := Lint(content, types.FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(at(issues)).To(Equal([]string{
+			"2:9: error: Protocol: ",
+			"3:13: error: Port: ",
+			"4:1: error: Direction: ",
+			"4:11: error: Port: ",
+			"4:28: error: NodeGroup: ",
+			"4:29: error: Optional: ",
+			"5:13: error: Port: ",
+			"6:13: error: Port: ",
+		}))
+		Expect(issues[0].Message).To(Equal(`"tcp" must be upper case, use "TCP"`))
+		Expect(issues[1].Message).To(Equal("port 70000 is out of range 1-65535"))
+		Expect(issues[6].Message).To(Equal("range start 9999 is greater than its end 9000"))
+	})
+
+	It("reports the position of invalid YAML fields", func() {
+		content := []byte(`Ports:
+  - direction: Ingress
+    protocol: TCP
+    port: "9050"
+    nodeGroup: master
+    colour: red
+  - direction: ingress
+    protocol: SCTP
+    port: 9051
+DynamicRanges:
+  - direction: Ingress
+    protocol: udp
+    minPort: 9000
+`)
+		issues, err := Lint(content, types.FormatYAML)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(at(issues)).To(Equal([]string{
+			"4:11: error: port: ",
+			"6:5: warning: colour: ",
+			"7:5: error: nodeGroup: ",
+			"7:16: warning: direction: ",
+			"11:5: error: maxPort: ",
+			"12:15: error: protocol: ",
+		}))
+		Expect(issues[1].Message).To(Equal(`unknown field "colour" is ignored`))
+		Expect(issues[3].Message).To(Equal(`"ingress" should be written "Ingress"`))
+	})
+
+	It("reports the position of invalid JSON fields", func() {
+		content := []byte(`{
+  "Ports": [
+    {"direction": "Ingress", "protocol": "TCP", "port": 0, "nodeGroup": "master"}
+  ]
+}
+`)
+		issues, err := Lint(content, types.FormatJSON)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(at(issues)).To(Equal([]string{"3:57: error: port: "}))
+	})
+
+	It("accepts egress entries and keeps flows to different peers apart", func() {
+		content := []byte(`Direction,Protocol,Port,NodeGroup,Peer
+Egress,TCP,6443,worker,master
+Egress,TCP,6443,worker,external
+Ingress,TCP,6443,worker,master
+Egress,TCP,6443,worker,master
+`)
+		issues, err := Lint(content, types.FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(at(issues)).To(Equal([]string{
+			"4:25: warning: Peer: ",
+			"5:1: warning: ",
+		}))
+		Expect(issues[0].Message).To(Equal("is only used by Egress entries"))
+		Expect(issues[1].Message).To(Equal("duplicates the entry at line 2"))
+	})
+
+	It("checks the source CIDRs", func() {
+		content := []byte(`Direction,Protocol,Port,NodeGroup,SourceCIDRs
+Ingress,TCP,2379,master,10.0.0.0/16 fd00::/48
+Ingress,TCP,2380,master,10.0.0.0/33
+Egress,TCP,6443,master,10.0.0.1
+Ingress,TCP,22,master,10.0.0.1
+`)
+		issues, err := Lint(content, types.FormatCSV, WithReferences(Reference{
+			Description: "static entry list GeneralStaticEntriesMaster",
+			Entry:       types.ComDetails{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "master"},
+		}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issues).To(Equal([]Issue{
+			errorf(3, 25, "SourceCIDRs", `"10.0.0.0/33" is not an address or a CIDR`),
+			warningf(4, 24, "SourceCIDRs", "is only used by Ingress entries"),
+		}))
+	})
+
+	It("checks the address families", func() {
+		content := []byte(`Direction,Protocol,Port,NodeGroup,AddressFamily
+Ingress,UDP,546,master,IPv6
+Ingress,UDP,68,master,ipv4
+Ingress,TCP,22,master,IPv5
+Ingress,TCP,2379,master,
+`)
+		issues, err := Lint(content, types.FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issues).To(Equal([]Issue{
+			errorf(3, 23, "AddressFamily", `"ipv4" must be written "IPv4"`),
+			errorf(4, 23, "AddressFamily", `unknown address family "IPv5", must be one of IPv4,IPv6 or empty for both`),
+		}))
+	})
+
+	It("checks the bound addresses", func() {
+		content := []byte(`Direction,Protocol,Port,NodeGroup,BindScope,BindAddresses
+Ingress,TCP,9100,master,NodeIP,10.0.0.1 fd00::1
+Ingress,TCP,9101,master,nodeip,10.0.0.0/24
+Ingress,TCP,9102,master,Wildcard,10.0.0.1
+Ingress,TCP,9103,master,Host,
+Egress,TCP,6443,master,,10.0.0.1
+`)
+		issues, err := Lint(content, types.FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issues).To(Equal([]Issue{
+			errorf(3, 25, "BindScope", `"nodeip" must be written "NodeIP"`),
+			errorf(3, 32, "BindAddresses", `"10.0.0.0/24" is not an address`),
+			warningf(4, 34, "BindAddresses", "is ignored for the sockets bound to the Wildcard"),
+			errorf(5, 25, "BindScope", `unknown bind scope "Host", must be one of Wildcard,VIP,NodeIP,LinkLocal`),
+			warningf(6, 25, "BindAddresses", "is only used by Ingress entries"),
+		}))
+	})
+
+	It("checks the interfaces", func() {
+		content := []byte(`Direction,Protocol,Port,NodeGroup,Interface
+Ingress,SCTP,38412,worker,net1
+Ingress,TCP,9100,worker,ens4 ens5
+Ingress,TCP,9101,worker,secondary-network
+Egress,TCP,6443,worker,br-ex
+`)
+		issues, err := Lint(content, types.FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issues).To(Equal([]Issue{
+			errorf(3, 25, "Interface", `interface name "ens4 ens5" contains invalid characters`),
+			errorf(4, 25, "Interface", `interface name "secondary-network" is longer than 15 characters`),
+			warningf(5, 24, "Interface", "is only used by Ingress entries"),
+		}))
+	})
+
+	It("reads the source CIDRs as a list", func() {
+		content := []byte(`Ports:
+- direction: Ingress
+  protocol: TCP
+  port: 2379
+  nodeGroup: master
+  sourceCIDRs: [10.0.0.0/16, fd00::/48]
+- direction: Ingress
+  protocol: TCP
+  port: 2380
+  nodeGroup: master
+  sourceCIDRs: 10.0.0.0/16
+DynamicRanges:
+- direction: Ingress
+  protocol: TCP
+  minPort: 30000
+  maxPort: 32767
+  sourceCIDRs: [bad]
+`)
+		issues, err := Lint(content, types.FormatYAML)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issues).To(Equal([]Issue{
+			errorf(11, 16, "sourceCIDRs", "expected a list of addresses and CIDRs"),
+			errorf(17, 16, "sourceCIDRs", `"bad" is not an address or a CIDR`),
+		}))
+	})
+
+	It("checks the node groups", func() {
+		content := []byte(`Direction,Protocol,Port,NodeGroup
+Ingress,TCP,9050,master
+Ingress,TCP,9051,workr
+`)
+		issues, err := Lint(content, types.FormatCSV, WithNodeGroups([]string{"master", "worker"}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issues).To(HaveLen(1))
+		Expect(issues[0].String()).To(Equal(`3:18: error: NodeGroup: unknown node group "workr", the cluster has master,worker`))
+	})
+
+	It("warns about duplicate entries", func() {
+		content := []byte(`Direction,Protocol,Port,NodeGroup
+Ingress,TCP,9050,master
+Ingress,TCP,22,master
+Ingress,TCP,9050,master
+Ingress,TCP,22,worker
+`)
+		issues, err := Lint(content, types.FormatCSV, WithReferences(Reference{
+			Description: "static entry list GeneralStaticEntriesMaster",
+			Entry:       types.ComDetails{Direction: "Ingress", Protocol: "TCP", Port: 22, NodeGroup: "master"},
+		}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issues).To(Equal([]Issue{
+			warningf(3, 1, "", "duplicates the master-22-TCP entry of static entry list GeneralStaticEntriesMaster"),
+			warningf(4, 1, "", "duplicates the entry at line 2"),
+		}))
+		Expect(HasErrors(issues)).To(BeFalse())
+	})
+
+	It("reports missing CSV columns", func() {
+		issues, err := Lint([]byte("Direction,Port,NodeGroup\nIngress,22,master\n"), types.FormatCSV)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(issues).To(Equal([]Issue{errorf(1, 1, "", `missing column "Protocol"`)}))
+	})
+
+	It("fails to parse malformed content", func() {
+		_, err := Lint([]byte("Ports: [\n"), types.FormatYAML)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns the errors from Validate", func() {
+		content := []byte("Direction,Protocol,Port,NodeGroup\nIngress,tcp,0,master\n")
+		err := Validate(content, types.FormatCSV)
+		Expect(err).To(MatchError(`invalid custom entries:
+2:9: error: Protocol: "tcp" must be upper case, use "TCP"
+2:13: error: Port: port 0 is out of range 1-65535`))
+	})
+})
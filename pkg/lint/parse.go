@@ -0,0 +1,232 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// value is a raw field value of an entry and its position in the file.
+type value struct {
+	// name is the field name as written in the file.
+	name   string
+	raw    string
+	tag    string
+	line   int
+	column int
+}
+
+// entry is a raw Ports or DynamicRanges entry.
+type entry struct {
+	line   int
+	column int
+	// isRange is true for dynamic range entries.
+	isRange bool
+	// fields are keyed by their lower case name.
+	fields map[string]value
+}
+
+// parse reads the raw entries of content without interpreting the field values.
+// Structural problems, like unknown fields, are returned as issues.
+func parse(content []byte, format string) ([]entry, []Issue, error) {
+	switch format {
+	case types.FormatCSV:
+		return parseCSV(content)
+	case types.FormatJSON, types.FormatYAML:
+		return parseYAML(content)
+	default:
+		return nil, nil, fmt.Errorf("invalid value for format must be (json,yaml,csv)")
+	}
+}
+
+// csvColumns are the columns of the CSV format, as written by ComMatrix.ToCSV.
+var csvColumns = []string{"Direction", "Protocol", "Port", "Namespace", "Service", "Pod", "Container", "NodeGroup", "Optional", "Peer", "SourceCIDRs", "AddressFamily", "BindScope", "BindAddresses", "Interface"}
+
+func parseCSV(content []byte) ([]entry, []Issue, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var issues []Issue
+	for i, h := range header {
+		if !containsFold(csvColumns, h) {
+			line, col := r.FieldPos(i)
+			issues = append(issues, warningf(line, col, h, "unknown column %q is ignored", h))
+		}
+	}
+	missingColumn := false
+	for _, c := range []string{"Direction", "Protocol", "Port", "NodeGroup"} {
+		if !containsFold(header, c) {
+			issues = append(issues, errorf(1, 1, "", "missing column %q", c))
+			missingColumn = true
+		}
+	}
+	// The rows cannot be checked without their columns.
+	if missingColumn {
+		return nil, issues, nil
+	}
+
+	var entries []entry
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		line, col := r.FieldPos(0)
+		e := entry{line: line, column: col, fields: map[string]value{}}
+		for i, raw := range record {
+			if i >= len(header) {
+				l, c := r.FieldPos(i)
+				issues = append(issues, errorf(l, c, "", "row has %d fields, the header has %d", len(record), len(header)))
+				break
+			}
+			l, c := r.FieldPos(i)
+			e.fields[strings.ToLower(header[i])] = value{name: header[i], raw: strings.TrimSpace(raw), line: l, column: c}
+		}
+
+		port := e.fields["port"].raw
+		if port == "" {
+			issues = append(issues, warningf(line, col, "", "row has no port and is ignored"))
+			continue
+		}
+		// Dynamic range rows have a "min-max" port and their description in the Service column.
+		if strings.Contains(port, "-") {
+			e.isRange = true
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, issues, nil
+}
+
+// Known fields of the Ports and DynamicRanges entries in the JSON and YAML formats.
+var (
+	portFields  = []string{"direction", "protocol", "port", "namespace", "service", "pod", "container", "nodegroup", "optional", "peer", "sourcecidrs", "addressfamily", "bindscope", "bindaddresses", "interface"}
+	rangeFields = []string{"direction", "protocol", "minport", "maxport", "description", "optional", "sourcecidrs"}
+)
+
+func parseYAML(content []byte) ([]entry, []Issue, error) {
+	doc := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal(content, doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return nil, []Issue{errorf(root.Line, root.Column, "", "expected a mapping with Ports and DynamicRanges lists")}, nil
+	}
+
+	var entries []entry
+	var issues []Issue
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+
+		// Keys are matched case-insensitively, as encoding/json does when parsing the matrix.
+		var known []string
+		isRange := false
+		switch strings.ToLower(key.Value) {
+		case "ports":
+			known = portFields
+		case "dynamicranges":
+			known, isRange = rangeFields, true
+		default:
+			issues = append(issues, warningf(key.Line, key.Column, key.Value, "unknown field %q is ignored", key.Value))
+			continue
+		}
+
+		if val.Kind != yamlv3.SequenceNode {
+			if val.Tag != "!!null" {
+				issues = append(issues, errorf(val.Line, val.Column, key.Value, "expected a list"))
+			}
+			continue
+		}
+
+		for _, item := range val.Content {
+			if item.Kind != yamlv3.MappingNode {
+				issues = append(issues, errorf(item.Line, item.Column, key.Value, "expected a mapping"))
+				continue
+			}
+
+			e := entry{line: item.Line, column: item.Column, isRange: isRange, fields: map[string]value{}}
+			for j := 0; j+1 < len(item.Content); j += 2 {
+				k, v := item.Content[j], item.Content[j+1]
+				name := strings.ToLower(k.Value)
+				if !containsFold(known, name) {
+					issues = append(issues, warningf(k.Line, k.Column, k.Value, "unknown field %q is ignored", k.Value))
+					continue
+				}
+				// The sources and bound addresses are lists, kept as a space separated value like
+				// in the CSV format.
+				if name == "sourcecidrs" || name == "bindaddresses" {
+					if sources, ok := parseSourcesNode(v); ok {
+						e.fields[name] = value{name: k.Value, raw: sources, line: v.Line, column: v.Column}
+					} else if name == "sourcecidrs" {
+						issues = append(issues, errorf(v.Line, v.Column, k.Value, "expected a list of addresses and CIDRs"))
+					} else {
+						issues = append(issues, errorf(v.Line, v.Column, k.Value, "expected a list of addresses"))
+					}
+					continue
+				}
+				if v.Kind != yamlv3.ScalarNode {
+					issues = append(issues, errorf(v.Line, v.Column, k.Value, "expected a scalar value"))
+					continue
+				}
+				e.fields[name] = value{name: k.Value, raw: v.Value, tag: v.Tag, line: v.Line, column: v.Column}
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, issues, nil
+}
+
+// parseSourcesNode returns the items of a list of scalars separated by spaces.
+// A null value is an empty list.
+func parseSourcesNode(node *yamlv3.Node) (string, bool) {
+	if node.Kind == yamlv3.ScalarNode && node.Tag == "!!null" {
+		return "", true
+	}
+	if node.Kind != yamlv3.SequenceNode {
+		return "", false
+	}
+
+	items := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		if item.Kind != yamlv3.ScalarNode {
+			return "", false
+		}
+		items = append(items, item.Value)
+	}
+
+	return strings.Join(items, " "), true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package listeningsockets
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift-kni/commatrix/pkg/consts"
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// Commands listing the established TCP connections and the connected UDP sockets of the host.
+var (
+	establishedTCPCommand = []string{"/bin/sh", "-c", "ss -tanpH state established"}
+	establishedUDPCommand = []string{"/bin/sh", "-c", "ss -uanpH state established"}
+)
+
+// createEgressFromNode returns the egress flows of the connections established by the node.
+// Connections whose local port is one of the node's listeners were accepted by the node and
+// are left out, as are connections to loopback addresses.
+func (cc *ConnectionCheck) createEgressFromNode(debugPod *corev1.Pod, group string, listeners []Listener) ([]types.ComDetails, error) {
+	listening := map[string]bool{}
+	for _, l := range listeners {
+		listening[fmt.Sprintf("%s/%d", l.Entry.Protocol, l.Entry.Port)] = true
+	}
+	loopbackIPs := loopbackAddresses(cc.getHostAddresses(debugPod))
+
+	res := []types.ComDetails{}
+	seen := map[string]bool{}
+	for _, check := range []struct {
+		protocol string
+		command  []string
+	}{{"TCP", establishedTCPCommand}, {"UDP", establishedUDPCommand}} {
+		protocol := check.protocol
+		out, err := cc.podUtils.RunCommandOnPod(debugPod, check.command)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ssEntry := range splitByLines(out) {
+			conn, ok := parseConnection(ssEntry)
+			if !ok {
+				continue
+			}
+			if listening[fmt.Sprintf("%s/%d", protocol, conn.localPort)] || isLoopbackIP(conn.peerIP, loopbackIPs) {
+				continue
+			}
+
+			cd := types.ComDetails{
+				Direction: consts.EgressLabel,
+				Protocol:  protocol,
+				Port:      conn.peerPort,
+				NodeGroup: group,
+				Peer:      cc.resolvePeer(conn.peerIP),
+			}
+			// Identify the process of the first connection of every flow only,
+			// nodes usually hold many connections to the same peers.
+			if seen[cd.Key()] {
+				continue
+			}
+			seen[cd.Key()] = true
+
+			cd.Service, err = extractServiceName(ssEntry)
+			if err != nil {
+				log.Debug(err.Error())
+			}
+			containerInfo, err := cc.getContainerInfo(debugPod, ssEntry)
+			if err != nil {
+				log.Debugf("failed to identify container for ss entry: %serr: %s", ssEntry, err)
+			} else {
+				cd.Container = containerInfo.Containers[0].Labels.ContainerName
+				cd.Namespace = containerInfo.Containers[0].Labels.PodNamespace
+				cd.Pod = containerInfo.Containers[0].Labels.PodName
+			}
+			res = append(res, cd)
+		}
+	}
+
+	return res, nil
+}
+
+// resolvePeer returns the node group of the node owning ip, or "external".
+func (cc *ConnectionCheck) resolvePeer(ip net.IP) string {
+	if group, ok := cc.ipToGroup[ip.String()]; ok {
+		return group
+	}
+
+	return consts.ExternalPeer
+}
+
+// connection is an established connection parsed from an ss entry.
+type connection struct {
+	localPort int
+	peerIP    net.IP
+	peerPort  int
+}
+
+// parseConnection parses an entry of `ss -tanpH state established`. ss leaves out the
+// State column when filtering on a single state, both layouts are accepted.
+func parseConnection(ssEntry string) (connection, bool) {
+	fields := strings.Fields(ssEntry)
+	if len(fields) > 0 {
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			fields = fields[1:]
+		}
+	}
+	// Recv-Q Send-Q Local:Port Peer:Port [Process]
+	if len(fields) < 4 {
+		return connection{}, false
+	}
+
+	_, localPort, err := splitAddrPort(fields[2])
+	if err != nil {
+		log.Debugf("failed to parse local address of ss entry %q: %v", ssEntry, err)
+		return connection{}, false
+	}
+	peer, peerPort, err := splitAddrPort(fields[3])
+	if err != nil {
+		log.Debugf("failed to parse peer address of ss entry %q: %v", ssEntry, err)
+		return connection{}, false
+	}
+	peerIP := net.ParseIP(peer)
+	if peerIP == nil {
+		log.Debugf("failed to parse peer address of ss entry %q", ssEntry)
+		return connection{}, false
+	}
+
+	return connection{localPort: localPort, peerIP: peerIP, peerPort: peerPort}, true
+}
+
+// splitAddrPort splits ss addresses such as "10.0.0.1:6443", "[fd00::1]:2380" or
+// "[fe80::1%br-ex]:22" into the address, without its zone, and the port.
+func splitAddrPort(addrPort string) (string, int, error) {
+	idx := strings.LastIndex(addrPort, ":")
+	if idx == -1 {
+		return "", 0, fmt.Errorf("no port in address %q", addrPort)
+	}
+	port, err := strconv.Atoi(addrPort[idx+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in address %q: %w", addrPort, err)
+	}
+
+	addr := strings.Trim(addrPort[:idx], "[]")
+	if zone := strings.Index(addr, "%"); zone != -1 {
+		addr = addr[:zone]
+	}
+
+	return addr, port, nil
+}
+
+func isLoopbackIP(ip net.IP, loopbackIPs map[string]bool) bool {
+	return ip.IsLoopback() || loopbackIPs[ip.String()]
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,43 +25,78 @@ import (
 
 const (
 	localAddrPortFieldIdx = 3
+	loopbackInterface     = "lo"
 )
 
 type ConnectionCheck struct {
 	*client.ClientSet
 	podUtils    utils.UtilsInterface
 	nodeToGroup map[string]string
+	// nodeAddresses holds the internal and external addresses of every node, used to
+	// classify the addresses the listening sockets are bound to.
+	nodeAddresses map[string]map[string]bool
+	// ipToGroup maps the addresses of the nodes to their group, used to resolve egress peers.
+	ipToGroup map[string]string
+	egress    bool
 }
 
-func NewCheck(c *client.ClientSet, podUtils utils.UtilsInterface, customNodeGroups map[string]labels.Selector) (*ConnectionCheck, error) {
+type Option func(*ConnectionCheck)
+
+// WithEgress also collects the egress flows of the nodes from their established connections.
+func WithEgress() Option {
+	return func(cc *ConnectionCheck) {
+		cc.egress = true
+	}
+}
+
+func NewCheck(c *client.ClientSet, podUtils utils.UtilsInterface, customNodeGroups map[string]labels.Selector, opts ...Option) (*ConnectionCheck, error) {
 	nodes, err := podUtils.ListNodes()
 	if err != nil {
 		return nil, err
 	}
 
-	nodeToGroup, err := mcp.ResolveNodeToPool(nodes)
+	nodeToGroup, err := mcp.ResolveNodeToGroup(nodes, customNodeGroups)
 	if err != nil {
-		// Fallback: build node->group map (HyperShift or clusters without MCP): prefer NodePool label, else role
-		if nodeToGroup, err = types.BuildNodeToGroupMap(nodes); err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 
-	if err := types.ApplyCustomNodeGroupOverrides(nodeToGroup, customNodeGroups, nodes); err != nil {
-		return nil, err
+	cc := &ConnectionCheck{
+		ClientSet:     c,
+		podUtils:      podUtils,
+		nodeToGroup:   nodeToGroup,
+		nodeAddresses: buildNodeAddresses(nodes),
+		ipToGroup:     types.BuildAddressToGroupMap(nodes, nodeToGroup),
+	}
+	for _, o := range opts {
+		o(cc)
 	}
 
-	return &ConnectionCheck{
-		ClientSet:   c,
-		podUtils:    podUtils,
-		nodeToGroup: nodeToGroup,
-	}, nil
+	return cc, nil
+}
+
+// buildNodeAddresses returns the internal and external addresses of every node.
+func buildNodeAddresses(nodes []corev1.Node) map[string]map[string]bool {
+	res := map[string]map[string]bool{}
+	for _, node := range nodes {
+		res[node.Name] = map[string]bool{}
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != corev1.NodeInternalIP && addr.Type != corev1.NodeExternalIP {
+				continue
+			}
+			if ip := net.ParseIP(addr.Address); ip != nil {
+				res[node.Name][ip.String()] = true
+			}
+		}
+	}
+	return res
 }
 
-// GenerateSS generates the SS flows and stores then in SSOutTCP, SSOutUDP and SSCommMatrix.
+// GenerateSS generates the SS flows and stores then in SSOutTCP, SSOutUDP, SSOutSCTP and SSCommMatrix.
 func (cc *ConnectionCheck) GenerateSS(namespace string) (*SSResult, error) {
-	var ssOutTCP, ssOutUDP []byte
+	var ssOutTCP, ssOutUDP, ssOutSCTP []byte
 	nodesComDetails := []types.ComDetails{}
+	egressComDetails := []types.ComDetails{}
+	listeners := []Listener{}
 
 	nLock := &sync.Mutex{}
 	g := new(errgroup.Group)
@@ -85,18 +121,33 @@ func (cc *ConnectionCheck) GenerateSS(namespace string) (*SSResult, error) {
 			}()
 
 			group := cc.nodeToGroup[name]
-			cds, ssTCP, ssUDP, err := cc.createSSOutputFromNode(debugPod, group)
+			nodeListeners, ssTCP, ssUDP, ssSCTP, err := cc.createSSOutputFromNode(debugPod, group, cc.nodeAddresses[name])
 			if err != nil {
 				return err
 			}
+
+			var nodeEgress []types.ComDetails
+			if cc.egress {
+				if nodeEgress, err = cc.createEgressFromNode(debugPod, group, nodeListeners); err != nil {
+					return err
+				}
+			}
+
 			nLock.Lock()
 			defer nLock.Unlock()
 			ssTCPLine := fmt.Sprintf("node: %s\n%s\n", name, string(ssTCP))
 			ssUDPLine := fmt.Sprintf("node: %s\n%s\n", name, string(ssUDP))
+			ssSCTPLine := fmt.Sprintf("node: %s\n%s\n", name, string(ssSCTP))
 
-			nodesComDetails = append(nodesComDetails, cds...)
+			for i := range nodeListeners {
+				nodeListeners[i].Node = name
+				nodesComDetails = append(nodesComDetails, nodeListeners[i].Entry)
+			}
+			listeners = append(listeners, nodeListeners...)
+			egressComDetails = append(egressComDetails, nodeEgress...)
 			ssOutTCP = append(ssOutTCP, []byte(ssTCPLine)...)
 			ssOutUDP = append(ssOutUDP, []byte(ssUDPLine)...)
+			ssOutSCTP = append(ssOutSCTP, []byte(ssSCTPLine)...)
 			return nil
 		})
 	}
@@ -105,38 +156,79 @@ func (cc *ConnectionCheck) GenerateSS(namespace string) (*SSResult, error) {
 		return nil, err
 	}
 
-	ssComMat := types.ComMatrix{Ports: nodesComDetails}
+	ssComMat := types.ComMatrix{Ports: reconcileInterfaces(nodesComDetails)}
 	ssComMat.SortAndRemoveDuplicates()
 
-	return &SSResult{
-		ssOutTCP,
-		ssOutUDP,
-		&ssComMat,
-	}, nil
+	sort.Slice(listeners, func(i, j int) bool {
+		if listeners[i].Node != listeners[j].Node {
+			return listeners[i].Node < listeners[j].Node
+		}
+		return listeners[i].Entry.String() < listeners[j].Entry.String()
+	})
+
+	res := &SSResult{
+		rawTCP:       ssOutTCP,
+		rawUDP:       ssOutUDP,
+		rawSCTP:      ssOutSCTP,
+		SSCommMatrix: &ssComMat,
+		Listeners:    listeners,
+	}
+	if cc.egress {
+		res.EgressMatrix = &types.ComMatrix{Ports: egressComDetails}
+		res.EgressMatrix.SortAndRemoveDuplicates()
+	}
+
+	return res, nil
+}
+
+// reconcileInterfaces clears the interface of the entries whose sockets are not bound to the
+// same interface on every node of their group, as the interfaces of the nodes are not named
+// alike, or on every address of a node.
+func reconcileInterfaces(entries []types.ComDetails) []types.ComDetails {
+	interfaces := map[string]string{}
+	for _, cd := range entries {
+		key := cd.Key()
+		if iface, ok := interfaces[key]; ok && iface != cd.Interface {
+			cd.Interface = ""
+		}
+		interfaces[key] = cd.Interface
+	}
+	for i := range entries {
+		entries[i].Interface = interfaces[entries[i].Key()]
+	}
+	return entries
 }
 
-func (cc *ConnectionCheck) createSSOutputFromNode(debugPod *corev1.Pod, group string) ([]types.ComDetails, []byte, []byte, error) {
+func (cc *ConnectionCheck) createSSOutputFromNode(debugPod *corev1.Pod, group string, nodeAddresses map[string]bool) ([]Listener, []byte, []byte, []byte, error) {
 	ssOutTCP, err := cc.podUtils.RunCommandOnPod(debugPod, []string{"/bin/sh", "-c", "ss -anpltH"})
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	ssOutUDP, err := cc.podUtils.RunCommandOnPod(debugPod, []string{"/bin/sh", "-c", "ss -anpluH"})
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+	ssOutSCTP, err := cc.podUtils.RunCommandOnPod(debugPod, []string{"/bin/sh", "-c", "ss -anplSH"})
+	if err != nil {
+		return nil, nil, nil, nil, err
 	}
 
-	loopbackIPs := cc.getLoopbackIPs(debugPod)
+	hostAddresses := cc.getHostAddresses(debugPod)
+	loopbackIPs := loopbackAddresses(hostAddresses)
 	ssOutFilteredTCP := filterEntries(splitByLines(ssOutTCP), loopbackIPs)
 	ssOutFilteredUDP := filterEntries(splitByLines(ssOutUDP), loopbackIPs)
+	ssOutFilteredSCTP := filterEntries(splitByLines(ssOutSCTP), loopbackIPs)
 
-	tcpComDetails := cc.toComDetails(debugPod, ssOutFilteredTCP, "TCP", group)
-	udpComDetails := cc.toComDetails(debugPod, ssOutFilteredUDP, "UDP", group)
+	tcpListeners := cc.toListeners(debugPod, ssOutFilteredTCP, "TCP", group, nodeAddresses, hostAddresses)
+	udpListeners := cc.toListeners(debugPod, ssOutFilteredUDP, "UDP", group, nodeAddresses, hostAddresses)
+	sctpListeners := cc.toListeners(debugPod, ssOutFilteredSCTP, "SCTP", group, nodeAddresses, hostAddresses)
 
-	res := []types.ComDetails{}
-	res = append(res, udpComDetails...)
-	res = append(res, tcpComDetails...)
+	res := []Listener{}
+	res = append(res, udpListeners...)
+	res = append(res, tcpListeners...)
+	res = append(res, sctpListeners...)
 
-	return res, ssOutTCP, ssOutUDP, nil
+	return res, ssOutTCP, ssOutUDP, ssOutSCTP, nil
 }
 
 func splitByLines(bytes []byte) []string {
@@ -144,11 +236,15 @@ func splitByLines(bytes []byte) []string {
 	return strings.Split(str, "\n")
 }
 
-func (cc *ConnectionCheck) toComDetails(debugPod *corev1.Pod, ssOutput []string, protocol string, pool string) []types.ComDetails {
-	res := make([]types.ComDetails, 0)
+func (cc *ConnectionCheck) toListeners(debugPod *corev1.Pod, ssOutput []string, protocol string, pool string,
+	nodeAddresses map[string]bool, hostAddresses map[string]string) []Listener {
+	res := make([]Listener, 0)
 
 	for _, ssEntry := range ssOutput {
 		cd := parseComDetail(ssEntry)
+		if cd == nil {
+			continue
+		}
 
 		containerName, nameSpace, podName := "", "", ""
 		containerInfo, err := cc.getContainerInfo(debugPod, ssEntry)
@@ -166,7 +262,24 @@ func (cc *ConnectionCheck) toComDetails(debugPod *corev1.Pod, ssOutput []string,
 		cd.Protocol = protocol
 		cd.NodeGroup = pool
 		cd.Optional = false
-		res = append(res, *cd)
+
+		localAddress := extractLocalAddress(ssEntry)
+		var addr string
+		if cd.BindScope, addr = bindAddress(localAddress, nodeAddresses); addr != "" {
+			cd.BindAddresses = types.CIDRList{addr}
+		}
+		cd.Interface = bindInterface(localAddress, hostAddresses)
+
+		pid, err := extractPID(ssEntry)
+		if err != nil {
+			log.Debug(err.Error())
+		}
+		res = append(res, Listener{
+			Entry:        *cd,
+			LocalAddress: localAddress,
+			Process:      cd.Service,
+			PID:          pid,
+		})
 	}
 	return res
 }
@@ -230,13 +343,27 @@ func (cc *ConnectionCheck) extractContainerInfo(debugPod *corev1.Pod, containerI
 	return containerInfo, nil
 }
 
+// Listener is a listening socket found on a node and the matrix entry created for it.
+type Listener struct {
+	Node         string
+	Entry        types.ComDetails
+	LocalAddress string
+	Process      string
+	PID          string
+}
+
 type SSResult struct {
 	rawTCP       []byte
 	rawUDP       []byte
+	rawSCTP      []byte
 	SSCommMatrix *types.ComMatrix
+	// Listeners holds every listening socket that produced an entry, sorted by node.
+	Listeners []Listener
+	// EgressMatrix holds the egress flows of the nodes. Only set when the check was created WithEgress.
+	EgressMatrix *types.ComMatrix
 }
 
-// WriteSSRawFiles writes the SSOutTCP and SSOutUDP to files.
+// WriteSSRawFiles writes the SSOutTCP, SSOutUDP and SSOutSCTP to files.
 func (ssr *SSResult) WriteSSRawFiles(podUtils utils.UtilsInterface, destDir string) error {
 	err := podUtils.WriteFile(path.Join(destDir, consts.SSRawTCP), ssr.rawTCP)
 	if err != nil {
@@ -248,6 +375,11 @@ func (ssr *SSResult) WriteSSRawFiles(podUtils utils.UtilsInterface, destDir stri
 		return fmt.Errorf("failed writing to file: %w", err)
 	}
 
+	err = podUtils.WriteFile(path.Join(destDir, consts.SSRawSCTP), ssr.rawSCTP)
+	if err != nil {
+		return fmt.Errorf("failed writing to file: %w", err)
+	}
+
 	return nil
 }
 
@@ -271,6 +403,10 @@ func filterEntries(ssEntries []string, loopbackIPs map[string]bool) []string {
 		if s == "" {
 			continue
 		}
+		// SCTP sockets bound to several addresses list the other addresses on "`- ADDR:PORT" lines.
+		if strings.HasPrefix(strings.TrimSpace(s), "`-") {
+			continue
+		}
 		if isLoopbackEntry(s, loopbackIPs) {
 			continue
 		}
@@ -331,29 +467,43 @@ func isLoopbackEntry(ssEntry string, loopbackIPs map[string]bool) bool {
 	return false
 }
 
-func (cc *ConnectionCheck) getLoopbackIPs(debugPod *corev1.Pod) map[string]bool {
+// getHostAddresses returns the interface of every address of the host, including the VIPs
+// it holds and the aliases of the loopback interface.
+func (cc *ConnectionCheck) getHostAddresses(debugPod *corev1.Pod) map[string]string {
 	type addr struct {
 		Local string `json:"local"`
 	}
 	type iface struct {
+		IfName   string `json:"ifname"`
 		AddrInfo []addr `json:"addr_info"`
 	}
-	out, err := cc.podUtils.RunCommandOnPod(debugPod, []string{"chroot", "/host", "/bin/sh", "-c", "ip -j addr show lo"})
+	out, err := cc.podUtils.RunCommandOnPod(debugPod, []string{"chroot", "/host", "/bin/sh", "-c", "ip -j addr show"})
 	if err != nil {
-		return map[string]bool{}
+		return map[string]string{}
 	}
 	var parsed []iface
 	if json.Unmarshal(out, &parsed) != nil {
-		return map[string]bool{}
+		return map[string]string{}
 	}
-	ips := make(map[string]bool)
+	addresses := make(map[string]string)
 	for _, it := range parsed {
 		for _, ai := range it.AddrInfo {
-			if ai.Local != "" {
-				ips[ai.Local] = true
+			if ip := net.ParseIP(ai.Local); ip != nil {
+				addresses[ip.String()] = it.IfName
 			}
 		}
 	}
+	return addresses
+}
+
+// loopbackAddresses returns the addresses of the loopback interface among the host addresses.
+func loopbackAddresses(hostAddresses map[string]string) map[string]bool {
+	ips := make(map[string]bool)
+	for ip, iface := range hostAddresses {
+		if iface == loopbackInterface {
+			ips[ip] = true
+		}
+	}
 	return ips
 }
 
@@ -374,10 +524,75 @@ func parseComDetail(ssEntry string) *types.ComDetails {
 	}
 
 	return &types.ComDetails{
-		Direction: consts.IngressLabel,
-		Port:      port,
-		Service:   serviceName,
-		Optional:  false}
+		Direction:     consts.IngressLabel,
+		Port:          port,
+		Service:       serviceName,
+		Optional:      false,
+		AddressFamily: bindAddressFamily(extractLocalAddress(ssEntry))}
+}
+
+// bindAddressFamily returns the address family of the sockets bound to a local address of
+// ss. ss prints "*" for the IPv6 wildcard sockets accepting IPv4 too, which use both families.
+func bindAddressFamily(localAddress string) string {
+	ip := parseBindAddress(localAddress)
+	if ip == nil {
+		return ""
+	}
+	return types.AddressFamilyOf(ip)
+}
+
+// bindAddress returns the scope of a local address of ss relative to the addresses of the
+// node, and the address itself unless the socket is bound to the wildcard.
+func bindAddress(localAddress string, nodeAddresses map[string]bool) (string, string) {
+	if strings.HasPrefix(localAddress, "*") {
+		return types.BindScopeWildcard, ""
+	}
+	ip := parseBindAddress(localAddress)
+	if ip == nil {
+		return "", ""
+	}
+	scope := types.ClassifyBindAddress(ip, nodeAddresses)
+	if scope == types.BindScopeWildcard {
+		return scope, ""
+	}
+	return scope, ip.String()
+}
+
+// bindInterface returns the interface of the sockets bound to a local address of ss: the
+// interface the socket is bound to, or the interface holding its address. It is empty for
+// the sockets bound to the wildcard, which accept the traffic of every interface.
+func bindInterface(localAddress string, hostAddresses map[string]string) string {
+	if _, iface, ok := strings.Cut(localAddress, "%"); ok {
+		return iface
+	}
+	ip := parseBindAddress(localAddress)
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	return hostAddresses[ip.String()]
+}
+
+// parseBindAddress parses a local address of ss. Sockets bound to an interface are printed
+// as address%interface, the interface is left out.
+func parseBindAddress(localAddress string) net.IP {
+	addr, _, _ := strings.Cut(localAddress, "%")
+	return net.ParseIP(strings.Trim(addr, "[]"))
+}
+
+// extractLocalAddress returns the local address of an ss entry, without the port.
+func extractLocalAddress(ssEntry string) string {
+	fields := strings.Fields(ssEntry)
+	if len(fields) <= localAddrPortFieldIdx {
+		return ""
+	}
+
+	localAddrPort := fields[localAddrPortFieldIdx]
+	portIdx := strings.LastIndex(localAddrPort, ":")
+	if portIdx == -1 {
+		return localAddrPort
+	}
+
+	return localAddrPort[:portIdx]
 }
 
 func extractServiceName(ssEntry string) (string, error) {
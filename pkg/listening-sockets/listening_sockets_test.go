@@ -30,6 +30,13 @@ const (
 	UNCONN 0      0         127.0.0.1:323   0.0.0.0:* users:(("chronyd",pid=1015,fd=5))                        
 	UNCONN 0      0      10.46.97.104:500   0.0.0.0:* users:(("pluto",pid=2115,fd=21))`)
 
+	sctpExecCommandOutput = (`LISTEN 0      128    10.46.97.104:38412 0.0.0.0:* users:(("amf",pid=2116,fd=7))
+	   ` + "`-" + ` 10.46.98.104:38412
+	LISTEN 0      128       127.0.0.1:38413 0.0.0.0:* users:(("amf",pid=2116,fd=8))`)
+
+	hostAddresses = `[{"ifname":"lo","addr_info":[{"local":"127.0.0.1"},{"local":"::1"}]},
+	{"ifname":"br-ex","addr_info":[{"local":"10.46.97.104"}]}]`
+
 	procExecCommandOutput = (`1: /system.slice/crio-123abcd.scope
 	2: /system.slice/other-service.scope
 	
@@ -56,6 +63,11 @@ const (
 	UNCONN 0      0           0.0.0.0:111   0.0.0.0:* users:(("rpcbind",pid=1399,fd=5),("systemd",pid=1,fd=78))
 	UNCONN 0      0         127.0.0.1:323   0.0.0.0:* users:(("chronyd",pid=1015,fd=5))                        
 	UNCONN 0      0      10.46.97.104:500   0.0.0.0:* users:(("pluto",pid=2115,fd=21))`
+
+	expectedSCTPOutput = `node: test-node
+	LISTEN 0      128    10.46.97.104:38412 0.0.0.0:* users:(("amf",pid=2116,fd=7))
+	   ` + "`-" + ` 10.46.98.104:38412
+	LISTEN 0      128       127.0.0.1:38413 0.0.0.0:* users:(("amf",pid=2116,fd=8))`
 )
 
 var (
@@ -72,30 +84,51 @@ var (
 
 	expectedSSMat = []types.ComDetails{
 		{
-			Direction: "Ingress",
-			Protocol:  "UDP",
-			Port:      111,
-			NodeGroup: "master",
-			Service:   "rpcbind",
-			Namespace: "test-namespace",
-			Pod:       "test-pod",
-			Container: "test-container",
-			Optional:  false,
+			Direction:     "Ingress",
+			Protocol:      "SCTP",
+			Port:          38412,
+			NodeGroup:     "master",
+			Service:       "amf",
+			Namespace:     "test-namespace",
+			Pod:           "test-pod",
+			Container:     "test-container",
+			Optional:      false,
+			AddressFamily: types.AddressFamilyIPv4,
+			BindScope:     types.BindScopeNodeIP,
+			BindAddresses: types.CIDRList{"10.46.97.104"},
+			Interface:     "br-ex",
+		},
+		{
+			Direction:     "Ingress",
+			Protocol:      "UDP",
+			Port:          111,
+			NodeGroup:     "master",
+			Service:       "rpcbind",
+			Namespace:     "test-namespace",
+			Pod:           "test-pod",
+			Container:     "test-container",
+			Optional:      false,
+			AddressFamily: types.AddressFamilyIPv4,
+			BindScope:     types.BindScopeWildcard,
 		},
 		{
-			Direction: "Ingress",
-			Protocol:  "UDP",
-			Port:      500,
-			NodeGroup: "master",
-			Service:   "pluto",
-			Namespace: "test-namespace",
-			Pod:       "test-pod",
-			Container: "test-container",
-			Optional:  false,
+			Direction:     "Ingress",
+			Protocol:      "UDP",
+			Port:          500,
+			NodeGroup:     "master",
+			Service:       "pluto",
+			Namespace:     "test-namespace",
+			Pod:           "test-pod",
+			Container:     "test-container",
+			Optional:      false,
+			AddressFamily: types.AddressFamilyIPv4,
+			BindScope:     types.BindScopeNodeIP,
+			BindAddresses: types.CIDRList{"10.46.97.104"},
+			Interface:     "br-ex",
 		},
 	}
 
-	pids = []string{"1399", "2115"}
+	pids = []string{"1399", "2115", "2116"}
 )
 
 var _ = Describe("GenerateSS", func() {
@@ -118,6 +151,9 @@ var _ = Describe("GenerateSS", func() {
 					"node-role.kubernetes.io/master": "",
 				},
 			},
+			Status: v1.NodeStatus{
+				Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.46.97.104"}},
+			},
 		}
 
 		mcpMaster := &machineconfigurationv1.MachineConfigPool{
@@ -144,7 +180,7 @@ var _ = Describe("GenerateSS", func() {
 		ctrlTest.Finish()
 	})
 
-	It("should generate the correct ss tcp, udp output and the correct ssMatrix", func() {
+	It("should generate the correct ss tcp, udp, sctp output and the correct ssMatrix", func() {
 		// RunCommandOnPod had more than one calling and in each call we want other output
 		// Mock expectation for TCP socket check
 		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(),
@@ -156,6 +192,11 @@ var _ = Describe("GenerateSS", func() {
 			[]string{"/bin/sh", "-c", "ss -anpluH"}).
 			Return([]byte(udpExecCommandOutput), nil).AnyTimes()
 
+		// Mock expectation for SCTP socket check
+		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(),
+			[]string{"/bin/sh", "-c", "ss -anplSH"}).
+			Return([]byte(sctpExecCommandOutput), nil).AnyTimes()
+
 		// Mock expectation for /proc/{pid}/cgroup command
 		for _, pid := range pids {
 			command := []string{"/bin/sh", "-c", fmt.Sprintf("cat /proc/%s/cgroup", pid)}
@@ -170,10 +211,10 @@ var _ = Describe("GenerateSS", func() {
 			Return([]byte(crictlExecCommandOut), nil).
 			AnyTimes()
 
-		// Mock expectation for loopback IP discovery on host
+		// Mock expectation for host addresses discovery
 		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(),
-			[]string{"chroot", "/host", "/bin/sh", "-c", "ip -j addr show lo"}).
-			Return([]byte(`[{"addr_info":[{"local":"127.0.0.1"},{"local":"::1"}]}]`), nil).
+			[]string{"chroot", "/host", "/bin/sh", "-c", "ip -j addr show"}).
+			Return([]byte(hostAddresses), nil).
 			AnyTimes()
 
 		mockUtils.EXPECT().
@@ -193,7 +234,127 @@ var _ = Describe("GenerateSS", func() {
 
 		Expect(normalizeOutput(string(ssResult.rawTCP))).To(Equal(normalizeOutput(expectedTCPOutput)))
 		Expect(normalizeOutput(string(ssResult.rawUDP))).To(Equal(normalizeOutput(expectedUDPOutput)))
+		Expect(normalizeOutput(string(ssResult.rawSCTP))).To(Equal(normalizeOutput(expectedSCTPOutput)))
 		Expect(ssResult.SSCommMatrix.Ports).To(Equal(expectedSSMat))
+
+		Expect(ssResult.Listeners).To(HaveLen(len(expectedSSMat)))
+		for i, l := range ssResult.Listeners {
+			Expect(l.Node).To(Equal("test-node"))
+			Expect(l.Entry).To(Equal(expectedSSMat[i]))
+			Expect(l.Process).To(Equal(expectedSSMat[i].Service))
+		}
+		Expect(ssResult.Listeners[0].LocalAddress).To(Equal("10.46.97.104"))
+		Expect(ssResult.Listeners[0].PID).To(Equal("2116"))
+		Expect(ssResult.Listeners[1].LocalAddress).To(Equal("0.0.0.0"))
+		Expect(ssResult.Listeners[1].PID).To(Equal("1399"))
+		Expect(ssResult.Listeners[2].LocalAddress).To(Equal("10.46.97.104"))
+		Expect(ssResult.Listeners[2].PID).To(Equal("2115"))
+	})
+})
+
+var _ = Describe("GenerateSS with egress", func() {
+	const (
+		listenTCP = `LISTEN 0 4096 0.0.0.0:9100 0.0.0.0:* users:(("node_exporter",pid=4147,fd=3))`
+		// The first connection was accepted by node_exporter, the others were opened by the node.
+		establishedTCP = `0 0 10.0.0.1:9100 10.0.0.2:51234 users:(("node_exporter",pid=4147,fd=7))
+0 0 10.0.0.1:40210 10.0.0.2:6443 users:(("kubelet",pid=2000,fd=20))
+0 0 10.0.0.1:40212 10.0.0.2:6443 users:(("kubelet",pid=2000,fd=21))
+0 0 [::ffff:10.0.0.1]:50110 [::ffff:203.0.113.7]:443 users:(("crio",pid=1500,fd=9))
+0 0 127.0.0.1:40300 127.0.0.1:9100 users:(("curl",pid=3000,fd=3))`
+		establishedUDP = `ESTAB 0 0 10.0.0.1:41000 10.0.0.2:6081 users:(("ovs-vswitchd",pid=1200,fd=30))`
+	)
+
+	BeforeEach(func() {
+		sch := runtime.NewScheme()
+		Expect(v1.AddToScheme(sch)).To(Succeed())
+
+		nodes := []v1.Node{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Labels: map[string]string{"node-role.kubernetes.io/worker": ""}},
+				Status:     v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}}},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "master-0", Labels: map[string]string{"node-role.kubernetes.io/master": ""}},
+				Status:     v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}}},
+			},
+		}
+		clientset = &client.ClientSet{Client: fake.NewClientBuilder().WithScheme(sch).Build()}
+
+		ctrlTest = gomock.NewController(GinkgoT())
+		mockUtils = mock_utils.NewMockUtilsInterface(ctrlTest)
+		mockUtils.EXPECT().ListNodes().Return(nodes, nil).AnyTimes()
+		mockUtils.EXPECT().CreatePodOnNode(gomock.Any(), consts.DefaultDebugNamespace, consts.DefaultDebugPodImage, []string{}).
+			Return(mockPod, nil).AnyTimes()
+		mockUtils.EXPECT().WaitForPodStatus(consts.DefaultDebugNamespace, mockPod, v1.PodRunning).Return(nil).AnyTimes()
+		mockUtils.EXPECT().DeletePod(mockPod).Return(nil).AnyTimes()
+
+		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(), []string{"/bin/sh", "-c", "ss -anpltH"}).
+			Return([]byte(listenTCP), nil).AnyTimes()
+		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(), []string{"/bin/sh", "-c", "ss -anpluH"}).
+			Return([]byte(""), nil).AnyTimes()
+		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(), []string{"/bin/sh", "-c", "ss -anplSH"}).
+			Return([]byte(""), nil).AnyTimes()
+		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(), []string{"/bin/sh", "-c", "ss -tanpH state established"}).
+			Return([]byte(establishedTCP), nil).AnyTimes()
+		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(), []string{"/bin/sh", "-c", "ss -uanpH state established"}).
+			Return([]byte(establishedUDP), nil).AnyTimes()
+		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(), []string{"chroot", "/host", "/bin/sh", "-c", "ip -j addr show"}).
+			Return([]byte(`[{"ifname":"lo","addr_info":[{"local":"127.0.0.1"}]}]`), nil).AnyTimes()
+		// The processes do not run in containers.
+		mockUtils.EXPECT().RunCommandOnPod(gomock.Any(), gomock.Any()).
+			Return([]byte("0::/system.slice/kubelet.service"), nil).AnyTimes()
+	})
+
+	AfterEach(func() {
+		ctrlTest.Finish()
+	})
+
+	It("resolves the peers of the connections opened by the nodes", func() {
+		connectionCheck, err := NewCheck(clientset, mockUtils, nil, WithEgress())
+		Expect(err).NotTo(HaveOccurred())
+
+		ssResult, err := connectionCheck.GenerateSS(consts.DefaultDebugNamespace)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(ssResult.EgressMatrix.Ports).To(Equal([]types.ComDetails{
+			{Direction: "Egress", Protocol: "TCP", Port: 443, NodeGroup: "master", Service: "crio", Peer: "external"},
+			{Direction: "Egress", Protocol: "TCP", Port: 6443, NodeGroup: "master", Service: "kubelet", Peer: "master"},
+			{Direction: "Egress", Protocol: "UDP", Port: 6081, NodeGroup: "master", Service: "ovs-vswitchd", Peer: "master"},
+			{Direction: "Egress", Protocol: "TCP", Port: 443, NodeGroup: "worker", Service: "crio", Peer: "external"},
+			{Direction: "Egress", Protocol: "TCP", Port: 6443, NodeGroup: "worker", Service: "kubelet", Peer: "master"},
+			{Direction: "Egress", Protocol: "UDP", Port: 6081, NodeGroup: "worker", Service: "ovs-vswitchd", Peer: "master"},
+		}))
+	})
+
+	It("does not collect egress flows by default", func() {
+		connectionCheck, err := NewCheck(clientset, mockUtils, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		ssResult, err := connectionCheck.GenerateSS(consts.DefaultDebugNamespace)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ssResult.EgressMatrix).To(BeNil())
+	})
+})
+
+var _ = Describe("parseConnection", func() {
+	It("parses entries with and without the state column", func() {
+		conn, ok := parseConnection(`0 0 10.0.0.1:40210 10.0.0.2:6443 users:(("kubelet",pid=2000,fd=20))`)
+		Expect(ok).To(BeTrue())
+		Expect(conn.localPort).To(Equal(40210))
+		Expect(conn.peerIP.String()).To(Equal("10.0.0.2"))
+		Expect(conn.peerPort).To(Equal(6443))
+
+		conn, ok = parseConnection(`ESTAB 0 0 [fe80::1%br-ex]:50000 [fd00::2]:2380`)
+		Expect(ok).To(BeTrue())
+		Expect(conn.peerIP.String()).To(Equal("fd00::2"))
+		Expect(conn.peerPort).To(Equal(2380))
+	})
+
+	It("rejects malformed entries", func() {
+		_, ok := parseConnection(``)
+		Expect(ok).To(BeFalse())
+		_, ok = parseConnection(`0 0 10.0.0.1 10.0.0.2`)
+		Expect(ok).To(BeFalse())
 	})
 })
 
@@ -233,6 +394,87 @@ var _ = Describe("isLoopbackEntry", func() {
 	})
 })
 
+var _ = Describe("bindAddressFamily", func() {
+	It("returns the family of the bound address", func() {
+		Expect(bindAddressFamily("0.0.0.0")).To(Equal(types.AddressFamilyIPv4))
+		Expect(bindAddressFamily("10.46.97.104")).To(Equal(types.AddressFamilyIPv4))
+		Expect(bindAddressFamily("[::]")).To(Equal(types.AddressFamilyIPv6))
+		Expect(bindAddressFamily("[fe80::1]%eth0")).To(Equal(types.AddressFamilyIPv6))
+		Expect(bindAddressFamily("[::ffff:10.0.0.1]")).To(Equal(types.AddressFamilyIPv4))
+	})
+
+	It("returns both families for the wildcard sockets", func() {
+		Expect(bindAddressFamily("*")).To(BeEmpty())
+		Expect(bindAddressFamily("*%eth0")).To(BeEmpty())
+		Expect(bindAddressFamily("")).To(BeEmpty())
+	})
+})
+
+var _ = Describe("bindAddress", func() {
+	nodeAddresses := map[string]bool{"10.0.0.1": true, "fd00::1": true}
+
+	It("classifies the bound address relative to the node addresses", func() {
+		for _, tc := range []struct {
+			localAddress, scope, address string
+		}{
+			{"*", types.BindScopeWildcard, ""},
+			{"0.0.0.0", types.BindScopeWildcard, ""},
+			{"[::]", types.BindScopeWildcard, ""},
+			{"10.0.0.1", types.BindScopeNodeIP, "10.0.0.1"},
+			{"[fd00::1]", types.BindScopeNodeIP, "fd00::1"},
+			{"[::ffff:10.0.0.1]", types.BindScopeNodeIP, "10.0.0.1"},
+			{"10.0.0.100", types.BindScopeVIP, "10.0.0.100"},
+			{"[fe80::1]%eth0", types.BindScopeLinkLocal, "fe80::1"},
+			{"169.254.169.2", types.BindScopeLinkLocal, "169.254.169.2"},
+			{"", "", ""},
+		} {
+			scope, address := bindAddress(tc.localAddress, nodeAddresses)
+			Expect(scope).To(Equal(tc.scope), tc.localAddress)
+			Expect(address).To(Equal(tc.address), tc.localAddress)
+		}
+	})
+})
+
+var _ = Describe("bindInterface", func() {
+	hostAddresses := map[string]string{"10.0.0.1": "br-ex", "192.168.100.1": "ens4", "fd00::1": "br-ex"}
+
+	It("infers the interface from the bound address", func() {
+		for _, tc := range []struct {
+			localAddress, iface string
+		}{
+			{"*", ""},
+			{"0.0.0.0", ""},
+			{"[::]", ""},
+			{"10.0.0.1", "br-ex"},
+			{"192.168.100.1", "ens4"},
+			{"[fd00::1]", "br-ex"},
+			{"[::ffff:192.168.100.1]", "ens4"},
+			{"[fe80::1]%ens5", "ens5"},
+			{"0.0.0.0%ens4", "ens4"},
+			{"10.0.0.100", ""},
+		} {
+			Expect(bindInterface(tc.localAddress, hostAddresses)).To(Equal(tc.iface), tc.localAddress)
+		}
+	})
+
+	It("clears the interface of the entries bound to different interfaces", func() {
+		entries := reconcileInterfaces([]types.ComDetails{
+			{Direction: "Ingress", Protocol: "TCP", Port: 80, NodeGroup: "worker", Interface: "ens4"},
+			{Direction: "Ingress", Protocol: "TCP", Port: 80, NodeGroup: "worker", Interface: "ens5"},
+			{Direction: "Ingress", Protocol: "TCP", Port: 443, NodeGroup: "worker", Interface: "ens4"},
+			{Direction: "Ingress", Protocol: "TCP", Port: 443, NodeGroup: "worker", Interface: "ens4"},
+			{Direction: "Ingress", Protocol: "TCP", Port: 8080, NodeGroup: "worker", Interface: "ens4"},
+			{Direction: "Ingress", Protocol: "TCP", Port: 8080, NodeGroup: "worker"},
+		})
+		Expect(entries[0].Interface).To(BeEmpty())
+		Expect(entries[1].Interface).To(BeEmpty())
+		Expect(entries[2].Interface).To(Equal("ens4"))
+		Expect(entries[3].Interface).To(Equal("ens4"))
+		Expect(entries[4].Interface).To(BeEmpty())
+		Expect(entries[5].Interface).To(BeEmpty())
+	})
+})
+
 var _ = Describe("filterEntries (loopback filtering)", func() {
 	It("should filter out loopback addresses from ss entries", func() {
 		entries := []string{
@@ -1,7 +1,11 @@
 package matrixdiff
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
+
+	"sigs.k8s.io/yaml"
 
 	"github.com/openshift-kni/commatrix/pkg/types"
 )
@@ -22,10 +26,46 @@ type MatrixDiff struct {
 	// Matrix Diff's ComMatrix is the combined matrix of the primary and secondary matrices.
 	types.ComMatrix
 	cdToStatus map[string]status
+	// changes holds, by entry key, the entries of both matrices whose attributes differ.
+	changes map[string]Change
+}
+
+// Change is an entry present in both matrices with different attributes.
+type Change struct {
+	Primary   types.ComDetails `json:"primary" yaml:"primary"`
+	Secondary types.ComDetails `json:"secondary" yaml:"secondary"`
+}
+
+// Report is the machine-readable representation of a MatrixDiff.
+type Report struct {
+	UniquePrimary   []types.ComDetails `json:"uniquePrimary" yaml:"uniquePrimary"`
+	UniqueSecondary []types.ComDetails `json:"uniqueSecondary" yaml:"uniqueSecondary"`
+	Changed         []Change           `json:"changed" yaml:"changed"`
+	Shared          []types.ComDetails `json:"shared" yaml:"shared"`
+}
+
+// Option configures the diff.
+type Option func(*options)
+
+type options struct {
+	attributeChanges bool
+}
+
+// WithAttributeChanges reports the entries present in both matrices whose source CIDRs,
+// address family, bind addresses or interface differ as changed rather than shared.
+func WithAttributeChanges() Option {
+	return func(o *options) {
+		o.attributeChanges = true
+	}
 }
 
 // Generates the diff between primary mat to secondary mat.
-func Generate(primary *types.ComMatrix, secondary *types.ComMatrix) MatrixDiff {
+func Generate(primary *types.ComMatrix, secondary *types.ComMatrix, opts ...Option) MatrixDiff {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	matrix := types.ComMatrix{}
 	epsStatus := map[string]status{}
 
@@ -49,7 +89,37 @@ func Generate(primary *types.ComMatrix, secondary *types.ComMatrix) MatrixDiff {
 
 	matrix.SortAndRemoveDuplicates()
 
-	return MatrixDiff{matrix, epsStatus}
+	changes := map[string]Change{}
+	if o.attributeChanges {
+		changes = attributeChanges(primary, secondary)
+	}
+
+	return MatrixDiff{matrix, epsStatus, changes}
+}
+
+// attributeChanges returns, by entry key, the entries of primary whose attributes differ
+// from the entry with the same key in secondary. The entries of the same flow are merged
+// first, so the attributes of the whole flow are compared.
+func attributeChanges(primary *types.ComMatrix, secondary *types.ComMatrix) map[string]Change {
+	merged := func(m *types.ComMatrix) []types.ComDetails {
+		res := types.ComMatrix{Ports: slices.Clone(m.Ports)}
+		res.SortAndRemoveDuplicates()
+		return res.Ports
+	}
+
+	secondaryByKey := map[string]types.ComDetails{}
+	for _, cd := range merged(secondary) {
+		secondaryByKey[cd.Key()] = cd
+	}
+
+	changes := map[string]Change{}
+	for _, cd := range merged(primary) {
+		if other, ok := secondaryByKey[cd.Key()]; ok && !cd.SameAttributes(other) {
+			changes[cd.Key()] = Change{Primary: cd, Secondary: other}
+		}
+	}
+
+	return changes
 }
 
 func (m *MatrixDiff) String() (string, error) {
@@ -60,6 +130,12 @@ func (m *MatrixDiff) String() (string, error) {
 	diff := colNames + "\n"
 
 	for _, cd := range m.Ports {
+		if change, ok := m.changes[cd.Key()]; ok {
+			// a changed entry is shown as removed from secondary mat and added in primary mat.
+			diff += fmt.Sprintf("+ %s\n- %s\n", change.Primary, change.Secondary)
+			continue
+		}
+
 		switch m.cdToStatus[cd.String()] {
 		case both:
 			diff += fmt.Sprintf("%s\n", cd)
@@ -101,11 +177,14 @@ func (m *MatrixDiff) GetUniqueSecondary() *types.ComMatrix {
 	return &matrix
 }
 
-// Get the common entries in both mat.
+// Get the common entries in both mat, leaving out the changed entries.
 func (m *MatrixDiff) GetSharedEntries() *types.ComMatrix {
 	matrix := types.ComMatrix{}
 
 	for _, cd := range m.Ports {
+		if _, ok := m.changes[cd.Key()]; ok {
+			continue
+		}
 		if m.cdToStatus[cd.String()] == both {
 			matrix.Ports = append(matrix.Ports, cd)
 		}
@@ -113,3 +192,67 @@ func (m *MatrixDiff) GetSharedEntries() *types.ComMatrix {
 
 	return &matrix
 }
+
+// GetChanged returns the entries present in both mat whose attributes differ.
+func (m *MatrixDiff) GetChanged() []Change {
+	changes := []Change{}
+
+	for _, cd := range m.Ports {
+		if change, ok := m.changes[cd.Key()]; ok {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// HasDifferences returns true if any entry is present in only one of the matrices or changed.
+func (m *MatrixDiff) HasDifferences() bool {
+	if len(m.changes) > 0 {
+		return true
+	}
+
+	for _, cd := range m.Ports {
+		if m.cdToStatus[cd.String()] != both {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Report returns the diff split into unique primary, unique secondary, changed and shared entries.
+func (m *MatrixDiff) Report() Report {
+	return Report{
+		UniquePrimary:   nonNilPorts(m.GetUniquePrimary()),
+		UniqueSecondary: nonNilPorts(m.GetUniqueSecondary()),
+		Changed:         m.GetChanged(),
+		Shared:          nonNilPorts(m.GetSharedEntries()),
+	}
+}
+
+func (m *MatrixDiff) ToJSON() ([]byte, error) {
+	out, err := json.MarshalIndent(m.Report(), "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (m *MatrixDiff) ToYAML() ([]byte, error) {
+	out, err := yaml.Marshal(m.Report())
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// nonNilPorts makes sure empty sections are serialized as empty lists rather than null.
+func nonNilPorts(m *types.ComMatrix) []types.ComDetails {
+	if m.Ports == nil {
+		return []types.ComDetails{}
+	}
+	return m.Ports
+}
@@ -0,0 +1,155 @@
+package matrixdocs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// Directories of the documented matrices, relative to the docs directory.
+const (
+	RawDir    = "raw"
+	UniqueDir = "unique"
+)
+
+// commonPrefix is the file name prefix of the per node group common matrices.
+const commonPrefix = "common-"
+
+// Docs holds the documented matrices computed from the generated matrices of several deployments.
+type Docs struct {
+	// Raw holds the full matrix of each deployment, e.g. aws, aws-sno, bm and none-sno.
+	Raw map[string]*types.ComMatrix
+	// Common holds, per node group, the entries shared by all the deployments that have the group.
+	// Groups found in a single deployment have no common matrix.
+	Common map[string]*types.ComMatrix
+	// Unique holds the entries of each deployment that are not in the common matrix of their group.
+	Unique map[string]*types.ComMatrix
+}
+
+// Generate computes the documented matrices from the generated matrices, keyed by deployment name.
+// Entries are compared by their key, namespace, service and pod, so an entry served by a different
+// workload in some deployment stays in the unique matrix of each deployment. Dynamic ranges are not
+// part of the documented matrices.
+func Generate(matrices map[string]*types.ComMatrix) *Docs {
+	docs := &Docs{
+		Raw:    map[string]*types.ComMatrix{},
+		Common: map[string]*types.ComMatrix{},
+		Unique: map[string]*types.ComMatrix{},
+	}
+
+	// byGroup holds the matrices of the deployments that have each node group.
+	byGroup := map[string][]types.ComMatrix{}
+	for _, name := range sortedNames(matrices) {
+		raw := &types.ComMatrix{Ports: append([]types.ComDetails{}, matrices[name].Ports...)}
+		raw.SortAndRemoveDuplicates()
+		docs.Raw[name] = raw
+
+		for group, groupMatrix := range raw.SeparateMatrixByGroup() {
+			byGroup[group] = append(byGroup[group], groupMatrix)
+		}
+	}
+
+	for group, groupMatrices := range byGroup {
+		if len(groupMatrices) < 2 {
+			continue
+		}
+		docs.Common[group] = intersect(groupMatrices)
+	}
+
+	commonKeys := map[string]bool{}
+	for _, common := range docs.Common {
+		for _, cd := range common.Ports {
+			commonKeys[entryKey(cd)] = true
+		}
+	}
+
+	for name, raw := range docs.Raw {
+		unique := &types.ComMatrix{}
+		for _, cd := range raw.Ports {
+			if commonKeys[entryKey(cd)] {
+				continue
+			}
+			unique.Ports = append(unique.Ports, cd)
+		}
+		docs.Unique[name] = unique
+	}
+
+	return docs
+}
+
+// intersect returns the entries of the first matrix that are in all the others.
+func intersect(matrices []types.ComMatrix) *types.ComMatrix {
+	keys := make([]map[string]bool, len(matrices))
+	for i, m := range matrices {
+		keys[i] = map[string]bool{}
+		for _, cd := range m.Ports {
+			keys[i][entryKey(cd)] = true
+		}
+	}
+
+	res := &types.ComMatrix{}
+	for _, cd := range matrices[0].Ports {
+		shared := true
+		for i := 1; i < len(matrices) && shared; i++ {
+			shared = keys[i][entryKey(cd)]
+		}
+		if shared {
+			res.Ports = append(res.Ports, cd)
+		}
+	}
+	res.SortAndRemoveDuplicates()
+
+	return res
+}
+
+// entryKey identifies a documented entry by its key and the workload serving it.
+func entryKey(cd types.ComDetails) string {
+	return fmt.Sprintf("%s-%s-%s-%s", cd.Key(), cd.Namespace, cd.Service, cd.Pod)
+}
+
+// Write writes the documented matrices as CSV files to dir:
+// raw/<name>.csv, unique/<name>.csv and unique/common-<group>.csv.
+func (d *Docs) Write(dir string) error {
+	files := map[string]*types.ComMatrix{}
+	for name, m := range d.Raw {
+		files[filepath.Join(RawDir, name+".csv")] = m
+	}
+	for name, m := range d.Unique {
+		files[filepath.Join(UniqueDir, name+".csv")] = m
+	}
+	for group, m := range d.Common {
+		files[filepath.Join(UniqueDir, commonPrefix+group+".csv")] = m
+	}
+
+	for _, sub := range []string{RawDir, UniqueDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Join(dir, sub), err)
+		}
+	}
+
+	for _, file := range sortedNames(files) {
+		out, err := files[file].ToCSV()
+		if err != nil {
+			return fmt.Errorf("failed to render %s: %w", file, err)
+		}
+		path := filepath.Join(dir, file)
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func sortedNames(m map[string]*types.ComMatrix) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
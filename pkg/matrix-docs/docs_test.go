@@ -0,0 +1,88 @@
+package matrixdocs
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+func entry(group string, port int) types.ComDetails {
+	return types.ComDetails{Direction: "Ingress", Protocol: "TCP", Port: port, NodeGroup: group}
+}
+
+var _ = Describe("Generate", func() {
+	matrices := map[string]*types.ComMatrix{
+		"aws": {Ports: []types.ComDetails{
+			entry("master", 6443), entry("master", 9001), entry("worker", 80), entry("worker", 10250),
+		}},
+		"bm": {Ports: []types.ComDetails{
+			entry("master", 6443), entry("master", 9100), entry("worker", 10250), entry("worker", 10250),
+		}},
+		"aws-sno": {Ports: []types.ComDetails{
+			entry("master", 6443), entry("master", 9001),
+		}},
+	}
+
+	It("computes the common entries of every node group", func() {
+		docs := Generate(matrices)
+		Expect(docs.Common).To(HaveLen(2))
+		Expect(docs.Common["master"].Ports).To(Equal([]types.ComDetails{entry("master", 6443)}))
+		Expect(docs.Common["worker"].Ports).To(Equal([]types.ComDetails{entry("worker", 10250)}))
+	})
+
+	It("keeps the remaining entries of every deployment in its unique matrix", func() {
+		docs := Generate(matrices)
+		Expect(docs.Raw["bm"].Ports).To(HaveLen(3))
+		Expect(docs.Unique["aws"].Ports).To(Equal([]types.ComDetails{entry("master", 9001), entry("worker", 80)}))
+		Expect(docs.Unique["bm"].Ports).To(Equal([]types.ComDetails{entry("master", 9100)}))
+		Expect(docs.Unique["aws-sno"].Ports).To(Equal([]types.ComDetails{entry("master", 9001)}))
+	})
+
+	It("has no common matrix for a group of a single deployment", func() {
+		docs := Generate(map[string]*types.ComMatrix{
+			"aws":     {Ports: []types.ComDetails{entry("master", 6443), entry("worker", 80)}},
+			"aws-sno": {Ports: []types.ComDetails{entry("master", 6443)}},
+		})
+		Expect(docs.Common).To(HaveKey("master"))
+		Expect(docs.Common).NotTo(HaveKey("worker"))
+		Expect(docs.Unique["aws"].Ports).To(Equal([]types.ComDetails{entry("worker", 80)}))
+	})
+	It("keeps an entry served by different workloads in the unique matrix of each deployment", func() {
+		router := func(service string) types.ComDetails {
+			cd := entry("worker", 443)
+			cd.Namespace, cd.Service, cd.Pod = "openshift-ingress", service, "router-default"
+			return cd
+		}
+		docs := Generate(map[string]*types.ComMatrix{
+			"aws": {Ports: []types.ComDetails{entry("worker", 10250), router("router-default")}},
+			"bm":  {Ports: []types.ComDetails{entry("worker", 10250), router("router-internal-default")}},
+		})
+		Expect(docs.Common["worker"].Ports).To(Equal([]types.ComDetails{entry("worker", 10250)}))
+		Expect(docs.Unique["aws"].Ports).To(Equal([]types.ComDetails{router("router-default")}))
+		Expect(docs.Unique["bm"].Ports).To(Equal([]types.ComDetails{router("router-internal-default")}))
+	})
+})
+
+var _ = Describe("Write", func() {
+	It("writes the raw, unique and common matrices", func() {
+		dir := GinkgoT().TempDir()
+		docs := Generate(map[string]*types.ComMatrix{
+			"aws": {Ports: []types.ComDetails{entry("master", 6443), entry("master", 9001)}},
+			"bm":  {Ports: []types.ComDetails{entry("master", 6443)}},
+		})
+		Expect(docs.Write(dir)).To(Succeed())
+
+		for _, file := range []string{"raw/aws.csv", "raw/bm.csv", "unique/aws.csv", "unique/bm.csv", "unique/common-master.csv"} {
+			Expect(filepath.Join(dir, file)).To(BeAnExistingFile())
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, "unique", "common-master.csv"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring(",6443,"))
+		Expect(string(content)).NotTo(ContainSubstring(",9001,"))
+	})
+})
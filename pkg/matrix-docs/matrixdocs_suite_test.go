@@ -0,0 +1,13 @@
+package matrixdocs
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestMatrixDocs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "matrixdocs Suite")
+}
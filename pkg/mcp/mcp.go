@@ -6,10 +6,44 @@ import (
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/openshift-kni/commatrix/pkg/consts"
+	"github.com/openshift-kni/commatrix/pkg/types"
 )
 
+// ResolveNodeToGroup returns the node group of every node the way the matrix is generated:
+// see ResolveBaseNodeToGroup, with the nodes matching a custom node group selector moved to
+// the custom group.
+func ResolveNodeToGroup(nodes []corev1.Node, customNodeGroups map[string]labels.Selector) (map[string]string, error) {
+	nodeToGroup, _, err := ResolveBaseNodeToGroup(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := types.ApplyCustomNodeGroupOverrides(nodeToGroup, customNodeGroups, nodes); err != nil {
+		return nil, err
+	}
+
+	return nodeToGroup, nil
+}
+
+// ResolveBaseNodeToGroup returns the node group of every node before the custom node group
+// overrides: its MachineConfigPool when every node has a rendered MachineConfig annotation,
+// otherwise (HyperShift or clusters without MCP) its NodePool label, else its role. fromPools
+// is true when the groups are the MachineConfigPools.
+func ResolveBaseNodeToGroup(nodes []corev1.Node) (nodeToGroup map[string]string, fromPools bool, err error) {
+	if nodeToGroup, err := ResolveNodeToPool(nodes); err == nil {
+		return nodeToGroup, true, nil
+	}
+
+	nodeToGroup, err = types.BuildNodeToGroupMap(nodes)
+	if err != nil {
+		return nil, false, err
+	}
+	return nodeToGroup, false, nil
+}
+
 // ResolveNodeToPool builds a mapping from node name to its MachineConfigPool.
 // It derives the pool from the node annotation "machineconfiguration.openshift.io/currentConfig",
 // expected in the form: "rendered-<pool>-<hash>". The pool name is obtained by removing the
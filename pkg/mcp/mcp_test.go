@@ -8,6 +8,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // Test 1: Annotation-based resolution coverage.
@@ -101,3 +102,37 @@ var _ = Describe("GetPoolRolesForStaticEntriesExpansion", func() {
 		Expect(roles["custom"]).To(ContainElements("master", "worker"))
 	})
 })
+
+// Test 3: Node group resolution with the fallback and the custom node groups.
+var _ = Describe("ResolveNodeToGroup", func() {
+	rendered := func(name, config string, nodeLabels map[string]string) corev1.Node {
+		return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nodeLabels,
+			Annotations: map[string]string{"machineconfiguration.openshift.io/currentConfig": config}}}
+	}
+
+	It("uses the pools, then the custom node groups", func() {
+		nodes := []corev1.Node{
+			rendered("n1", "rendered-master-abc", map[string]string{consts.RoleLabel + "master": ""}),
+			rendered("n2", "rendered-worker-abc", map[string]string{consts.RoleLabel + "worker": "", "zone": "edge"}),
+			rendered("n3", "rendered-worker-abc", map[string]string{consts.RoleLabel + "worker": ""}),
+		}
+		selector, err := labels.Parse("zone=edge")
+		Expect(err).NotTo(HaveOccurred())
+
+		mapping, err := ResolveNodeToGroup(nodes, map[string]labels.Selector{"edge": selector})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mapping).To(Equal(map[string]string{"n1": "master", "n2": "edge", "n3": "worker"}))
+	})
+
+	It("falls back to the roles without rendered MachineConfigs", func() {
+		nodes := []corev1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "n1", Labels: map[string]string{consts.RoleLabel + "master": ""}}},
+			rendered("n2", "rendered-infra-abc", map[string]string{consts.RoleLabel + "worker": ""}),
+		}
+
+		mapping, fromPools, err := ResolveBaseNodeToGroup(nodes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fromPools).To(BeFalse())
+		Expect(mapping).To(Equal(map[string]string{"n1": "master", "n2": "worker"}))
+	})
+})
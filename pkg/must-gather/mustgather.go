@@ -0,0 +1,171 @@
+package mustgather
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+)
+
+// wantedKinds are the kinds read from the must-gather tree.
+var wantedKinds = map[schema.GroupVersionKind]bool{
+	corev1.SchemeGroupVersion.WithKind("Node"):               true,
+	corev1.SchemeGroupVersion.WithKind("Service"):            true,
+	corev1.SchemeGroupVersion.WithKind("Pod"):                true,
+	discoveryv1.SchemeGroupVersion.WithKind("EndpointSlice"): true,
+	configv1.SchemeGroupVersion.WithKind("Infrastructure"):   true,
+	configv1.SchemeGroupVersion.WithKind("Network"):          true,
+	configv1.SchemeGroupVersion.WithKind("ClusterVersion"):   true,
+	corev1.SchemeGroupVersion.WithKind("ConfigMap"):          true,
+}
+
+// wantedConfigMaps are the ConfigMaps read from the must-gather tree, by namespace and name.
+// The install-config holds the machine networks of the clusters whose Infrastructure does not.
+var wantedConfigMaps = map[string]bool{
+	"kube-system/cluster-config-v1": true,
+}
+
+// Load reads the Nodes, Services, EndpointSlices, Pods, Infrastructure, Network and
+// ClusterVersion objects and the install-config ConfigMap of the must-gather tree rooted
+// at dir and returns a ClientSet backed by a read-only store holding them.
+func Load(dir string) (*client.ClientSet, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read must-gather directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("must-gather path %s is not a directory", dir)
+	}
+
+	scheme, err := client.NewScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	objs, err := loadObjects(dir, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReadOnlyClientSet(scheme, objs), nil
+}
+
+// loadObjects walks dir and decodes the wanted objects from every YAML file.
+// Objects found in several files, as pods are in must-gathers, are only kept once.
+func loadObjects(dir string, scheme *runtime.Scheme) ([]runtime.Object, error) {
+	var objs []runtime.Object
+	seen := map[string]bool{}
+	counts := map[string]int{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		items, err := decodeItems(content)
+		if err != nil {
+			log.Debugf("Skipping %s: %v", path, err)
+			return nil
+		}
+
+		for _, item := range items {
+			gvk := item.GroupVersionKind()
+			if !wantedKinds[gvk] {
+				continue
+			}
+			if gvk.Kind == "ConfigMap" && !wantedConfigMaps[item.GetNamespace()+"/"+item.GetName()] {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s/%s", gvk.Kind, item.GetNamespace(), item.GetName())
+			if seen[key] {
+				continue
+			}
+
+			obj, err := scheme.New(gvk)
+			if err != nil {
+				return err
+			}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, obj); err != nil {
+				return fmt.Errorf("failed to decode %s in %s: %w", key, path, err)
+			}
+
+			seen[key] = true
+			counts[gvk.Kind]++
+			objs = append(objs, obj)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load must-gather %s: %w", dir, err)
+	}
+
+	log.Debugf("Loaded objects from must-gather: %v", counts)
+	if counts["Node"] == 0 {
+		return nil, fmt.Errorf("no nodes found in must-gather %s", dir)
+	}
+
+	return objs, nil
+}
+
+// decodeItems decodes a YAML file holding a single object or a list of objects.
+// List items without apiVersion and kind inherit them from the list kind.
+func decodeItems(content []byte) ([]*unstructured.Unstructured, error) {
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{Object: raw}
+	if obj.GetKind() == "" {
+		return nil, errors.New("not a Kubernetes object")
+	}
+	if !obj.IsList() {
+		return []*unstructured.Unstructured{obj}, nil
+	}
+
+	itemKind := strings.TrimSuffix(obj.GetKind(), "List")
+	list, ok := raw["items"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	items := make([]*unstructured.Unstructured, 0, len(list))
+	for _, i := range list {
+		m, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		item := &unstructured.Unstructured{Object: m}
+		if item.GetKind() == "" && itemKind != "" {
+			item.SetAPIVersion(obj.GetAPIVersion())
+			item.SetKind(itemKind)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
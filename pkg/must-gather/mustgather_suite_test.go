@@ -0,0 +1,13 @@
+package mustgather
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestMustGather(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "must-gather Suite")
+}
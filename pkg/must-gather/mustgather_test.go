@@ -0,0 +1,163 @@
+package mustgather
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	internalflows "github.com/openshift-kni/commatrix/pkg/internal-flows"
+)
+
+const (
+	nodesYAML = `apiVersion: v1
+kind: NodeList
+items:
+- metadata:
+    name: master-0
+    labels:
+      node-role.kubernetes.io/master: ""
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: worker-0
+    labels:
+      node-role.kubernetes.io/worker: ""
+`
+	infraYAML = `apiVersion: config.openshift.io/v1
+kind: Infrastructure
+metadata:
+  name: cluster
+status:
+  controlPlaneTopology: HighlyAvailable
+  platformStatus:
+    type: BareMetal
+`
+	podYAML = `apiVersion: v1
+kind: Pod
+metadata:
+  name: etcd-master-0
+  namespace: openshift-etcd
+spec:
+  containers:
+  - name: etcd
+    image: etcd
+`
+	configMapYAML = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+  namespace: default
+`
+	installConfigYAML = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cluster-config-v1
+  namespace: kube-system
+data:
+  install-config: |
+    networking:
+      machineNetwork:
+      - cidr: 10.0.0.0/16
+`
+)
+
+var _ = Describe("Load", func() {
+	var dir string
+
+	writeFile := func(rel, content string) {
+		path := filepath.Join(dir, rel)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		writeFile("quay-io-image/cluster-scoped-resources/core/nodes.yaml", nodesYAML)
+		writeFile("quay-io-image/cluster-scoped-resources/config.openshift.io/infrastructures/cluster.yaml", infraYAML)
+		writeFile("quay-io-image/namespaces/openshift-etcd/core/pods.yaml", "apiVersion: v1\nkind: PodList\nitems:\n- "+
+			"metadata:\n    name: etcd-master-0\n    namespace: openshift-etcd\n  spec:\n    containers:\n    - name: etcd\n      image: etcd\n")
+		writeFile("quay-io-image/namespaces/openshift-etcd/pods/etcd-master-0/etcd-master-0.yaml", podYAML)
+		writeFile("quay-io-image/namespaces/default/core/configmaps.yaml", configMapYAML)
+		writeFile("quay-io-image/namespaces/openshift-etcd/pods/etcd-master-0/etcd/etcd/logs/current.log", "not yaml: [")
+		writeFile("quay-io-image/event-filter.yaml", "not: [valid")
+	})
+
+	It("serves the objects of the must-gather", func() {
+		cs, err := Load(dir)
+		Expect(err).ToNot(HaveOccurred())
+
+		nodes := &corev1.NodeList{}
+		Expect(cs.List(context.TODO(), nodes)).To(Succeed())
+		Expect(nodes.Items).To(HaveLen(2))
+
+		pods := &corev1.PodList{}
+		Expect(cs.List(context.TODO(), pods, runtimeclient.InNamespace("openshift-etcd"))).To(Succeed())
+		Expect(pods.Items).To(HaveLen(1))
+
+		infra := &configv1.Infrastructure{}
+		Expect(cs.Get(context.TODO(), runtimeclient.ObjectKey{Name: "cluster"}, infra)).To(Succeed())
+		Expect(infra.Status.PlatformStatus.Type).To(Equal(configv1.BareMetalPlatformType))
+
+		coreNodes, err := cs.CoreV1Interface.Nodes().List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(coreNodes.Items).To(HaveLen(2))
+
+		configMaps := &corev1.ConfigMapList{}
+		Expect(cs.List(context.TODO(), configMaps)).To(Succeed())
+		Expect(configMaps.Items).To(BeEmpty())
+	})
+
+	It("serves the install-config for the machine networks", func() {
+		writeFile("quay-io-image/namespaces/kube-system/core/configmaps.yaml",
+			"apiVersion: v1\nkind: ConfigMapList\nitems:\n- metadata:\n    name: other\n    namespace: kube-system\n")
+		writeFile("quay-io-image/namespaces/kube-system/core/configmaps/cluster-config-v1.yaml", installConfigYAML)
+
+		cs, err := Load(dir)
+		Expect(err).ToNot(HaveOccurred())
+
+		configMaps := &corev1.ConfigMapList{}
+		Expect(cs.List(context.TODO(), configMaps)).To(Succeed())
+		Expect(configMaps.Items).To(HaveLen(1))
+		Expect(configMaps.Items[0].Name).To(Equal("cluster-config-v1"))
+
+		infra := &configv1.Infrastructure{}
+		Expect(cs.Get(context.TODO(), runtimeclient.ObjectKey{Name: "cluster"}, infra)).To(Succeed())
+		Expect(internalflows.GetMachineNetworks(cs, infra.Status.PlatformStatus)).To(Equal([]string{"10.0.0.0/16"}))
+	})
+
+	It("rejects writes", func() {
+		cs, err := Load(dir)
+		Expect(err).ToNot(HaveOccurred())
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+		Expect(cs.Create(context.TODO(), ns)).To(MatchError(ErrReadOnly))
+
+		node := &corev1.Node{}
+		Expect(cs.Get(context.TODO(), runtimeclient.ObjectKey{Name: "worker-0"}, node)).To(Succeed())
+		Expect(cs.Update(context.TODO(), node)).To(MatchError(ErrReadOnly))
+		Expect(cs.Delete(context.TODO(), node)).To(MatchError(ErrReadOnly))
+		Expect(cs.Status().Update(context.TODO(), node)).To(MatchError(ErrReadOnly))
+
+		_, err = cs.CoreV1Interface.Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{})
+		Expect(err).To(MatchError(ErrReadOnly))
+	})
+
+	It("fails without nodes", func() {
+		Expect(os.Remove(filepath.Join(dir, "quay-io-image/cluster-scoped-resources/core/nodes.yaml"))).To(Succeed())
+
+		_, err := Load(dir)
+		Expect(err).To(MatchError(ContainSubstring("no nodes found")))
+	})
+
+	It("fails for a missing directory", func() {
+		_, err := Load(filepath.Join(dir, "missing"))
+		Expect(err).To(HaveOccurred())
+	})
+})
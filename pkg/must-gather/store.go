@@ -0,0 +1,92 @@
+package mustgather
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+)
+
+// ErrReadOnly is returned for writes to the objects loaded from a must-gather.
+var ErrReadOnly = errors.New("the must-gather store is read-only")
+
+// newReadOnlyClientSet returns a ClientSet whose clients serve objs and reject writes.
+// Only the runtime client and the core/v1 client are set.
+func newReadOnlyClientSet(scheme *runtime.Scheme, objs []runtime.Object) *client.ClientSet {
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build()
+
+	var coreObjs []runtime.Object
+	for _, obj := range objs {
+		switch obj.(type) {
+		case *corev1.Node, *corev1.Service, *corev1.Pod:
+			coreObjs = append(coreObjs, obj)
+		}
+	}
+	clientset := k8sfake.NewSimpleClientset(coreObjs...)
+	for _, verb := range []string{"create", "update", "patch", "delete", "delete-collection"} {
+		clientset.PrependReactor(verb, "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, ErrReadOnly
+		})
+	}
+
+	return &client.ClientSet{
+		Client:          readOnlyClient{c},
+		CoreV1Interface: clientset.CoreV1(),
+	}
+}
+
+// readOnlyClient serves reads from the wrapped client and rejects all writes.
+type readOnlyClient struct {
+	runtimeclient.Client
+}
+
+func (readOnlyClient) Create(context.Context, runtimeclient.Object, ...runtimeclient.CreateOption) error {
+	return ErrReadOnly
+}
+
+func (readOnlyClient) Update(context.Context, runtimeclient.Object, ...runtimeclient.UpdateOption) error {
+	return ErrReadOnly
+}
+
+func (readOnlyClient) Patch(context.Context, runtimeclient.Object, runtimeclient.Patch, ...runtimeclient.PatchOption) error {
+	return ErrReadOnly
+}
+
+func (readOnlyClient) Delete(context.Context, runtimeclient.Object, ...runtimeclient.DeleteOption) error {
+	return ErrReadOnly
+}
+
+func (readOnlyClient) DeleteAllOf(context.Context, runtimeclient.Object, ...runtimeclient.DeleteAllOfOption) error {
+	return ErrReadOnly
+}
+
+func (c readOnlyClient) Status() runtimeclient.SubResourceWriter {
+	return readOnlySubResourceClient{}
+}
+
+func (c readOnlyClient) SubResource(subResource string) runtimeclient.SubResourceClient {
+	return readOnlySubResourceClient{c.Client.SubResource(subResource)}
+}
+
+type readOnlySubResourceClient struct {
+	runtimeclient.SubResourceReader
+}
+
+func (readOnlySubResourceClient) Create(context.Context, runtimeclient.Object, runtimeclient.Object, ...runtimeclient.SubResourceCreateOption) error {
+	return ErrReadOnly
+}
+
+func (readOnlySubResourceClient) Update(context.Context, runtimeclient.Object, ...runtimeclient.SubResourceUpdateOption) error {
+	return ErrReadOnly
+}
+
+func (readOnlySubResourceClient) Patch(context.Context, runtimeclient.Object, runtimeclient.Patch, ...runtimeclient.SubResourcePatchOption) error {
+	return ErrReadOnly
+}
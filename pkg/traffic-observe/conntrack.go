@@ -0,0 +1,81 @@
+package trafficobserve
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ConntrackCommand lists the connection tracking table of the host. conntrack -o extended
+// and /proc/net/nf_conntrack print the entries in the same format.
+var ConntrackCommand = []string{"/bin/sh", "-c",
+	"chroot /host conntrack -L -o extended 2>/dev/null || cat /proc/net/nf_conntrack"}
+
+// trackedProtocols are the protocols of the conntrack entries that carry ports.
+var trackedProtocols = map[string]bool{"tcp": true, "udp": true, "sctp": true}
+
+// conntrackEntry is the original direction tuple of a conntrack entry.
+type conntrackEntry struct {
+	protocol string
+	src      net.IP
+	dst      net.IP
+	sport    int
+	dport    int
+}
+
+// key identifies the connection of the entry.
+func (e conntrackEntry) key() string {
+	return e.protocol + " " + net.JoinHostPort(e.src.String(), strconv.Itoa(e.sport)) +
+		" " + net.JoinHostPort(e.dst.String(), strconv.Itoa(e.dport))
+}
+
+// parseConntrack parses the TCP, UDP and SCTP entries of conntrack output. Entries of
+// other protocols and malformed lines are skipped.
+func parseConntrack(out []byte) []conntrackEntry {
+	var res []conntrackEntry
+	for _, line := range strings.Split(string(out), "\n") {
+		if e, ok := parseConntrackLine(line); ok {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+// parseConntrackLine parses lines such as
+//
+//	ipv4 2 tcp 6 431999 ESTABLISHED src=10.0.0.2 dst=10.0.0.1 sport=51234 dport=6443 src=10.0.0.1 ...
+//
+// The "ipv4 2" prefix is optional. Only the first, original direction, tuple is kept.
+func parseConntrackLine(line string) (conntrackEntry, bool) {
+	e := conntrackEntry{}
+	for _, field := range strings.Fields(line) {
+		if e.protocol == "" {
+			if trackedProtocols[field] {
+				e.protocol = strings.ToUpper(field)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch {
+		case key == "src" && e.src == nil:
+			e.src = net.ParseIP(value)
+		case key == "dst" && e.dst == nil:
+			e.dst = net.ParseIP(value)
+		case key == "sport" && e.sport == 0:
+			e.sport, _ = strconv.Atoi(value)
+		case key == "dport" && e.dport == 0:
+			e.dport, _ = strconv.Atoi(value)
+		}
+	}
+
+	if e.protocol == "" || e.src == nil || e.dst == nil || e.dport == 0 {
+		return conntrackEntry{}, false
+	}
+
+	return e, true
+}
@@ -0,0 +1,267 @@
+package trafficobserve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/openshift-kni/commatrix/pkg/consts"
+	"github.com/openshift-kni/commatrix/pkg/mcp"
+	"github.com/openshift-kni/commatrix/pkg/types"
+	"github.com/openshift-kni/commatrix/pkg/utils"
+)
+
+// DefaultInterval is the time between two reads of the conntrack table of a node.
+const DefaultInterval = 10 * time.Second
+
+// ClusterSource is the source group of the connections from the pod network.
+const ClusterSource = "cluster"
+
+// listAddressesCommand lists the addresses of the host, including the VIPs it holds.
+var listAddressesCommand = []string{"chroot", "/host", "/bin/sh", "-c", "ip -j addr show"}
+
+// Flow is an inbound flow observed on the nodes of a group.
+type Flow struct {
+	Protocol string `json:"protocol" yaml:"protocol"`
+	Port     int    `json:"port" yaml:"port"`
+	// NodeGroup is the group of the nodes receiving the flow.
+	NodeGroup string `json:"nodeGroup" yaml:"nodeGroup"`
+	// SourceGroup is the group of the node the flow comes from, "cluster" for the pod
+	// network, or "external".
+	SourceGroup string `json:"sourceGroup" yaml:"sourceGroup"`
+	// Connections is the number of distinct connections observed.
+	Connections int `json:"connections" yaml:"connections"`
+}
+
+func (f Flow) key() string {
+	return fmt.Sprintf("%s-%d-%s-%s", f.NodeGroup, f.Port, f.Protocol, f.SourceGroup)
+}
+
+type Observer struct {
+	podUtils    utils.UtilsInterface
+	nodeToGroup map[string]string
+	ipToGroup   map[string]string
+	interval    time.Duration
+	// clusterNetworks are the CIDRs of the pod network.
+	clusterNetworks    []*net.IPNet
+	clusterNetworksRaw []string
+}
+
+type Option func(*Observer)
+
+// WithInterval sets the time between two reads of the conntrack table of a node.
+func WithInterval(d time.Duration) Option {
+	return func(o *Observer) {
+		o.interval = d
+	}
+}
+
+// WithClusterNetworks sets the CIDRs of the pod network, the cluster networks of the Network
+// config, so the connections from pods are told apart from the external ones.
+func WithClusterNetworks(cidrs []string) Option {
+	return func(o *Observer) {
+		o.clusterNetworksRaw = cidrs
+	}
+}
+
+func NewObserver(podUtils utils.UtilsInterface, customNodeGroups map[string]labels.Selector, opts ...Option) (*Observer, error) {
+	nodes, err := podUtils.ListNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeToGroup, err := mcp.ResolveNodeToGroup(nodes, customNodeGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Observer{
+		podUtils:    podUtils,
+		nodeToGroup: nodeToGroup,
+		ipToGroup:   types.BuildAddressToGroupMap(nodes, nodeToGroup),
+		interval:    DefaultInterval,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	for _, cidr := range o.clusterNetworksRaw {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster network %q: %w", cidr, err)
+		}
+		o.clusterNetworks = append(o.clusterNetworks, ipNet)
+	}
+
+	return o, nil
+}
+
+// Observe reads the conntrack table of every node through a debug pod in namespace,
+// every interval for duration, and returns the inbound flows aggregated by node group,
+// destination port, protocol and source node group. The table is read at least once.
+func (o *Observer) Observe(namespace string, duration time.Duration) ([]Flow, error) {
+	flows := map[string]*Flow{}
+	nLock := &sync.Mutex{}
+	g := new(errgroup.Group)
+	for nodeName, group := range o.nodeToGroup {
+		name, group := nodeName, group
+		g.Go(func() error {
+			nodeFlows, err := o.observeNode(name, group, namespace, duration)
+			if err != nil {
+				return fmt.Errorf("failed to observe the traffic of node %s: %w", name, err)
+			}
+
+			nLock.Lock()
+			defer nLock.Unlock()
+			for _, f := range nodeFlows {
+				if existing, ok := flows[f.key()]; ok {
+					existing.Connections += f.Connections
+					continue
+				}
+				flow := f
+				flows[f.key()] = &flow
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	res := make([]Flow, 0, len(flows))
+	for _, f := range flows {
+		res = append(res, *f)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].key() < res[j].key()
+	})
+
+	return res, nil
+}
+
+func (o *Observer) observeNode(nodeName, group, namespace string, duration time.Duration) ([]Flow, error) {
+	debugPod, err := o.podUtils.CreatePodOnNode(nodeName, namespace, consts.DefaultDebugPodImage, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		err := o.podUtils.DeletePod(debugPod)
+		if err != nil {
+			log.Warningf("failed cleaning debug pod %s: %v", debugPod.Name, err)
+		}
+	}()
+
+	err = o.podUtils.WaitForPodStatus(namespace, debugPod, corev1.PodRunning)
+	if err != nil {
+		return nil, err
+	}
+
+	localIPs, err := o.localAddresses(debugPod)
+	if err != nil {
+		return nil, err
+	}
+
+	// connections holds the flow of every distinct connection seen in the samples.
+	connections := map[string]Flow{}
+	deadline := time.Now().Add(duration)
+	for {
+		out, err := o.podUtils.RunCommandOnPod(debugPod, ConntrackCommand)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the conntrack table: %w", err)
+		}
+
+		for _, e := range parseConntrack(out) {
+			if e.dst.IsLoopback() || !localIPs[e.dst.String()] {
+				continue
+			}
+			connections[e.key()] = Flow{
+				Protocol:    e.protocol,
+				Port:        e.dport,
+				NodeGroup:   group,
+				SourceGroup: o.resolveSource(e.src, group, localIPs),
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		time.Sleep(min(o.interval, remaining))
+	}
+	log.Debugf("Observed %d connections to node %s", len(connections), nodeName)
+
+	flows := map[string]*Flow{}
+	for _, c := range connections {
+		if f, ok := flows[c.key()]; ok {
+			f.Connections++
+			continue
+		}
+		flow := c
+		flow.Connections = 1
+		flows[c.key()] = &flow
+	}
+
+	res := make([]Flow, 0, len(flows))
+	for _, f := range flows {
+		res = append(res, *f)
+	}
+
+	return res, nil
+}
+
+// resolveSource returns the node group of the node owning ip, "cluster" for the pod
+// network, or "external". Connections from the node's own addresses come from its group.
+func (o *Observer) resolveSource(ip net.IP, group string, localIPs map[string]bool) string {
+	if g, ok := o.ipToGroup[ip.String()]; ok {
+		return g
+	}
+	if localIPs[ip.String()] {
+		return group
+	}
+	for _, ipNet := range o.clusterNetworks {
+		if ipNet.Contains(ip) {
+			return ClusterSource
+		}
+	}
+
+	return consts.ExternalPeer
+}
+
+// localAddresses returns the addresses of the host the debug pod runs on.
+func (o *Observer) localAddresses(debugPod *corev1.Pod) (map[string]bool, error) {
+	type addr struct {
+		Local string `json:"local"`
+	}
+	type iface struct {
+		AddrInfo []addr `json:"addr_info"`
+	}
+
+	out, err := o.podUtils.RunCommandOnPod(debugPod, listAddressesCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the host addresses: %w", err)
+	}
+	var parsed []iface
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse the host addresses: %w", err)
+	}
+
+	ips := map[string]bool{}
+	for _, it := range parsed {
+		for _, ai := range it.AddrInfo {
+			if ip := net.ParseIP(ai.Local); ip != nil {
+				ips[ip.String()] = true
+			}
+		}
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,130 @@
+package trafficobserve
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+	mock_utils "github.com/openshift-kni/commatrix/pkg/utils/mock"
+)
+
+// conntrackTable holds, from the point of view of master-0 (10.0.0.1):
+// two connections from worker-0 to the API server, one from an external client to
+// SSH, a NodePort connection, a connection opened by master-0 and an ICMP entry.
+const conntrackTable = `ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.2 dst=10.0.0.1 sport=51234 dport=6443 src=10.0.0.1 dst=10.0.0.2 sport=6443 dport=51234 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.2 dst=10.0.0.1 sport=51236 dport=6443 src=10.0.0.1 dst=10.0.0.2 sport=6443 dport=51236 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 tcp      6 300 ESTABLISHED src=192.0.2.10 dst=10.0.0.1 sport=40000 dport=22 src=10.0.0.1 dst=192.0.2.10 sport=22 dport=40000 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 tcp      6 300 ESTABLISHED src=192.0.2.11 dst=10.0.0.1 sport=40001 dport=31000 src=10.128.0.5 dst=10.0.0.1 sport=8080 dport=40001 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 tcp      6 300 ESTABLISHED src=10.0.0.1 dst=203.0.113.7 sport=50110 dport=443 src=203.0.113.7 dst=10.0.0.1 sport=443 dport=50110 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 icmp     1 29 src=10.0.0.2 dst=10.0.0.1 type=8 code=0 id=1 src=10.0.0.1 dst=10.0.0.2 type=0 code=0 id=1 mark=0 zone=0 use=2
+`
+
+var _ = Describe("parseConntrack", func() {
+	It("keeps the original tuple of the TCP, UDP and SCTP entries", func() {
+		entries := parseConntrack([]byte(conntrackTable + `udp      17 29 src=10.0.0.2 dst=10.0.0.1 sport=41000 dport=6081 [UNREPLIED] src=10.0.0.1 dst=10.0.0.2 sport=6081 dport=41000 mark=0 use=1
+garbage
+`))
+		Expect(entries).To(HaveLen(6))
+		Expect(entries[0].protocol).To(Equal("TCP"))
+		Expect(entries[0].src.String()).To(Equal("10.0.0.2"))
+		Expect(entries[0].dst.String()).To(Equal("10.0.0.1"))
+		Expect(entries[0].dport).To(Equal(6443))
+		Expect(entries[3].dport).To(Equal(31000))
+		Expect(entries[5].protocol).To(Equal("UDP"))
+		Expect(entries[5].dport).To(Equal(6081))
+	})
+})
+
+var _ = Describe("Observer", func() {
+	It("aggregates the inbound flows by port, protocol and source group", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		mockUtils := mock_utils.NewMockUtilsInterface(ctrl)
+		nodes := []corev1.Node{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "master-0", Labels: map[string]string{"node-role.kubernetes.io/master": ""}},
+				Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}}},
+			},
+		}
+		workers := []corev1.Node{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Labels: map[string]string{"node-role.kubernetes.io/worker": ""}},
+				Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.2"}}},
+			},
+		}
+		mockUtils.EXPECT().ListNodes().Return(append(nodes, workers...), nil).AnyTimes()
+
+		pods := map[string]*corev1.Pod{}
+		for _, name := range []string{"master-0", "worker-0"} {
+			pods[name] = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "debug-" + name, Namespace: "ns"}}
+			mockUtils.EXPECT().CreatePodOnNode(name, "ns", gomock.Any(), gomock.Any()).Return(pods[name], nil)
+			mockUtils.EXPECT().WaitForPodStatus("ns", pods[name], corev1.PodRunning).Return(nil)
+			mockUtils.EXPECT().DeletePod(pods[name]).Return(nil)
+			// Both nodes hold the same table, only the entries to their own addresses count.
+			// Prometheus scrapes the node exporter from the pod network.
+			mockUtils.EXPECT().RunCommandOnPod(pods[name], ConntrackCommand).Return([]byte(conntrackTable+
+				"ipv4     2 tcp      6 300 ESTABLISHED src=10.128.2.7 dst=10.0.0.1 sport=42000 dport=9100 src=10.0.0.1 dst=10.128.2.7 sport=9100 dport=42000 [ASSURED] mark=0 zone=0 use=2\n"), nil)
+		}
+		mockUtils.EXPECT().RunCommandOnPod(pods["master-0"], listAddressesCommand).
+			Return([]byte(`[{"addr_info":[{"local":"127.0.0.1"}]},{"addr_info":[{"local":"10.0.0.1"}]}]`), nil)
+		mockUtils.EXPECT().RunCommandOnPod(pods["worker-0"], listAddressesCommand).
+			Return([]byte(`[{"addr_info":[{"local":"10.0.0.2"}]}]`), nil)
+
+		observer, err := NewObserver(mockUtils, nil, WithClusterNetworks([]string{"10.128.0.0/14"}))
+		Expect(err).ToNot(HaveOccurred())
+
+		flows, err := observer.Observe("ns", 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(flows).To(Equal([]Flow{
+			{Protocol: "TCP", Port: 22, NodeGroup: "master", SourceGroup: "external", Connections: 1},
+			{Protocol: "TCP", Port: 31000, NodeGroup: "master", SourceGroup: "external", Connections: 1},
+			{Protocol: "TCP", Port: 6443, NodeGroup: "master", SourceGroup: "worker", Connections: 2},
+			{Protocol: "TCP", Port: 9100, NodeGroup: "master", SourceGroup: "cluster", Connections: 1},
+		}))
+	})
+
+	It("rejects invalid cluster networks", func() {
+		mockUtils := mock_utils.NewMockUtilsInterface(gomock.NewController(GinkgoT()))
+		mockUtils.EXPECT().ListNodes().Return(nil, nil)
+
+		_, err := NewObserver(mockUtils, nil, WithClusterNetworks([]string{"pods"}))
+		Expect(err).To(MatchError(ContainSubstring("invalid cluster network")))
+	})
+})
+
+var _ = Describe("Classify", func() {
+	matrix := &types.ComMatrix{
+		Ports: []types.ComDetails{
+			{Direction: "Ingress", Protocol: "TCP", Port: 6443, NodeGroup: "master", Service: "apiserver"},
+			{Direction: "Ingress", Protocol: "TCP", Port: 9001, NodeGroup: "master", Optional: true},
+			{Direction: "Egress", Protocol: "TCP", Port: 443, NodeGroup: "master", Peer: "external"},
+		},
+		DynamicRanges: types.DynamicRangeList{{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767}},
+	}
+	flows := []Flow{
+		{Protocol: "TCP", Port: 6443, NodeGroup: "master", SourceGroup: "worker", Connections: 2},
+		{Protocol: "TCP", Port: 6443, NodeGroup: "master", SourceGroup: "master", Connections: 1},
+		{Protocol: "TCP", Port: 22, NodeGroup: "master", SourceGroup: "external", Connections: 1},
+		{Protocol: "TCP", Port: 31000, NodeGroup: "master", SourceGroup: "external", Connections: 1},
+	}
+
+	It("classifies the ingress entries and the observed flows", func() {
+		report := Classify(matrix, flows)
+		Expect(report.Observations).To(Equal([]Observation{
+			{Status: StatusNotInMatrix, Protocol: "TCP", Port: 22, NodeGroup: "master", Sources: GroupList{"external"}, Connections: 1},
+			{Status: StatusUsed, Protocol: "TCP", Port: 6443, NodeGroup: "master", Service: "apiserver", Sources: GroupList{"master", "worker"}, Connections: 3},
+			{Status: StatusUnused, Protocol: "TCP", Port: 9001, NodeGroup: "master", Optional: true, Sources: GroupList{}},
+		}))
+		Expect(report.Summary()).To(Equal("1 used, 1 unused (1 optional), 1 not in matrix"))
+	})
+
+	It("writes the sources as a space separated list in CSV", func() {
+		out, err := Classify(matrix, flows).ToCSV()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(out)).To(ContainSubstring("Status,Protocol,Port,NodeGroup,Namespace,Service,Optional,Sources,Connections\n"))
+		Expect(string(out)).To(ContainSubstring("used,TCP,6443,master,,apiserver,false,master worker,3\n"))
+	})
+})
@@ -0,0 +1,181 @@
+package trafficobserve
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gocarina/gocsv"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/types"
+)
+
+// Statuses of the observations.
+const (
+	// StatusUsed marks matrix entries that received traffic.
+	StatusUsed = "used"
+	// StatusUnused marks matrix entries that received no traffic.
+	StatusUnused = "unused"
+	// StatusNotInMatrix marks observed flows that no matrix entry or range allows.
+	StatusNotInMatrix = "not-in-matrix"
+)
+
+// GroupList is a list of node groups, written as a space separated list in CSV.
+type GroupList []string
+
+func (l GroupList) MarshalCSV() (string, error) {
+	return strings.Join(l, " "), nil
+}
+
+func (l *GroupList) UnmarshalCSV(s string) error {
+	*l = strings.Fields(s)
+	return nil
+}
+
+// Observation is a matrix entry or an observed flow, and the traffic it received.
+type Observation struct {
+	Status    string `json:"status" yaml:"status" csv:"Status"`
+	Protocol  string `json:"protocol" yaml:"protocol" csv:"Protocol"`
+	Port      int    `json:"port" yaml:"port" csv:"Port"`
+	NodeGroup string `json:"nodeGroup" yaml:"nodeGroup" csv:"NodeGroup"`
+	Namespace string `json:"namespace" yaml:"namespace" csv:"Namespace"`
+	Service   string `json:"service" yaml:"service" csv:"Service"`
+	Optional  bool   `json:"optional" yaml:"optional" csv:"Optional"`
+	// Sources are the node groups the traffic came from.
+	Sources     GroupList `json:"sources" yaml:"sources" csv:"Sources"`
+	Connections int       `json:"connections" yaml:"connections" csv:"Connections"`
+}
+
+// Classification classifies the ingress entries of a matrix by the traffic observed on the nodes.
+type Classification struct {
+	Observations []Observation `json:"observations" yaml:"observations"`
+}
+
+// Classify marks every ingress entry of m as used or unused by flows, and reports the
+// flows that neither an entry nor a dynamic range of m allows as not in the matrix.
+// Flows within a dynamic range do not mark any entry as used.
+func Classify(m *types.ComMatrix, flows []Flow) *Classification {
+	byEntry := map[string][]Flow{}
+	for _, f := range flows {
+		cd := types.ComDetails{NodeGroup: f.NodeGroup, Port: f.Port, Protocol: f.Protocol}
+		byEntry[cd.Key()] = append(byEntry[cd.Key()], f)
+	}
+
+	report := &Classification{Observations: []Observation{}}
+	classified := map[string]bool{}
+	for _, cd := range m.Ports {
+		if cd.IsEgress() || classified[cd.Key()] {
+			continue
+		}
+		classified[cd.Key()] = true
+
+		obs := Observation{
+			Status:    StatusUnused,
+			Protocol:  cd.Protocol,
+			Port:      cd.Port,
+			NodeGroup: cd.NodeGroup,
+			Namespace: cd.Namespace,
+			Service:   cd.Service,
+			Optional:  cd.Optional,
+			Sources:   GroupList{},
+		}
+		if entryFlows := byEntry[cd.Key()]; len(entryFlows) > 0 {
+			obs.Status = StatusUsed
+			obs.Sources, obs.Connections = aggregate(entryFlows)
+		}
+		report.Observations = append(report.Observations, obs)
+	}
+
+	for key, entryFlows := range byEntry {
+		f := entryFlows[0]
+		if classified[key] || inDynamicRange(m, f) {
+			continue
+		}
+		obs := Observation{Status: StatusNotInMatrix, Protocol: f.Protocol, Port: f.Port, NodeGroup: f.NodeGroup}
+		obs.Sources, obs.Connections = aggregate(entryFlows)
+		report.Observations = append(report.Observations, obs)
+	}
+
+	slices.SortFunc(report.Observations, func(a, b Observation) int {
+		return cmp.Or(
+			cmp.Compare(a.NodeGroup, b.NodeGroup),
+			cmp.Compare(a.Protocol, b.Protocol),
+			cmp.Compare(a.Port, b.Port),
+		)
+	})
+
+	return report
+}
+
+func aggregate(flows []Flow) (GroupList, int) {
+	sources := GroupList{}
+	connections := 0
+	for _, f := range flows {
+		if !slices.Contains(sources, f.SourceGroup) {
+			sources = append(sources, f.SourceGroup)
+		}
+		connections += f.Connections
+	}
+	slices.Sort(sources)
+
+	return sources, connections
+}
+
+func inDynamicRange(m *types.ComMatrix, f Flow) bool {
+	for _, dr := range m.DynamicRanges {
+		if !dr.IsEgress() && dr.Protocol == f.Protocol && f.Port >= dr.MinPort && f.Port <= dr.MaxPort {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Count returns the number of observations with the given status.
+func (r *Classification) Count(status string) int {
+	n := 0
+	for _, o := range r.Observations {
+		if o.Status == status {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Summary returns a one line summary of the report.
+func (r *Classification) Summary() string {
+	unusedOptional := 0
+	for _, o := range r.Observations {
+		if o.Status == StatusUnused && o.Optional {
+			unusedOptional++
+		}
+	}
+
+	return fmt.Sprintf("%d used, %d unused (%d optional), %d not in matrix",
+		r.Count(StatusUsed), r.Count(StatusUnused), unusedOptional, r.Count(StatusNotInMatrix))
+}
+
+func (r *Classification) ToCSV() ([]byte, error) {
+	out := &bytes.Buffer{}
+	csvwriter := csv.NewWriter(out)
+	if err := gocsv.MarshalCSV(&r.Observations, csvwriter); err != nil {
+		return nil, err
+	}
+	csvwriter.Flush()
+
+	return out.Bytes(), nil
+}
+
+func (r *Classification) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "    ")
+}
+
+func (r *Classification) ToYAML() ([]byte, error) {
+	return yaml.Marshal(r)
+}
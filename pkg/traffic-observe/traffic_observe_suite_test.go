@@ -0,0 +1,13 @@
+package trafficobserve
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTrafficObserve(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "trafficobserve Suite")
+}
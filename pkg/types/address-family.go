@@ -0,0 +1,128 @@
+package types
+
+import (
+	"net"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	AddressFamilyIPv4 = "IPv4"
+	AddressFamilyIPv6 = "IPv6"
+)
+
+// AddressFamilies are the address families of the entries of a single family.
+var AddressFamilies = []string{AddressFamilyIPv4, AddressFamilyIPv6}
+
+// AddressFamilyOf returns the address family of ip.
+func AddressFamilyOf(ip net.IP) string {
+	if ip.To4() != nil {
+		return AddressFamilyIPv4
+	}
+	return AddressFamilyIPv6
+}
+
+// MergeAddressFamilies returns the address family covering the flows of both families,
+// which is empty, meaning both, unless they are the same.
+func MergeAddressFamilies(a, b string) string {
+	if strings.EqualFold(a, b) {
+		return a
+	}
+	return ""
+}
+
+// allowsAddressFamily returns true if the flows of the entry use the given family.
+func (cd ComDetails) allowsAddressFamily(family string) bool {
+	return cd.AddressFamily == "" || strings.EqualFold(cd.AddressFamily, family)
+}
+
+// MachineNetworks returns the machine networks of the platforms that report them.
+func MachineNetworks(status *configv1.PlatformStatus) []string {
+	if status == nil {
+		return nil
+	}
+
+	var cidrs []configv1.CIDR
+	switch {
+	case status.BareMetal != nil:
+		cidrs = status.BareMetal.MachineNetworks
+	case status.OpenStack != nil:
+		cidrs = status.OpenStack.MachineNetworks
+	case status.VSphere != nil:
+		cidrs = status.VSphere.MachineNetworks
+	}
+
+	res := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		res = append(res, string(cidr))
+	}
+	return res
+}
+
+// BuildNodeGroupAddressFamilies returns the address family of every node group from the
+// internal addresses of its nodes, empty for the dual-stack groups. The groups without
+// internal addresses use the families of the machine networks.
+func BuildNodeGroupAddressFamilies(nodes []corev1.Node, nodeToGroup map[string]string, machineNetworks []string) map[string]string {
+	families := map[string]map[string]bool{}
+	for _, node := range nodes {
+		group, ok := nodeToGroup[node.Name]
+		if !ok {
+			continue
+		}
+		if families[group] == nil {
+			families[group] = map[string]bool{}
+		}
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != corev1.NodeInternalIP {
+				continue
+			}
+			if ip := net.ParseIP(addr.Address); ip != nil {
+				families[group][AddressFamilyOf(ip)] = true
+			}
+		}
+	}
+
+	machineFamilies := map[string]bool{}
+	for _, cidr := range machineNetworks {
+		if ip, _, err := net.ParseCIDR(cidr); err == nil {
+			machineFamilies[AddressFamilyOf(ip)] = true
+		}
+	}
+
+	res := map[string]string{}
+	for group, groupFamilies := range families {
+		if len(groupFamilies) == 0 {
+			groupFamilies = machineFamilies
+		}
+		res[group] = ""
+		if len(groupFamilies) == 1 {
+			for family := range groupFamilies {
+				res[group] = family
+			}
+		}
+	}
+
+	return res
+}
+
+// ApplyNodeGroupAddressFamilies limits the entries of the single-stack node groups to the
+// family of the group. The entries of the other family, like DHCPv6 on an IPv4 group,
+// are removed.
+func (m *ComMatrix) ApplyNodeGroupAddressFamilies(groupFamilies map[string]string) {
+	res := make([]ComDetails, 0, len(m.Ports))
+	for _, cd := range m.Ports {
+		family := groupFamilies[cd.NodeGroup]
+		if family == "" {
+			res = append(res, cd)
+			continue
+		}
+		if !cd.allowsAddressFamily(family) {
+			continue
+		}
+		cd.AddressFamily = family
+		res = append(res, cd)
+	}
+	m.Ports = res
+}
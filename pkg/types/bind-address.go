@@ -0,0 +1,64 @@
+package types
+
+import (
+	"net"
+	"slices"
+)
+
+// Scopes of the addresses the listening sockets of a flow are bound to.
+const (
+	// BindScopeWildcard is the scope of the sockets bound to all the addresses of the node.
+	BindScopeWildcard = "Wildcard"
+	// BindScopeVIP is the scope of the sockets bound to an address that is not an address
+	// of the node object, like a keepalived virtual IP or a secondary address.
+	BindScopeVIP = "VIP"
+	// BindScopeNodeIP is the scope of the sockets bound to an internal or external address
+	// of the node.
+	BindScopeNodeIP = "NodeIP"
+	// BindScopeLinkLocal is the scope of the sockets bound to a link-local address.
+	BindScopeLinkLocal = "LinkLocal"
+)
+
+// BindScopes are the scopes of the bound addresses, from the widest to the narrowest.
+var BindScopes = []string{BindScopeWildcard, BindScopeVIP, BindScopeNodeIP, BindScopeLinkLocal}
+
+// ClassifyBindAddress returns the scope of ip, an address a socket is bound to, relative
+// to the internal and external addresses of its node.
+func ClassifyBindAddress(ip net.IP, nodeAddresses map[string]bool) string {
+	switch {
+	case ip.IsUnspecified():
+		return BindScopeWildcard
+	case nodeAddresses[ip.String()]:
+		return BindScopeNodeIP
+	case ip.IsLinkLocalUnicast():
+		return BindScopeLinkLocal
+	}
+	return BindScopeVIP
+}
+
+// mergeBindAddresses merges the bound addresses of other, the same flow found on another
+// node or by another source, into the entry. Once bound to the wildcard, the flow is
+// reachable on every address. Otherwise the addresses are merged and the entry keeps
+// the widest scope.
+func (cd *ComDetails) mergeBindAddresses(other ComDetails) {
+	switch {
+	case other.BindScope == "":
+	case cd.BindScope == "":
+		cd.BindScope, cd.BindAddresses = other.BindScope, other.BindAddresses
+	case cd.BindScope == BindScopeWildcard || other.BindScope == BindScopeWildcard:
+		cd.BindScope, cd.BindAddresses = BindScopeWildcard, nil
+	default:
+		addresses := slices.Concat(cd.BindAddresses, other.BindAddresses)
+		slices.Sort(addresses)
+		cd.BindAddresses = slices.Compact(addresses)
+		if slices.Index(BindScopes, other.BindScope) < slices.Index(BindScopes, cd.BindScope) {
+			cd.BindScope = other.BindScope
+		}
+	}
+}
+
+// hasBindAddresses returns true if the listening sockets of the entry are bound to
+// specific addresses.
+func (cd ComDetails) hasBindAddresses() bool {
+	return cd.BindScope != BindScopeWildcard && len(cd.BindAddresses) > 0
+}
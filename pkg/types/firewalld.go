@@ -0,0 +1,192 @@
+package types
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// firewalldPort is a port or range of a firewalld zone, allowed from every source or from
+// the sources of a rich rule.
+type firewalldPort struct {
+	protocol string
+	port     string
+	minPort  int
+	family   string
+	source   string
+	comments []string
+}
+
+func (p firewalldPort) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s", p.family, p.source, p.protocol, p.port)
+}
+
+// rank orders the ports allowed from every source, the ports of a single address family and
+// the ports restricted to a source.
+func (p firewalldPort) rank() int {
+	switch {
+	case p.source != "":
+		return 2
+	case p.family != "":
+		return 1
+	}
+	return 0
+}
+
+// ToFirewalld returns a firewalld zone allowing the ingress flows of the matrix, described
+// with the node group of the matrix. Every port is commented with the services using it.
+// The flows of a single address family or restricted to sources are allowed by rich rules,
+// and so is ICMP, as the DROP target of the zone would drop it.
+// The interfaces and bound addresses of the entries are not part of the zone, which applies
+// to the interfaces it is bound to.
+func (m *ComMatrix) ToFirewalld(nodeGroup string) ([]byte, error) {
+	ports, err := m.firewalldPorts()
+	if err != nil {
+		return nil, err
+	}
+
+	var ingressEntries, ingressRanges int
+	for _, cd := range m.Ports {
+		if !cd.IsEgress() {
+			ingressEntries++
+		}
+	}
+	for _, dr := range m.DynamicRanges {
+		if !dr.IsEgress() {
+			ingressRanges++
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n")
+	out.WriteString("<zone target=\"DROP\">\n")
+	fmt.Fprintf(&out, "  <short>%s</short>\n", xmlText(fmt.Sprintf("OpenShift %s", nodeGroup)))
+	fmt.Fprintf(&out, "  <description>%s</description>\n", xmlText(fmt.Sprintf(
+		"Ingress flows of the %s node group from the OpenShift communication matrix: %d entries and %d dynamic ranges.",
+		nodeGroup, ingressEntries, ingressRanges)))
+	// The DROP target also drops ICMP, which the nftables rules allow.
+	out.WriteString("  <!-- ICMP -->\n")
+	for _, protocol := range []string{"icmp", "ipv6-icmp"} {
+		fmt.Fprintf(&out, "  <rule>\n    <protocol value=\"%s\"/>\n    <accept/>\n  </rule>\n", protocol)
+	}
+
+	for _, p := range ports {
+		if len(p.comments) > 0 {
+			fmt.Fprintf(&out, "  <!-- %s -->\n", xmlComment(strings.Join(p.comments, ", ")))
+		}
+		portElement := fmt.Sprintf("<port protocol=\"%s\" port=\"%s\"/>", xmlText(strings.ToLower(p.protocol)), xmlText(p.port))
+		if p.family == "" && p.source == "" {
+			fmt.Fprintf(&out, "  %s\n", portElement)
+			continue
+		}
+
+		out.WriteString("  <rule")
+		if p.family != "" {
+			fmt.Fprintf(&out, " family=\"%s\"", strings.ToLower(p.family))
+		}
+		out.WriteString(">\n")
+		if p.source != "" {
+			fmt.Fprintf(&out, "    <source address=\"%s\"/>\n", xmlText(p.source))
+		}
+		fmt.Fprintf(&out, "    %s\n", portElement)
+		out.WriteString("    <accept/>\n")
+		out.WriteString("  </rule>\n")
+	}
+	out.WriteString("</zone>\n")
+
+	return out.Bytes(), nil
+}
+
+// firewalldPorts returns the ports and ranges of the ingress entries and dynamic ranges: the
+// ports allowed from every source first, then the ports of a single address family and the
+// ports restricted to sources, one per source. Entries of other protocols are left out.
+func (m *ComMatrix) firewalldPorts() ([]firewalldPort, error) {
+	index := map[string]int{}
+	var res []firewalldPort
+	add := func(p firewalldPort, comment string) {
+		i, ok := index[p.key()]
+		if !ok {
+			i = len(res)
+			index[p.key()] = i
+			res = append(res, p)
+		}
+		if comment != "" && !slices.Contains(res[i].comments, comment) {
+			res[i].comments = append(res[i].comments, comment)
+		}
+	}
+	addSources := func(p firewalldPort, sources CIDRList, comment string) error {
+		if len(sources) == 0 {
+			add(p, comment)
+			return nil
+		}
+		ipv4, ipv6, err := sources.ByFamily()
+		if err != nil {
+			return err
+		}
+		for _, s := range ipv4 {
+			if p.family != AddressFamilyIPv6 {
+				add(firewalldPort{protocol: p.protocol, port: p.port, minPort: p.minPort, family: AddressFamilyIPv4, source: s}, comment)
+			}
+		}
+		for _, s := range ipv6 {
+			if p.family != AddressFamilyIPv4 {
+				add(firewalldPort{protocol: p.protocol, port: p.port, minPort: p.minPort, family: AddressFamilyIPv6, source: s}, comment)
+			}
+		}
+		return nil
+	}
+
+	for _, cd := range m.Ports {
+		if cd.IsEgress() || !slices.Contains(NFTProtocols, cd.Protocol) {
+			continue
+		}
+		p := firewalldPort{protocol: cd.Protocol, port: fmt.Sprint(cd.Port), minPort: cd.Port, family: cd.AddressFamily}
+		if err := addSources(p, cd.SourceCIDRs, cd.nftComment()); err != nil {
+			return nil, err
+		}
+	}
+	for _, dr := range m.DynamicRanges {
+		if dr.IsEgress() || !slices.Contains(NFTProtocols, dr.Protocol) {
+			continue
+		}
+		p := firewalldPort{protocol: dr.Protocol, port: dr.PortRangeString(), minPort: dr.MinPort}
+		if err := addSources(p, dr.SourceCIDRs, dr.Description); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.SortStableFunc(res, func(a, b firewalldPort) int {
+		if c := cmp.Compare(a.rank(), b.rank()); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.family, b.family); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.source, b.source); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(slices.Index(NFTProtocols, a.protocol), slices.Index(NFTProtocols, b.protocol)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.minPort, b.minPort)
+	})
+	return res, nil
+}
+
+// xmlText escapes s for an XML attribute or element.
+func xmlText(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// xmlComment returns s without the double hyphens XML comments cannot contain.
+func xmlComment(s string) string {
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "-")
+	}
+	return strings.TrimSuffix(s, "-")
+}
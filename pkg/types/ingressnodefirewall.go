@@ -0,0 +1,408 @@
+package types
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift-kni/commatrix/pkg/consts"
+)
+
+const (
+	ingressNodeFirewallAPIVersion = "ingressnodefirewall.openshift.io/v1alpha1"
+	ingressNodeFirewallKind       = "IngressNodeFirewall"
+	// ingressNodeFirewallNamePrefix names the CRs of a node group after the group, and after
+	// their interface for the interfaces other than the default one.
+	ingressNodeFirewallNamePrefix = "commatrix"
+	// ingressNodeFirewallMaxRules is the number of rules the eBPF program of the operator
+	// holds per source CIDR.
+	ingressNodeFirewallMaxRules = 100
+	// ingressNodeFirewallDefaultInterface is the interface the entries without an interface
+	// are enforced on, the node network bridge of OVN-Kubernetes.
+	ingressNodeFirewallDefaultInterface = primaryInterface
+	// The local port range of the nodes is left out of the deny rules: the eBPF program is
+	// stateless and would drop the replies to the connections the nodes open.
+	ingressNodeFirewallLocalPortMin = 32768
+	ingressNodeFirewallLocalPortMax = 60999
+)
+
+// ingressNodeFirewallAllSources are the sources of the entries not restricted to sources,
+// by address family.
+var ingressNodeFirewallAllSources = map[string]string{
+	AddressFamilyIPv4: "0.0.0.0/0",
+	AddressFamilyIPv6: "::/0",
+}
+
+// The IngressNodeFirewall CR of the Ingress Node Firewall Operator written by
+// ToIngressNodeFirewall.
+type (
+	ingressNodeFirewall struct {
+		APIVersion string                  `json:"apiVersion"`
+		Kind       string                  `json:"kind"`
+		Metadata   ingressNodeFirewallMeta `json:"metadata"`
+		Spec       ingressNodeFirewallSpec `json:"spec"`
+	}
+	ingressNodeFirewallMeta struct {
+		Name string `json:"name"`
+	}
+	ingressNodeFirewallSpec struct {
+		NodeSelector *metav1.LabelSelector      `json:"nodeSelector"`
+		Interfaces   []string                   `json:"interfaces"`
+		Ingress      []ingressNodeFirewallRules `json:"ingress"`
+	}
+	ingressNodeFirewallRules struct {
+		SourceCIDRs []string                  `json:"sourceCIDRs"`
+		Rules       []ingressNodeFirewallRule `json:"rules"`
+	}
+	ingressNodeFirewallRule struct {
+		Order          int                               `json:"order"`
+		ProtocolConfig ingressNodeFirewallProtocolConfig `json:"protocolConfig"`
+		Action         string                            `json:"action"`
+	}
+	ingressNodeFirewallProtocolConfig struct {
+		Protocol string                    `json:"protocol"`
+		TCP      *ingressNodeFirewallPorts `json:"tcp,omitempty"`
+		UDP      *ingressNodeFirewallPorts `json:"udp,omitempty"`
+		SCTP     *ingressNodeFirewallPorts `json:"sctp,omitempty"`
+	}
+	ingressNodeFirewallPorts struct {
+		Ports string `json:"ports"`
+	}
+)
+
+// ingressNodeFirewallPortRange is a port range of a protocol allowed by a rule.
+type ingressNodeFirewallPortRange struct {
+	protocol string
+	minPort  int
+	maxPort  int
+}
+
+// ingressNodeFirewallFlow is an ingress entry or dynamic range with the CIDRs it is allowed
+// from.
+type ingressNodeFirewallFlow struct {
+	ingressNodeFirewallPortRange
+	sources []*net.IPNet
+}
+
+// WithNodeGroupSelectors sets the label selectors of the custom node groups, used as the
+// nodeSelector of their IngressNodeFirewall CRs. The CRs of the other node groups select
+// the nodes with the role of the group.
+func WithNodeGroupSelectors(selectors map[string]labels.Selector) NFTOption {
+	return func(o *nftOptions) {
+		o.nodeGroupSelectors = selectors
+	}
+}
+
+// nodeGroupSelector returns the selector of the nodes of the node group.
+func (o *nftOptions) nodeGroupSelector(nodeGroup string) labels.Selector {
+	if selector, ok := o.nodeGroupSelectors[nodeGroup]; ok {
+		return selector
+	}
+	return labels.SelectorFromSet(labels.Set{consts.RoleLabel + nodeGroup: ""})
+}
+
+// ToIngressNodeFirewall returns the IngressNodeFirewall CRs of the Ingress Node Firewall
+// Operator allowing the ingress flows of the matrix on the nodes of the node group, see
+// WithNodeGroupSelectors. Every interface of the entries gets a CR allowing its entries and
+// the entries without an interface, which are enforced on br-ex. The CRs are separated by
+// YAML document markers.
+//
+// The operator evaluates the rules of the most specific source CIDR of a packet, so the
+// rules of every source allow the flows of the less specific sources as well, then deny
+// the other TCP, UDP and SCTP ports except the local port range of the nodes.
+func (m *ComMatrix) ToIngressNodeFirewall(nodeGroup string, opts ...NFTOption) ([]byte, error) {
+	selector, err := metav1.ParseToLabelSelector(newNFTOptions(opts).nodeGroupSelector(nodeGroup).String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid node selector of node group %s: %w", nodeGroup, err)
+	}
+
+	var docs [][]byte
+	names := map[string]string{}
+	for _, iface := range m.ingressNodeFirewallInterfaces() {
+		name := ingressNodeFirewallName(nodeGroup, iface)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("interfaces %s and %s of node group %s would share the IngressNodeFirewall CR name %s", other, iface, nodeGroup, name)
+		}
+		names[name] = iface
+
+		cr, err := m.onInterface(iface).ingressNodeFirewall(nodeGroup, name, iface, selector)
+		if err != nil {
+			return nil, err
+		}
+		out, err := yaml.Marshal(cr)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, out)
+	}
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// ingressNodeFirewall returns the CR allowing the ingress flows of the matrix on the interface.
+func (m *ComMatrix) ingressNodeFirewall(nodeGroup, name, iface string, selector *metav1.LabelSelector) (*ingressNodeFirewall, error) {
+	flows, err := m.ingressNodeFirewallFlows()
+	if err != nil {
+		return nil, err
+	}
+
+	var ingress []ingressNodeFirewallRules
+	rulesIndex := map[string]int{}
+	for _, source := range ingressNodeFirewallSources(flows) {
+		ranges := ingressNodeFirewallRanges(flows, source)
+		key := fmt.Sprint(ranges)
+		if i, ok := rulesIndex[key]; ok {
+			ingress[i].SourceCIDRs = append(ingress[i].SourceCIDRs, source.String())
+			continue
+		}
+
+		rules := ingressNodeFirewallRulesOf(ranges)
+		if len(rules) > ingressNodeFirewallMaxRules {
+			return nil, fmt.Errorf("node group %s needs %d rules for source %s, the Ingress Node Firewall holds at most %d",
+				nodeGroup, len(rules), source, ingressNodeFirewallMaxRules)
+		}
+		rulesIndex[key] = len(ingress)
+		ingress = append(ingress, ingressNodeFirewallRules{SourceCIDRs: []string{source.String()}, Rules: rules})
+	}
+
+	return &ingressNodeFirewall{
+		APIVersion: ingressNodeFirewallAPIVersion,
+		Kind:       ingressNodeFirewallKind,
+		Metadata:   ingressNodeFirewallMeta{Name: name},
+		Spec: ingressNodeFirewallSpec{
+			NodeSelector: selector,
+			Interfaces:   []string{iface},
+			Ingress:      ingress,
+		},
+	}, nil
+}
+
+// ingressNodeFirewallFlows returns the TCP, UDP and SCTP ingress entries and dynamic ranges
+// with their sources, every source of their address family when they are not restricted.
+func (m *ComMatrix) ingressNodeFirewallFlows() ([]ingressNodeFirewallFlow, error) {
+	var res []ingressNodeFirewallFlow
+	add := func(protocol string, minPort, maxPort int, family string, sources CIDRList) error {
+		if !slices.Contains(NFTProtocols, protocol) {
+			return nil
+		}
+		if len(sources) == 0 {
+			for _, f := range AddressFamilies {
+				if family == "" || family == f {
+					sources = append(sources, ingressNodeFirewallAllSources[f])
+				}
+			}
+		}
+
+		f := ingressNodeFirewallFlow{ingressNodeFirewallPortRange: ingressNodeFirewallPortRange{protocol: protocol, minPort: minPort, maxPort: maxPort}}
+		for _, s := range sources {
+			ipNet, err := ingressNodeFirewallCIDR(s)
+			if err != nil {
+				return err
+			}
+			if family == "" || family == ipNetFamily(ipNet) {
+				f.sources = append(f.sources, ipNet)
+			}
+		}
+		res = append(res, f)
+		return nil
+	}
+
+	for _, cd := range m.Ports {
+		if cd.IsEgress() {
+			continue
+		}
+		if err := add(cd.Protocol, cd.Port, cd.Port, cd.AddressFamily, cd.SourceCIDRs); err != nil {
+			return nil, err
+		}
+	}
+	for _, dr := range m.DynamicRanges {
+		if dr.IsEgress() {
+			continue
+		}
+		if err := add(dr.Protocol, dr.MinPort, dr.MaxPort, "", dr.SourceCIDRs); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// ingressNodeFirewallSources returns the sources of the rules: every source of both address
+// families first, then the sources of the flows by address.
+func ingressNodeFirewallSources(flows []ingressNodeFirewallFlow) []*net.IPNet {
+	var res []*net.IPNet
+	seen := map[string]bool{}
+	add := func(ipNet *net.IPNet) {
+		if !seen[ipNet.String()] {
+			seen[ipNet.String()] = true
+			res = append(res, ipNet)
+		}
+	}
+
+	for _, f := range AddressFamilies {
+		ipNet, _ := ingressNodeFirewallCIDR(ingressNodeFirewallAllSources[f])
+		add(ipNet)
+	}
+	var sources []*net.IPNet
+	for _, f := range flows {
+		sources = append(sources, f.sources...)
+	}
+	slices.SortFunc(sources, func(a, b *net.IPNet) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+	for _, s := range sources {
+		add(s)
+	}
+	return res
+}
+
+// ingressNodeFirewallRanges returns the merged port ranges of the flows allowed from the
+// source, sorted by protocol and port.
+func ingressNodeFirewallRanges(flows []ingressNodeFirewallFlow, source *net.IPNet) []ingressNodeFirewallPortRange {
+	var ranges []ingressNodeFirewallPortRange
+	for _, f := range flows {
+		if slices.ContainsFunc(f.sources, func(s *net.IPNet) bool { return ipNetContains(s, source) }) {
+			ranges = append(ranges, f.ingressNodeFirewallPortRange)
+		}
+	}
+	slices.SortFunc(ranges, func(a, b ingressNodeFirewallPortRange) int {
+		if c := cmp.Compare(slices.Index(NFTProtocols, a.protocol), slices.Index(NFTProtocols, b.protocol)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.minPort, b.minPort)
+	})
+
+	var res []ingressNodeFirewallPortRange
+	for _, r := range ranges {
+		if last := len(res) - 1; last >= 0 && res[last].protocol == r.protocol && r.minPort <= res[last].maxPort+1 {
+			res[last].maxPort = max(res[last].maxPort, r.maxPort)
+			continue
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// ingressNodeFirewallRulesOf returns the rules allowing the port ranges, then denying the
+// other ports of every protocol except the local port range of the nodes.
+func ingressNodeFirewallRulesOf(ranges []ingressNodeFirewallPortRange) []ingressNodeFirewallRule {
+	var res []ingressNodeFirewallRule
+	add := func(r ingressNodeFirewallPortRange, action string) {
+		ports := &ingressNodeFirewallPorts{Ports: fmt.Sprint(r.minPort)}
+		if r.maxPort != r.minPort {
+			ports.Ports = fmt.Sprintf("%d-%d", r.minPort, r.maxPort)
+		}
+		config := ingressNodeFirewallProtocolConfig{Protocol: r.protocol}
+		switch r.protocol {
+		case ProtocolTCP:
+			config.TCP = ports
+		case ProtocolUDP:
+			config.UDP = ports
+		case ProtocolSCTP:
+			config.SCTP = ports
+		}
+		res = append(res, ingressNodeFirewallRule{Order: len(res) + 1, ProtocolConfig: config, Action: action})
+	}
+
+	for _, r := range ranges {
+		add(r, "Allow")
+	}
+	for _, protocol := range NFTProtocols {
+		add(ingressNodeFirewallPortRange{protocol: protocol, minPort: 1, maxPort: ingressNodeFirewallLocalPortMin - 1}, "Deny")
+		add(ingressNodeFirewallPortRange{protocol: protocol, minPort: ingressNodeFirewallLocalPortMax + 1, maxPort: 65535}, "Deny")
+	}
+	return res
+}
+
+// ingressNodeFirewallInterfaces returns the interfaces of the ingress entries, with the
+// default interface when an ingress entry or dynamic range has no interface or the matrix
+// has no ingress flow.
+func (m *ComMatrix) ingressNodeFirewallInterfaces() []string {
+	var res []string
+	add := func(iface string) {
+		if iface == "" {
+			iface = ingressNodeFirewallDefaultInterface
+		}
+		if !slices.Contains(res, iface) {
+			res = append(res, iface)
+		}
+	}
+	for _, cd := range m.Ports {
+		if !cd.IsEgress() {
+			add(cd.Interface)
+		}
+	}
+	for _, dr := range m.DynamicRanges {
+		if !dr.IsEgress() {
+			add("")
+		}
+	}
+	if len(res) == 0 {
+		return []string{ingressNodeFirewallDefaultInterface}
+	}
+	slices.Sort(res)
+	return res
+}
+
+// onInterface returns the matrix of the flows allowed on the interface: the ingress entries
+// of the interface, the ones without an interface and the dynamic ranges.
+func (m *ComMatrix) onInterface(iface string) *ComMatrix {
+	res := &ComMatrix{DynamicRanges: m.DynamicRanges}
+	for _, cd := range m.Ports {
+		if cd.Interface == "" || cd.Interface == iface {
+			res.Ports = append(res.Ports, cd)
+		}
+	}
+	return res
+}
+
+// ingressNodeFirewallName returns the name of the CR of the node group on the interface. The
+// characters of the interface a resource name does not accept are replaced by dashes.
+func ingressNodeFirewallName(nodeGroup, iface string) string {
+	name := fmt.Sprintf("%s-%s", ingressNodeFirewallNamePrefix, nodeGroup)
+	if iface == ingressNodeFirewallDefaultInterface {
+		return name
+	}
+	return name + "-" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(iface))
+}
+
+// ingressNodeFirewallCIDR returns the CIDR of a source, the CIDR of a single address for an
+// address.
+func ingressNodeFirewallCIDR(source string) (*net.IPNet, error) {
+	if ip := net.ParseIP(source); ip != nil {
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source %q, must be an address or a CIDR", source)
+	}
+	return ipNet, nil
+}
+
+// ipNetContains returns true if outer holds every address of inner.
+func ipNetContains(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+func ipNetFamily(ipNet *net.IPNet) string {
+	if ipNet.IP.To4() != nil {
+		return AddressFamilyIPv4
+	}
+	return AddressFamilyIPv6
+}
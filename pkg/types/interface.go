@@ -0,0 +1,112 @@
+package types
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// primaryInterface is the interface of the primary network of the nodes, the node network
+// bridge of OVN-Kubernetes.
+const primaryInterface = "br-ex"
+
+// interfaceNameMaxLen is the longest name of a network interface, IFNAMSIZ without the
+// terminating null byte.
+const interfaceNameMaxLen = 15
+
+// ValidateInterfaceName returns an error if name is not a valid network interface name.
+func ValidateInterfaceName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("empty interface name")
+	case len(name) > interfaceNameMaxLen:
+		return fmt.Errorf("interface name %q is longer than %d characters", name, interfaceNameMaxLen)
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid interface name %q", name)
+	case strings.ContainsFunc(name, func(r rune) bool {
+		return r <= ' ' || r == '/' || r == ':' || r == '"' || r == '\\' || r > '~'
+	}):
+		return fmt.Errorf("interface name %q contains invalid characters", name)
+	}
+	return nil
+}
+
+// mergeInterface merges the interface of other, the same flow found on another node or by
+// another source, into the entry. A flow found on different interfaces is not scoped to any.
+func (cd *ComDetails) mergeInterface(other ComDetails) {
+	if cd.Interface != other.Interface {
+		cd.Interface = ""
+	}
+}
+
+// ingressByInterface returns the ingress entries of the matrix by interface. The entries
+// without an interface and the ingress dynamic ranges are under the empty interface, like
+// every entry when the rules are not scoped to the interfaces.
+func (m *ComMatrix) ingressByInterface(scoped bool) (map[string]*ComMatrix, error) {
+	res := map[string]*ComMatrix{"": {}}
+	for _, cd := range m.Ports {
+		if cd.IsEgress() {
+			continue
+		}
+		if cd.Interface != "" {
+			if err := ValidateInterfaceName(cd.Interface); err != nil {
+				return nil, err
+			}
+		}
+		iface := cd.Interface
+		if !scoped {
+			iface = ""
+		}
+		if res[iface] == nil {
+			res[iface] = &ComMatrix{}
+		}
+		res[iface].Ports = append(res[iface].Ports, cd)
+	}
+	for _, dr := range m.DynamicRanges {
+		if !dr.IsEgress() {
+			res[""].DynamicRanges = append(res[""].DynamicRanges, dr)
+		}
+	}
+	return res, nil
+}
+
+// nftInterfaces returns the interfaces of the ingress entries, the empty interface first.
+func nftInterfaces(byInterface map[string]*ComMatrix) []string {
+	return slices.Sorted(maps.Keys(byInterface))
+}
+
+// nftInterfaceMatches returns the match of the packets received on the interface, none for
+// every interface.
+func nftInterfaceMatches(iface string) []nftMatch {
+	if iface == "" {
+		return nil
+	}
+	return []nftMatch{nftEq(nftMeta("iifname"), nftSingle(nftString(iface)))}
+}
+
+// nftOtherInterfacesSection returns the section dropping the packets received on the
+// interfaces of no entry, false if no entry has an interface. An error is returned if no
+// entry is scoped to the primary interface, as its traffic would be dropped.
+func nftOtherInterfacesSection(interfaces []string) (nftSection, bool, error) {
+	var names []string
+	for _, iface := range interfaces {
+		if iface != "" {
+			names = append(names, iface)
+		}
+	}
+	if len(names) == 0 {
+		return nftSection{}, false, nil
+	}
+	if !slices.Contains(names, primaryInterface) {
+		return nftSection{}, false, fmt.Errorf("no entry is scoped to the primary interface %s, dropping the traffic "+
+			"of the other interfaces would drop its traffic: add an entry with interface %s", primaryInterface, primaryInterface)
+	}
+	return nftSection{
+		comment: "Drop the traffic of the interfaces without flows",
+		rules: []nftRule{{
+			matches: []nftMatch{{left: nftMeta("iifname"), op: "!=", right: nftSet(names, nftString)}},
+			verdict: "drop",
+		}},
+	}, true, nil
+}
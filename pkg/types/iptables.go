@@ -0,0 +1,258 @@
+package types
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/openshift-kni/commatrix/pkg/utils"
+)
+
+const (
+	// iptablesChain holds the ingress rules, jumped to from INPUT.
+	iptablesChain = "OPENSHIFT"
+	// iptablesEgressChain holds the egress rules, jumped to from OUTPUT.
+	iptablesEgressChain = "OPENSHIFT_EGRESS"
+	// iptablesMultiportMax is the number of ports a multiport match accepts, a range
+	// counting as two ports.
+	iptablesMultiportMax = 15
+)
+
+// iptablesFileExtensions are the extensions of the iptables-restore and ip6tables-restore
+// files of a node group.
+var iptablesFileExtensions = map[string]string{
+	AddressFamilyIPv4: "iptables",
+	AddressFamilyIPv6: "ip6tables",
+}
+
+// ToIPTables returns the iptables-restore rules of the given address family allowing the
+// flows of the matrix, ip6tables-restore rules for IPv6. The rules follow ToNFTables: the
+// ingress rules are in the OPENSHIFT chain jumped to from INPUT, the egress rules in the
+// OPENSHIFT_EGRESS chain jumped to from OUTPUT. The ingress traffic of the other ports is
+// logged at a limited rate and dropped, the egress traffic of the other ports is only logged.
+// The filter table is replaced when the rules are restored. Of the options, only
+// WithNFTInterfaceRules and WithNFTEgressDrop apply.
+func (m *ComMatrix) ToIPTables(family string, opts ...NFTOption) ([]byte, error) {
+	if !slices.Contains(AddressFamilies, family) {
+		return nil, fmt.Errorf("unknown address family %q, must be one of %s", family, strings.Join(AddressFamilies, ","))
+	}
+	o := newNFTOptions(opts)
+
+	byInterface, err := m.ingressByInterface(o.interfaceRules)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules, sourceRules strings.Builder
+	for _, iface := range nftInterfaces(byInterface) {
+		match := ""
+		if iface != "" {
+			match = fmt.Sprintf("-i %s ", iface)
+		}
+		ingress := byInterface[iface]
+		writeIPTablesPortRules(&rules, iptablesChain, match, iptablesFamilyPorts(ingress.nftPorts(false, &nftOptions{}), family))
+		if err := ingress.writeIPTablesSourceRules(&sourceRules, family, match); err != nil {
+			return nil, err
+		}
+	}
+
+	icmp := "icmp"
+	if family == AddressFamilyIPv6 {
+		icmp = "ipv6-icmp"
+	}
+	egressPorts := iptablesFamilyPorts(m.nftPorts(true, &nftOptions{}), family)
+
+	var out strings.Builder
+	out.WriteString("*filter\n")
+	out.WriteString(":INPUT ACCEPT [0:0]\n:FORWARD ACCEPT [0:0]\n:OUTPUT ACCEPT [0:0]\n")
+	fmt.Fprintf(&out, ":%s - [0:0]\n", iptablesChain)
+	if len(egressPorts) > 0 {
+		fmt.Fprintf(&out, ":%s - [0:0]\n", iptablesEgressChain)
+	}
+	fmt.Fprintf(&out, "-A INPUT -j %s\n", iptablesChain)
+
+	fmt.Fprintf(&out, `
+# Allow loopback traffic
+-A %[1]s -i lo -j ACCEPT
+
+# Allow established and related traffic
+-A %[1]s -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+
+# Allow ICMP
+-A %[1]s -p %[2]s -j ACCEPT
+
+# Allow specific TCP, UDP and SCTP ports
+%[3]s`, iptablesChain, icmp, rules.String())
+
+	if sourceRules.Len() > 0 {
+		fmt.Fprintf(&out, "\n# Allow specific ports from their allowed sources\n%s", sourceRules.String())
+	}
+
+	if family == AddressFamilyIPv4 {
+		fmt.Fprintf(&out, `
+# Drop broadcast traffic with rate-limited logging
+-A %[1]s -d 255.255.255.255/32 -m limit --limit 1/minute -j LOG --log-prefix "firewall "
+-A %[1]s -d 255.255.255.255/32 -j DROP
+`, iptablesChain)
+	}
+
+	fmt.Fprintf(&out, `
+# Rate-limited logging and default drop
+-A %[1]s -m limit --limit 1/minute -j LOG --log-prefix "firewall "
+-A %[1]s -j DROP
+`, iptablesChain)
+
+	if len(egressPorts) > 0 {
+		var egressRules strings.Builder
+		writeIPTablesPortRules(&egressRules, iptablesEgressChain, "", egressPorts)
+		fmt.Fprintf(&out, `
+-A OUTPUT -j %[1]s
+
+# Allow loopback traffic
+-A %[1]s -o lo -j ACCEPT
+
+# Allow established and related traffic
+-A %[1]s -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+
+# Allow ICMP
+-A %[1]s -p %[2]s -j ACCEPT
+
+# Allow specific TCP, UDP and SCTP ports
+%[3]s`, iptablesEgressChain, icmp, egressRules.String())
+		if o.egressDrop {
+			fmt.Fprintf(&out, `
+# Rate-limited logging and default drop
+-A %[1]s -m limit --limit 1/minute -j LOG --log-prefix "firewall egress "
+-A %[1]s -j DROP
+`, iptablesEgressChain)
+		} else {
+			fmt.Fprintf(&out, `
+# Rate-limited logging of the other egress traffic
+-A %[1]s -m limit --limit 1/minute -j LOG --log-prefix "firewall egress "
+`, iptablesEgressChain)
+		}
+	}
+
+	out.WriteString("COMMIT\n")
+	return []byte(out.String()), nil
+}
+
+// writeIPTablesFiles writes the iptables-restore and ip6tables-restore files of the matrix.
+func (m *ComMatrix) writeIPTablesFiles(utilsHelpers utils.UtilsInterface, fileName, destDir string, opts ...NFTOption) error {
+	for _, family := range AddressFamilies {
+		res, err := m.ToIPTables(family, opts...)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(destDir, fmt.Sprintf("%s.%s", fileName, iptablesFileExtensions[family]))
+		if err := utilsHelpers.WriteFile(path, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iptablesFamilyPorts returns the ports returned by nftPorts allowed on the given address
+// family by protocol: the ports of both families and the ports of that family.
+func iptablesFamilyPorts(ports map[string]map[string][]string, family string) map[string][]string {
+	res := map[string][]string{}
+	for _, protocol := range NFTProtocols {
+		if familyPorts := uniquePorts(slices.Concat(ports[""][protocol], ports[family][protocol])); len(familyPorts) > 0 {
+			res[protocol] = familyPorts
+		}
+	}
+	return res
+}
+
+// writeIPTablesPortRules writes the multiport rules of the chain allowing the ports of each
+// protocol after the given match, splitting the ports the multiport match cannot hold.
+func writeIPTablesPortRules(rules *strings.Builder, chain, match string, ports map[string][]string) {
+	for _, protocol := range NFTProtocols {
+		for _, chunk := range iptablesMultiport(ports[protocol]) {
+			fmt.Fprintf(rules, "-A %s %s-p %s -m multiport --dports %s -j ACCEPT\n",
+				chain, match, strings.ToLower(protocol), strings.Join(chunk, ","))
+		}
+	}
+}
+
+// iptablesMultiport splits the ports and ranges into the lists of a multiport match, the
+// ranges written with a colon.
+func iptablesMultiport(ports []string) [][]string {
+	var res [][]string
+	var chunk []string
+	size := 0
+	for _, port := range ports {
+		n := 1
+		if strings.Contains(port, "-") {
+			n = 2
+			port = strings.Replace(port, "-", ":", 1)
+		}
+		if size+n > iptablesMultiportMax {
+			res = append(res, chunk)
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, port)
+		size += n
+	}
+	if len(chunk) > 0 {
+		res = append(res, chunk)
+	}
+	return res
+}
+
+// writeIPTablesSourceRules writes the rules allowing the ingress entries restricted to source
+// CIDRs of the given address family, after the given match. The entries with the same
+// sources share one rule per protocol.
+func (m *ComMatrix) writeIPTablesSourceRules(rules *strings.Builder, family, match string) error {
+	groups := map[string]map[string][]string{}
+	add := func(sources CIDRList, entryFamily, protocol, port string) error {
+		if len(sources) == 0 || !slices.Contains(NFTProtocols, protocol) || (entryFamily != "" && entryFamily != family) {
+			return nil
+		}
+		ipv4, ipv6, err := sources.ByFamily()
+		if err != nil {
+			return err
+		}
+		familySources := ipv4
+		if family == AddressFamilyIPv6 {
+			familySources = ipv6
+		}
+		if len(familySources) == 0 {
+			return nil
+		}
+		key := strings.Join(familySources, ",")
+		if groups[key] == nil {
+			groups[key] = map[string][]string{}
+		}
+		groups[key][protocol] = append(groups[key][protocol], port)
+		return nil
+	}
+
+	for _, line := range m.Ports {
+		if line.IsEgress() {
+			continue
+		}
+		if err := add(line.SourceCIDRs, line.AddressFamily, line.Protocol, fmt.Sprint(line.Port)); err != nil {
+			return err
+		}
+	}
+	for _, dr := range m.DynamicRanges {
+		if dr.IsEgress() {
+			continue
+		}
+		if err := add(dr.SourceCIDRs, "", dr.Protocol, dr.PortRangeString()); err != nil {
+			return err
+		}
+	}
+
+	for _, sources := range slices.Sorted(maps.Keys(groups)) {
+		ports := groups[sources]
+		for protocol := range ports {
+			ports[protocol] = uniquePorts(ports[protocol])
+		}
+		writeIPTablesPortRules(rules, iptablesChain, fmt.Sprintf("%s-s %s ", match, sources), ports)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package types
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gocarina/gocsv"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// LocatedEntry is a matrix entry together with the line of the file it was read from.
+type LocatedEntry struct {
+	Line  int
+	Entry ComDetails
+}
+
+// LocateEntries parses content like ParseToComMatrix and returns the port entries with
+// the line each one starts at. Dynamic range rows are not returned.
+func LocateEntries(content []byte, format string) ([]LocatedEntry, error) {
+	switch format {
+	case FormatCSV:
+		return locateCSVEntries(content)
+	case FormatJSON, FormatYAML:
+		// JSON is valid YAML, so both are located through the YAML node tree.
+		return locateYAMLEntries(content)
+	default:
+		return nil, fmt.Errorf("invalid value for format must be (json,yaml,csv)")
+	}
+}
+
+func locateCSVEntries(content []byte) ([]LocatedEntry, error) {
+	lines, err := csvRecordLines(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []csvRow
+	if err := gocsv.UnmarshalBytes(content, &rows); err != nil {
+		return nil, err
+	}
+	if len(rows) != len(lines) {
+		return nil, fmt.Errorf("failed to locate CSV rows: got %d rows and %d records", len(rows), len(lines))
+	}
+
+	var res []LocatedEntry
+	for i, r := range rows {
+		portStr := strings.TrimSpace(r.Port)
+		if portStr == "" || strings.Contains(portStr, "-") {
+			continue
+		}
+
+		cd, err := parseComDetailsFromCSVRow(r, portStr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lines[i], err)
+		}
+		res = append(res, LocatedEntry{Line: lines[i], Entry: cd})
+	}
+
+	return res, nil
+}
+
+// csvRecordLines returns the line number of every data record, skipping the header.
+func csvRecordLines(content []byte) ([]int, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	var lines []int
+	for first := true; ; first = false {
+		if _, err := r.Read(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return lines, nil
+			}
+			return nil, err
+		}
+		if first {
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		lines = append(lines, line)
+	}
+}
+
+func locateYAMLEntries(content []byte) ([]LocatedEntry, error) {
+	doc := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal(content, doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping with a Ports list", root.Line)
+	}
+
+	var res []LocatedEntry
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		// Keys are matched case-insensitively, as encoding/json does when parsing the matrix.
+		if !strings.EqualFold(root.Content[i].Value, "Ports") {
+			continue
+		}
+		ports := root.Content[i+1]
+		if ports.Kind != yamlv3.SequenceNode {
+			return nil, fmt.Errorf("line %d: expected Ports to be a list", ports.Line)
+		}
+
+		for _, item := range ports.Content {
+			cd, err := decodeComDetailsNode(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", item.Line, err)
+			}
+			res = append(res, LocatedEntry{Line: item.Line, Entry: cd})
+		}
+	}
+
+	return res, nil
+}
+
+// decodeComDetailsNode decodes a YAML node into ComDetails with the json field
+// matching rules used by ParseToComMatrix.
+func decodeComDetailsNode(node *yamlv3.Node) (ComDetails, error) {
+	var raw map[string]interface{}
+	if err := node.Decode(&raw); err != nil {
+		return ComDetails{}, err
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return ComDetails{}, err
+	}
+
+	var cd ComDetails
+	if err := json.Unmarshal(b, &cd); err != nil {
+		return ComDetails{}, err
+	}
+
+	return cd, nil
+}
@@ -0,0 +1,376 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	nftTableFamily = "inet"
+	nftTableName   = "openshift_filter"
+	// nftJSONSchemaVersion is the version of the libnftables JSON schema written by json.
+	nftJSONSchemaVersion = 1
+)
+
+// nftRuleset is the typed model of the openshift_filter table written by ToNFTables and
+// ToNFTablesJSON, so the text and JSON formats allow the same traffic.
+type nftRuleset struct {
+	counters []string
+	chains   []nftChain
+}
+
+// nftChain is a base chain of the table, accepting the traffic its rules do not drop.
+type nftChain struct {
+	name     string
+	hook     string
+	sections []nftSection
+}
+
+// nftSection is a group of rules of a chain, written after a comment.
+type nftSection struct {
+	comment string
+	// compact sections follow the previous section without an empty line.
+	compact bool
+	rules   []nftRule
+}
+
+// nftRule matches packets with all of its matches and applies its statements: a named
+// counter, then its verdict, or a rate-limited log before its verdict. A rule logging
+// without a verdict lets the packets continue to the next rules.
+type nftRule struct {
+	matches   []nftMatch
+	counter   string
+	logPrefix string
+	verdict   string
+	comment   string
+}
+
+// nftMatch compares an expression of the packet with a value.
+type nftMatch struct {
+	left  nftExpression
+	op    string
+	right nftValue
+}
+
+// nftExpression is the left side of a match, with its text and JSON forms.
+type nftExpression struct {
+	text string
+	json any
+}
+
+// nftValue is the right side of a match: a single element, an anonymous set of elements,
+// or a list of flags.
+type nftValue struct {
+	elements []nftElement
+	set      bool
+	flags    bool
+}
+
+// nftElement is a port, a range, an address, a prefix or a name, with its text and JSON
+// forms.
+type nftElement struct {
+	text string
+	json any
+}
+
+func nftMeta(key string) nftExpression {
+	text := key
+	if key == "nfproto" {
+		text = "meta " + key
+	}
+	return nftExpression{text: text, json: map[string]nftJSONKey{"meta": {Key: key}}}
+}
+
+func nftPayload(protocol, field string) nftExpression {
+	return nftExpression{
+		text: protocol + " " + field,
+		json: map[string]nftJSONPayload{"payload": {Protocol: protocol, Field: field}},
+	}
+}
+
+func nftCt(key string) nftExpression {
+	return nftExpression{text: "ct " + key, json: map[string]nftJSONKey{"ct": {Key: key}}}
+}
+
+// nftSymbol is a keyword or a name written without quotes, like a protocol or a family.
+func nftSymbol(s string) nftElement {
+	return nftElement{text: s, json: s}
+}
+
+// nftString is a name written with quotes, like an interface name.
+func nftString(s string) nftElement {
+	return nftElement{text: fmt.Sprintf("\"%s\"", s), json: s}
+}
+
+// nftPort is a port or a min-max range of ports.
+func nftPort(port string) nftElement {
+	if minPort, maxPort, ok := strings.Cut(port, "-"); ok {
+		lo, errMin := strconv.Atoi(minPort)
+		hi, errMax := strconv.Atoi(maxPort)
+		if errMin == nil && errMax == nil {
+			return nftElement{text: port, json: map[string][]int{"range": {lo, hi}}}
+		}
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		return nftElement{text: port, json: p}
+	}
+	return nftSymbol(port)
+}
+
+// nftAddress is an address or a CIDR.
+func nftAddress(addr string) nftElement {
+	if ip, ipNet, err := net.ParseCIDR(addr); err == nil {
+		length, _ := ipNet.Mask.Size()
+		return nftElement{text: addr, json: map[string]nftJSONPrefix{"prefix": {Addr: ip.String(), Len: length}}}
+	}
+	return nftSymbol(addr)
+}
+
+// nftSet returns the set of the elements.
+func nftSet[T any](values []T, element func(T) nftElement) nftValue {
+	res := nftValue{set: true}
+	for _, v := range values {
+		res.elements = append(res.elements, element(v))
+	}
+	return res
+}
+
+func nftSingle(element nftElement) nftValue {
+	return nftValue{elements: []nftElement{element}}
+}
+
+func nftEq(left nftExpression, right nftValue) nftMatch {
+	return nftMatch{left: left, op: "==", right: right}
+}
+
+// nftPortMatch matches the destination port of the protocol with a set of ports.
+func nftPortMatch(protocol string, ports []string) nftMatch {
+	return nftEq(nftPayload(strings.ToLower(protocol), "dport"), nftSet(ports, nftPort))
+}
+
+// nftAddressMatch matches the source or destination addresses of the family with a set of
+// addresses and CIDRs.
+func nftAddressMatch(family, field string, addresses []string) nftMatch {
+	protocol := "ip"
+	if family == AddressFamilyIPv6 {
+		protocol = "ip6"
+	}
+	return nftEq(nftPayload(protocol, field), nftSet(addresses, nftAddress))
+}
+
+func (v nftValue) text() string {
+	var texts []string
+	for _, e := range v.elements {
+		texts = append(texts, e.text)
+	}
+	switch {
+	case v.flags:
+		return strings.Join(texts, ",")
+	case v.set:
+		return "{ " + strings.Join(texts, ", ") + " }"
+	}
+	return strings.Join(texts, " ")
+}
+
+func (v nftValue) json() any {
+	var elements []any
+	for _, e := range v.elements {
+		elements = append(elements, e.json)
+	}
+	switch {
+	case v.flags:
+		return elements
+	case v.set:
+		return map[string]any{"set": elements}
+	case len(elements) == 1:
+		return elements[0]
+	}
+	return elements
+}
+
+func (mt nftMatch) text() string {
+	if mt.op == "!=" {
+		return fmt.Sprintf("%s != %s", mt.left.text, mt.right.text())
+	}
+	return fmt.Sprintf("%s %s", mt.left.text, mt.right.text())
+}
+
+func (r nftRule) text() string {
+	var parts []string
+	for _, mt := range r.matches {
+		parts = append(parts, mt.text())
+	}
+	if r.counter != "" {
+		parts = append(parts, fmt.Sprintf("counter name \"%s\"", r.counter))
+	}
+	if r.logPrefix != "" && r.verdict == "" {
+		parts = append(parts, fmt.Sprintf("limit rate 1/minute log prefix \"%s\"", r.logPrefix))
+	} else if r.logPrefix != "" {
+		parts = append(parts, fmt.Sprintf("jump { limit rate 1/minute log prefix \"%s\"; %s; }", r.logPrefix, r.verdict))
+	} else {
+		parts = append(parts, r.verdict)
+	}
+	if r.comment != "" {
+		parts = append(parts, fmt.Sprintf("comment \"%s\"", r.comment))
+	}
+	return strings.Join(parts, " ")
+}
+
+// text returns the ruleset in the nft text format.
+func (rs *nftRuleset) text() []byte {
+	var out strings.Builder
+	fmt.Fprintf(&out, "#!/usr/sbin/nft -f\ntable %s %s {\n", nftTableFamily, nftTableName)
+	for _, name := range rs.counters {
+		fmt.Fprintf(&out, "    counter %s {\n    }\n\n", name)
+	}
+
+	for i, c := range rs.chains {
+		if i > 0 {
+			out.WriteString("\n\n")
+		}
+		fmt.Fprintf(&out, "    chain %s {\n        type filter hook %s priority 1; policy accept;\n", c.name, c.hook)
+		for _, s := range c.sections {
+			if !s.compact {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "        # %s\n", s.comment)
+			for _, r := range s.rules {
+				fmt.Fprintf(&out, "        %s\n", r.text())
+			}
+		}
+		out.WriteString("    }")
+	}
+	out.WriteString("\n}")
+
+	return []byte(out.String())
+}
+
+// The objects of the libnftables JSON schema written by json.
+type (
+	nftJSONDocument struct {
+		Nftables []nftJSONObject `json:"nftables"`
+	}
+	nftJSONObject struct {
+		Metainfo *nftJSONMetainfo `json:"metainfo,omitempty"`
+		Table    *nftJSONTable    `json:"table,omitempty"`
+		Counter  *nftJSONCounter  `json:"counter,omitempty"`
+		Chain    *nftJSONChain    `json:"chain,omitempty"`
+		Rule     *nftJSONRule     `json:"rule,omitempty"`
+	}
+	nftJSONMetainfo struct {
+		JSONSchemaVersion int `json:"json_schema_version"`
+	}
+	nftJSONTable struct {
+		Family string `json:"family"`
+		Name   string `json:"name"`
+	}
+	nftJSONCounter struct {
+		Family string `json:"family"`
+		Table  string `json:"table"`
+		Name   string `json:"name"`
+	}
+	nftJSONChain struct {
+		Family string `json:"family"`
+		Table  string `json:"table"`
+		Name   string `json:"name"`
+		Type   string `json:"type"`
+		Hook   string `json:"hook"`
+		Prio   int    `json:"prio"`
+		Policy string `json:"policy"`
+	}
+	nftJSONRule struct {
+		Family  string           `json:"family"`
+		Table   string           `json:"table"`
+		Chain   string           `json:"chain"`
+		Expr    []map[string]any `json:"expr"`
+		Comment string           `json:"comment,omitempty"`
+	}
+	nftJSONMatch struct {
+		Op    string `json:"op"`
+		Left  any    `json:"left"`
+		Right any    `json:"right"`
+	}
+	nftJSONKey struct {
+		Key string `json:"key"`
+	}
+	nftJSONPayload struct {
+		Protocol string `json:"protocol"`
+		Field    string `json:"field"`
+	}
+	nftJSONPrefix struct {
+		Addr string `json:"addr"`
+		Len  int    `json:"len"`
+	}
+	nftJSONLimit struct {
+		Rate int    `json:"rate"`
+		Per  string `json:"per"`
+	}
+	nftJSONLog struct {
+		Prefix string `json:"prefix"`
+	}
+)
+
+func (mt nftMatch) json() nftJSONMatch {
+	op := mt.op
+	if mt.right.flags {
+		op = "in"
+	}
+	return nftJSONMatch{Op: op, Left: mt.left.json, Right: mt.right.json()}
+}
+
+// json returns the expressions of the rule in the libnftables JSON schema. The rate-limited
+// log of a rule is a rule of its own, followed by the rule of the verdict.
+func (r nftRule) json() [][]map[string]any {
+	var matches []map[string]any
+	for _, mt := range r.matches {
+		matches = append(matches, map[string]any{"match": mt.json()})
+	}
+	verdict := map[string]any{r.verdict: nil}
+
+	if r.logPrefix != "" {
+		logRule := append(append([]map[string]any{}, matches...),
+			map[string]any{"limit": nftJSONLimit{Rate: 1, Per: "minute"}},
+			map[string]any{"log": nftJSONLog{Prefix: r.logPrefix}})
+		if r.verdict == "" {
+			return [][]map[string]any{logRule}
+		}
+		verdictRule := append(append([]map[string]any{}, matches...), verdict)
+		return [][]map[string]any{logRule, verdictRule}
+	}
+
+	expr := matches
+	if r.counter != "" {
+		expr = append(expr, map[string]any{"counter": r.counter})
+	}
+	return [][]map[string]any{append(expr, verdict)}
+}
+
+// json returns the ruleset in the libnftables JSON schema, as read by `nft -j -f`.
+func (rs *nftRuleset) json() ([]byte, error) {
+	doc := nftJSONDocument{Nftables: []nftJSONObject{
+		{Metainfo: &nftJSONMetainfo{JSONSchemaVersion: nftJSONSchemaVersion}},
+		{Table: &nftJSONTable{Family: nftTableFamily, Name: nftTableName}},
+	}}
+	for _, name := range rs.counters {
+		doc.Nftables = append(doc.Nftables, nftJSONObject{Counter: &nftJSONCounter{Family: nftTableFamily, Table: nftTableName, Name: name}})
+	}
+	for _, c := range rs.chains {
+		doc.Nftables = append(doc.Nftables, nftJSONObject{Chain: &nftJSONChain{
+			Family: nftTableFamily, Table: nftTableName, Name: c.name, Type: "filter", Hook: c.hook, Prio: 1, Policy: "accept",
+		}})
+		for _, s := range c.sections {
+			for _, r := range s.rules {
+				for _, expr := range r.json() {
+					doc.Nftables = append(doc.Nftables, nftJSONObject{Rule: &nftJSONRule{
+						Family: nftTableFamily, Table: nftTableName, Chain: c.name, Expr: expr, Comment: r.comment,
+					}})
+				}
+			}
+		}
+	}
+
+	return json.MarshalIndent(doc, "", "    ")
+}
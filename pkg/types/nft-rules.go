@@ -0,0 +1,260 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// nftCommentMaxLen is the longest comment nftables accepts on a rule.
+const nftCommentMaxLen = 128
+
+// NFTOption configures the nftables rules generated by ToNFTables.
+type NFTOption func(*nftOptions)
+
+type nftOptions struct {
+	rulePerFlow         bool
+	counters            bool
+	bindAddresses       bool
+	interfaceRules      bool
+	dropOtherInterfaces bool
+	egressDrop          bool
+	// nodeGroupSelectors are the selectors of the custom node groups, by group.
+	nodeGroupSelectors map[string]labels.Selector
+}
+
+// WithNFTRulePerFlow writes one rule per entry instead of one rule per protocol. The rule
+// of an entry is commented with its namespace, service and pod, the rule of a dynamic
+// range with its description.
+func WithNFTRulePerFlow() NFTOption {
+	return func(o *nftOptions) {
+		o.rulePerFlow = true
+	}
+}
+
+// WithNFTCounters adds a named counter to the rule of every entry, so the traffic of a
+// flow can be read with `nft list counter`. It implies WithNFTRulePerFlow.
+func WithNFTCounters() NFTOption {
+	return func(o *nftOptions) {
+		o.rulePerFlow = true
+		o.counters = true
+	}
+}
+
+// WithNFTBindAddressRules only allows the ingress entries whose listening sockets are bound
+// to specific addresses on these addresses, with ip daddr and ip6 daddr rules, so the ports
+// stay closed on the other addresses of the nodes. Entries restricted to source CIDRs keep
+// their source rules.
+func WithNFTBindAddressRules() NFTOption {
+	return func(o *nftOptions) {
+		o.bindAddresses = true
+	}
+}
+
+// WithNFTInterfaceRules only allows the ingress entries with an interface on this interface,
+// with iifname rules. By default the interface of an entry is only recorded in the matrix and
+// the entry is allowed on every interface.
+func WithNFTInterfaceRules() NFTOption {
+	return func(o *nftOptions) {
+		o.interfaceRules = true
+	}
+}
+
+// WithNFTDropOtherInterfaces drops the ingress traffic of the interfaces no entry is scoped
+// to, so the entries without an interface are only allowed on the interfaces of the matrix
+// instead of every interface. The rules of a node group without interfaces are unchanged, and
+// the rules of a node group with interfaces fail unless an entry is scoped to br-ex, the
+// primary interface. It implies WithNFTInterfaceRules.
+func WithNFTDropOtherInterfaces() NFTOption {
+	return func(o *nftOptions) {
+		o.interfaceRules = true
+		o.dropOtherInterfaces = true
+	}
+}
+
+// WithNFTEgressDrop drops the egress traffic that no egress entry allows. By default the
+// egress chain only logs this traffic, as the egress entries discovered from the connections
+// of the nodes at one point in time seldom hold all the flows the nodes need.
+func WithNFTEgressDrop() NFTOption {
+	return func(o *nftOptions) {
+		o.egressDrop = true
+	}
+}
+
+func newNFTOptions(opts []NFTOption) *nftOptions {
+	o := &nftOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// nftFlow is the rule of one entry or dynamic range.
+type nftFlow struct {
+	protocol string
+	port     string
+	sources  CIDRList
+	// destinations are the addresses the listening sockets of the flow are bound to.
+	destinations CIDRList
+	family       string
+	comment      string
+}
+
+// nftFlowRules holds the per-flow rules of a matrix and the counters they use.
+type nftFlowRules struct {
+	counters []string
+}
+
+// nftFlows returns the flows of the ingress or egress entries and dynamic ranges.
+// Egress flows only match on their destination port, so flows to several peers
+// sharing a port are written once.
+func (m *ComMatrix) nftFlows(egress bool, o *nftOptions) []nftFlow {
+	var res []nftFlow
+	seen := map[string]bool{}
+	add := func(f nftFlow) {
+		if !slices.Contains(NFTProtocols, f.protocol) {
+			return
+		}
+		key := f.protocol + "/" + f.port + "/" + f.family + "/" + f.sources.key() + "/" + f.destinations.key()
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		res = append(res, f)
+	}
+
+	for _, cd := range m.Ports {
+		if cd.IsEgress() != egress {
+			continue
+		}
+		f := nftFlow{protocol: cd.Protocol, port: fmt.Sprint(cd.Port), family: cd.AddressFamily, comment: cd.nftComment()}
+		if !egress {
+			f.sources = cd.SourceCIDRs
+			if o.bindAddresses && len(f.sources) == 0 && cd.hasBindAddresses() {
+				f.destinations = cd.BindAddresses
+			}
+		}
+		add(f)
+	}
+
+	for _, dr := range m.DynamicRanges {
+		if dr.IsEgress() != egress {
+			continue
+		}
+		f := nftFlow{protocol: dr.Protocol, port: dr.PortRangeString(), comment: dr.Description}
+		if !egress {
+			f.sources = dr.SourceCIDRs
+		}
+		add(f)
+	}
+
+	return res
+}
+
+// nftComment returns the namespace, service and pod of the entry separated by slashes.
+func (cd ComDetails) nftComment() string {
+	var parts []string
+	for _, s := range []string{cd.Namespace, cd.Service, cd.Pod} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// rules returns the rules of the flows: the unrestricted ones of each protocol first, then
+// the ones limited to their bound addresses and the ones restricted to their sources, one
+// rule per address family of the addresses used by the flow. match is prepended to every
+// rule and chain prefixes the names of the counters.
+func (r *nftFlowRules) rules(chain string, flows []nftFlow, o *nftOptions, match []nftMatch) (open, bound, restricted []nftRule, err error) {
+	for _, protocol := range NFTProtocols {
+		for _, f := range flows {
+			if f.protocol == protocol && len(f.sources) == 0 && len(f.destinations) == 0 {
+				open = append(open, r.rule(slices.Concat(match, nftFamilyMatches(f.family)), f, chain, o))
+			}
+		}
+	}
+
+	for _, f := range flows {
+		if len(f.destinations) == 0 {
+			continue
+		}
+		ipv4, ipv6, err := f.destinations.ByFamily()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		rule := r.rule(nil, f, chain, o)
+		if len(ipv4) > 0 {
+			bound = append(bound, rule.after(match, nftAddressMatch(AddressFamilyIPv4, "daddr", ipv4)))
+		}
+		if len(ipv6) > 0 {
+			bound = append(bound, rule.after(match, nftAddressMatch(AddressFamilyIPv6, "daddr", ipv6)))
+		}
+	}
+
+	for _, f := range flows {
+		if len(f.sources) == 0 {
+			continue
+		}
+		ipv4, ipv6, err := f.sources.ByFamily()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		rule := r.rule(nil, f, chain, o)
+		if len(ipv4) > 0 && f.family != AddressFamilyIPv6 {
+			restricted = append(restricted, rule.after(match, nftAddressMatch(AddressFamilyIPv4, "saddr", ipv4)))
+		}
+		if len(ipv6) > 0 && f.family != AddressFamilyIPv4 {
+			restricted = append(restricted, rule.after(match, nftAddressMatch(AddressFamilyIPv6, "saddr", ipv6)))
+		}
+	}
+
+	return open, bound, restricted, nil
+}
+
+// rule returns the rule matching the destination port of the flow after the given matches,
+// with its counter, verdict and comment, and registers its counter.
+func (r *nftFlowRules) rule(match []nftMatch, f nftFlow, chain string, o *nftOptions) nftRule {
+	res := nftRule{
+		matches: slices.Concat(match, []nftMatch{nftEq(nftPayload(strings.ToLower(f.protocol), "dport"), nftSingle(nftPort(f.port)))}),
+		verdict: "accept",
+		comment: nftCommentString(f.comment),
+	}
+	if o.counters {
+		res.counter = r.addCounter(fmt.Sprintf("%s_%s_%s", chain, strings.ToLower(f.protocol), strings.ReplaceAll(f.port, "-", "_")))
+	}
+	return res
+}
+
+// after returns a copy of the rule matching the given matches first.
+func (rule nftRule) after(match []nftMatch, more ...nftMatch) nftRule {
+	rule.matches = slices.Concat(match, more, rule.matches)
+	return rule
+}
+
+// addCounter registers a counter with the given name, suffixed when the name is taken.
+func (r *nftFlowRules) addCounter(name string) string {
+	res := name
+	for i := 2; slices.Contains(r.counters, res); i++ {
+		res = fmt.Sprintf("%s_%d", name, i)
+	}
+	r.counters = append(r.counters, res)
+	return res
+}
+
+// nftCommentString strips the characters nftables does not accept in a quoted comment and
+// truncates it to the longest comment nftables accepts.
+func nftCommentString(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '"' || r == '\\' || r < ' ' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > nftCommentMaxLen {
+		s = s[:nftCommentMaxLen]
+	}
+	return s
+}
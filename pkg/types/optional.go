@@ -0,0 +1,86 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// OptionalSelection lists the optional flows kept in the firewall outputs when the
+// optional flows are excluded.
+type OptionalSelection struct {
+	Keep []OptionalSelector `json:"keep" yaml:"keep"`
+}
+
+// OptionalSelector matches the optional flows with all of its set fields. The service
+// of a dynamic range is its description, and a port matches a range containing it.
+type OptionalSelector struct {
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Service   string `json:"service,omitempty" yaml:"service,omitempty"`
+	Protocol  string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	Port      int    `json:"port,omitempty" yaml:"port,omitempty"`
+}
+
+// ParseOptionalSelection parses a JSON or YAML optional flows selection.
+func ParseOptionalSelection(content []byte) (*OptionalSelection, error) {
+	selection := &OptionalSelection{}
+	if err := yaml.UnmarshalStrict(content, selection); err != nil {
+		return nil, fmt.Errorf("failed to parse the optional flows selection: %w", err)
+	}
+
+	for i, s := range selection.Keep {
+		if s == (OptionalSelector{}) {
+			return nil, fmt.Errorf("keep[%d]: set at least one of namespace, service, protocol and port", i)
+		}
+		if s.Protocol != "" && !slices.Contains(NFTProtocols, strings.ToUpper(s.Protocol)) {
+			return nil, fmt.Errorf("keep[%d]: invalid protocol %q, valid options are: %s", i, s.Protocol, strings.Join(NFTProtocols, ", "))
+		}
+		if s.Port < 0 || s.Port > 65535 {
+			return nil, fmt.Errorf("keep[%d]: invalid port %d", i, s.Port)
+		}
+	}
+
+	return selection, nil
+}
+
+func (s OptionalSelector) matches(namespace, service, protocol string, minPort, maxPort int) bool {
+	return (s.Namespace == "" || s.Namespace == namespace) &&
+		(s.Service == "" || s.Service == service) &&
+		(s.Protocol == "" || strings.EqualFold(s.Protocol, protocol)) &&
+		(s.Port == 0 || (minPort <= s.Port && s.Port <= maxPort))
+}
+
+// keeps returns true if a selector of the selection matches the flow.
+func (sel *OptionalSelection) keeps(namespace, service, protocol string, minPort, maxPort int) bool {
+	if sel == nil {
+		return false
+	}
+	return slices.ContainsFunc(sel.Keep, func(s OptionalSelector) bool {
+		return s.matches(namespace, service, protocol, minPort, maxPort)
+	})
+}
+
+// ExcludeOptional returns the matrix without its optional entries and dynamic ranges, except
+// the ones kept by the selection, and a matrix of the excluded ones. The selection may be nil.
+func (m *ComMatrix) ExcludeOptional(selection *OptionalSelection) (kept, excluded *ComMatrix) {
+	kept, excluded = &ComMatrix{}, &ComMatrix{}
+	for _, cd := range m.Ports {
+		if cd.Optional && !selection.keeps(cd.Namespace, cd.Service, cd.Protocol, cd.Port, cd.Port) {
+			excluded.Ports = append(excluded.Ports, cd)
+			continue
+		}
+		kept.Ports = append(kept.Ports, cd)
+	}
+
+	for _, dr := range m.DynamicRanges {
+		if dr.Optional && !selection.keeps("", dr.Description, dr.Protocol, dr.MinPort, dr.MaxPort) {
+			excluded.DynamicRanges = append(excluded.DynamicRanges, dr)
+			continue
+		}
+		kept.DynamicRanges = append(kept.DynamicRanges, dr)
+	}
+
+	return kept, excluded
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"slices"
 
 	configv1 "github.com/openshift/api/config/v1"
 )
@@ -307,58 +308,217 @@ var StandardStaticEntries = []ComDetails{
 // General IPv6-only static entries that should be applied when the cluster supports IPv6.
 var GeneralIPv6StaticEntriesWorker = []ComDetails{
 	{
-		Direction: "Ingress",
-		Protocol:  "UDP",
-		Port:      546,
-		NodeGroup: "worker",
-		Service:   "NetworkManager",
-		Namespace: "",
-		Pod:       "",
-		Container: "",
-		Optional:  false,
+		Direction:     "Ingress",
+		Protocol:      "UDP",
+		Port:          546,
+		NodeGroup:     "worker",
+		Service:       "NetworkManager",
+		Namespace:     "",
+		Pod:           "",
+		Container:     "",
+		Optional:      false,
+		AddressFamily: AddressFamilyIPv6,
 	},
 }
 
 var GeneralIPv6StaticEntriesMaster = []ComDetails{
 	{
-		Direction: "Ingress",
-		Protocol:  "UDP",
-		Port:      546,
-		NodeGroup: "master",
-		Service:   "NetworkManager",
-		Namespace: "",
-		Pod:       "",
-		Container: "",
-		Optional:  false,
+		Direction:     "Ingress",
+		Protocol:      "UDP",
+		Port:          546,
+		NodeGroup:     "master",
+		Service:       "NetworkManager",
+		Namespace:     "",
+		Pod:           "",
+		Container:     "",
+		Optional:      false,
+		AddressFamily: AddressFamilyIPv6,
 	},
 }
 
 // DHCP static entries that should be applied when the host uses DHCP for network configuration.
 var GeneralDHCPStaticEntriesWorker = []ComDetails{
 	{
-		Direction: "Ingress",
+		Direction:     "Ingress",
+		Protocol:      "UDP",
+		Port:          68,
+		NodeGroup:     "worker",
+		Service:       "NetworkManager",
+		Namespace:     "",
+		Pod:           "",
+		Container:     "",
+		Optional:      false,
+		AddressFamily: AddressFamilyIPv4,
+	},
+}
+
+var GeneralDHCPStaticEntriesMaster = []ComDetails{
+	{
+		Direction:     "Ingress",
+		Protocol:      "UDP",
+		Port:          68,
+		NodeGroup:     "master",
+		Service:       "NetworkManager",
+		Namespace:     "",
+		Pod:           "",
+		Container:     "",
+		Optional:      false,
+		AddressFamily: AddressFamilyIPv4,
+	},
+}
+
+// Egress static entries of the flows the nodes always need: DNS, NTP, the API VIP, the
+// Geneve tunnels and the etcd peers. They are added with the egress flows of the nodes,
+// which only hold the connections established when the flows were discovered.
+var GeneralEgressStaticEntriesMaster = []ComDetails{
+	{
+		Direction: "Egress",
 		Protocol:  "UDP",
-		Port:      68,
-		NodeGroup: "worker",
-		Service:   "NetworkManager",
-		Namespace: "",
+		Port:      53,
+		NodeGroup: "master",
+		Service:   "dns",
+		Namespace: "Host system service",
 		Pod:       "",
 		Container: "",
 		Optional:  false,
+		Peer:      "external",
+	}, {
+		Direction: "Egress",
+		Protocol:  "TCP",
+		Port:      53,
+		NodeGroup: "master",
+		Service:   "dns",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "external",
+	}, {
+		Direction: "Egress",
+		Protocol:  "UDP",
+		Port:      123,
+		NodeGroup: "master",
+		Service:   "chronyd",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "external",
+	}, {
+		Direction: "Egress",
+		Protocol:  "TCP",
+		Port:      6443,
+		NodeGroup: "master",
+		Service:   "kubelet",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "master",
+	}, {
+		Direction: "Egress",
+		Protocol:  "UDP",
+		Port:      6081,
+		NodeGroup: "master",
+		Service:   "ovs-vswitchd",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "master",
+	}, {
+		Direction: "Egress",
+		Protocol:  "TCP",
+		Port:      2380,
+		NodeGroup: "master",
+		Service:   "etcd",
+		Namespace: "openshift-etcd",
+		Pod:       "etcd",
+		Container: "etcd",
+		Optional:  false,
+		Peer:      "master",
 	},
 }
 
-var GeneralDHCPStaticEntriesMaster = []ComDetails{
+// Egress static entries of the flows between the control plane and the worker nodes.
+var StandardEgressStaticEntries = []ComDetails{
 	{
-		Direction: "Ingress",
+		Direction: "Egress",
 		Protocol:  "UDP",
-		Port:      68,
+		Port:      6081,
 		NodeGroup: "master",
-		Service:   "NetworkManager",
-		Namespace: "",
+		Service:   "ovs-vswitchd",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "worker",
+	}, {
+		Direction: "Egress",
+		Protocol:  "UDP",
+		Port:      53,
+		NodeGroup: "worker",
+		Service:   "dns",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "external",
+	}, {
+		Direction: "Egress",
+		Protocol:  "TCP",
+		Port:      53,
+		NodeGroup: "worker",
+		Service:   "dns",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "external",
+	}, {
+		Direction: "Egress",
+		Protocol:  "UDP",
+		Port:      123,
+		NodeGroup: "worker",
+		Service:   "chronyd",
+		Namespace: "Host system service",
 		Pod:       "",
 		Container: "",
 		Optional:  false,
+		Peer:      "external",
+	}, {
+		Direction: "Egress",
+		Protocol:  "TCP",
+		Port:      6443,
+		NodeGroup: "worker",
+		Service:   "kubelet",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "master",
+	}, {
+		Direction: "Egress",
+		Protocol:  "UDP",
+		Port:      6081,
+		NodeGroup: "worker",
+		Service:   "ovs-vswitchd",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "master",
+	}, {
+		Direction: "Egress",
+		Protocol:  "UDP",
+		Port:      6081,
+		NodeGroup: "worker",
+		Service:   "ovs-vswitchd",
+		Namespace: "Host system service",
+		Pod:       "",
+		Container: "",
+		Optional:  false,
+		Peer:      "worker",
 	},
 }
 
@@ -381,21 +541,51 @@ var KubeletNodePortDefaultDynamicRange = DynamicRangeList{
 	},
 }
 
+// StaticEntryList is a named list of static entries.
+type StaticEntryList struct {
+	Name    string
+	Entries []ComDetails
+}
+
 // GetStaticEntries returns the static entries for the given platform, topology,
 // IPv6 and DHCP configuration.
 func GetStaticEntries(platformType configv1.PlatformType, topology configv1.TopologyMode, ipv6Enabled, dhcpEnabled bool) ([]ComDetails, error) {
+	lists, err := GetStaticEntryLists(platformType, topology, ipv6Enabled, dhcpEnabled)
+	if err != nil {
+		return nil, err
+	}
+
 	var comDetails []ComDetails
+	for _, l := range lists {
+		comDetails = append(comDetails, l.Entries...)
+	}
+
+	return comDetails, nil
+}
+
+// GetEgressStaticEntries returns the egress static entries for the given topology.
+func GetEgressStaticEntries(topology configv1.TopologyMode) []ComDetails {
+	if topology == configv1.SingleReplicaTopologyMode {
+		return slices.Clone(GeneralEgressStaticEntriesMaster)
+	}
+	return slices.Concat(GeneralEgressStaticEntriesMaster, StandardEgressStaticEntries)
+}
+
+// GetStaticEntryLists returns the named static entry lists that apply to the given
+// platform, topology, IPv6 and DHCP configuration.
+func GetStaticEntryLists(platformType configv1.PlatformType, topology configv1.TopologyMode, ipv6Enabled, dhcpEnabled bool) ([]StaticEntryList, error) {
+	var lists []StaticEntryList
 
 	switch platformType {
 	case configv1.BareMetalPlatformType:
-		comDetails = append(comDetails, BaremetalStaticEntriesMaster...)
+		lists = append(lists, StaticEntryList{"BaremetalStaticEntriesMaster", BaremetalStaticEntriesMaster})
 		if topology != configv1.SingleReplicaTopologyMode {
-			comDetails = append(comDetails, BaremetalStaticEntriesWorker...)
+			lists = append(lists, StaticEntryList{"BaremetalStaticEntriesWorker", BaremetalStaticEntriesWorker})
 		}
 	case configv1.NonePlatformType:
-		comDetails = append(comDetails, NoneStaticEntriesMaster...)
+		lists = append(lists, StaticEntryList{"NoneStaticEntriesMaster", NoneStaticEntriesMaster})
 		if topology != configv1.SingleReplicaTopologyMode {
-			comDetails = append(comDetails, NoneStaticEntriesWorker...)
+			lists = append(lists, StaticEntryList{"NoneStaticEntriesWorker", NoneStaticEntriesWorker})
 		}
 	case configv1.AWSPlatformType:
 		// No cloud-specific static entries
@@ -403,25 +593,25 @@ func GetStaticEntries(platformType configv1.PlatformType, topology configv1.Topo
 		return nil, fmt.Errorf("invalid value for cluster environment: %v", platformType)
 	}
 
-	comDetails = append(comDetails, GeneralStaticEntriesMaster...)
+	lists = append(lists, StaticEntryList{"GeneralStaticEntriesMaster", GeneralStaticEntriesMaster})
 	if ipv6Enabled {
-		comDetails = append(comDetails, GeneralIPv6StaticEntriesMaster...)
+		lists = append(lists, StaticEntryList{"GeneralIPv6StaticEntriesMaster", GeneralIPv6StaticEntriesMaster})
 	}
 	if dhcpEnabled {
-		comDetails = append(comDetails, GeneralDHCPStaticEntriesMaster...)
+		lists = append(lists, StaticEntryList{"GeneralDHCPStaticEntriesMaster", GeneralDHCPStaticEntriesMaster})
 	}
 	if topology == configv1.SingleReplicaTopologyMode {
-		return comDetails, nil
+		return lists, nil
 	}
 
-	comDetails = append(comDetails, StandardStaticEntries...)
-	comDetails = append(comDetails, GeneralStaticEntriesWorker...)
+	lists = append(lists, StaticEntryList{"StandardStaticEntries", StandardStaticEntries})
+	lists = append(lists, StaticEntryList{"GeneralStaticEntriesWorker", GeneralStaticEntriesWorker})
 	if ipv6Enabled {
-		comDetails = append(comDetails, GeneralIPv6StaticEntriesWorker...)
+		lists = append(lists, StaticEntryList{"GeneralIPv6StaticEntriesWorker", GeneralIPv6StaticEntriesWorker})
 	}
 	if dhcpEnabled {
-		comDetails = append(comDetails, GeneralDHCPStaticEntriesWorker...)
+		lists = append(lists, StaticEntryList{"GeneralDHCPStaticEntriesWorker", GeneralDHCPStaticEntriesWorker})
 	}
 
-	return comDetails, nil
+	return lists, nil
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"maps"
+	"net"
 	"path/filepath"
 	"reflect"
 	"slices"
@@ -52,6 +53,15 @@ func SupportedTopologiesList() []configv1.TopologyMode {
 	return slices.Collect(maps.Keys(supportedTopologies))
 }
 
+const (
+	ProtocolTCP  = "TCP"
+	ProtocolUDP  = "UDP"
+	ProtocolSCTP = "SCTP"
+)
+
+// NFTProtocols are the protocols of the entries written to the nftables rules, in rule order.
+var NFTProtocols = []string{ProtocolTCP, ProtocolUDP, ProtocolSCTP}
+
 const (
 	FormatJSON   = "json"
 	FormatYAML   = "yaml"
@@ -59,6 +69,15 @@ const (
 	FormatNFT    = "nft"
 	FormatButane = "butane" // Butane config with embedded nftables firewall rules
 	FormatMC     = "mc"     // MachineConfig with embedded nftables firewall rules (transpiled from Butane)
+	// FormatNFTJSON is the nft format in the libnftables JSON schema.
+	FormatNFTJSON = "nft-json"
+	// FormatFirewalld is a firewalld zone per node group.
+	FormatFirewalld = "firewalld"
+	// FormatIPTables is a pair of iptables-restore and ip6tables-restore files per node group.
+	FormatIPTables = "iptables"
+	// FormatIngressNodeFirewall is an IngressNodeFirewall CR of the Ingress Node Firewall
+	// Operator per node group.
+	FormatIngressNodeFirewall = "ingressnodefirewall"
 )
 
 type ComMatrix struct {
@@ -76,6 +95,21 @@ type ComDetails struct {
 	Container string `json:"container" yaml:"container" csv:"Container"`
 	NodeGroup string `json:"nodeGroup" yaml:"nodeGroup" csv:"NodeGroup"`
 	Optional  bool   `json:"optional" yaml:"optional" csv:"Optional"`
+	// Peer is the node group or "external" the flow goes to. Only set for egress flows.
+	Peer string `json:"peer,omitempty" yaml:"peer,omitempty" csv:"Peer"`
+	// SourceCIDRs are the addresses and CIDRs the flow is allowed from. Any source when empty.
+	SourceCIDRs CIDRList `json:"sourceCIDRs,omitempty" yaml:"sourceCIDRs,omitempty" csv:"SourceCIDRs"`
+	// AddressFamily is IPv4 or IPv6 for the flows of a single address family. Both when empty.
+	AddressFamily string `json:"addressFamily,omitempty" yaml:"addressFamily,omitempty" csv:"AddressFamily"`
+	// BindScope is the scope of the address the listening sockets of the flow are bound to,
+	// see BindScopes. Unknown when empty, e.g. for the flows not found by ss.
+	BindScope string `json:"bindScope,omitempty" yaml:"bindScope,omitempty" csv:"BindScope"`
+	// BindAddresses are the addresses the listening sockets of the flow are bound to on the
+	// nodes of the group. Empty for the sockets bound to the wildcard.
+	BindAddresses CIDRList `json:"bindAddresses,omitempty" yaml:"bindAddresses,omitempty" csv:"BindAddresses"`
+	// Interface is the network interface the ingress flow is allowed on. Every interface
+	// when empty.
+	Interface string `json:"interface,omitempty" yaml:"interface,omitempty" csv:"Interface"`
 }
 
 type DynamicRange struct {
@@ -85,6 +119,54 @@ type DynamicRange struct {
 	MaxPort     int    `json:"maxPort" yaml:"maxPort" csv:"MaxPort"`
 	Description string `json:"description" yaml:"description" csv:"Description"`
 	Optional    bool   `json:"optional" yaml:"optional" csv:"Optional"`
+	// SourceCIDRs are the addresses and CIDRs the range is allowed from. Any source when empty.
+	SourceCIDRs CIDRList `json:"sourceCIDRs,omitempty" yaml:"sourceCIDRs,omitempty" csv:"SourceCIDRs"`
+}
+
+// CIDRList is a list of addresses and CIDRs, written as a space separated list in CSV.
+type CIDRList []string
+
+func (l CIDRList) MarshalCSV() (string, error) {
+	return l.String(), nil
+}
+
+func (l *CIDRList) UnmarshalCSV(s string) error {
+	*l = nil
+	if fields := strings.Fields(s); len(fields) > 0 {
+		*l = fields
+	}
+	return nil
+}
+
+func (l CIDRList) String() string {
+	return strings.Join(l, " ")
+}
+
+// ByFamily splits the list into its IPv4 and IPv6 addresses and CIDRs. An error is
+// returned for values that are neither an address nor a CIDR.
+func (l CIDRList) ByFamily() (ipv4 []string, ipv6 []string, err error) {
+	for _, s := range l {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			if ip, _, err = net.ParseCIDR(s); err != nil {
+				return nil, nil, fmt.Errorf("invalid source %q, must be an address or a CIDR", s)
+			}
+		}
+		if ip.To4() != nil {
+			ipv4 = append(ipv4, s)
+		} else {
+			ipv6 = append(ipv6, s)
+		}
+	}
+
+	return ipv4, ipv6, nil
+}
+
+// key identifies the sources regardless of their order.
+func (l CIDRList) key() string {
+	sorted := slices.Clone(l)
+	slices.Sort(sorted)
+	return strings.Join(sorted, " ")
 }
 
 type ContainerInfo struct {
@@ -97,6 +179,11 @@ type ContainerInfo struct {
 	} `json:"containers"`
 }
 
+// IsEgress returns true if the range is an egress range.
+func (dr *DynamicRange) IsEgress() bool {
+	return strings.EqualFold(dr.Direction, consts.EgressLabel)
+}
+
 func (dr *DynamicRange) PortRangeString() string {
 	return fmt.Sprintf("%d-%d", dr.MinPort, dr.MaxPort)
 }
@@ -106,6 +193,7 @@ func (dr *DynamicRange) PortRangeString() string {
 func (dr *DynamicRange) CanMerge(next DynamicRange) bool {
 	return dr.Direction == next.Direction &&
 		dr.Protocol == next.Protocol &&
+		dr.SourceCIDRs.key() == next.SourceCIDRs.key() &&
 		next.MinPort >= dr.MinPort &&
 		next.MinPort <= dr.MaxPort+1
 }
@@ -128,7 +216,7 @@ func (dr *DynamicRange) Merge(next DynamicRange) bool {
 
 type DynamicRangeList []DynamicRange
 
-// Squash merges DynamicRanges with matching Direction and Protocol into a single range.
+// Squash merges DynamicRanges with matching Direction, Protocol and SourceCIDRs into a single range.
 // Note that this Squash will potentially lose information as Description and Optional will be combined. See
 // DynamicRange.Merge() for details.
 func (drl *DynamicRangeList) Squash() {
@@ -136,7 +224,7 @@ func (drl *DynamicRangeList) Squash() {
 		return
 	}
 
-	// Sort by Direction, Protocol, MinPort
+	// Sort by Direction, Protocol, SourceCIDRs, MinPort
 	slices.SortFunc(*drl, func(a, b DynamicRange) int {
 		if c := cmp.Compare(a.Direction, b.Direction); c != 0 {
 			return c
@@ -144,10 +232,13 @@ func (drl *DynamicRangeList) Squash() {
 		if c := cmp.Compare(a.Protocol, b.Protocol); c != 0 {
 			return c
 		}
+		if c := cmp.Compare(a.SourceCIDRs.key(), b.SourceCIDRs.key()); c != 0 {
+			return c
+		}
 		return cmp.Compare(a.MinPort, b.MinPort)
 	})
 
-	// Merge all ranges with same Direction/Protocol
+	// Merge all ranges with same Direction/Protocol/SourceCIDRs
 	merged := DynamicRangeList{(*drl)[0]}
 	for _, next := range (*drl)[1:] {
 		if merged[len(merged)-1].Merge(next) {
@@ -181,6 +272,12 @@ func (m *ComMatrix) ToCSV() ([]byte, error) {
 			"",                              // Container (empty)
 			"",                              // NodeGroup (empty)
 			strconv.FormatBool(dr.Optional), // Optional
+			"",                              // Peer (empty)
+			dr.SourceCIDRs.String(),         // SourceCIDRs
+			"",                              // AddressFamily (empty)
+			"",                              // BindScope (empty)
+			"",                              // BindAddresses (empty)
+			"",                              // Interface (empty)
 		}
 		if err := csvwriter.Write(row); err != nil {
 			return nil, err
@@ -210,8 +307,8 @@ func (m *ComMatrix) ToYAML() ([]byte, error) {
 	return out, nil
 }
 
-func (m *ComMatrix) ToButane(nodePool string, utilsHelpers utils.UtilsInterface) ([]byte, error) {
-	nftRules, err := m.ToNFTables()
+func (m *ComMatrix) ToButane(nodePool string, utilsHelpers utils.UtilsInterface, opts ...NFTOption) ([]byte, error) {
+	nftRules, err := m.ToNFTables(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -219,8 +316,8 @@ func (m *ComMatrix) ToButane(nodePool string, utilsHelpers utils.UtilsInterface)
 	return firewall.NFTablesToButane(nftRules, nodePool, utilsHelpers)
 }
 
-func (m *ComMatrix) ToMachineConfig(nodePool string, utilsHelpers utils.UtilsInterface) ([]byte, error) {
-	nftRules, err := m.ToNFTables()
+func (m *ComMatrix) ToMachineConfig(nodePool string, utilsHelpers utils.UtilsInterface, opts ...NFTOption) ([]byte, error) {
+	nftRules, err := m.ToNFTables(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -237,14 +334,18 @@ func (m *ComMatrix) String() string {
 	return result.String()
 }
 
-func (m *ComMatrix) WriteMatrixToFileByType(utilsHelpers utils.UtilsInterface, fileNamePrefix, format string, destDir string) error {
-	if format == FormatNFT || format == FormatButane || format == FormatMC {
+// WriteMatrixToFileByType writes the matrix in the given format. The nft, nft-json, butane,
+// mc, firewalld, iptables and ingressnodefirewall formats are written per node group, with
+// their rules generated with the given options.
+func (m *ComMatrix) WriteMatrixToFileByType(utilsHelpers utils.UtilsInterface, fileNamePrefix, format string, destDir string, opts ...NFTOption) error {
+	if format == FormatNFT || format == FormatNFTJSON || format == FormatButane || format == FormatMC || format == FormatFirewalld || format == FormatIPTables ||
+		format == FormatIngressNodeFirewall {
 		pools := m.SeparateMatrixByGroup()
 		for poolName, mat := range pools {
 			if len(mat.Ports) == 0 {
 				continue
 			}
-			if err := mat.writeMatrixToFile(utilsHelpers, fileNamePrefix+"-"+poolName, format, poolName, destDir); err != nil {
+			if err := mat.writeMatrixToFile(utilsHelpers, fileNamePrefix+"-"+poolName, format, poolName, destDir, opts...); err != nil {
 				return err
 			}
 		}
@@ -258,7 +359,7 @@ func (m *ComMatrix) WriteMatrixToFileByType(utilsHelpers utils.UtilsInterface, f
 	return m.writeMatrixToFile(utilsHelpers, fileNamePrefix, format, "", destDir)
 }
 
-func (m *ComMatrix) print(format, nodePool string, utilsHelpers utils.UtilsInterface) ([]byte, error) {
+func (m *ComMatrix) print(format, nodePool string, utilsHelpers utils.UtilsInterface, opts ...NFTOption) ([]byte, error) {
 	switch format {
 	case FormatJSON:
 		return m.ToJSON()
@@ -267,13 +368,19 @@ func (m *ComMatrix) print(format, nodePool string, utilsHelpers utils.UtilsInter
 	case FormatYAML:
 		return m.ToYAML()
 	case FormatNFT:
-		return m.ToNFTables()
+		return m.ToNFTables(opts...)
+	case FormatNFTJSON:
+		return m.ToNFTablesJSON(opts...)
 	case FormatButane:
-		return m.ToButane(nodePool, utilsHelpers)
+		return m.ToButane(nodePool, utilsHelpers, opts...)
 	case FormatMC:
-		return m.ToMachineConfig(nodePool, utilsHelpers)
+		return m.ToMachineConfig(nodePool, utilsHelpers, opts...)
+	case FormatFirewalld:
+		return m.ToFirewalld(nodePool)
+	case FormatIngressNodeFirewall:
+		return m.ToIngressNodeFirewall(nodePool, opts...)
 	default:
-		return nil, fmt.Errorf("invalid format: %s. Please specify json, csv, yaml, nft, butane, or mc", format)
+		return nil, fmt.Errorf("invalid format: %s. Please specify json, csv, yaml, nft, nft-json, butane, mc, firewalld, iptables or ingressnodefirewall", format)
 	}
 }
 
@@ -297,15 +404,24 @@ func (m *ComMatrix) SeparateMatrixByGroup() map[string]ComMatrix {
 	return res
 }
 
-func (m *ComMatrix) writeMatrixToFile(utilsHelpers utils.UtilsInterface, fileName, format, nodePool, destDir string) error {
-	res, err := m.print(format, nodePool, utilsHelpers)
+func (m *ComMatrix) writeMatrixToFile(utilsHelpers utils.UtilsInterface, fileName, format, nodePool, destDir string, opts ...NFTOption) error {
+	if format == FormatIPTables {
+		return m.writeIPTablesFiles(utilsHelpers, fileName, destDir, opts...)
+	}
+
+	res, err := m.print(format, nodePool, utilsHelpers, opts...)
 	if err != nil {
 		return err
 	}
 
 	ext := format
-	if format == FormatButane || format == FormatMC {
+	switch format {
+	case FormatButane, FormatMC, FormatIngressNodeFirewall:
 		ext = "yaml"
+	case FormatNFTJSON:
+		ext = "json"
+	case FormatFirewalld:
+		ext = "xml"
 	}
 	comMatrixFileName := filepath.Join(destDir, fmt.Sprintf("%s.%s", fileName, ext))
 	return utilsHelpers.WriteFile(comMatrixFileName, res)
@@ -329,58 +445,355 @@ func (m *ComMatrix) Contains(cd ComDetails) bool {
 	return false
 }
 
-func (m *ComMatrix) ToNFTables() ([]byte, error) {
-	var tcpPorts []string
-	var udpPorts []string
+// ToNFTables returns the nftables rules allowing the flows of the matrix. By default the
+// ports of a protocol share one rule, see WithNFTRulePerFlow for one rule per flow.
+func (m *ComMatrix) ToNFTables(opts ...NFTOption) ([]byte, error) {
+	rs, err := m.nftRuleset(newNFTOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return rs.text(), nil
+}
+
+// ToNFTablesJSON returns the rules of ToNFTables in the libnftables JSON schema, to be
+// loaded with `nft -j -f`. The rate-limited logging of the dropped traffic is a rule of
+// its own, followed by the drop rule.
+func (m *ComMatrix) ToNFTablesJSON(opts ...NFTOption) ([]byte, error) {
+	rs, err := m.nftRuleset(newNFTOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return rs.json()
+}
+
+// nftRuleset returns the model of the openshift_filter table allowing the flows of the
+// matrix.
+func (m *ComMatrix) nftRuleset(o *nftOptions) (*nftRuleset, error) {
+	flowRules := &nftFlowRules{}
+
+	byInterface, err := m.ingressByInterface(o.interfaceRules)
+	if err != nil {
+		return nil, err
+	}
+	interfaces := nftInterfaces(byInterface)
+
+	var rules, boundRules, sourceRules []nftRule
+	for _, iface := range interfaces {
+		open, bound, restricted, err := byInterface[iface].nftIngressRules(flowRules, o, nftInterfaceMatches(iface))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, open...)
+		boundRules = append(boundRules, bound...)
+		sourceRules = append(sourceRules, restricted...)
+	}
+
+	sections := nftBaseSections("iif")
+	if o.dropOtherInterfaces {
+		s, ok, err := nftOtherInterfacesSection(interfaces)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sections = append(sections, s)
+		}
+	}
+	sections = append(sections, nftSection{comment: "Allow specific TCP, UDP and SCTP ports", rules: rules})
+	if len(boundRules) > 0 {
+		sections = append(sections, nftSection{comment: "Allow specific ports on the addresses their sockets are bound to", rules: boundRules})
+	}
+	if len(sourceRules) > 0 {
+		sections = append(sections, nftSection{comment: "Allow specific ports from their allowed sources", rules: sourceRules})
+	}
+	sections = append(sections,
+		nftSection{comment: "Drop broadcast traffic with rate-limited logging", rules: []nftRule{{
+			matches:   []nftMatch{nftEq(nftPayload("ip", "daddr"), nftSingle(nftAddress("255.255.255.255")))},
+			logPrefix: "firewall ",
+			verdict:   "drop",
+		}}},
+		nftSection{comment: "Rate-limited logging and default drop", rules: []nftRule{{logPrefix: "firewall ", verdict: "drop"}}},
+	)
+
+	rs := &nftRuleset{chains: []nftChain{{name: "OPENSHIFT", hook: "input", sections: sections}}}
+
+	egressChain, err := m.nftEgressChain(flowRules, o)
+	if err != nil {
+		return nil, err
+	}
+	if egressChain != nil {
+		rs.chains = append(rs.chains, *egressChain)
+	}
+	rs.counters = flowRules.counters
+
+	return rs, nil
+}
+
+// nftBaseSections returns the sections allowing the loopback traffic on the given interface
+// key, iif or oif, the established and related traffic and ICMP.
+func nftBaseSections(loopbackKey string) []nftSection {
+	accept := func(left nftExpression, right nftElement) []nftRule {
+		return []nftRule{{matches: []nftMatch{nftEq(left, nftSingle(right))}, verdict: "accept"}}
+	}
+	return []nftSection{
+		{comment: "Allow loopback traffic", rules: accept(nftMeta(loopbackKey), nftSymbol("lo"))},
+		{comment: "Allow established and related traffic", rules: []nftRule{{
+			matches: []nftMatch{nftEq(nftCt("state"), nftValue{elements: []nftElement{nftSymbol("established"), nftSymbol("related")}, flags: true})},
+			verdict: "accept",
+		}}},
+		{comment: "Allow ICMP on ipv4", rules: accept(nftPayload("ip", "protocol"), nftSymbol("icmp"))},
+		{comment: "Allow ICMP on ipv6", compact: true, rules: accept(nftPayload("ip6", "nexthdr"), nftSymbol("ipv6-icmp"))},
+	}
+}
+
+// nftIngressRules returns the rules of the ingress entries of the matrix: the unrestricted
+// ones, the ones limited to their bound addresses and the ones restricted to their sources.
+// The rules start with the given matches.
+func (m *ComMatrix) nftIngressRules(flowRules *nftFlowRules, o *nftOptions, match []nftMatch) (open, bound, restricted []nftRule, err error) {
+	if o.rulePerFlow {
+		return flowRules.rules("in", m.nftFlows(false, o), o, match)
+	}
+
+	open = nftProtocolRules(m.nftPorts(false, o), match)
+	if o.bindAddresses {
+		if bound, err = m.nftBindAddressRules(match); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if restricted, err = m.nftSourceRules(match); err != nil {
+		return nil, nil, nil, err
+	}
+	return open, bound, restricted, nil
+}
+
+// nftPorts returns the ports and ranges of the ingress or egress entries by address family
+// and protocol, the family being empty for the entries of both families. Entries of other
+// protocols and ingress entries restricted to source CIDRs, or to their bound addresses
+// with WithNFTBindAddressRules, are left out.
+func (m *ComMatrix) nftPorts(egress bool, o *nftOptions) map[string]map[string][]string {
+	res := map[string]map[string][]string{}
+	add := func(family, protocol, port string) {
+		if res[family] == nil {
+			res[family] = map[string][]string{}
+		}
+		res[family][protocol] = append(res[family][protocol], port)
+	}
+
 	for _, line := range m.Ports {
-		if line.Protocol == "TCP" {
-			tcpPorts = append(tcpPorts, fmt.Sprint(line.Port))
-		} else if line.Protocol == "UDP" {
-			udpPorts = append(udpPorts, fmt.Sprint(line.Port))
+		if line.IsEgress() != egress || !slices.Contains(NFTProtocols, line.Protocol) {
+			continue
 		}
+		if !egress && (len(line.SourceCIDRs) > 0 || (o.bindAddresses && line.hasBindAddresses())) {
+			continue
+		}
+		add(line.AddressFamily, line.Protocol, fmt.Sprint(line.Port))
 	}
 
 	for _, dr := range m.DynamicRanges {
-		rangeStr := dr.PortRangeString()
-		if dr.Protocol == "TCP" {
-			tcpPorts = append(tcpPorts, rangeStr)
-		} else if dr.Protocol == "UDP" {
-			udpPorts = append(udpPorts, rangeStr)
+		if dr.IsEgress() != egress || !slices.Contains(NFTProtocols, dr.Protocol) {
+			continue
+		}
+		if !egress && len(dr.SourceCIDRs) > 0 {
+			continue
+		}
+		add("", dr.Protocol, dr.PortRangeString())
+	}
+
+	return res
+}
+
+// nftProtocolRules returns one rule per address family and protocol allowing the ports
+// returned by nftPorts, after the given matches. The rules of a single family match its
+// nfproto.
+func nftProtocolRules(ports map[string]map[string][]string, match []nftMatch) []nftRule {
+	var rules []nftRule
+	for _, family := range append([]string{""}, AddressFamilies...) {
+		for _, protocol := range NFTProtocols {
+			// Flows to several peers share the same port.
+			if protocolPorts := uniquePorts(ports[family][protocol]); len(protocolPorts) > 0 {
+				rules = append(rules, nftRule{
+					matches: slices.Concat(match, nftFamilyMatches(family), []nftMatch{nftPortMatch(protocol, protocolPorts)}),
+					verdict: "accept",
+				})
+			}
 		}
 	}
+	return rules
+}
+
+// nftFamilyMatches returns the match of the packets of the given address family, none for
+// both families.
+func nftFamilyMatches(family string) []nftMatch {
+	switch family {
+	case AddressFamilyIPv4:
+		return []nftMatch{nftEq(nftMeta("nfproto"), nftSingle(nftSymbol("ipv4")))}
+	case AddressFamilyIPv6:
+		return []nftMatch{nftEq(nftMeta("nfproto"), nftSingle(nftSymbol("ipv6")))}
+	}
+	return nil
+}
+
+// nftBindAddressRules returns the rules allowing the ingress entries on the addresses their
+// sockets are bound to. The entries bound to the same addresses share one rule per protocol
+// and address family, after the given match. Entries restricted to source CIDRs are left to
+// nftSourceRules.
+func (m *ComMatrix) nftBindAddressRules(match []nftMatch) ([]nftRule, error) {
+	type bindGroup struct {
+		addresses CIDRList
+		ports     map[string][]string
+	}
+	groups := map[string]*bindGroup{}
+	for _, line := range m.Ports {
+		if line.IsEgress() || len(line.SourceCIDRs) > 0 || !line.hasBindAddresses() || !slices.Contains(NFTProtocols, line.Protocol) {
+			continue
+		}
+		g, ok := groups[line.BindAddresses.key()]
+		if !ok {
+			g = &bindGroup{addresses: line.BindAddresses, ports: map[string][]string{}}
+			groups[line.BindAddresses.key()] = g
+		}
+		g.ports[line.Protocol] = append(g.ports[line.Protocol], fmt.Sprint(line.Port))
+	}
+
+	var rules []nftRule
+	for _, key := range slices.Sorted(maps.Keys(groups)) {
+		g := groups[key]
+		ipv4, ipv6, err := g.addresses.ByFamily()
+		if err != nil {
+			return nil, err
+		}
+		for _, protocol := range NFTProtocols {
+			ports := uniquePorts(g.ports[protocol])
+			if len(ports) == 0 {
+				continue
+			}
+			if len(ipv4) > 0 {
+				rules = append(rules, nftAddressRule(match, AddressFamilyIPv4, "daddr", ipv4, protocol, ports))
+			}
+			if len(ipv6) > 0 {
+				rules = append(rules, nftAddressRule(match, AddressFamilyIPv6, "daddr", ipv6, protocol, ports))
+			}
+		}
+	}
+
+	return rules, nil
+}
 
-	tcpPortsStr := strings.Join(tcpPorts, ", ")
-	udpPortsStr := strings.Join(udpPorts, ", ")
+// nftSourceRules returns the rules allowing the ingress entries restricted to source CIDRs,
+// after the given match.
+// The entries with the same sources share one rule per protocol and address family.
+func (m *ComMatrix) nftSourceRules(match []nftMatch) ([]nftRule, error) {
+	type sourceGroup struct {
+		sources CIDRList
+		// ports holds the ports of each address family by protocol, the family being
+		// empty for the entries of both families.
+		ports map[string]map[string][]string
+	}
+	groups := map[string]*sourceGroup{}
+	add := func(sources CIDRList, family, protocol, port string) {
+		if len(sources) == 0 || !slices.Contains(NFTProtocols, protocol) {
+			return
+		}
+		g, ok := groups[sources.key()]
+		if !ok {
+			g = &sourceGroup{sources: sources, ports: map[string]map[string][]string{}}
+			groups[sources.key()] = g
+		}
+		if g.ports[protocol] == nil {
+			g.ports[protocol] = map[string][]string{}
+		}
+		g.ports[protocol][family] = append(g.ports[protocol][family], port)
+	}
 
-	result := fmt.Sprintf(`#!/usr/sbin/nft -f
-table inet openshift_filter {
-    chain OPENSHIFT {
-        type filter hook input priority 1; policy accept;
+	for _, line := range m.Ports {
+		if !line.IsEgress() {
+			add(line.SourceCIDRs, line.AddressFamily, line.Protocol, fmt.Sprint(line.Port))
+		}
+	}
+	for _, dr := range m.DynamicRanges {
+		if !dr.IsEgress() {
+			add(dr.SourceCIDRs, "", dr.Protocol, dr.PortRangeString())
+		}
+	}
 
-        # Allow loopback traffic
-        iif lo accept
+	var rules []nftRule
+	for _, key := range slices.Sorted(maps.Keys(groups)) {
+		g := groups[key]
+		ipv4, ipv6, err := g.sources.ByFamily()
+		if err != nil {
+			return nil, err
+		}
+		for _, protocol := range NFTProtocols {
+			// The rule of a family allows the entries of both families and of that family.
+			ipv4Ports := slices.Concat(g.ports[protocol][""], g.ports[protocol][AddressFamilyIPv4])
+			ipv6Ports := slices.Concat(g.ports[protocol][""], g.ports[protocol][AddressFamilyIPv6])
+			if len(ipv4) > 0 && len(ipv4Ports) > 0 {
+				rules = append(rules, nftAddressRule(match, AddressFamilyIPv4, "saddr", ipv4, protocol, ipv4Ports))
+			}
+			if len(ipv6) > 0 && len(ipv6Ports) > 0 {
+				rules = append(rules, nftAddressRule(match, AddressFamilyIPv6, "saddr", ipv6, protocol, ipv6Ports))
+			}
+		}
+	}
+
+	return rules, nil
+}
 
-        # Allow established and related traffic
-        ct state established,related accept
+// nftAddressRule returns the rule allowing the ports of the protocol on the source or
+// destination addresses of the family, after the given matches.
+func nftAddressRule(match []nftMatch, family, field string, addresses []string, protocol string, ports []string) nftRule {
+	return nftRule{
+		matches: slices.Concat(match, []nftMatch{nftAddressMatch(family, field, addresses), nftPortMatch(protocol, ports)}),
+		verdict: "accept",
+	}
+}
 
-        # Allow ICMP on ipv4
-        ip protocol icmp accept
-        # Allow ICMP on ipv6
-        ip6 nexthdr ipv6-icmp accept
+// nftEgressChain returns the output hook chain allowing the egress entries, or nil if the
+// matrix has none. The chain only matches destination ports, peers are not part of the
+// rules. The other egress traffic is logged, and only dropped with WithNFTEgressDrop.
+func (m *ComMatrix) nftEgressChain(flowRules *nftFlowRules, o *nftOptions) (*nftChain, error) {
+	var rules []nftRule
+	if o.rulePerFlow {
+		flows := m.nftFlows(true, o)
+		if len(flows) == 0 {
+			return nil, nil
+		}
+		var err error
+		if rules, _, _, err = flowRules.rules("out", flows, o, nil); err != nil {
+			return nil, err
+		}
+	} else {
+		ports := m.nftPorts(true, o)
+		if len(ports) == 0 {
+			return nil, nil
+		}
 
-        # Allow specific TCP and UDP ports
-        tcp dport { %s } accept
-        udp dport { %s } accept
+		rules = nftProtocolRules(ports, nil)
+	}
 
-        # Drop broadcast traffic with rate-limited logging
-        ip daddr 255.255.255.255 jump { limit rate 1/minute log prefix "firewall "; drop; }
+	last := nftSection{comment: "Rate-limited logging of the other egress traffic", rules: []nftRule{{logPrefix: "firewall egress "}}}
+	if o.egressDrop {
+		last = nftSection{comment: "Rate-limited logging and default drop", rules: []nftRule{{logPrefix: "firewall egress ", verdict: "drop"}}}
+	}
+	sections := append(nftBaseSections("oif"),
+		nftSection{comment: "Allow specific TCP, UDP and SCTP ports", rules: rules},
+		last,
+	)
+	return &nftChain{name: consts.NFTEgressChain, hook: "output", sections: sections}, nil
+}
 
-        # Rate-limited logging and default drop
-        jump { limit rate 1/minute log prefix "firewall "; drop; }
-    }
-}`, tcpPortsStr, udpPortsStr)
+func uniquePorts(ports []string) []string {
+	seen := map[string]bool{}
+	res := []string{}
+	for _, p := range ports {
+		if !seen[p] {
+			seen[p] = true
+			res = append(res, p)
+		}
+	}
 
-	return []byte(result), nil
+	return res
 }
 
 // Merge creates a copy of the current matrix and merges another matrix into it.
@@ -415,14 +828,20 @@ func (m *ComMatrix) Merge(other *ComMatrix) *ComMatrix {
 
 // SortAndRemoveDuplicates removes duplicates in the matrix and sort it.
 func (m *ComMatrix) SortAndRemoveDuplicates() {
-	allKeys := make(map[string]bool)
+	allKeys := make(map[string]int)
 	res := []ComDetails{}
 	for _, item := range m.Ports {
-		str := fmt.Sprintf("%s-%d-%s", item.NodeGroup, item.Port, item.Protocol)
-		if _, value := allKeys[str]; !value {
-			allKeys[str] = true
-			res = append(res, item)
+		str := item.Key()
+		if i, ok := allKeys[str]; ok {
+			// The same flow found for both address families allows both.
+			res[i].AddressFamily = MergeAddressFamilies(res[i].AddressFamily, item.AddressFamily)
+			res[i].mergeBindAddresses(item)
+			res[i].mergeSourceCIDRs(item)
+			res[i].mergeInterface(item)
+			continue
 		}
+		allKeys[str] = len(res)
+		res = append(res, item)
 	}
 	m.Ports = res
 
@@ -437,19 +856,65 @@ func (m *ComMatrix) SortAndRemoveDuplicates() {
 			return res
 		}
 
-		return cmp.Compare(a.Port, b.Port)
+		res = cmp.Compare(a.Port, b.Port)
+		if res != 0 {
+			return res
+		}
+
+		res = cmp.Compare(a.Direction, b.Direction)
+		if res != 0 {
+			return res
+		}
+
+		return cmp.Compare(a.Peer, b.Peer)
 	})
 }
 
+// mergeSourceCIDRs merges the sources of other, the same flow found on another node or by
+// another source, into the entry. A flow allowed from any source stays unrestricted.
+func (cd *ComDetails) mergeSourceCIDRs(other ComDetails) {
+	switch {
+	case cd.SourceCIDRs.key() == other.SourceCIDRs.key():
+	case len(cd.SourceCIDRs) == 0 || len(other.SourceCIDRs) == 0:
+		cd.SourceCIDRs = nil
+	default:
+		sources := slices.Concat(cd.SourceCIDRs, other.SourceCIDRs)
+		slices.Sort(sources)
+		cd.SourceCIDRs = slices.Compact(sources)
+	}
+}
+
 func (cd ComDetails) String() string {
-	return fmt.Sprintf("%s,%s,%d,%s,%s,%s,%s,%s,%v", cd.Direction, cd.Protocol, cd.Port, cd.Namespace, cd.Service, cd.Pod, cd.Container, cd.NodeGroup, cd.Optional)
+	return fmt.Sprintf("%s,%s,%d,%s,%s,%s,%s,%s,%v,%s,%s,%s,%s,%s,%s", cd.Direction, cd.Protocol, cd.Port, cd.Namespace, cd.Service, cd.Pod, cd.Container, cd.NodeGroup, cd.Optional, cd.Peer, cd.SourceCIDRs, cd.AddressFamily, cd.BindScope, cd.BindAddresses, cd.Interface)
 }
 
 func (cd ComDetails) Equals(other ComDetails) bool {
-	strComDetail1 := fmt.Sprintf("%s-%d-%s", cd.NodeGroup, cd.Port, cd.Protocol)
-	strComDetail2 := fmt.Sprintf("%s-%d-%s", other.NodeGroup, other.Port, other.Protocol)
+	return cd.Key() == other.Key()
+}
+
+// SameAttributes returns true if the entry and other restrict the flow the same way: same
+// source CIDRs, address family, bind addresses and interface.
+func (cd ComDetails) SameAttributes(other ComDetails) bool {
+	return cd.SourceCIDRs.key() == other.SourceCIDRs.key() &&
+		cd.AddressFamily == other.AddressFamily &&
+		cd.BindAddresses.key() == other.BindAddresses.key() &&
+		cd.Interface == other.Interface
+}
+
+// Key identifies the entry by its node group, port and protocol. Egress entries are
+// also identified by their direction and peer.
+func (cd ComDetails) Key() string {
+	key := fmt.Sprintf("%s-%d-%s", cd.NodeGroup, cd.Port, cd.Protocol)
+	if cd.IsEgress() {
+		key += fmt.Sprintf("-%s-%s", consts.EgressLabel, cd.Peer)
+	}
+
+	return key
+}
 
-	return strComDetail1 == strComDetail2
+// IsEgress returns true if the entry is an egress flow.
+func (cd ComDetails) IsEgress() bool {
+	return strings.EqualFold(cd.Direction, consts.EgressLabel)
 }
 
 func GetComMatrixHeadersByFormat(format string) (string, error) {
@@ -551,6 +1016,27 @@ func BuildNodeToGroupMap(nodes []corev1.Node) (map[string]string, error) {
 	return nodeToGroup, nil
 }
 
+// BuildAddressToGroupMap maps the internal and external addresses of the nodes to their group.
+func BuildAddressToGroupMap(nodes []corev1.Node, nodeToGroup map[string]string) map[string]string {
+	res := map[string]string{}
+	for _, node := range nodes {
+		group, ok := nodeToGroup[node.Name]
+		if !ok {
+			continue
+		}
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != corev1.NodeInternalIP && addr.Type != corev1.NodeExternalIP {
+				continue
+			}
+			if ip := net.ParseIP(addr.Address); ip != nil {
+				res[ip.String()] = group
+			}
+		}
+	}
+
+	return res
+}
+
 // ParseToComMatrix parses input content in one of the supported formats (json, yaml, csv)
 // and returns a ComMatrix that includes both ComDetails (Ports) and DynamicRanges.
 func ParseToComMatrix(content []byte, format string) (*ComMatrix, error) {
@@ -574,7 +1060,7 @@ func ParseToComMatrix(content []byte, format string) (*ComMatrix, error) {
 	}
 }
 
-func parseDynamicRangeFromCSVRow(direction, protocol, description string, optional bool, portStr string) (DynamicRange, error) {
+func parseDynamicRangeFromCSVRow(direction, protocol, description string, sourceCIDRs CIDRList, optional bool, portStr string) (DynamicRange, error) {
 	minPort, maxPort, err := ParsePortRangeHyphen(portStr)
 	if err != nil {
 		return DynamicRange{}, fmt.Errorf("invalid port range %q: %w", portStr, err)
@@ -586,41 +1072,54 @@ func parseDynamicRangeFromCSVRow(direction, protocol, description string, option
 		MaxPort:     maxPort,
 		Description: description,
 		Optional:    optional,
+		SourceCIDRs: sourceCIDRs,
 	}, nil
 }
 
-func parseComDetailsFromCSVRow(direction, protocol, namespace, service, pod, container, nodeGroup string, optional bool, portStr string) (ComDetails, error) {
+func parseComDetailsFromCSVRow(r csvRow, portStr string) (ComDetails, error) {
 	port, err := strconv.Atoi(portStr)
 	if err != nil {
 		return ComDetails{}, fmt.Errorf("invalid port %q: %w", portStr, err)
 	}
 	return ComDetails{
-		Direction: direction,
-		Protocol:  protocol,
-		Port:      port,
-		Namespace: namespace,
-		Service:   service,
-		Pod:       pod,
-		Container: container,
-		NodeGroup: nodeGroup,
-		Optional:  optional,
+		Direction:     r.Direction,
+		Protocol:      r.Protocol,
+		Port:          port,
+		Namespace:     r.Namespace,
+		Service:       r.Service,
+		Pod:           r.Pod,
+		Container:     r.Container,
+		NodeGroup:     r.NodeGroup,
+		Optional:      r.Optional,
+		Peer:          r.Peer,
+		SourceCIDRs:   r.SourceCIDRs,
+		AddressFamily: r.AddressFamily,
+		BindScope:     r.BindScope,
+		BindAddresses: r.BindAddresses,
+		Interface:     r.Interface,
 	}, nil
 }
 
-func parseCSVToComMatrix(content []byte) (*ComMatrix, error) {
-	// Use a CSV projection with string fields for Port to allow ranges.
-	type csvRow struct {
-		Direction string `csv:"Direction"`
-		Protocol  string `csv:"Protocol"`
-		Port      string `csv:"Port"`
-		Namespace string `csv:"Namespace"`
-		Service   string `csv:"Service"`
-		Pod       string `csv:"Pod"`
-		Container string `csv:"Container"`
-		NodeGroup string `csv:"NodeGroup"`
-		Optional  bool   `csv:"Optional"`
-	}
+// csvRow is a CSV projection of ComDetails with a string Port field to allow ranges.
+type csvRow struct {
+	Direction     string   `csv:"Direction"`
+	Protocol      string   `csv:"Protocol"`
+	Port          string   `csv:"Port"`
+	Namespace     string   `csv:"Namespace"`
+	Service       string   `csv:"Service"`
+	Pod           string   `csv:"Pod"`
+	Container     string   `csv:"Container"`
+	NodeGroup     string   `csv:"NodeGroup"`
+	Optional      bool     `csv:"Optional"`
+	Peer          string   `csv:"Peer"`
+	SourceCIDRs   CIDRList `csv:"SourceCIDRs"`
+	AddressFamily string   `csv:"AddressFamily"`
+	BindScope     string   `csv:"BindScope"`
+	BindAddresses CIDRList `csv:"BindAddresses"`
+	Interface     string   `csv:"Interface"`
+}
 
+func parseCSVToComMatrix(content []byte) (*ComMatrix, error) {
 	var rows []csvRow
 	if err := gocsv.UnmarshalBytes(content, &rows); err != nil {
 		return nil, err
@@ -641,7 +1140,7 @@ func parseCSVToComMatrix(content []byte) (*ComMatrix, error) {
 
 		// Dynamic range row when Port looks like "min-max"
 		if strings.Contains(portStr, "-") {
-			dr, err := parseDynamicRangeFromCSVRow(r.Direction, r.Protocol, r.Service, r.Optional, portStr)
+			dr, err := parseDynamicRangeFromCSVRow(r.Direction, r.Protocol, r.Service, r.SourceCIDRs, r.Optional, portStr)
 			if err != nil {
 				return nil, err
 			}
@@ -650,7 +1149,7 @@ func parseCSVToComMatrix(content []byte) (*ComMatrix, error) {
 		}
 
 		// Regular ComDetails row
-		cd, err := parseComDetailsFromCSVRow(r.Direction, r.Protocol, r.Namespace, r.Service, r.Pod, r.Container, r.NodeGroup, r.Optional, portStr)
+		cd, err := parseComDetailsFromCSVRow(r, portStr)
 		if err != nil {
 			return nil, err
 		}
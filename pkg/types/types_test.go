@@ -1,6 +1,10 @@
 package types
 
 import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
 	"github.com/openshift-kni/commatrix/pkg/utils"
 
 	g "github.com/onsi/ginkgo/v2"
@@ -8,6 +12,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
 )
 
 // fakeUtils embeds UtilsInterface and overrides only GetClusterVersion
@@ -86,7 +91,7 @@ var _ = g.Describe("Dynamic range parsing and helpers", func() {
 
 	g.Describe("parseDynamicRangeFromCSVRow", func() {
 		g.It("creates DynamicRange from a valid CSV row fields", func() {
-			dr, err := parseDynamicRangeFromCSVRow("Ingress", "TCP", "NodePort range", true, "30000-32767")
+			dr, err := parseDynamicRangeFromCSVRow("Ingress", "TCP", "NodePort range", nil, true, "30000-32767")
 			o.Expect(err).ToNot(o.HaveOccurred())
 			o.Expect(dr.Direction).To(o.Equal("Ingress"))
 			o.Expect(dr.Protocol).To(o.Equal("TCP"))
@@ -97,7 +102,7 @@ var _ = g.Describe("Dynamic range parsing and helpers", func() {
 		})
 
 		g.It("errors on invalid port range field", func() {
-			_, err := parseDynamicRangeFromCSVRow("Egress", "UDP", "bad", false, "foo-bar")
+			_, err := parseDynamicRangeFromCSVRow("Egress", "UDP", "bad", nil, false, "foo-bar")
 			o.Expect(err).To(o.HaveOccurred())
 		})
 	})
@@ -392,6 +397,205 @@ var _ = g.Describe("SeparateMatrixByGroup", func() {
 	})
 })
 
+var _ = g.Describe("Egress entries", func() {
+	g.It("keeps egress flows to different peers apart from the ingress entry of the same port", func() {
+		mat := ComMatrix{
+			Ports: []ComDetails{
+				{Direction: "Egress", Port: 6443, Protocol: "TCP", NodeGroup: "worker", Peer: "master"},
+				{Direction: "Ingress", Port: 6443, Protocol: "TCP", NodeGroup: "worker"},
+				{Direction: "Egress", Port: 6443, Protocol: "TCP", NodeGroup: "worker", Peer: "external"},
+				{Direction: "Egress", Port: 6443, Protocol: "TCP", NodeGroup: "worker", Peer: "master"},
+			},
+		}
+
+		mat.SortAndRemoveDuplicates()
+		o.Expect(mat.Ports).To(o.Equal([]ComDetails{
+			{Direction: "Egress", Port: 6443, Protocol: "TCP", NodeGroup: "worker", Peer: "external"},
+			{Direction: "Egress", Port: 6443, Protocol: "TCP", NodeGroup: "worker", Peer: "master"},
+			{Direction: "Ingress", Port: 6443, Protocol: "TCP", NodeGroup: "worker"},
+		}))
+		o.Expect(mat.Ports[2].Key()).To(o.Equal("worker-6443-TCP"))
+		o.Expect(mat.Ports[1].Key()).To(o.Equal("worker-6443-TCP-Egress-master"))
+	})
+
+	g.It("writes the egress entries to an output hook chain", func() {
+		mat := ComMatrix{
+			Ports: []ComDetails{
+				{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"},
+				{Direction: "Egress", Port: 6443, Protocol: "TCP", NodeGroup: "worker", Peer: "master"},
+				{Direction: "Egress", Port: 6443, Protocol: "TCP", NodeGroup: "worker", Peer: "external"},
+				{Direction: "Egress", Port: 6081, Protocol: "UDP", NodeGroup: "worker", Peer: "master"},
+			},
+		}
+
+		out, err := mat.ToNFTables()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		ingress, egress, found := strings.Cut(string(out), "chain OPENSHIFT_EGRESS {")
+		o.Expect(found).To(o.BeTrue())
+		o.Expect(ingress).To(o.ContainSubstring("tcp dport { 22 } accept"))
+		o.Expect(ingress).NotTo(o.ContainSubstring("6443"))
+		o.Expect(egress).To(o.ContainSubstring("type filter hook output priority 1; policy accept;"))
+		o.Expect(egress).To(o.ContainSubstring("tcp dport { 6443 } accept"))
+		o.Expect(egress).To(o.ContainSubstring("udp dport { 6081 } accept"))
+		o.Expect(egress).NotTo(o.ContainSubstring(" 22 "))
+		o.Expect(egress).To(o.ContainSubstring("        limit rate 1/minute log prefix \"firewall egress \"\n"))
+		o.Expect(egress).NotTo(o.ContainSubstring("drop"))
+	})
+
+	g.It("drops the other egress traffic with WithNFTEgressDrop", func() {
+		mat := ComMatrix{Ports: []ComDetails{{Direction: "Egress", Port: 6443, Protocol: "TCP", NodeGroup: "worker", Peer: "master"}}}
+
+		out, err := mat.ToNFTables(WithNFTEgressDrop())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		_, egress, found := strings.Cut(string(out), "chain OPENSHIFT_EGRESS {")
+		o.Expect(found).To(o.BeTrue())
+		o.Expect(egress).To(o.ContainSubstring("jump { limit rate 1/minute log prefix \"firewall egress \"; drop; }"))
+	})
+
+	g.It("has no egress chain without egress entries", func() {
+		mat := ComMatrix{Ports: []ComDetails{{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"}}}
+
+		out, err := mat.ToNFTables()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).NotTo(o.ContainSubstring("OPENSHIFT_EGRESS"))
+	})
+
+	g.It("round trips the peer through CSV", func() {
+		mat := ComMatrix{Ports: []ComDetails{{Direction: "Egress", Port: 53, Protocol: "UDP", NodeGroup: "master", Peer: "external"}}}
+
+		out, err := mat.ToCSV()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.Equal("Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional,Peer,SourceCIDRs,AddressFamily,BindScope,BindAddresses,Interface\n" +
+			"Egress,UDP,53,,,,,master,false,external,,,,,\n"))
+
+		parsed, err := ParseToComMatrix(out, FormatCSV)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(parsed.Ports).To(o.Equal(mat.Ports))
+	})
+})
+
+var _ = g.Describe("SCTP entries", func() {
+	g.It("writes the SCTP ports and ranges to their own rule", func() {
+		mat := ComMatrix{
+			Ports: []ComDetails{
+				{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"},
+				{Direction: "Ingress", Port: 38412, Protocol: "SCTP", NodeGroup: "worker"},
+			},
+			DynamicRanges: DynamicRangeList{
+				{Direction: "Ingress", Protocol: "SCTP", MinPort: 30000, MaxPort: 32767, Description: "SCTP node ports"},
+			},
+		}
+
+		out, err := mat.ToNFTables()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("tcp dport { 22 } accept"))
+		o.Expect(string(out)).To(o.ContainSubstring("sctp dport { 38412, 30000-32767 } accept"))
+	})
+
+	g.It("has no SCTP rule without SCTP entries", func() {
+		mat := ComMatrix{Ports: []ComDetails{{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"}}}
+
+		out, err := mat.ToNFTables()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).NotTo(o.ContainSubstring("sctp dport"))
+	})
+
+	g.It("writes the SCTP egress entries to the output hook chain", func() {
+		mat := ComMatrix{Ports: []ComDetails{{Direction: "Egress", Port: 36412, Protocol: "SCTP", NodeGroup: "worker", Peer: "external"}}}
+
+		out, err := mat.ToNFTables()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		_, egress, found := strings.Cut(string(out), "chain OPENSHIFT_EGRESS {")
+		o.Expect(found).To(o.BeTrue())
+		o.Expect(egress).To(o.ContainSubstring("sctp dport { 36412 } accept"))
+	})
+
+	g.It("round trips SCTP dynamic ranges through CSV", func() {
+		mat := ComMatrix{
+			Ports:         []ComDetails{{Direction: "Ingress", Port: 38412, Protocol: "SCTP", NodeGroup: "worker"}},
+			DynamicRanges: DynamicRangeList{{Direction: "Ingress", Protocol: "SCTP", MinPort: 30000, MaxPort: 32767, Description: "SCTP node ports"}},
+		}
+
+		out, err := mat.ToCSV()
+		o.Expect(err).ToNot(o.HaveOccurred())
+
+		parsed, err := ParseToComMatrix(out, FormatCSV)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(parsed.Ports).To(o.Equal(mat.Ports))
+		o.Expect(parsed.DynamicRanges).To(o.Equal(mat.DynamicRanges))
+	})
+})
+
+var _ = g.Describe("Source CIDRs", func() {
+	mat := ComMatrix{
+		Ports: []ComDetails{
+			{Direction: "Ingress", Port: 6443, Protocol: "TCP", NodeGroup: "master"},
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/16", "fd00::/48"}},
+			{Direction: "Ingress", Port: 2380, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"fd00::/48", "10.0.0.0/16"}},
+			{Direction: "Ingress", Port: 6081, Protocol: "UDP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/16"}},
+		},
+		DynamicRanges: DynamicRangeList{
+			{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767, SourceCIDRs: CIDRList{"192.0.2.10"}},
+		},
+	}
+
+	g.It("writes the restricted entries to per-source rules", func() {
+		out, err := mat.ToNFTables()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("        tcp dport { 6443 } accept\n\n" +
+			"        # Allow specific ports from their allowed sources\n" +
+			"        ip saddr { 10.0.0.0/16 } udp dport { 6081 } accept\n" +
+			"        ip saddr { 10.0.0.0/16 } tcp dport { 2379, 2380 } accept\n" +
+			"        ip6 saddr { fd00::/48 } tcp dport { 2379, 2380 } accept\n" +
+			"        ip saddr { 192.0.2.10 } tcp dport { 30000-32767 } accept\n"))
+		o.Expect(string(out)).NotTo(o.ContainSubstring("        udp dport"))
+	})
+
+	g.It("fails on invalid sources", func() {
+		invalid := ComMatrix{Ports: []ComDetails{{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"nodes"}}}}
+		_, err := invalid.ToNFTables()
+		o.Expect(err).To(o.HaveOccurred())
+	})
+
+	g.It("round trips the sources through CSV, JSON and YAML", func() {
+		for _, format := range []string{FormatCSV, FormatJSON, FormatYAML} {
+			out, err := mat.print(format, "", nil)
+			o.Expect(err).ToNot(o.HaveOccurred())
+
+			parsed, err := ParseToComMatrix(out, format)
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(parsed.Ports).To(o.Equal(mat.Ports), format)
+			o.Expect(parsed.DynamicRanges).To(o.Equal(mat.DynamicRanges), format)
+		}
+	})
+
+	g.It("merges the sources of a flow found with different sources", func() {
+		merged := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.1.0/24"}},
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/24", "10.0.1.0/24"}},
+			{Direction: "Ingress", Port: 6443, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/24"}},
+			{Direction: "Ingress", Port: 6443, Protocol: "TCP", NodeGroup: "master"},
+		}}
+		merged.SortAndRemoveDuplicates()
+		o.Expect(merged.Ports).To(o.Equal([]ComDetails{
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/24", "10.0.1.0/24"}},
+			{Direction: "Ingress", Port: 6443, Protocol: "TCP", NodeGroup: "master"},
+		}))
+	})
+
+	g.It("does not merge ranges with different sources", func() {
+		drl := DynamicRangeList{
+			{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 31000},
+			{Direction: "Ingress", Protocol: "TCP", MinPort: 31001, MaxPort: 32767, SourceCIDRs: CIDRList{"10.0.0.0/16"}},
+			{Direction: "Ingress", Protocol: "TCP", MinPort: 31001, MaxPort: 32000},
+		}
+		drl.Squash()
+		o.Expect(drl).To(o.HaveLen(2))
+		o.Expect(drl[0].PortRangeString()).To(o.Equal("30000-32000"))
+		o.Expect(drl[1].SourceCIDRs).To(o.Equal(CIDRList{"10.0.0.0/16"}))
+	})
+})
+
 var _ = g.Describe("Merge", func() {
 	g.It("merges two non-nil matrices with ports and ranges", func() {
 		m1 := &ComMatrix{
@@ -720,3 +924,940 @@ var _ = g.Describe("Merge", func() {
 		o.Expect(dr.Description).To(o.Equal("First"))
 	})
 })
+
+var _ = g.Describe("LocateEntries", func() {
+	g.It("returns the line of every CSV port row", func() {
+		content := []byte("Direction,Protocol,Port,Namespace,Service,Pod,Container,NodeGroup,Optional\n" +
+			"Ingress,TCP,9107,ns,svc,pod,ctr,worker,false\n" +
+			"Ingress,TCP,30000-32767,,,,,,false\n" +
+			"Ingress,UDP,6081,,,,,master,true\n")
+
+		located, err := LocateEntries(content, FormatCSV)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(located).To(o.HaveLen(2))
+		o.Expect(located[0].Line).To(o.Equal(2))
+		o.Expect(located[0].Entry.Port).To(o.Equal(9107))
+		o.Expect(located[0].Entry.NodeGroup).To(o.Equal("worker"))
+		o.Expect(located[1].Line).To(o.Equal(4))
+		o.Expect(located[1].Entry.Optional).To(o.BeTrue())
+	})
+
+	g.It("returns the line of every YAML port entry", func() {
+		content := []byte("ports:\n" +
+			"- direction: Ingress\n" +
+			"  protocol: TCP\n" +
+			"  port: 9107\n" +
+			"  nodeGroup: worker\n" +
+			"- direction: Ingress\n" +
+			"  protocol: UDP\n" +
+			"  port: 6081\n" +
+			"  nodeGroup: master\n")
+
+		located, err := LocateEntries(content, FormatYAML)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(located).To(o.HaveLen(2))
+		o.Expect(located[0].Line).To(o.Equal(2))
+		o.Expect(located[0].Entry.Port).To(o.Equal(9107))
+		o.Expect(located[1].Line).To(o.Equal(6))
+		o.Expect(located[1].Entry.Protocol).To(o.Equal("UDP"))
+	})
+
+	g.It("returns the line of every JSON port entry", func() {
+		content := []byte(`{
+  "Ports": [
+    {
+      "direction": "Ingress",
+      "protocol": "TCP",
+      "port": 9107,
+      "nodeGroup": "worker"
+    }
+  ]
+}`)
+
+		located, err := LocateEntries(content, FormatJSON)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(located).To(o.HaveLen(1))
+		o.Expect(located[0].Line).To(o.Equal(3))
+		o.Expect(located[0].Entry.Port).To(o.Equal(9107))
+	})
+
+	g.It("reports the line of an invalid entry", func() {
+		content := []byte("ports:\n- port: abc\n")
+
+		_, err := LocateEntries(content, FormatYAML)
+		o.Expect(err).To(o.MatchError(o.ContainSubstring("line 2")))
+	})
+})
+
+var _ = g.Describe("Per-flow nftables rules", func() {
+	mat := ComMatrix{
+		Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", Namespace: "Host system service", Service: "sshd", NodeGroup: "master"},
+			{Direction: "Ingress", Port: 9537, Protocol: "TCP", Namespace: "openshift-machine-config-operator", Service: "crio", Pod: "crio-\"x\"", NodeGroup: "master"},
+			{Direction: "Ingress", Port: 6081, Protocol: "UDP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/16", "fd00::/48"}},
+			{Direction: "Egress", Port: 6443, Protocol: "TCP", Namespace: "openshift-etcd", NodeGroup: "master", Peer: "master"},
+			{Direction: "Egress", Port: 6443, Protocol: "TCP", Namespace: "openshift-etcd", NodeGroup: "master", Peer: "external"},
+		},
+		DynamicRanges: DynamicRangeList{
+			{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767, Description: "Kubelet node ports"},
+		},
+	}
+
+	g.It("keeps the compact rules by default", func() {
+		out, err := mat.ToNFTables()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("tcp dport { 22, 9537, 30000-32767 } accept"))
+		o.Expect(string(out)).NotTo(o.ContainSubstring("comment"))
+		o.Expect(string(out)).NotTo(o.ContainSubstring("counter"))
+	})
+
+	g.It("writes one commented rule per flow", func() {
+		out, err := mat.ToNFTables(WithNFTRulePerFlow())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("table inet openshift_filter {\n    chain OPENSHIFT {"))
+		o.Expect(string(out)).To(o.ContainSubstring("        # Allow specific TCP, UDP and SCTP ports\n" +
+			"        tcp dport 22 accept comment \"Host system service/sshd\"\n" +
+			"        tcp dport 9537 accept comment \"openshift-machine-config-operator/crio/crio-x\"\n" +
+			"        tcp dport 30000-32767 accept comment \"Kubelet node ports\"\n\n" +
+			"        # Allow specific ports from their allowed sources\n" +
+			"        ip saddr { 10.0.0.0/16 } udp dport 6081 accept\n" +
+			"        ip6 saddr { fd00::/48 } udp dport 6081 accept\n"))
+
+		_, egress, found := strings.Cut(string(out), "chain OPENSHIFT_EGRESS {")
+		o.Expect(found).To(o.BeTrue())
+		o.Expect(strings.Count(egress, "tcp dport 6443 accept comment \"openshift-etcd\"")).To(o.Equal(1))
+	})
+
+	g.It("adds a named counter to every rule", func() {
+		out, err := mat.ToNFTables(WithNFTCounters())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("table inet openshift_filter {\n" +
+			"    counter in_tcp_22 {\n    }\n\n" +
+			"    counter in_tcp_9537 {\n    }\n\n" +
+			"    counter in_tcp_30000_32767 {\n    }\n\n" +
+			"    counter in_udp_6081 {\n    }\n\n" +
+			"    counter out_tcp_6443 {\n    }\n\n" +
+			"    chain OPENSHIFT {"))
+		o.Expect(string(out)).To(o.ContainSubstring("tcp dport 22 counter name \"in_tcp_22\" accept comment \"Host system service/sshd\""))
+		o.Expect(string(out)).To(o.ContainSubstring("ip saddr { 10.0.0.0/16 } udp dport 6081 counter name \"in_udp_6081\" accept"))
+		o.Expect(string(out)).To(o.ContainSubstring("ip6 saddr { fd00::/48 } udp dport 6081 counter name \"in_udp_6081\" accept"))
+		o.Expect(string(out)).To(o.ContainSubstring("tcp dport 6443 counter name \"out_tcp_6443\" accept"))
+	})
+
+	g.It("truncates long comments", func() {
+		long := ComMatrix{Ports: []ComDetails{{Direction: "Ingress", Port: 22, Protocol: "TCP", Namespace: strings.Repeat("n", 200), NodeGroup: "master"}}}
+		out, err := long.ToNFTables(WithNFTRulePerFlow())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("comment \"" + strings.Repeat("n", 128) + "\"\n"))
+	})
+})
+
+var _ = g.Describe("Optional flows exclusion", func() {
+	mat := ComMatrix{
+		Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", Namespace: "Host system service", Service: "sshd", NodeGroup: "master", Optional: true},
+			{Direction: "Ingress", Port: 111, Protocol: "TCP", Namespace: "Host system service", Service: "rpcbind", NodeGroup: "master", Optional: true},
+			{Direction: "Ingress", Port: 111, Protocol: "UDP", Namespace: "Host system service", Service: "rpcbind", NodeGroup: "master", Optional: true},
+			{Direction: "Ingress", Port: 6443, Protocol: "TCP", Namespace: "openshift-kube-apiserver", Service: "apiserver", NodeGroup: "master"},
+		},
+		DynamicRanges: DynamicRangeList{
+			{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767, Description: "Kubelet node ports", Optional: true},
+			{Direction: "Ingress", Protocol: "UDP", MinPort: 30000, MaxPort: 32767, Description: "Kubelet node ports", Optional: true},
+		},
+	}
+
+	g.It("excludes every optional flow without a selection", func() {
+		kept, excluded := mat.ExcludeOptional(nil)
+		o.Expect(kept.Ports).To(o.Equal(mat.Ports[3:]))
+		o.Expect(kept.DynamicRanges).To(o.BeEmpty())
+		o.Expect(excluded.Ports).To(o.Equal(mat.Ports[:3]))
+		o.Expect(excluded.DynamicRanges).To(o.Equal(mat.DynamicRanges))
+	})
+
+	g.It("keeps the optional flows matched by the selection", func() {
+		selection, err := ParseOptionalSelection([]byte(`keep:
+- service: sshd
+- namespace: Host system service
+  protocol: udp
+  port: 111
+- service: Kubelet node ports
+  protocol: TCP
+  port: 30080
+`))
+		o.Expect(err).ToNot(o.HaveOccurred())
+
+		kept, excluded := mat.ExcludeOptional(selection)
+		o.Expect(kept.Ports).To(o.Equal([]ComDetails{mat.Ports[0], mat.Ports[2], mat.Ports[3]}))
+		o.Expect(kept.DynamicRanges).To(o.Equal(mat.DynamicRanges[:1]))
+		o.Expect(excluded.Ports).To(o.Equal([]ComDetails{mat.Ports[1]}))
+		o.Expect(excluded.DynamicRanges).To(o.Equal(mat.DynamicRanges[1:]))
+	})
+
+	g.It("parses JSON selections", func() {
+		selection, err := ParseOptionalSelection([]byte(`{"keep": [{"port": 22}]}`))
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(selection.Keep).To(o.Equal([]OptionalSelector{{Port: 22}}))
+	})
+
+	g.It("rejects invalid selections", func() {
+		for _, content := range []string{
+			"keep:\n- {}\n",
+			"keep:\n- protocol: icmp\n",
+			"keep:\n- port: 70000\n",
+			"keep:\n- svc: sshd\n",
+		} {
+			_, err := ParseOptionalSelection([]byte(content))
+			o.Expect(err).To(o.HaveOccurred(), content)
+		}
+	})
+})
+
+var _ = g.Describe("Address families", func() {
+	g.It("allows both families for a flow found for each family", func() {
+		mat := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", AddressFamily: AddressFamilyIPv4},
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", AddressFamily: AddressFamilyIPv6},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "master", AddressFamily: AddressFamilyIPv6},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "master", AddressFamily: AddressFamilyIPv6},
+		}}
+		mat.SortAndRemoveDuplicates()
+		o.Expect(mat.Ports).To(o.Equal([]ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master"},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "master", AddressFamily: AddressFamilyIPv6},
+		}))
+	})
+
+	g.It("finds the family of the node groups", func() {
+		node := func(name string, addresses ...string) corev1.Node {
+			n := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+			for _, a := range addresses {
+				n.Status.Addresses = append(n.Status.Addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: a})
+			}
+			return n
+		}
+		nodes := []corev1.Node{
+			node("master-0", "10.0.0.1"),
+			node("worker-0", "10.0.0.2", "fd00::2"),
+			node("edge-0", "fd00::3"),
+			node("infra-0"),
+		}
+		nodeToGroup := map[string]string{"master-0": "master", "worker-0": "worker", "edge-0": "edge", "infra-0": "infra"}
+
+		o.Expect(BuildNodeGroupAddressFamilies(nodes, nodeToGroup, []string{"fd00::/64"})).To(o.Equal(map[string]string{
+			"master": AddressFamilyIPv4,
+			"worker": "",
+			"edge":   AddressFamilyIPv6,
+			"infra":  AddressFamilyIPv6,
+		}))
+		o.Expect(BuildNodeGroupAddressFamilies(nodes, nodeToGroup, nil)).To(o.HaveKeyWithValue("infra", ""))
+	})
+
+	g.It("limits the entries of the single-stack node groups to their family", func() {
+		mat := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master"},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "master", AddressFamily: AddressFamilyIPv6},
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "worker", AddressFamily: AddressFamilyIPv6},
+		}}
+		mat.ApplyNodeGroupAddressFamilies(map[string]string{"master": AddressFamilyIPv4, "worker": ""})
+		o.Expect(mat.Ports).To(o.Equal([]ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", AddressFamily: AddressFamilyIPv4},
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "worker", AddressFamily: AddressFamilyIPv6},
+		}))
+	})
+
+	g.Describe("ToNFTables", func() {
+		mat := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"},
+			{Direction: "Ingress", Port: 68, Protocol: "UDP", NodeGroup: "worker", AddressFamily: AddressFamilyIPv4},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "worker", AddressFamily: AddressFamilyIPv6},
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "worker", AddressFamily: AddressFamilyIPv4, SourceCIDRs: CIDRList{"10.0.0.0/16", "fd00::/48"}},
+		}}
+
+		g.It("matches the family of the single-stack flows", func() {
+			out, err := mat.ToNFTables()
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(string(out)).To(o.ContainSubstring("        # Allow specific TCP, UDP and SCTP ports\n" +
+				"        tcp dport { 22 } accept\n" +
+				"        meta nfproto ipv4 udp dport { 68 } accept\n" +
+				"        meta nfproto ipv6 udp dport { 546 } accept\n\n" +
+				"        # Allow specific ports from their allowed sources\n" +
+				"        ip saddr { 10.0.0.0/16 } tcp dport { 2379 } accept\n\n"))
+		})
+
+		g.It("matches the family of the single-stack flows with one rule per flow", func() {
+			out, err := mat.ToNFTables(WithNFTRulePerFlow())
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(string(out)).To(o.ContainSubstring("        # Allow specific TCP, UDP and SCTP ports\n" +
+				"        tcp dport 22 accept\n" +
+				"        meta nfproto ipv4 udp dport 68 accept\n" +
+				"        meta nfproto ipv6 udp dport 546 accept\n\n" +
+				"        # Allow specific ports from their allowed sources\n" +
+				"        ip saddr { 10.0.0.0/16 } tcp dport 2379 accept\n\n"))
+		})
+	})
+
+	g.It("round trips the family through CSV", func() {
+		mat := ComMatrix{Ports: []ComDetails{{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "worker", AddressFamily: AddressFamilyIPv6}}}
+
+		out, err := mat.ToCSV()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.HaveSuffix("Ingress,UDP,546,,,,,worker,false,,,IPv6,,,\n"))
+
+		parsed, err := ParseToComMatrix(out, FormatCSV)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(parsed.Ports).To(o.Equal(mat.Ports))
+	})
+})
+
+var _ = g.Describe("Bind addresses", func() {
+	g.It("merges the addresses of a flow found on several nodes", func() {
+		mat := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "master"},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeNodeIP, BindAddresses: CIDRList{"10.0.0.2"}},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeVIP, BindAddresses: CIDRList{"10.0.0.100"}},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeNodeIP, BindAddresses: CIDRList{"10.0.0.1"}},
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeNodeIP, BindAddresses: CIDRList{"10.0.0.1"}},
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeWildcard},
+		}}
+		mat.SortAndRemoveDuplicates()
+		o.Expect(mat.Ports).To(o.Equal([]ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeWildcard},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeVIP, BindAddresses: CIDRList{"10.0.0.1", "10.0.0.100", "10.0.0.2"}},
+		}))
+	})
+
+	g.Describe("ToNFTables", func() {
+		mat := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeWildcard},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeNodeIP, BindAddresses: CIDRList{"10.0.0.1", "fd00::1"}},
+			{Direction: "Ingress", Port: 9101, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeNodeIP, BindAddresses: CIDRList{"fd00::1", "10.0.0.1"}},
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", BindScope: BindScopeNodeIP, BindAddresses: CIDRList{"10.0.0.1"}, SourceCIDRs: CIDRList{"10.0.0.0/16"}},
+		}}
+
+		g.It("ignores the bound addresses by default", func() {
+			out, err := mat.ToNFTables()
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(string(out)).To(o.ContainSubstring("tcp dport { 22, 9100, 9101 } accept"))
+			o.Expect(string(out)).NotTo(o.ContainSubstring("daddr { 10.0.0.1"))
+		})
+
+		g.It("only allows the flows on their bound addresses", func() {
+			out, err := mat.ToNFTables(WithNFTBindAddressRules())
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(string(out)).To(o.ContainSubstring("        # Allow specific TCP, UDP and SCTP ports\n" +
+				"        tcp dport { 22 } accept\n\n" +
+				"        # Allow specific ports on the addresses their sockets are bound to\n" +
+				"        ip daddr { 10.0.0.1 } tcp dport { 9100, 9101 } accept\n" +
+				"        ip6 daddr { fd00::1 } tcp dport { 9100, 9101 } accept\n\n" +
+				"        # Allow specific ports from their allowed sources\n" +
+				"        ip saddr { 10.0.0.0/16 } tcp dport { 2379 } accept\n\n"))
+		})
+
+		g.It("only allows the flows on their bound addresses with one rule per flow", func() {
+			out, err := mat.ToNFTables(WithNFTBindAddressRules(), WithNFTRulePerFlow())
+			o.Expect(err).ToNot(o.HaveOccurred())
+			o.Expect(string(out)).To(o.ContainSubstring("        # Allow specific TCP, UDP and SCTP ports\n" +
+				"        tcp dport 22 accept\n\n" +
+				"        # Allow specific ports on the addresses their sockets are bound to\n" +
+				"        ip daddr { 10.0.0.1 } tcp dport 9100 accept\n" +
+				"        ip6 daddr { fd00::1 } tcp dport 9100 accept\n" +
+				"        ip daddr { 10.0.0.1 } tcp dport 9101 accept\n" +
+				"        ip6 daddr { fd00::1 } tcp dport 9101 accept\n\n" +
+				"        # Allow specific ports from their allowed sources\n" +
+				"        ip saddr { 10.0.0.0/16 } tcp dport 2379 accept\n\n"))
+		})
+	})
+})
+
+var _ = g.Describe("Interfaces", func() {
+	mat := ComMatrix{Ports: []ComDetails{
+		{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"},
+		{Direction: "Ingress", Port: 38412, Protocol: "SCTP", NodeGroup: "worker", Interface: "net1"},
+		{Direction: "Ingress", Port: 8805, Protocol: "UDP", NodeGroup: "worker", Interface: "net1", SourceCIDRs: CIDRList{"192.168.100.0/24"}},
+		{Direction: "Ingress", Port: 6443, Protocol: "TCP", NodeGroup: "worker", Interface: "br-ex"},
+		{Direction: "Egress", Port: 443, Protocol: "TCP", NodeGroup: "worker", Peer: "external"},
+	}}
+
+	g.It("allows the entries on every interface by default", func() {
+		out, err := mat.ToNFTables()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("        # Allow specific TCP, UDP and SCTP ports\n" +
+			"        tcp dport { 22, 6443 } accept\n" +
+			"        sctp dport { 38412 } accept\n\n" +
+			"        # Allow specific ports from their allowed sources\n" +
+			"        ip saddr { 192.168.100.0/24 } udp dport { 8805 } accept\n\n"))
+		o.Expect(string(out)).NotTo(o.ContainSubstring("iifname"))
+	})
+
+	g.It("scopes the rules of the entries to their interface", func() {
+		out, err := mat.ToNFTables(WithNFTInterfaceRules())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("        # Allow specific TCP, UDP and SCTP ports\n" +
+			"        tcp dport { 22 } accept\n" +
+			"        iifname \"br-ex\" tcp dport { 6443 } accept\n" +
+			"        iifname \"net1\" sctp dport { 38412 } accept\n\n" +
+			"        # Allow specific ports from their allowed sources\n" +
+			"        iifname \"net1\" ip saddr { 192.168.100.0/24 } udp dport { 8805 } accept\n\n"))
+		o.Expect(string(out)).To(o.ContainSubstring("        tcp dport { 443 } accept"))
+		o.Expect(string(out)).NotTo(o.ContainSubstring("iifname !="))
+	})
+
+	g.It("scopes the rules of the entries to their interface with one rule per flow", func() {
+		out, err := mat.ToNFTables(WithNFTInterfaceRules(), WithNFTRulePerFlow())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("        iifname \"net1\" sctp dport 38412 accept\n"))
+		o.Expect(string(out)).To(o.ContainSubstring("        iifname \"net1\" ip saddr { 192.168.100.0/24 } udp dport 8805 accept\n"))
+	})
+
+	g.It("drops the traffic of the other interfaces", func() {
+		out, err := mat.ToNFTables(WithNFTDropOtherInterfaces())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("        ip6 nexthdr ipv6-icmp accept\n\n" +
+			"        # Drop the traffic of the interfaces without flows\n" +
+			"        iifname != { \"br-ex\", \"net1\" } drop\n\n" +
+			"        # Allow specific TCP, UDP and SCTP ports\n"))
+	})
+
+	g.It("fails to drop the traffic of the other interfaces without an entry on the primary interface", func() {
+		secondaryOnly := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "worker", Interface: "ens4"},
+		}}
+		_, err := secondaryOnly.ToNFTables(WithNFTDropOtherInterfaces())
+		o.Expect(err).To(o.MatchError(o.ContainSubstring("no entry is scoped to the primary interface br-ex")))
+
+		_, err = secondaryOnly.ToNFTables(WithNFTInterfaceRules())
+		o.Expect(err).ToNot(o.HaveOccurred())
+	})
+
+	g.It("keeps the traffic of every interface without interfaces", func() {
+		withoutInterfaces := ComMatrix{Ports: []ComDetails{{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master"}}}
+		out, err := withoutInterfaces.ToNFTables(WithNFTDropOtherInterfaces())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		expected, err := withoutInterfaces.ToNFTables()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(out).To(o.Equal(expected))
+	})
+
+	g.It("does not scope a flow found on different interfaces", func() {
+		merged := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 8805, Protocol: "UDP", NodeGroup: "worker", Interface: "net1"},
+			{Direction: "Ingress", Port: 8805, Protocol: "UDP", NodeGroup: "worker", Interface: "net1"},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "worker", Interface: "net1"},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "worker", Interface: "ens4"},
+		}}
+		merged.SortAndRemoveDuplicates()
+		o.Expect(merged.Ports).To(o.Equal([]ComDetails{
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "worker"},
+			{Direction: "Ingress", Port: 8805, Protocol: "UDP", NodeGroup: "worker", Interface: "net1"},
+		}))
+	})
+
+	g.It("rejects invalid interface names", func() {
+		invalid := ComMatrix{Ports: []ComDetails{{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker", Interface: "eth0\" accept"}}}
+		_, err := invalid.ToNFTables()
+		o.Expect(err).To(o.HaveOccurred())
+	})
+
+	g.It("round trips the interface through CSV", func() {
+		out, err := mat.ToCSV()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("Ingress,SCTP,38412,,,,,worker,false,,,,,,net1\n"))
+
+		parsed, err := ParseToComMatrix(out, FormatCSV)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(parsed.Ports).To(o.Equal(mat.Ports))
+	})
+})
+
+var _ = g.Describe("Firewalld zones", func() {
+	mat := ComMatrix{
+		Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", Namespace: "Host system service", Service: "sshd"},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "master", Namespace: "openshift-monitoring", Service: "node-exporter", Pod: "node-exporter"},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "master", Namespace: "openshift-monitoring", Service: "kube-rbac-proxy"},
+			{Direction: "Ingress", Port: 6081, Protocol: "UDP", NodeGroup: "master", Service: "ovn-kubernetes"},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "master", AddressFamily: AddressFamilyIPv6},
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", Service: "etcd", SourceCIDRs: CIDRList{"10.0.0.0/16", "fd00:10::/64"}},
+			{Direction: "Egress", Port: 443, Protocol: "TCP", NodeGroup: "master", Peer: "external"},
+		},
+		DynamicRanges: []DynamicRange{
+			{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767, Description: "Kubelet node ports"},
+			{Direction: "Egress", Protocol: "TCP", MinPort: 32768, MaxPort: 60999, Description: "Ephemeral ports"},
+		},
+	}
+
+	g.It("writes a zone allowing the ingress flows of the node group", func() {
+		out, err := mat.ToFirewalld("master")
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.Equal(`<?xml version="1.0" encoding="utf-8"?>
+<zone target="DROP">
+  <short>OpenShift master</short>
+  <description>Ingress flows of the master node group from the OpenShift communication matrix: 6 entries and 1 dynamic ranges.</description>
+  <!-- ICMP -->
+  <rule>
+    <protocol value="icmp"/>
+    <accept/>
+  </rule>
+  <rule>
+    <protocol value="ipv6-icmp"/>
+    <accept/>
+  </rule>
+  <!-- Host system service/sshd -->
+  <port protocol="tcp" port="22"/>
+  <!-- openshift-monitoring/node-exporter/node-exporter, openshift-monitoring/kube-rbac-proxy -->
+  <port protocol="tcp" port="9100"/>
+  <!-- Kubelet node ports -->
+  <port protocol="tcp" port="30000-32767"/>
+  <!-- ovn-kubernetes -->
+  <port protocol="udp" port="6081"/>
+  <rule family="ipv6">
+    <port protocol="udp" port="546"/>
+    <accept/>
+  </rule>
+  <!-- etcd -->
+  <rule family="ipv4">
+    <source address="10.0.0.0/16"/>
+    <port protocol="tcp" port="2379"/>
+    <accept/>
+  </rule>
+  <!-- etcd -->
+  <rule family="ipv6">
+    <source address="fd00:10::/64"/>
+    <port protocol="tcp" port="2379"/>
+    <accept/>
+  </rule>
+</zone>
+`))
+	})
+
+	g.It("escapes the metadata of the flows", func() {
+		escaped := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 8080, Protocol: "TCP", NodeGroup: "worker", Namespace: "a&b", Service: "svc--<x>"},
+		}}
+		out, err := escaped.ToFirewalld("worker")
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("  <!-- a&b/svc-<x> -->\n"))
+	})
+
+	g.It("rejects invalid sources", func() {
+		invalid := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"bad"}},
+		}}
+		_, err := invalid.ToFirewalld("master")
+		o.Expect(err).To(o.HaveOccurred())
+	})
+})
+
+var _ = g.Describe("iptables rules", func() {
+	mat := ComMatrix{
+		Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master"},
+			{Direction: "Ingress", Port: 6081, Protocol: "UDP", NodeGroup: "master"},
+			{Direction: "Ingress", Port: 67, Protocol: "UDP", NodeGroup: "master", AddressFamily: AddressFamilyIPv4},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "master", AddressFamily: AddressFamilyIPv6},
+			{Direction: "Ingress", Port: 38412, Protocol: "SCTP", NodeGroup: "master", Interface: "net1"},
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/16", "fd00:10::/64"}},
+			{Direction: "Ingress", Port: 2380, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/16", "fd00:10::/64"}},
+			{Direction: "Egress", Port: 443, Protocol: "TCP", NodeGroup: "master", Peer: "external"},
+		},
+		DynamicRanges: []DynamicRange{
+			{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767, Description: "Kubelet node ports"},
+		},
+	}
+
+	g.It("writes the iptables-restore rules of IPv4", func() {
+		out, err := mat.ToIPTables(AddressFamilyIPv4)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.Equal(`*filter
+:INPUT ACCEPT [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:OPENSHIFT - [0:0]
+:OPENSHIFT_EGRESS - [0:0]
+-A INPUT -j OPENSHIFT
+
+# Allow loopback traffic
+-A OPENSHIFT -i lo -j ACCEPT
+
+# Allow established and related traffic
+-A OPENSHIFT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+
+# Allow ICMP
+-A OPENSHIFT -p icmp -j ACCEPT
+
+# Allow specific TCP, UDP and SCTP ports
+-A OPENSHIFT -p tcp -m multiport --dports 22,30000:32767 -j ACCEPT
+-A OPENSHIFT -p udp -m multiport --dports 6081,67 -j ACCEPT
+-A OPENSHIFT -p sctp -m multiport --dports 38412 -j ACCEPT
+
+# Allow specific ports from their allowed sources
+-A OPENSHIFT -s 10.0.0.0/16 -p tcp -m multiport --dports 2379,2380 -j ACCEPT
+
+# Drop broadcast traffic with rate-limited logging
+-A OPENSHIFT -d 255.255.255.255/32 -m limit --limit 1/minute -j LOG --log-prefix "firewall "
+-A OPENSHIFT -d 255.255.255.255/32 -j DROP
+
+# Rate-limited logging and default drop
+-A OPENSHIFT -m limit --limit 1/minute -j LOG --log-prefix "firewall "
+-A OPENSHIFT -j DROP
+
+-A OUTPUT -j OPENSHIFT_EGRESS
+
+# Allow loopback traffic
+-A OPENSHIFT_EGRESS -o lo -j ACCEPT
+
+# Allow established and related traffic
+-A OPENSHIFT_EGRESS -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+
+# Allow ICMP
+-A OPENSHIFT_EGRESS -p icmp -j ACCEPT
+
+# Allow specific TCP, UDP and SCTP ports
+-A OPENSHIFT_EGRESS -p tcp -m multiport --dports 443 -j ACCEPT
+
+# Rate-limited logging of the other egress traffic
+-A OPENSHIFT_EGRESS -m limit --limit 1/minute -j LOG --log-prefix "firewall egress "
+COMMIT
+`))
+	})
+
+	g.It("scopes the rules of the entries to their interface", func() {
+		out, err := mat.ToIPTables(AddressFamilyIPv4, WithNFTInterfaceRules())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("-A OPENSHIFT -p udp -m multiport --dports 6081,67 -j ACCEPT\n" +
+			"-A OPENSHIFT -i net1 -p sctp -m multiport --dports 38412 -j ACCEPT\n"))
+	})
+
+	g.It("drops the other egress traffic with WithNFTEgressDrop", func() {
+		out, err := mat.ToIPTables(AddressFamilyIPv4, WithNFTEgressDrop())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.HaveSuffix("# Rate-limited logging and default drop\n" +
+			"-A OPENSHIFT_EGRESS -m limit --limit 1/minute -j LOG --log-prefix \"firewall egress \"\n" +
+			"-A OPENSHIFT_EGRESS -j DROP\n" +
+			"COMMIT\n"))
+	})
+
+	g.It("writes the ip6tables-restore rules of IPv6", func() {
+		out, err := mat.ToIPTables(AddressFamilyIPv6)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring("-A OPENSHIFT -p ipv6-icmp -j ACCEPT\n"))
+		o.Expect(string(out)).To(o.ContainSubstring("-A OPENSHIFT -p udp -m multiport --dports 6081,546 -j ACCEPT\n"))
+		o.Expect(string(out)).To(o.ContainSubstring("-A OPENSHIFT -s fd00:10::/64 -p tcp -m multiport --dports 2379,2380 -j ACCEPT\n"))
+		o.Expect(string(out)).NotTo(o.ContainSubstring("255.255.255.255"))
+		o.Expect(string(out)).NotTo(o.ContainSubstring("10.0.0.0/16"))
+	})
+
+	g.It("splits the ports a multiport match cannot hold", func() {
+		var many ComMatrix
+		for port := 9000; port < 9015; port++ {
+			many.Ports = append(many.Ports, ComDetails{Direction: "Ingress", Port: port, Protocol: "TCP", NodeGroup: "worker"})
+		}
+		many.DynamicRanges = []DynamicRange{{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767}}
+
+		out, err := many.ToIPTables(AddressFamilyIPv4)
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(string(out)).To(o.ContainSubstring(
+			"-A OPENSHIFT -p tcp -m multiport --dports 9000,9001,9002,9003,9004,9005,9006,9007,9008,9009,9010,9011,9012,9013,9014 -j ACCEPT\n" +
+				"-A OPENSHIFT -p tcp -m multiport --dports 30000:32767 -j ACCEPT\n"))
+	})
+
+	g.It("rejects unknown address families", func() {
+		_, err := mat.ToIPTables("IPv5")
+		o.Expect(err).To(o.HaveOccurred())
+	})
+})
+
+var _ = g.Describe("nftables JSON", func() {
+	mat := ComMatrix{
+		Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", Namespace: "Host system service", Service: "sshd"},
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/16"}},
+			{Direction: "Egress", Port: 443, Protocol: "TCP", NodeGroup: "master", Peer: "external"},
+		},
+		DynamicRanges: DynamicRangeList{
+			{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767, Description: "Kubernetes node ports"},
+		},
+	}
+
+	compact := func(out []byte) string {
+		var buf bytes.Buffer
+		o.Expect(json.Compact(&buf, out)).To(o.Succeed())
+		return buf.String()
+	}
+
+	g.It("writes the table and chains of the nft rules", func() {
+		out, err := mat.ToNFTablesJSON()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(compact(out)).To(o.HavePrefix(`{"nftables":[` +
+			`{"metainfo":{"json_schema_version":1}},` +
+			`{"table":{"family":"inet","name":"openshift_filter"}},` +
+			`{"chain":{"family":"inet","table":"openshift_filter","name":"OPENSHIFT","type":"filter","hook":"input","prio":1,"policy":"accept"}},` +
+			`{"rule":{"family":"inet","table":"openshift_filter","chain":"OPENSHIFT","expr":[{"match":{"op":"==","left":{"meta":{"key":"iif"}},"right":"lo"}},{"accept":null}]}},` +
+			`{"rule":{"family":"inet","table":"openshift_filter","chain":"OPENSHIFT","expr":[{"match":{"op":"in","left":{"ct":{"key":"state"}},"right":["established","related"]}},{"accept":null}]}},`))
+		o.Expect(compact(out)).To(o.ContainSubstring(
+			`{"chain":{"family":"inet","table":"openshift_filter","name":"OPENSHIFT_EGRESS","type":"filter","hook":"output","prio":1,"policy":"accept"}}`))
+	})
+
+	g.It("writes the ports, ranges and sources of the rules", func() {
+		out, err := mat.ToNFTablesJSON()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(compact(out)).To(o.ContainSubstring(
+			`"expr":[{"match":{"op":"==","left":{"payload":{"protocol":"tcp","field":"dport"}},"right":{"set":[22,{"range":[30000,32767]}]}}},{"accept":null}]`))
+		o.Expect(compact(out)).To(o.ContainSubstring(
+			`"expr":[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"saddr"}},"right":{"set":[{"prefix":{"addr":"10.0.0.0","len":16}}]}}},` +
+				`{"match":{"op":"==","left":{"payload":{"protocol":"tcp","field":"dport"}},"right":{"set":[2379]}}},{"accept":null}]`))
+	})
+
+	g.It("writes the rate-limited logging before the drop rule", func() {
+		out, err := mat.ToNFTablesJSON()
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(compact(out)).To(o.ContainSubstring(
+			`{"rule":{"family":"inet","table":"openshift_filter","chain":"OPENSHIFT","expr":[{"limit":{"rate":1,"per":"minute"}},{"log":{"prefix":"firewall "}}]}},` +
+				`{"rule":{"family":"inet","table":"openshift_filter","chain":"OPENSHIFT","expr":[{"drop":null}]}}`))
+	})
+
+	g.It("writes the counters and comments of the rules per flow", func() {
+		out, err := mat.ToNFTablesJSON(WithNFTCounters())
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(compact(out)).To(o.ContainSubstring(`{"counter":{"family":"inet","table":"openshift_filter","name":"in_tcp_22"}}`))
+		o.Expect(compact(out)).To(o.ContainSubstring(
+			`"expr":[{"match":{"op":"==","left":{"payload":{"protocol":"tcp","field":"dport"}},"right":22}},{"counter":"in_tcp_22"},{"accept":null}],"comment":"Host system service/sshd"`))
+		o.Expect(compact(out)).To(o.ContainSubstring(`"right":{"range":[30000,32767]}}},{"counter":"in_tcp_30000_32767"},{"accept":null}],"comment":"Kubernetes node ports"`))
+	})
+
+	g.It("holds the rules of the nft format", func() {
+		for _, opts := range [][]NFTOption{nil, {WithNFTCounters()}, {WithNFTEgressDrop()}} {
+			text, err := mat.ToNFTables(opts...)
+			o.Expect(err).ToNot(o.HaveOccurred())
+			out, err := mat.ToNFTablesJSON(opts...)
+			o.Expect(err).ToNot(o.HaveOccurred())
+
+			var doc struct {
+				Nftables []map[string]any `json:"nftables"`
+			}
+			o.Expect(json.Unmarshal(out, &doc)).To(o.Succeed())
+			rules := 0
+			for _, obj := range doc.Nftables {
+				if _, ok := obj["rule"]; ok {
+					rules++
+				}
+			}
+			// Every rule of the text format is a JSON rule, the logging rules with a verdict are two.
+			textRules := strings.Count(string(text), " accept\n") + strings.Count(string(text), " accept comment") +
+				2*strings.Count(string(text), "jump { limit rate") + strings.Count(string(text), "limit rate 1/minute log prefix \"firewall egress \"\n")
+			o.Expect(rules).To(o.Equal(textRules))
+		}
+	})
+})
+
+var _ = g.Describe("IngressNodeFirewall CRs", func() {
+	mat := ComMatrix{
+		Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master"},
+			{Direction: "Ingress", Port: 2379, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"10.0.0.0/16", "fd00::/48"}},
+			{Direction: "Ingress", Port: 546, Protocol: "UDP", NodeGroup: "master", AddressFamily: AddressFamilyIPv6},
+			{Direction: "Egress", Port: 443, Protocol: "TCP", NodeGroup: "master"},
+		},
+		DynamicRanges: DynamicRangeList{{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767}},
+	}
+
+	parseAll := func(out []byte) []ingressNodeFirewall {
+		var res []ingressNodeFirewall
+		for _, doc := range strings.Split(string(out), "---\n") {
+			var cr ingressNodeFirewall
+			o.Expect(yaml.Unmarshal([]byte(doc), &cr)).To(o.Succeed())
+			res = append(res, cr)
+		}
+		return res
+	}
+	parse := func(out []byte) ingressNodeFirewall {
+		crs := parseAll(out)
+		o.Expect(crs).To(o.HaveLen(1))
+		return crs[0]
+	}
+	rule := func(order int, protocol, ports, action string) ingressNodeFirewallRule {
+		config := ingressNodeFirewallProtocolConfig{Protocol: protocol}
+		switch protocol {
+		case ProtocolTCP:
+			config.TCP = &ingressNodeFirewallPorts{Ports: ports}
+		case ProtocolUDP:
+			config.UDP = &ingressNodeFirewallPorts{Ports: ports}
+		case ProtocolSCTP:
+			config.SCTP = &ingressNodeFirewallPorts{Ports: ports}
+		}
+		return ingressNodeFirewallRule{Order: order, ProtocolConfig: config, Action: action}
+	}
+	denyRules := func(order int) []ingressNodeFirewallRule {
+		var res []ingressNodeFirewallRule
+		for _, protocol := range NFTProtocols {
+			res = append(res, rule(order, protocol, "1-32767", "Deny"), rule(order+1, protocol, "61000-65535", "Deny"))
+			order += 2
+		}
+		return res
+	}
+
+	g.It("selects the nodes of the role of the node group", func() {
+		out, err := mat.ToIngressNodeFirewall("master")
+		o.Expect(err).ToNot(o.HaveOccurred())
+		cr := parse(out)
+		o.Expect(cr.APIVersion).To(o.Equal("ingressnodefirewall.openshift.io/v1alpha1"))
+		o.Expect(cr.Kind).To(o.Equal("IngressNodeFirewall"))
+		o.Expect(cr.Metadata.Name).To(o.Equal("commatrix-master"))
+		o.Expect(cr.Spec.NodeSelector.MatchLabels).To(o.Equal(map[string]string{"node-role.kubernetes.io/master": ""}))
+		o.Expect(cr.Spec.Interfaces).To(o.Equal([]string{"br-ex"}))
+	})
+
+	g.It("selects the nodes of a custom node group with its selector", func() {
+		selector, err := labels.Parse("node-role.kubernetes.io/ingress,zone in (a,b)")
+		o.Expect(err).ToNot(o.HaveOccurred())
+
+		out, err := mat.ToIngressNodeFirewall("mc-ingress", WithNodeGroupSelectors(map[string]labels.Selector{"mc-ingress": selector}))
+		o.Expect(err).ToNot(o.HaveOccurred())
+		cr := parse(out)
+		o.Expect(cr.Metadata.Name).To(o.Equal("commatrix-mc-ingress"))
+		o.Expect(cr.Spec.NodeSelector.MatchLabels).To(o.BeEmpty())
+		o.Expect(cr.Spec.NodeSelector.MatchExpressions).To(o.Equal([]metav1.LabelSelectorRequirement{
+			{Key: "node-role.kubernetes.io/ingress", Operator: metav1.LabelSelectorOpExists},
+			{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+		}))
+	})
+
+	g.It("allows the flows of every source before denying the other ports", func() {
+		out, err := mat.ToIngressNodeFirewall("master")
+		o.Expect(err).ToNot(o.HaveOccurred())
+		o.Expect(parse(out).Spec.Ingress).To(o.Equal([]ingressNodeFirewallRules{
+			{
+				SourceCIDRs: []string{"0.0.0.0/0"},
+				Rules: append([]ingressNodeFirewallRule{
+					rule(1, ProtocolTCP, "22", "Allow"),
+					rule(2, ProtocolTCP, "30000-32767", "Allow"),
+				}, denyRules(3)...),
+			},
+			{
+				SourceCIDRs: []string{"::/0"},
+				Rules: append([]ingressNodeFirewallRule{
+					rule(1, ProtocolTCP, "22", "Allow"),
+					rule(2, ProtocolTCP, "30000-32767", "Allow"),
+					rule(3, ProtocolUDP, "546", "Allow"),
+				}, denyRules(4)...),
+			},
+			{
+				SourceCIDRs: []string{"10.0.0.0/16"},
+				Rules: append([]ingressNodeFirewallRule{
+					rule(1, ProtocolTCP, "22", "Allow"),
+					rule(2, ProtocolTCP, "2379", "Allow"),
+					rule(3, ProtocolTCP, "30000-32767", "Allow"),
+				}, denyRules(4)...),
+			},
+			{
+				SourceCIDRs: []string{"fd00::/48"},
+				Rules: append([]ingressNodeFirewallRule{
+					rule(1, ProtocolTCP, "22", "Allow"),
+					rule(2, ProtocolTCP, "2379", "Allow"),
+					rule(3, ProtocolTCP, "30000-32767", "Allow"),
+					rule(4, ProtocolUDP, "546", "Allow"),
+				}, denyRules(5)...),
+			},
+		}))
+	})
+
+	g.It("shares the rules of the sources allowing the same flows", func() {
+		shared := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"},
+			{Direction: "Ingress", Port: 9100, Protocol: "TCP", NodeGroup: "worker", Interface: "br-ex"},
+			{Direction: "Ingress", Port: 9101, Protocol: "TCP", NodeGroup: "worker"},
+			{Direction: "Ingress", Port: 10250, Protocol: "TCP", NodeGroup: "worker", SourceCIDRs: CIDRList{"192.0.2.10", "192.0.2.11"}},
+		}}
+
+		out, err := shared.ToIngressNodeFirewall("worker")
+		o.Expect(err).ToNot(o.HaveOccurred())
+		cr := parse(out)
+		o.Expect(cr.Spec.Interfaces).To(o.Equal([]string{"br-ex"}))
+		o.Expect(cr.Spec.Ingress).To(o.HaveLen(2))
+		o.Expect(cr.Spec.Ingress[0].SourceCIDRs).To(o.Equal([]string{"0.0.0.0/0", "::/0"}))
+		o.Expect(cr.Spec.Ingress[0].Rules[:2]).To(o.Equal([]ingressNodeFirewallRule{
+			rule(1, ProtocolTCP, "22", "Allow"),
+			rule(2, ProtocolTCP, "9100-9101", "Allow"),
+		}))
+		o.Expect(cr.Spec.Ingress[1].SourceCIDRs).To(o.Equal([]string{"192.0.2.10/32", "192.0.2.11/32"}))
+		o.Expect(cr.Spec.Ingress[1].Rules[2]).To(o.Equal(rule(3, ProtocolTCP, "10250", "Allow")))
+	})
+
+	g.It("writes a CR per interface with the entries without an interface on br-ex", func() {
+		mixed := ComMatrix{
+			Ports: []ComDetails{
+				{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker"},
+				{Direction: "Ingress", Port: 38412, Protocol: "SCTP", NodeGroup: "worker", Interface: "net1"},
+				{Direction: "Ingress", Port: 8805, Protocol: "UDP", NodeGroup: "worker", Interface: "Net_2"},
+			},
+			DynamicRanges: DynamicRangeList{{Direction: "Ingress", Protocol: "TCP", MinPort: 30000, MaxPort: 32767}},
+		}
+
+		out, err := mixed.ToIngressNodeFirewall("worker")
+		o.Expect(err).ToNot(o.HaveOccurred())
+		crs := parseAll(out)
+		o.Expect(crs).To(o.HaveLen(3))
+
+		o.Expect(crs[0].Metadata.Name).To(o.Equal("commatrix-worker-net-2"))
+		o.Expect(crs[0].Spec.Interfaces).To(o.Equal([]string{"Net_2"}))
+		o.Expect(crs[0].Spec.Ingress[0].Rules[:3]).To(o.Equal([]ingressNodeFirewallRule{
+			rule(1, ProtocolTCP, "22", "Allow"),
+			rule(2, ProtocolTCP, "30000-32767", "Allow"),
+			rule(3, ProtocolUDP, "8805", "Allow"),
+		}))
+
+		o.Expect(crs[1].Metadata.Name).To(o.Equal("commatrix-worker"))
+		o.Expect(crs[1].Spec.Interfaces).To(o.Equal([]string{"br-ex"}))
+		o.Expect(crs[1].Spec.Ingress[0].Rules[:3]).To(o.Equal([]ingressNodeFirewallRule{
+			rule(1, ProtocolTCP, "22", "Allow"),
+			rule(2, ProtocolTCP, "30000-32767", "Allow"),
+			rule(3, ProtocolTCP, "1-32767", "Deny"),
+		}))
+
+		o.Expect(crs[2].Metadata.Name).To(o.Equal("commatrix-worker-net1"))
+		o.Expect(crs[2].Spec.Interfaces).To(o.Equal([]string{"net1"}))
+		o.Expect(crs[2].Spec.Ingress[0].Rules[:3]).To(o.Equal([]ingressNodeFirewallRule{
+			rule(1, ProtocolTCP, "22", "Allow"),
+			rule(2, ProtocolTCP, "30000-32767", "Allow"),
+			rule(3, ProtocolSCTP, "38412", "Allow"),
+		}))
+	})
+
+	g.It("does not enforce br-ex when every entry has another interface", func() {
+		scoped := ComMatrix{Ports: []ComDetails{{Direction: "Ingress", Port: 38412, Protocol: "SCTP", NodeGroup: "worker", Interface: "net1"}}}
+
+		out, err := scoped.ToIngressNodeFirewall("worker")
+		o.Expect(err).ToNot(o.HaveOccurred())
+		cr := parse(out)
+		o.Expect(cr.Spec.Interfaces).To(o.Equal([]string{"net1"}))
+	})
+
+	g.It("fails when two interfaces share a CR name", func() {
+		clashing := ComMatrix{Ports: []ComDetails{
+			{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "worker", Interface: "net_1"},
+			{Direction: "Ingress", Port: 23, Protocol: "TCP", NodeGroup: "worker", Interface: "net-1"},
+		}}
+
+		_, err := clashing.ToIngressNodeFirewall("worker")
+		o.Expect(err).To(o.MatchError(o.ContainSubstring("would share the IngressNodeFirewall CR name")))
+	})
+
+	g.It("fails when a source needs more rules than the operator holds", func() {
+		var many ComMatrix
+		for port := 9000; port < 9200; port += 2 {
+			many.Ports = append(many.Ports, ComDetails{Direction: "Ingress", Port: port, Protocol: "TCP", NodeGroup: "worker"})
+		}
+
+		_, err := many.ToIngressNodeFirewall("worker")
+		o.Expect(err).To(o.MatchError(o.ContainSubstring("at most 100")))
+	})
+
+	g.It("fails on invalid sources", func() {
+		invalid := ComMatrix{Ports: []ComDetails{{Direction: "Ingress", Port: 22, Protocol: "TCP", NodeGroup: "master", SourceCIDRs: CIDRList{"nodes"}}}}
+		_, err := invalid.ToIngressNodeFirewall("master")
+		o.Expect(err).To(o.HaveOccurred())
+	})
+})
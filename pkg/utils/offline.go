@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift-kni/commatrix/pkg/client"
+)
+
+// ErrNoCluster is returned by the offline helpers for operations that need cluster access.
+var ErrNoCluster = errors.New("operation requires cluster access")
+
+type offlineUtils struct {
+	clusterVersion string
+}
+
+// NewOffline returns a UtilsInterface for commands that run without cluster access.
+// Only WriteFile and GetClusterVersion are supported; GetClusterVersion returns the
+// given major.minor clusterVersion instead of querying the cluster.
+func NewOffline(clusterVersion string) UtilsInterface {
+	return &offlineUtils{clusterVersion: clusterVersion}
+}
+
+// NormalizeClusterVersion trims a version like "4.17.3" to its major.minor form ("4.17").
+func NormalizeClusterVersion(version string) (string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("unexpected cluster version format: %q", version)
+	}
+	return strings.Join(parts[:2], "."), nil
+}
+
+func (u *offlineUtils) WriteFile(path string, data []byte) error {
+	return os.WriteFile(path, data, 0644)
+}
+
+func (u *offlineUtils) GetClusterVersion() (string, error) {
+	if u.clusterVersion == "" {
+		return "", fmt.Errorf("cluster version is not set: %w", ErrNoCluster)
+	}
+	return u.clusterVersion, nil
+}
+
+func (u *offlineUtils) ListNodes() ([]corev1.Node, error) {
+	return nil, fmt.Errorf("failed to list nodes: %w", ErrNoCluster)
+}
+
+func (u *offlineUtils) CreateNamespace(namespace string) error {
+	return fmt.Errorf("failed creating namespace %s: %w", namespace, ErrNoCluster)
+}
+
+func (u *offlineUtils) DeleteNamespace(namespace string) error {
+	return fmt.Errorf("failed deleting namespace %s: %w", namespace, ErrNoCluster)
+}
+
+func (u *offlineUtils) CreatePodOnNode(nodeName, namespace, image string, command []string) (*corev1.Pod, error) {
+	return nil, fmt.Errorf("failed creating pod on node %s: %w", nodeName, ErrNoCluster)
+}
+
+func (u *offlineUtils) DeletePod(pod *corev1.Pod) error {
+	return fmt.Errorf("failed deleting pod %s: %w", pod.Name, ErrNoCluster)
+}
+
+func (u *offlineUtils) RunCommandOnPod(pod *corev1.Pod, command []string) ([]byte, error) {
+	return nil, fmt.Errorf("failed running command on pod %s: %w", pod.Name, ErrNoCluster)
+}
+
+func (u *offlineUtils) GetPodLogs(namespace string, pod *corev1.Pod) (string, error) {
+	return "", fmt.Errorf("failed getting logs of pod %s/%s: %w", namespace, pod.Name, ErrNoCluster)
+}
+
+func (u *offlineUtils) GetPlatformType() (configv1.PlatformType, error) {
+	return "", fmt.Errorf("failed to get platform type: %w", ErrNoCluster)
+}
+
+func (u *offlineUtils) GetControlPlaneTopology() (configv1.TopologyMode, error) {
+	return "", fmt.Errorf("failed to get control plane topology: %w", ErrNoCluster)
+}
+
+func (u *offlineUtils) WaitForPodStatus(namespace string, pod *corev1.Pod, PodPhase corev1.PodPhase) error {
+	return fmt.Errorf("failed waiting for pod %s/%s: %w", namespace, pod.Name, ErrNoCluster)
+}
+
+func (u *offlineUtils) IsIPv6Enabled() (bool, error) {
+	return false, fmt.Errorf("failed to detect IPv6: %w", ErrNoCluster)
+}
+
+func (u *offlineUtils) IsDHCPEnabled() (bool, error) {
+	return false, fmt.Errorf("failed to detect DHCP: %w", ErrNoCluster)
+}
+
+// readOnlyUtils serves the cluster queries from a read-only ClientSet, such as one loaded
+// from a must-gather, and rejects everything that needs debug pods or namespaces.
+type readOnlyUtils struct {
+	*utils
+}
+
+// NewReadOnly returns a UtilsInterface backed by the read-only ClientSet c. Operations that
+// need a live cluster, like running debug pods or detecting DHCP, return ErrNoCluster.
+func NewReadOnly(c *client.ClientSet) UtilsInterface {
+	return &readOnlyUtils{&utils{c}}
+}
+
+func (u *readOnlyUtils) CreateNamespace(namespace string) error {
+	return fmt.Errorf("failed creating namespace %s: %w", namespace, ErrNoCluster)
+}
+
+func (u *readOnlyUtils) DeleteNamespace(namespace string) error {
+	return fmt.Errorf("failed deleting namespace %s: %w", namespace, ErrNoCluster)
+}
+
+func (u *readOnlyUtils) CreatePodOnNode(nodeName, namespace, image string, command []string) (*corev1.Pod, error) {
+	return nil, fmt.Errorf("failed creating pod on node %s: %w", nodeName, ErrNoCluster)
+}
+
+func (u *readOnlyUtils) DeletePod(pod *corev1.Pod) error {
+	return fmt.Errorf("failed deleting pod %s: %w", pod.Name, ErrNoCluster)
+}
+
+func (u *readOnlyUtils) RunCommandOnPod(pod *corev1.Pod, command []string) ([]byte, error) {
+	return nil, fmt.Errorf("failed running command on pod %s: %w", pod.Name, ErrNoCluster)
+}
+
+func (u *readOnlyUtils) GetPodLogs(namespace string, pod *corev1.Pod) (string, error) {
+	return "", fmt.Errorf("failed getting logs of pod %s/%s: %w", namespace, pod.Name, ErrNoCluster)
+}
+
+func (u *readOnlyUtils) WaitForPodStatus(namespace string, pod *corev1.Pod, PodPhase corev1.PodPhase) error {
+	return fmt.Errorf("failed waiting for pod %s/%s: %w", namespace, pod.Name, ErrNoCluster)
+}
+
+func (u *readOnlyUtils) IsDHCPEnabled() (bool, error) {
+	return false, fmt.Errorf("failed to detect DHCP: %w", ErrNoCluster)
+}
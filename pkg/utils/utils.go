@@ -350,11 +350,7 @@ func (u *utils) GetClusterVersion() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	parts := strings.SplitN(cv.Status.Desired.Version, ".", 3)
-	if len(parts) < 2 {
-		return "", fmt.Errorf("unexpected cluster version format: %q", cv.Status.Desired.Version)
-	}
-	return strings.Join(parts[:2], "."), nil
+	return NormalizeClusterVersion(cv.Status.Desired.Version)
 }
 
 // IsIPv6Enabled detects whether the cluster networking includes IPv6.